@@ -12,6 +12,8 @@ import (
 
 	"muxueTools/internal/api"
 	"muxueTools/internal/config"
+	"muxueTools/internal/storage"
+	"muxueTools/internal/types"
 
 	"github.com/sirupsen/logrus"
 )
@@ -29,6 +31,7 @@ func main() {
 		configPath = flag.String("config", "", "Path to configuration file")
 		showHelp   = flag.Bool("help", false, "Show help message")
 		showVer    = flag.Bool("version", false, "Show version information")
+		rotateKEK  = flag.Bool("rotate-kek", false, "Re-wrap stored API keys under a new key-encryption key and exit")
 	)
 	flag.Parse()
 
@@ -80,6 +83,15 @@ func main() {
 	}
 
 	cfg := config.Get()
+
+	if *rotateKEK {
+		if err := runRotateKEK(cfg.Database, logger); err != nil {
+			logger.Fatalf("Failed to rotate key-encryption key: %v", err)
+		}
+		logger.Info("Key-encryption key rotated. Configure the new key before the next start.")
+		os.Exit(0)
+	}
+
 	logger.WithFields(logrus.Fields{
 		"port":     cfg.Server.Port,
 		"keys":     len(cfg.Keys),
@@ -140,6 +152,40 @@ func main() {
 	logger.Info("Server exited properly")
 }
 
+// runRotateKEK opens the database with the current key-encryption key and
+// re-wraps its data key under the one given by MXLN_NEW_KEK,
+// MXLN_NEW_KEK_FILE or MXLN_NEW_KEK_PASSPHRASE.
+// The current key must be configured explicitly: rotating away from the
+// generated key file would leave that file stale, and the next start would
+// fail to open the database with it.
+func runRotateKEK(dbCfg types.DatabaseConfig, logger *logrus.Logger) error {
+	if !dbCfg.Encryption.IsSet() {
+		return fmt.Errorf("no key-encryption key is configured; set database.encryption.key_file to the generated %s "+
+			"(preferably after moving it out of the data directory) before rotating", storage.DefaultKEKFileName)
+	}
+
+	newCfg := types.EncryptionConfig{
+		Key:        os.Getenv("MXLN_NEW_KEK"),
+		KeyFile:    os.Getenv("MXLN_NEW_KEK_FILE"),
+		Passphrase: os.Getenv("MXLN_NEW_KEK_PASSPHRASE"),
+	}
+	if !newCfg.IsSet() {
+		return fmt.Errorf("set MXLN_NEW_KEK, MXLN_NEW_KEK_FILE or MXLN_NEW_KEK_PASSPHRASE")
+	}
+	newKEK, err := storage.ResolveKEK(newCfg, "")
+	if err != nil {
+		return err
+	}
+
+	st, err := api.OpenStorage(dbCfg, logger)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+
+	return st.RotateKEK(newKEK)
+}
+
 // printBanner prints the application banner.
 func printBanner(logger *logrus.Logger) {
 	banner := `
@@ -180,9 +226,15 @@ func printUsage() {
 	fmt.Println("  MuxueTools                     Start with default config")
 	fmt.Println("  MuxueTools -config ./my.yaml   Start with custom config file")
 	fmt.Println("  MuxueTools -version            Show version information")
+	fmt.Println("  MuxueTools -rotate-kek         Rotate the key-encryption key (see MXLN_NEW_KEK*)")
 	fmt.Println()
 	fmt.Println("Environment Variables:")
 	fmt.Println("  MXLN_SERVER_PORT            Override server port")
 	fmt.Println("  MXLN_POOL_STRATEGY          Override pool strategy")
 	fmt.Println("  MXLN_LOGGING_LEVEL          Override log level")
+	fmt.Println("  MXLN_DATABASE_ENCRYPTION_KEY         Key-encryption key (32 bytes, base64 or hex)")
+	fmt.Println("  MXLN_DATABASE_ENCRYPTION_KEY_FILE    File holding the key-encryption key")
+	fmt.Println("  MXLN_DATABASE_ENCRYPTION_PASSPHRASE  Derive the key-encryption key from a passphrase")
+	fmt.Println("  MXLN_NEW_KEK, MXLN_NEW_KEK_FILE, MXLN_NEW_KEK_PASSPHRASE")
+	fmt.Println("                              New key-encryption key for -rotate-kek")
 }
@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"sort"
 	"strconv"
@@ -13,6 +12,7 @@ import (
 	"time"
 
 	"muxueTools/internal/config"
+	"muxueTools/internal/gemini"
 	"muxueTools/internal/keypool"
 	"muxueTools/internal/storage"
 	"muxueTools/internal/types"
@@ -29,6 +29,7 @@ type AdminHandler struct {
 	pool    *keypool.Pool
 	logger  *logrus.Logger
 	storage *storage.Storage
+	client  *gemini.Client // Optional: used to probe upstream model lists
 }
 
 // NewAdminHandler creates a new admin handler.
@@ -40,81 +41,40 @@ func NewAdminHandler(pool *keypool.Pool, logger *logrus.Logger, store *storage.S
 	}
 }
 
+// SetClient sets the Gemini client used for upstream probes such as model detection.
+func (h *AdminHandler) SetClient(client *gemini.Client) {
+	h.client = client
+}
+
 // ==================== Models ====================
 
 // ListAvailableModels handles GET /api/models - Get available models from Gemini API.
-// Uses a valid key from the pool to query the Gemini models API.
+// Uses a valid key from the pool, through its proxy, to query the Gemini models API.
 func (h *AdminHandler) ListAvailableModels(c *gin.Context) {
+	if h.client == nil {
+		RespondSuccess(c, []string{})
+		return
+	}
+
 	// Get a valid key from the pool
-	key, err := h.pool.GetKey()
+	key, err := h.pool.GetKey(types.KeyRequest{})
 	if err != nil {
 		h.logger.WithError(err).Warn("Failed to get key for models list")
 		RespondSuccess(c, []string{})
 		return
 	}
+	defer h.pool.ReleaseKey(key)
 
-	// Create HTTP client with timeout
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
 	defer cancel()
 
-	// Call Gemini models.list API
-	url := "https://generativelanguage.googleapis.com/v1beta/models?key=" + key.APIKey
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to create models request")
-		RespondSuccess(c, []string{})
-		return
-	}
-
-	resp, err := http.DefaultClient.Do(httpReq)
+	// Call Gemini models.list API; only models that support generateContent (chat) are returned
+	modelNames, err := h.client.ListModels(types.WithKeyProxy(ctx, h.pool.ProxyFor(key)), key.APIKey)
 	if err != nil {
 		h.logger.WithError(err).Warn("Models list request failed")
 		RespondSuccess(c, []string{})
 		return
 	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to read models response")
-		RespondSuccess(c, []string{})
-		return
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		h.logger.WithFields(logrus.Fields{
-			"status": resp.StatusCode,
-			"body":   string(body),
-		}).Warn("Gemini API returned error for models list")
-		RespondSuccess(c, []string{})
-		return
-	}
-
-	// Parse models list
-	var result struct {
-		Models []struct {
-			Name                       string   `json:"name"`
-			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
-		} `json:"models"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		h.logger.WithError(err).Error("Failed to parse models response")
-		RespondSuccess(c, []string{})
-		return
-	}
-
-	// Filter to only include models that support generateContent (chat)
-	modelNames := make([]string, 0, len(result.Models))
-	for _, m := range result.Models {
-		// Only include models that support "generateContent"
-		for _, method := range m.SupportedGenerationMethods {
-			if method == "generateContent" {
-				modelNames = append(modelNames, strings.TrimPrefix(m.Name, "models/"))
-				break
-			}
-		}
-	}
 
 	h.logger.WithFields(logrus.Fields{
 		"model_count": len(modelNames),
@@ -133,11 +93,52 @@ func (h *AdminHandler) ListKeys(c *gin.Context) {
 		Success: true,
 		Data:    stats,
 		Total:   len(stats),
+		Groups:  h.pool.GetGroupStatus(),
 	}
 
 	c.JSON(http.StatusOK, resp)
 }
 
+// ListExpiringKeys handles GET /api/keys/expiring - List keys that have
+// expired or expire within ?days (default 7), soonest first.
+func (h *AdminHandler) ListExpiringKeys(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days < 0 || days > 3650 {
+		RespondBadRequest(c, "days must be an integer between 0 and 3650")
+		return
+	}
+	RespondSuccess(c, h.pool.ExpiringKeys(time.Duration(days)*24*time.Hour))
+}
+
+// ExplainKeySelection handles GET /api/keys/explain - Dry-run key selection
+// for ?model (OpenAI or Gemini name, optionally with an "@group" suffix) and
+// ?group. Reports why each key would or would not be used and which key the
+// strategy would choose next, without taking a lease.
+func (h *AdminHandler) ExplainKeySelection(c *gin.Context) {
+	model := strings.TrimSpace(c.Query("model"))
+	group := strings.TrimSpace(c.Query("group"))
+	if i := strings.LastIndex(model, "@"); i > 0 {
+		if group == "" {
+			group = model[i+1:]
+		}
+		model = model[:i]
+	}
+	if model != "" {
+		model = gemini.MapModelName(model)
+	}
+
+	explanation, err := h.pool.ExplainSelection(types.KeyRequest{Model: model, Group: group})
+	if err != nil {
+		if appErr, ok := err.(*types.AppError); ok {
+			RespondError(c, appErr)
+			return
+		}
+		RespondInternalError(c, err.Error())
+		return
+	}
+	RespondSuccess(c, explanation)
+}
+
 // AddKey handles POST /api/keys - Add a new key.
 func (h *AdminHandler) AddKey(c *gin.Context) {
 	var req types.CreateKeyRequest
@@ -147,10 +148,27 @@ func (h *AdminHandler) AddKey(c *gin.Context) {
 	}
 
 	// Validate key format (basic check)
-	if len(req.Key) < 10 {
+	req.Key = types.NormalizeAPIKey(req.Key)
+	if len(req.Key) < 10 || (req.Validate && !types.IsValidAPIKeyFormat(req.Key)) {
 		RespondBadRequest(c, "Invalid API key format")
 		return
 	}
+	if req.Weight < 0 {
+		RespondBadRequest(c, "weight must be >= 0")
+		return
+	}
+	if msg := req.Limits.Validate(); msg != "" {
+		RespondBadRequest(c, msg)
+		return
+	}
+	if msg := types.ValidateKeySchedule(req.NotBefore, req.ExpiresAt, req.ActiveWindows); msg != "" {
+		RespondBadRequest(c, msg)
+		return
+	}
+	if msg := types.ValidateProxyURL(req.Proxy); msg != "" {
+		RespondBadRequest(c, msg)
+		return
+	}
 
 	// Create key object
 	newKey := &types.Key{
@@ -166,6 +184,18 @@ func (h *AdminHandler) AddKey(c *gin.Context) {
 		Stats:        types.KeyStats{},
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
+		Source:       types.KeySourceAPI,
+
+		AllowedModels: req.AllowedModels,
+		DeniedModels:  req.DeniedModels,
+		Weight:        req.Weight,
+		Priority:      req.Priority,
+		Limits:        req.Limits,
+		Proxy:         req.Proxy,
+
+		NotBefore:     req.NotBefore,
+		ExpiresAt:     req.ExpiresAt,
+		ActiveWindows: req.ActiveWindows,
 	}
 
 	if newKey.Tags == nil {
@@ -177,6 +207,22 @@ func (h *AdminHandler) AddKey(c *gin.Context) {
 		newKey.Provider = "google_aistudio"
 	}
 
+	if req.Validate {
+		if h.client == nil {
+			RespondError(c, types.NewServiceUnavailableError("Key validation is not available"))
+			return
+		}
+		if h.pool.HasAPIKey(newKey.APIKey) {
+			RespondBadRequest(c, "API key already exists")
+			return
+		}
+		result := h.probeKey(c.Request.Context(), newKey.APIKey, h.pool.ProxyFor(newKey))
+		if !applyKeyProbe(newKey, result, req.KeepInvalid) {
+			RespondBadRequest(c, "API key validation failed: "+result.Reason())
+			return
+		}
+	}
+
 	// Add to pool (will also persist to DB if storage is configured)
 	if err := h.pool.AddKey(newKey); err != nil {
 		if err.Error() == "key already exists" {
@@ -196,11 +242,12 @@ func (h *AdminHandler) AddKey(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, types.CreateKeyResponse{
 		Success: true,
-		Data:    *newKey,
+		Data:    *newKey.Redacted(),
 	})
 }
 
 // ValidateKey handles POST /api/keys/validate - Validate a key and get available models.
+// The key is probed the same way as bulk tests and validated imports.
 func (h *AdminHandler) ValidateKey(c *gin.Context) {
 	var req types.ValidateKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -208,99 +255,35 @@ func (h *AdminHandler) ValidateKey(c *gin.Context) {
 		return
 	}
 
-	// Create HTTP client with timeout
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
-	defer cancel()
-
-	// Call Gemini models.list API
-	url := "https://generativelanguage.googleapis.com/v1beta/models?key=" + req.Key
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to create request")
-		c.JSON(http.StatusOK, types.ValidateKeyResponse{
-			Success: true,
-			Data:    types.ValidateKeyResult{Valid: false, Error: fmt.Sprintf("Request creation failed: %v", err)},
-		})
+	if h.client == nil {
+		RespondError(c, types.NewServiceUnavailableError("Key validation is not available"))
 		return
 	}
 
-	start := time.Now()
-	resp, err := http.DefaultClient.Do(httpReq)
-	latency := time.Since(start).Milliseconds()
-
-	if err != nil {
-		h.logger.WithError(err).Warn("Key validation request failed")
-		c.JSON(http.StatusOK, types.ValidateKeyResponse{
-			Success: true,
-			Data:    types.ValidateKeyResult{Valid: false, LatencyMs: latency, Error: fmt.Sprintf("Request failed: %v", err)},
-		})
-		return
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to read response body")
-		c.JSON(http.StatusOK, types.ValidateKeyResponse{
-			Success: true,
-			Data:    types.ValidateKeyResult{Valid: false, LatencyMs: latency, Error: "Failed to read response"},
-		})
-		return
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		// Parse error response
-		var errResp struct {
-			Error struct {
-				Message string `json:"message"`
-				Code    int    `json:"code"`
-			} `json:"error"`
-		}
-		errMsg := "Invalid API Key"
-		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error.Message != "" {
-			errMsg = errResp.Error.Message
-		}
-
-		c.JSON(http.StatusOK, types.ValidateKeyResponse{
-			Success: true,
-			Data:    types.ValidateKeyResult{Valid: false, LatencyMs: latency, Error: errMsg},
-		})
+	if msg := types.ValidateProxyURL(req.Proxy); msg != "" {
+		RespondBadRequest(c, msg)
 		return
 	}
 
-	// Parse models list
-	var result struct {
-		Models []struct {
-			Name string `json:"name"`
-		} `json:"models"`
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		h.logger.WithError(err).Error("Failed to parse models response")
-		c.JSON(http.StatusOK, types.ValidateKeyResponse{
-			Success: true,
-			Data:    types.ValidateKeyResult{Valid: false, LatencyMs: latency, Error: "Failed to parse response"},
-		})
-		return
-	}
-
-	// Extract model names (remove "models/" prefix)
-	modelNames := make([]string, 0, len(result.Models))
-	for _, m := range result.Models {
-		modelNames = append(modelNames, strings.TrimPrefix(m.Name, "models/"))
-	}
-
+	result := h.probeKey(c.Request.Context(), types.NormalizeAPIKey(req.Key), req.Proxy)
 	h.logger.WithFields(logrus.Fields{
-		"latency_ms":  latency,
-		"model_count": len(modelNames),
-	}).Info("Key validated successfully")
+		"health":      result.Health,
+		"latency_ms":  result.LatencyMs,
+		"model_count": len(result.Models),
+	}).Info("Key validated")
 
+	models := result.Models
+	if models == nil {
+		models = []string{}
+	}
 	c.JSON(http.StatusOK, types.ValidateKeyResponse{
 		Success: true,
 		Data: types.ValidateKeyResult{
-			Valid:     true,
-			LatencyMs: latency,
-			Models:    modelNames,
+			Valid:     result.Health == types.KeyHealthValid,
+			Health:    result.Health,
+			LatencyMs: result.LatencyMs,
+			Models:    models,
+			Error:     result.Error,
 		},
 	})
 }
@@ -334,107 +317,489 @@ func (h *AdminHandler) DeleteKey(c *gin.Context) {
 	})
 }
 
-// TestKey handles POST /api/keys/:id/test - Test key validity.
-func (h *AdminHandler) TestKey(c *gin.Context) {
+// UpdateKey handles PATCH /api/keys/:id - Update a key's settings in place,
+// keeping its ID and statistics.
+func (h *AdminHandler) UpdateKey(c *gin.Context) {
 	keyID := c.Param("id")
 	if keyID == "" {
 		RespondBadRequest(c, "Key ID is required")
 		return
 	}
 
-	// Find key in pool
-	stats := h.pool.GetStats()
-	var targetKey *types.Key
-	for _, key := range stats {
-		if key.ID == keyID {
-			targetKey = &key
-			break
+	var req types.UpdateKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondBadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if req.Name != nil && len(*req.Name) > 100 {
+		RespondBadRequest(c, "name must be at most 100 characters")
+		return
+	}
+	if req.Weight != nil && *req.Weight < 0 {
+		RespondBadRequest(c, "weight must be >= 0")
+		return
+	}
+	if req.Limits != nil {
+		if msg := req.Limits.Validate(); msg != "" {
+			RespondBadRequest(c, msg)
+			return
+		}
+	}
+	if req.Proxy != nil {
+		if msg := types.ValidateProxyURL(*req.Proxy); msg != "" {
+			RespondBadRequest(c, msg)
+			return
+		}
+		// Responses mask the proxy password; an unchanged masked value sent
+		// back by an edit form keeps the stored one.
+		if current, err := h.pool.GetKeyByID(keyID); err == nil &&
+			*req.Proxy != current.Proxy && *req.Proxy == types.RedactProxyURL(current.Proxy) {
+			req.Proxy = &current.Proxy
+		}
+	}
+	if req.Tags != nil && *req.Tags == nil {
+		*req.Tags = []string{}
+	}
+	if req.NotBefore.Set || req.ExpiresAt.Set || req.ActiveWindows != nil {
+		current, err := h.pool.GetKeyByID(keyID)
+		if err != nil {
+			RespondNotFound(c, "Key")
+			return
+		}
+		notBefore, expiresAt, windows := current.NotBefore, current.ExpiresAt, []types.ActiveWindow(nil)
+		if req.NotBefore.Set {
+			notBefore = req.NotBefore.Time
+		}
+		if req.ExpiresAt.Set {
+			expiresAt = req.ExpiresAt.Time
+		}
+		if req.ActiveWindows != nil {
+			windows = *req.ActiveWindows
+		}
+		if msg := types.ValidateKeySchedule(notBefore, expiresAt, windows); msg != "" {
+			RespondBadRequest(c, msg)
+			return
 		}
 	}
 
-	if targetKey == nil {
-		RespondNotFound(c, "Key")
+	key, err := h.pool.UpdateKey(keyID, req)
+	if err != nil {
+		if err == types.ErrKeyNotFound {
+			RespondNotFound(c, "Key")
+			return
+		}
+		h.logger.WithError(err).Error("Failed to update key")
+		RespondInternalError(c, "Failed to update key")
 		return
 	}
 
-	// Note: Actual key testing would require making a test request to Gemini API
-	// For now, return a mock response based on key status
-	result := types.TestKeyResult{
-		Valid:     targetKey.Status == types.KeyStatusActive && targetKey.Enabled,
-		LatencyMs: 150, // Mock latency
-		Models:    []string{"gemini-1.5-pro-latest", "gemini-1.5-flash-latest"},
+	h.logger.WithFields(logrus.Fields{
+		"key_id":  keyID,
+		"enabled": key.Enabled,
+		"status":  key.Status,
+	}).Info("Key updated successfully")
+
+	RespondSuccess(c, key.Redacted())
+}
+
+// BulkUpdateKeys handles POST /api/keys/bulk - Enable, disable or delete the
+// keys selected by ID list and/or tag.
+func (h *AdminHandler) BulkUpdateKeys(c *gin.Context) {
+	var req types.BulkKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondBadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	switch req.Action {
+	case types.BulkKeyActionEnable, types.BulkKeyActionDisable, types.BulkKeyActionDelete:
+	default:
+		RespondBadRequest(c, "Invalid action: "+req.Action)
+		return
+	}
+	if len(req.IDs) == 0 && req.Tag == "" {
+		RespondBadRequest(c, "Either ids or tag is required")
+		return
+	}
+
+	result := types.BulkKeyResult{
+		Action: req.Action,
+		Errors: []string{},
 	}
 
-	if !result.Valid {
-		if targetKey.Status == types.KeyStatusRateLimited {
-			result.Error = "Key is currently rate limited"
-		} else if targetKey.Status == types.KeyStatusDisabled || !targetKey.Enabled {
-			result.Error = "Key is disabled"
+	for _, id := range h.selectBulkKeys(req) {
+		result.Matched++
+
+		var err error
+		switch req.Action {
+		case types.BulkKeyActionDelete:
+			err = h.pool.RemoveKey(id)
+		default:
+			enabled := req.Action == types.BulkKeyActionEnable
+			_, err = h.pool.UpdateKey(id, types.UpdateKeyRequest{Enabled: &enabled})
 		}
+
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", id, err))
+			continue
+		}
+		result.Affected++
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"action":   result.Action,
+		"matched":  result.Matched,
+		"affected": result.Affected,
+	}).Info("Bulk key operation completed")
+
+	RespondSuccess(c, result)
+}
+
+// selectBulkKeys returns the IDs listed in req plus the IDs of keys carrying
+// req.Tag, without duplicates. Listed IDs are kept even if unknown so the
+// result reports them as errors.
+func (h *AdminHandler) selectBulkKeys(req types.BulkKeyRequest) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	add := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, id := range req.IDs {
+		add(id)
+	}
+	if req.Tag != "" {
+		for _, key := range h.pool.GetStats() {
+			for _, tag := range key.Tags {
+				if tag == req.Tag {
+					add(key.ID)
+					break
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// TestKey handles POST /api/keys/:id/test - Probe one key and record the
+// outcome, like a bulk test of that key.
+func (h *AdminHandler) TestKey(c *gin.Context) {
+	keyID := c.Param("id")
+	if keyID == "" {
+		RespondBadRequest(c, "Key ID is required")
+		return
+	}
+
+	if h.client == nil {
+		RespondError(c, types.NewServiceUnavailableError("Key testing is not available"))
+		return
+	}
+
+	key, err := h.pool.GetKeyByID(keyID)
+	if err != nil {
+		RespondNotFound(c, "Key")
+		return
 	}
 
+	item := h.testKey(c.Request.Context(), h.keyTestTarget(key), false)
+	h.logger.WithFields(logrus.Fields{
+		"key_id":     keyID,
+		"health":     item.Health,
+		"latency_ms": item.LatencyMs,
+	}).Info("Key tested")
+
 	c.JSON(http.StatusOK, types.TestKeyResponse{
 		Success: true,
-		Data:    result,
+		Data: types.TestKeyResult{
+			Valid:     item.Health == types.KeyHealthValid,
+			Health:    item.Health,
+			LatencyMs: item.LatencyMs,
+			Models:    item.Models,
+			Error:     item.Error,
+		},
 	})
 }
 
+// UpdateKeyModels handles PUT /api/keys/:id/models - Set allowed/denied model patterns.
+func (h *AdminHandler) UpdateKeyModels(c *gin.Context) {
+	keyID := c.Param("id")
+	if keyID == "" {
+		RespondBadRequest(c, "Key ID is required")
+		return
+	}
+
+	var req types.UpdateKeyModelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondBadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	var allowed, denied []string
+	if req.AllowedModels != nil {
+		allowed = normalizeModelPatterns(*req.AllowedModels)
+	}
+	if req.DeniedModels != nil {
+		denied = normalizeModelPatterns(*req.DeniedModels)
+	}
+
+	key, err := h.pool.SetModelPatterns(keyID, allowed, denied)
+	if err != nil {
+		if err == types.ErrKeyNotFound {
+			RespondNotFound(c, "Key")
+			return
+		}
+		h.logger.WithError(err).Error("Failed to update key models")
+		RespondInternalError(c, "Failed to update key models")
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"key_id":  keyID,
+		"allowed": key.AllowedModels,
+		"denied":  key.DeniedModels,
+	}).Info("Key model patterns updated")
+
+	RespondSuccess(c, key.Redacted())
+}
+
+// DetectKeyModels handles POST /api/keys/:id/models/detect - Probe models.list
+// with the key and restrict routing to the models it reports.
+func (h *AdminHandler) DetectKeyModels(c *gin.Context) {
+	keyID := c.Param("id")
+	if keyID == "" {
+		RespondBadRequest(c, "Key ID is required")
+		return
+	}
+
+	if h.client == nil {
+		RespondError(c, types.NewServiceUnavailableError("Model detection is not available"))
+		return
+	}
+
+	key, err := h.pool.GetKeyByID(keyID)
+	if err != nil {
+		RespondNotFound(c, "Key")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	models, err := h.client.ListModels(types.WithKeyProxy(ctx, h.pool.ProxyFor(key)), key.APIKey)
+	if err != nil {
+		h.logger.WithError(err).WithField("key_id", keyID).Warn("Model detection failed")
+		RespondError(c, types.AsAppError(err))
+		return
+	}
+
+	updated, err := h.pool.SetDetectedModels(keyID, models)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to save detected models")
+		RespondInternalError(c, "Failed to save detected models")
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"key_id":      keyID,
+		"model_count": len(models),
+	}).Info("Key models detected")
+
+	RespondSuccess(c, updated.Redacted())
+}
+
+// normalizeModelPatterns trims patterns, strips the "models/" prefix and drops empty entries.
+func normalizeModelPatterns(patterns []string) []string {
+	result := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = types.NormalizeModelName(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
 // ImportKeys handles POST /api/keys/import - Batch import keys.
+// The body may be the JSON request, a key bundle, a JSON array, CSV or plain
+// text (see parseImportRequest). With validate set, keys are format-checked
+// and new keys are probed in parallel before import.
 func (h *AdminHandler) ImportKeys(c *gin.Context) {
-	var req types.ImportKeysRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	req, err := parseImportRequest(c)
+	if err != nil {
 		RespondBadRequest(c, "Invalid request body: "+err.Error())
 		return
 	}
+	if req.Bundle != nil {
+		items, err := storage.OpenKeyBundle(req.Bundle, req.Passphrase)
+		if err != nil {
+			RespondBadRequest(c, "Invalid key bundle: "+err.Error())
+			return
+		}
+		req.Keys = append(req.Keys, items...)
+	}
+	if len(req.Keys) == 0 {
+		RespondBadRequest(c, "No keys to import")
+		return
+	}
+	switch req.OnDuplicate {
+	case "":
+		req.OnDuplicate = types.ImportDuplicateSkip
+	case types.ImportDuplicateSkip, types.ImportDuplicateMerge, types.ImportDuplicateOverwrite:
+	default:
+		RespondBadRequest(c, "Invalid on_duplicate: "+req.OnDuplicate)
+		return
+	}
+	if req.Validate && h.client == nil {
+		RespondError(c, types.NewServiceUnavailableError("Key validation is not available"))
+		return
+	}
 
 	result := types.ImportKeysResult{
 		Imported: 0,
 		Skipped:  0,
 		Errors:   []string{},
+		Results:  make([]types.ImportKeyLineResult, len(req.Keys)),
 	}
 
+	// Normalize and check every line; the remaining keys are candidates
+	newKeys := make([]*types.Key, len(req.Keys))
+	existingIDs := make(map[int]string)
+	var toProbe []int
+	seen := make(map[string]bool)
 	for i, item := range req.Keys {
+		apiKey := types.NormalizeAPIKey(item.Key)
+		line := &result.Results[i]
+		line.Line = i + 1
+		line.MaskedKey = types.MaskAPIKey(apiKey)
+
 		// Basic validation
-		if len(item.Key) < 10 {
+		if len(apiKey) < 10 {
+			line.Status = types.ImportLineInvalidFormat
+			line.Error = "Key too short"
 			result.Errors = append(result.Errors, fmt.Sprintf("Item %d: Key too short", i+1))
 			continue
 		}
+		if req.Validate && !types.IsValidAPIKeyFormat(apiKey) {
+			line.Status = types.ImportLineInvalidFormat
+			line.Error = "Not an AI Studio API key"
+			result.Errors = append(result.Errors, fmt.Sprintf("Item %d: Invalid key format", i+1))
+			continue
+		}
+		// Settings are checked as for a key created or updated by hand
+		item.AllowedModels = normalizeOptionalPatterns(item.AllowedModels)
+		item.DeniedModels = normalizeOptionalPatterns(item.DeniedModels)
+		req.Keys[i] = item
+		msg := types.ValidateKeySchedule(item.NotBefore, item.ExpiresAt, item.ActiveWindows)
+		if msg == "" {
+			msg = types.ValidateProxyURL(item.Proxy)
+		}
+		if msg == "" && item.Weight < 0 {
+			msg = "weight must be >= 0"
+		}
+		if msg == "" && item.Limits != nil {
+			msg = item.Limits.Validate()
+		}
+		if msg != "" {
+			line.Status = types.ImportLineError
+			line.Error = msg
+			result.Errors = append(result.Errors, fmt.Sprintf("Item %d: %s", i+1, msg))
+			continue
+		}
 
-		// Create key object
-		newKey := &types.Key{
-			ID:        uuid.New().String(),
-			APIKey:    item.Key,
-			MaskedKey: types.MaskAPIKey(item.Key),
-			Name:      item.Name,
-			Status:    types.KeyStatusActive,
-			Enabled:   true,
-			Tags:      item.Tags,
-			Provider:  "google_aistudio", // Default provider, could be inferred or passed
-			Stats:     types.KeyStats{},
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+		if seen[apiKey] {
+			line.Status = types.ImportLineDuplicate
+			result.Skipped++
+			continue
 		}
+		seen[apiKey] = true
 
-		if newKey.Tags == nil {
-			newKey.Tags = []string{}
+		if existing, err := h.pool.GetKeyByAPIKey(apiKey); err == nil {
+			if req.OnDuplicate == types.ImportDuplicateSkip {
+				line.Status = types.ImportLineDuplicate
+				result.Skipped++
+			} else {
+				existingIDs[i] = existing.ID
+			}
+			continue
+		}
+
+		if req.Validate {
+			toProbe = append(toProbe, i)
+		}
+		newKeys[i] = newKeyFromImport(item, apiKey)
+	}
+
+	if req.Validate {
+		keys := make([]*types.Key, len(toProbe))
+		for j, i := range toProbe {
+			keys[j] = newKeys[i]
+		}
+		probes := h.probeKeys(c.Request.Context(), keys, req.Concurrency)
+		for j, i := range toProbe {
+			result.Results[i].Health = probes[j].Health
+			if !applyKeyProbe(newKeys[i], probes[j], req.KeepInvalid) {
+				result.Results[i].Status = types.ImportLineRejected
+				result.Results[i].Error = probes[j].Reason()
+				result.Rejected++
+				newKeys[i] = nil
+			}
+		}
+	}
+
+	for i := range req.Keys {
+		line := &result.Results[i]
+
+		if id, ok := existingIDs[i]; ok {
+			if err := h.updateImportedKey(id, req.Keys[i], req.OnDuplicate); err != nil {
+				line.Status = types.ImportLineError
+				line.Error = err.Error()
+				result.Errors = append(result.Errors, fmt.Sprintf("Item %d: %v", i+1, err))
+				continue
+			}
+			line.Status = types.ImportLineUpdated
+			line.KeyID = id
+			result.Updated++
+			continue
+		}
+
+		newKey := newKeys[i]
+		if newKey == nil {
+			continue
 		}
 
 		// Add to pool
 		if err := h.pool.AddKey(newKey); err != nil {
 			if err.Error() == "key already exists" {
+				line.Status = types.ImportLineDuplicate
 				result.Skipped++
 			} else {
+				line.Status = types.ImportLineError
+				line.Error = err.Error()
 				result.Errors = append(result.Errors, fmt.Sprintf("Item %d: %v", i+1, err))
 			}
 			continue
 		}
 
-		result.Imported++
+		line.KeyID = newKey.ID
+		if newKey.Enabled {
+			line.Status = types.ImportLineImported
+			result.Imported++
+		} else {
+			line.Status = types.ImportLineDisabled
+			line.Error = newKey.DisabledReason
+			result.Disabled++
+		}
 	}
 
 	h.logger.WithFields(logrus.Fields{
 		"imported": result.Imported,
 		"skipped":  result.Skipped,
+		"updated":  result.Updated,
+		"disabled": result.Disabled,
+		"rejected": result.Rejected,
 		"errors":   len(result.Errors),
 	}).Info("Keys import completed")
 
@@ -513,13 +878,15 @@ func (h *AdminHandler) GetKeyStats(c *gin.Context) {
 
 	var keyStats []types.KeyStatItem
 	for _, key := range stats {
+		windows := h.pool.GetWindowStats(key.ID)
 		keyStats = append(keyStats, types.KeyStatItem{
 			KeyID:        key.ID,
 			KeyName:      key.Name,
 			RequestCount: key.Stats.RequestCount,
 			SuccessRate:  key.Stats.SuccessRate(),
 			TokenUsage:   key.Stats.TotalTokens(),
-			AvgLatencyMs: 0, // Would be calculated from request logs
+			AvgLatencyMs: windows[types.StatsWindow24h].AvgLatencyMs,
+			Windows:      windows,
 		})
 	}
 
@@ -692,18 +1059,8 @@ func (h *AdminHandler) GetConfig(c *gin.Context) {
 		updateSource = "mxln"
 	}
 
-	// Get request timeout from storage or config
-	requestTimeout := cfg.Advanced.RequestTimeout
-	if h.storage != nil {
-		if storedTimeout, _ := h.storage.GetConfig("advanced.request_timeout"); storedTimeout != "" {
-			if parsed, err := strconv.Atoi(storedTimeout); err == nil && parsed > 0 {
-				requestTimeout = parsed
-			}
-		}
-	}
-	if requestTimeout == 0 {
-		requestTimeout = 120 // default
-	}
+	// Get upstream timeouts from storage or config
+	timeouts := resolveTimeouts(cfg, h.storage)
 
 	// Get stored port from storage (for user configuration, requires restart)
 	storedPort := cfg.Server.Port
@@ -719,6 +1076,7 @@ func (h *AdminHandler) GetConfig(c *gin.Context) {
 	poolStrategy := string(cfg.Pool.Strategy)
 	poolCooldown := cfg.Pool.CooldownSeconds
 	poolMaxRetries := cfg.Pool.MaxRetries
+	poolStatsWindow := string(h.pool.GetStatsWindow())
 	if h.storage != nil {
 		if storedStrategy, _ := h.storage.GetConfig("pool.strategy"); storedStrategy != "" {
 			poolStrategy = storedStrategy
@@ -733,6 +1091,9 @@ func (h *AdminHandler) GetConfig(c *gin.Context) {
 				poolMaxRetries = parsed
 			}
 		}
+		if storedWindow, _ := h.storage.GetConfig("pool.stats_window"); storedWindow != "" {
+			poolStatsWindow = storedWindow
+		}
 	}
 
 	// Get logging level from storage (override config.yaml values)
@@ -752,6 +1113,9 @@ func (h *AdminHandler) GetConfig(c *gin.Context) {
 		"top_k":             nil,
 		"thinking_level":    nil,
 		"media_resolution":  nil,
+		"safety_preset":     nil,
+		"safety_thresholds": nil,
+		"model_safety":      nil,
 	}
 	if h.storage != nil {
 		if sp, _ := h.storage.GetConfig("model_settings.system_prompt"); sp != "" {
@@ -783,6 +1147,21 @@ func (h *AdminHandler) GetConfig(c *gin.Context) {
 		if resolution, _ := h.storage.GetConfig("model_settings.media_resolution"); resolution != "" {
 			modelSettings["media_resolution"] = resolution
 		}
+		if preset, _ := h.storage.GetConfig("model_settings.safety_preset"); preset != "" {
+			modelSettings["safety_preset"] = preset
+		}
+		if thresholds, _ := h.storage.GetConfig("model_settings.safety_thresholds"); thresholds != "" {
+			var parsed map[string]string
+			if err := json.Unmarshal([]byte(thresholds), &parsed); err == nil {
+				modelSettings["safety_thresholds"] = parsed
+			}
+		}
+		if modelSafety, _ := h.storage.GetConfig("model_settings.model_safety"); modelSafety != "" {
+			var parsed map[string]types.SafetyOverride
+			if err := json.Unmarshal([]byte(modelSafety), &parsed); err == nil {
+				modelSettings["model_safety"] = parsed
+			}
+		}
 	}
 
 	// Return sanitized config (without sensitive data)
@@ -796,6 +1175,7 @@ func (h *AdminHandler) GetConfig(c *gin.Context) {
 			"strategy":         poolStrategy,
 			"cooldown_seconds": poolCooldown,
 			"max_retries":      poolMaxRetries,
+			"stats_window":     poolStatsWindow,
 		},
 		"logging": gin.H{
 			"level": loggingLevel,
@@ -811,7 +1191,10 @@ func (h *AdminHandler) GetConfig(c *gin.Context) {
 			"proxy_key":            proxyKey,
 		},
 		"advanced": gin.H{
-			"request_timeout": requestTimeout,
+			"request_timeout":           timeouts.Request,
+			"stream_first_byte_timeout": timeouts.StreamFirstByte,
+			"stream_idle_timeout":       timeouts.StreamIdle,
+			"parameter_mode":            resolveParameterMode(cfg, h.storage),
 		},
 		"model_settings": modelSettings,
 	}
@@ -840,6 +1223,7 @@ type PoolConfigUpdate struct {
 	Strategy        *string `json:"strategy,omitempty"`
 	CooldownSeconds *int    `json:"cooldown_seconds,omitempty"`
 	MaxRetries      *int    `json:"max_retries,omitempty"`
+	StatsWindow     *string `json:"stats_window,omitempty"`
 }
 
 // LoggingConfigUpdate represents logging configuration updates.
@@ -862,7 +1246,10 @@ type SecurityConfigUpdate struct {
 
 // AdvancedConfigUpdate represents advanced configuration updates.
 type AdvancedConfigUpdate struct {
-	RequestTimeout *int `json:"request_timeout,omitempty"`
+	RequestTimeout         *int    `json:"request_timeout,omitempty"`
+	StreamFirstByteTimeout *int    `json:"stream_first_byte_timeout,omitempty"`
+	StreamIdleTimeout      *int    `json:"stream_idle_timeout,omitempty"`
+	ParameterMode          *string `json:"parameter_mode,omitempty"`
 }
 
 // ModelSettingsConfigUpdate represents model settings configuration updates.
@@ -874,6 +1261,11 @@ type ModelSettingsConfigUpdate struct {
 	TopK            *int     `json:"top_k,omitempty"`
 	ThinkingLevel   *string  `json:"thinking_level,omitempty"`
 	MediaResolution *string  `json:"media_resolution,omitempty"`
+
+	// Safety settings; an empty map clears the stored thresholds or overrides
+	SafetyPreset     *string                         `json:"safety_preset,omitempty"`
+	SafetyThresholds map[string]string               `json:"safety_thresholds,omitempty"`
+	ModelSafety      map[string]types.SafetyOverride `json:"model_safety,omitempty"`
 }
 
 // UpdateConfig handles PUT /api/config - Update configuration.
@@ -905,7 +1297,7 @@ func (h *AdminHandler) UpdateConfig(c *gin.Context) {
 		// Update Strategy
 		if req.Pool.Strategy != nil {
 			strategyName := *req.Pool.Strategy
-			validStrategies := map[string]bool{"round_robin": true, "random": true, "least_used": true, "weighted": true}
+			validStrategies := map[string]bool{"round_robin": true, "random": true, "least_used": true, "weighted": true, "latency_aware": true}
 			if !validStrategies[strategyName] {
 				RespondBadRequest(c, "Invalid strategy: "+strategyName)
 				return
@@ -922,6 +1314,8 @@ func (h *AdminHandler) UpdateConfig(c *gin.Context) {
 				strategy = keypool.NewLeastUsedStrategy()
 			case "weighted":
 				strategy = keypool.NewWeightedStrategy()
+			case "latency_aware":
+				strategy = keypool.NewLatencyAwareStrategy()
 			}
 			h.pool.SetStrategy(strategy)
 
@@ -963,6 +1357,22 @@ func (h *AdminHandler) UpdateConfig(c *gin.Context) {
 			}
 			updated["pool.max_retries"] = maxRetries
 		}
+
+		// Update Stats Window
+		if req.Pool.StatsWindow != nil {
+			window := types.StatsWindow(*req.Pool.StatsWindow)
+			if !window.IsValid() {
+				RespondBadRequest(c, "Invalid stats_window: "+string(window))
+				return
+			}
+
+			h.pool.SetStatsWindow(window)
+
+			if h.storage != nil {
+				_ = h.storage.SetConfig("pool.stats_window", string(window))
+			}
+			updated["pool.stats_window"] = string(window)
+		}
 	}
 
 	// Process logging configuration updates
@@ -1058,6 +1468,49 @@ func (h *AdminHandler) UpdateConfig(c *gin.Context) {
 			}
 			updated["advanced.request_timeout"] = timeout
 		}
+
+		if req.Advanced.StreamFirstByteTimeout != nil {
+			timeout := *req.Advanced.StreamFirstByteTimeout
+			if timeout < 0 || timeout > 3600 {
+				RespondBadRequest(c, "Stream first-byte timeout must be between 0 and 3600 seconds")
+				return
+			}
+
+			if h.storage != nil {
+				_ = h.storage.SetConfig("advanced.stream_first_byte_timeout", strconv.Itoa(timeout))
+			}
+			updated["advanced.stream_first_byte_timeout"] = timeout
+		}
+
+		if req.Advanced.StreamIdleTimeout != nil {
+			timeout := *req.Advanced.StreamIdleTimeout
+			if timeout < 0 || timeout > 3600 {
+				RespondBadRequest(c, "Stream idle timeout must be between 0 and 3600 seconds")
+				return
+			}
+
+			if h.storage != nil {
+				_ = h.storage.SetConfig("advanced.stream_idle_timeout", strconv.Itoa(timeout))
+			}
+			updated["advanced.stream_idle_timeout"] = timeout
+		}
+
+		if req.Advanced.ParameterMode != nil {
+			mode := *req.Advanced.ParameterMode
+			if !isParameterMode(mode) {
+				RespondBadRequest(c, "Parameter mode must be 'clamp' or 'strict'")
+				return
+			}
+
+			if h.storage != nil {
+				_ = h.storage.SetConfig("advanced.parameter_mode", mode)
+			}
+			updated["advanced.parameter_mode"] = mode
+			applyParameterMode(h.client, mode)
+		}
+
+		// Apply hot update to the Gemini client
+		h.applyTimeouts(req.Advanced)
 	}
 
 	// Process model settings configuration
@@ -1147,6 +1600,46 @@ func (h *AdminHandler) UpdateConfig(c *gin.Context) {
 			}
 			updated["model_settings.media_resolution"] = resolution
 		}
+
+		if req.ModelSettings.SafetyPreset != nil {
+			preset := *req.ModelSettings.SafetyPreset
+			if err := gemini.ValidateSafetyPreset(preset); err != nil {
+				RespondBadRequest(c, "Invalid safety_preset: "+err.Error())
+				return
+			}
+			if h.storage != nil {
+				_ = h.storage.SetConfig("model_settings.safety_preset", preset)
+			}
+			updated["model_settings.safety_preset"] = preset
+		}
+
+		if req.ModelSettings.SafetyThresholds != nil {
+			thresholds := req.ModelSettings.SafetyThresholds
+			if err := gemini.ValidateSafetyThresholds(thresholds); err != nil {
+				RespondBadRequest(c, "Invalid safety_thresholds: "+err.Error())
+				return
+			}
+			if h.storage != nil {
+				data, _ := json.Marshal(thresholds)
+				_ = h.storage.SetConfig("model_settings.safety_thresholds", string(data))
+			}
+			updated["model_settings.safety_thresholds"] = thresholds
+		}
+
+		if req.ModelSettings.ModelSafety != nil {
+			modelSafety := req.ModelSettings.ModelSafety
+			for model, override := range modelSafety {
+				if err := gemini.ValidateSafetyOverride(&override); err != nil {
+					RespondBadRequest(c, "Invalid model_safety for "+model+": "+err.Error())
+					return
+				}
+			}
+			if h.storage != nil {
+				data, _ := json.Marshal(modelSafety)
+				_ = h.storage.SetConfig("model_settings.model_safety", string(data))
+			}
+			updated["model_settings.model_safety"] = modelSafety
+		}
 	}
 
 	h.logger.WithFields(logrus.Fields{
@@ -1247,6 +1740,15 @@ func (h *AdminHandler) CheckUpdate(c *gin.Context) {
 	})
 }
 
+// updateHTTPClient returns the HTTP client for update checks, which go
+// through the global upstream proxy.
+func (h *AdminHandler) updateHTTPClient() *http.Client {
+	if h.client != nil {
+		return h.client.HTTPClient("")
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
 // fetchMxlnUpdate fetches update info from mxln server.
 func (h *AdminHandler) fetchMxlnUpdate(currentVersion string) (UpdateCheckData, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -1257,8 +1759,7 @@ func (h *AdminHandler) fetchMxlnUpdate(currentVersion string) (UpdateCheckData,
 		return UpdateCheckData{}, err
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := h.updateHTTPClient().Do(req)
 	if err != nil {
 		return UpdateCheckData{}, err
 	}
@@ -1302,8 +1803,7 @@ func (h *AdminHandler) fetchGitHubUpdate(currentVersion string) (UpdateCheckData
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
 	req.Header.Set("User-Agent", "MuxueTools-Updater")
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := h.updateHTTPClient().Do(req)
 	if err != nil {
 		return UpdateCheckData{}, err
 	}
@@ -0,0 +1,484 @@
+﻿package api
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"muxueTools/internal/storage"
+	"muxueTools/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ==================== Key Backup ====================
+
+const (
+	// BundlePassphraseHeader carries the passphrase when a raw bundle is imported.
+	BundlePassphraseHeader = "X-Bundle-Passphrase"
+	// maxImportBodySize bounds the body accepted by ImportKeys.
+	maxImportBodySize = 10 << 20
+)
+
+// ExportKeyBundle handles POST /api/keys/export - Export keys with their
+// settings and stats as a passphrase-encrypted bundle. Because the bundle holds
+// the real keys, the request must carry the proxy key as a Bearer token, and
+// a proxy key must be configured: DefaultProxyKey is public and never accepted.
+func (h *AdminHandler) ExportKeyBundle(c *gin.Context) {
+	proxyKey := h.configuredProxyKey()
+	if proxyKey == "" {
+		RespondError(c, types.NewPermissionError("Exporting keys requires a proxy key to be configured in the security settings"))
+		return
+	}
+	if !hasBearerToken(c, proxyKey) {
+		RespondError(c, types.NewAuthenticationError("Exporting keys requires the proxy key as a Bearer token"))
+		return
+	}
+
+	var req types.ExportKeysRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondBadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+	if len(req.Passphrase) < storage.MinBundlePassphraseLength {
+		RespondBadRequest(c, fmt.Sprintf("passphrase must be at least %d characters", storage.MinBundlePassphraseLength))
+		return
+	}
+
+	var ids []string
+	if len(req.IDs) == 0 && req.Tag == "" {
+		for _, key := range h.pool.GetStats() {
+			ids = append(ids, key.ID)
+		}
+	} else {
+		ids = h.selectBulkKeys(types.BulkKeyRequest{IDs: req.IDs, Tag: req.Tag})
+	}
+
+	items := make([]types.ImportKeyItem, 0, len(ids))
+	for _, id := range ids {
+		key, err := h.pool.GetKeyByID(id)
+		if err != nil {
+			continue
+		}
+		items = append(items, importItemFromKey(key))
+	}
+
+	bundle, err := storage.SealKeyBundle(items, req.Passphrase)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create key bundle")
+		RespondInternalError(c, "Failed to create key bundle")
+		return
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"keys":      len(items),
+		"client_ip": c.ClientIP(),
+	}).Info("Key bundle exported")
+
+	filename := "muxuetools-keys-" + time.Now().Format("20060102") + ".json"
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.JSON(http.StatusOK, bundle)
+}
+
+// configuredProxyKey returns the proxy key set in the security settings, or
+// "" when none is set and requests fall back to DefaultProxyKey.
+func (h *AdminHandler) configuredProxyKey() string {
+	if h.storage == nil {
+		return ""
+	}
+	proxyKey, _ := h.storage.GetConfig("security.proxy_key")
+	return proxyKey
+}
+
+// hasBearerToken reports whether the request carries token as a Bearer token.
+func hasBearerToken(c *gin.Context, token string) bool {
+	got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || got == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// importItemFromKey converts a pool key into a bundle entry.
+func importItemFromKey(key *types.Key) types.ImportKeyItem {
+	enabled := key.Enabled
+	limits := key.Limits
+	stats := key.Stats
+	createdAt := key.CreatedAt
+	return types.ImportKeyItem{
+		Key:           key.APIKey,
+		Name:          key.Name,
+		Tags:          key.Tags,
+		Enabled:       &enabled,
+		Provider:      key.Provider,
+		DefaultModel:  key.DefaultModel,
+		Weight:        key.Weight,
+		Priority:      key.Priority,
+		Limits:        &limits,
+		AllowedModels: key.AllowedModels,
+		DeniedModels:  key.DeniedModels,
+		Stats:         &stats,
+		CreatedAt:     &createdAt,
+		NotBefore:     key.NotBefore,
+		ExpiresAt:     key.ExpiresAt,
+		ActiveWindows: key.ActiveWindows,
+		Proxy:         key.Proxy,
+	}
+}
+
+// newKeyFromImport creates a pool key from an import entry.
+func newKeyFromImport(item types.ImportKeyItem, apiKey string) *types.Key {
+	now := time.Now()
+	newKey := &types.Key{
+		ID:           uuid.New().String(),
+		APIKey:       apiKey,
+		MaskedKey:    types.MaskAPIKey(apiKey),
+		Name:         item.Name,
+		Status:       types.KeyStatusActive,
+		Enabled:      true,
+		Tags:         item.Tags,
+		Provider:     item.Provider,
+		DefaultModel: item.DefaultModel,
+		Weight:       item.Weight,
+		Priority:     item.Priority,
+		Stats:        types.KeyStats{},
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		Source:       types.KeySourceAPI,
+
+		AllowedModels: item.AllowedModels,
+		DeniedModels:  item.DeniedModels,
+
+		NotBefore:     item.NotBefore,
+		ExpiresAt:     item.ExpiresAt,
+		ActiveWindows: item.ActiveWindows,
+		Proxy:         item.Proxy,
+	}
+
+	if newKey.Tags == nil {
+		newKey.Tags = []string{}
+	}
+	if newKey.Provider == "" {
+		newKey.Provider = "google_aistudio"
+	}
+	if item.Enabled != nil && !*item.Enabled {
+		newKey.Enabled = false
+		newKey.Status = types.KeyStatusDisabled
+	}
+	if item.Limits != nil {
+		newKey.Limits = *item.Limits
+	}
+	if item.Stats != nil {
+		newKey.Stats = *item.Stats
+	}
+	if item.CreatedAt != nil {
+		newKey.CreatedAt = *item.CreatedAt
+	}
+	return newKey
+}
+
+// updateImportedKey applies an import entry to an existing key. Merge adds
+// new tags and fills empty fields; overwrite replaces the key's settings.
+// Stats are never touched.
+func (h *AdminHandler) updateImportedKey(id string, item types.ImportKeyItem, mode string) error {
+	key, err := h.pool.GetKeyByID(id)
+	if err != nil {
+		return err
+	}
+
+	var update types.UpdateKeyRequest
+	switch mode {
+	case types.ImportDuplicateMerge:
+		tags := mergeTags(key.Tags, item.Tags)
+		update.Tags = &tags
+		if key.Name == "" && item.Name != "" {
+			update.Name = &item.Name
+		}
+		if key.DefaultModel == "" && item.DefaultModel != "" {
+			update.DefaultModel = &item.DefaultModel
+		}
+		if key.Weight == 0 && item.Weight != 0 {
+			update.Weight = &item.Weight
+		}
+		if key.Priority == 0 && item.Priority != 0 {
+			update.Priority = &item.Priority
+		}
+		if key.Limits == (types.KeyLimits{}) && item.Limits != nil {
+			update.Limits = item.Limits
+		}
+		if len(key.AllowedModels) == 0 && item.AllowedModels != nil {
+			update.AllowedModels = &item.AllowedModels
+		}
+		if len(key.DeniedModels) == 0 && item.DeniedModels != nil {
+			update.DeniedModels = &item.DeniedModels
+		}
+		if key.NotBefore == nil && item.NotBefore != nil {
+			update.NotBefore = types.OptionalTime{Set: true, Time: item.NotBefore}
+		}
+		if key.ExpiresAt == nil && item.ExpiresAt != nil {
+			update.ExpiresAt = types.OptionalTime{Set: true, Time: item.ExpiresAt}
+		}
+		if len(key.ActiveWindows) == 0 && len(item.ActiveWindows) > 0 {
+			update.ActiveWindows = &item.ActiveWindows
+		}
+		if key.Proxy == "" && item.Proxy != "" {
+			update.Proxy = &item.Proxy
+		}
+
+	case types.ImportDuplicateOverwrite:
+		enabled := item.Enabled == nil || *item.Enabled
+		provider := item.Provider
+		if provider == "" {
+			provider = "google_aistudio"
+		}
+		tags := item.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		limits := types.KeyLimits{}
+		if item.Limits != nil {
+			limits = *item.Limits
+		}
+		windows := item.ActiveWindows
+		if windows == nil {
+			windows = []types.ActiveWindow{}
+		}
+		allowed, denied := item.AllowedModels, item.DeniedModels
+		if allowed == nil {
+			allowed = []string{}
+		}
+		if denied == nil {
+			denied = []string{}
+		}
+		update = types.UpdateKeyRequest{
+			Name:         &item.Name,
+			Tags:         &tags,
+			Enabled:      &enabled,
+			Provider:     &provider,
+			DefaultModel: &item.DefaultModel,
+			Weight:       &item.Weight,
+			Priority:     &item.Priority,
+			Limits:       &limits,
+			Proxy:        &item.Proxy,
+
+			NotBefore:     types.OptionalTime{Set: true, Time: item.NotBefore},
+			ExpiresAt:     types.OptionalTime{Set: true, Time: item.ExpiresAt},
+			ActiveWindows: &windows,
+			AllowedModels: &allowed,
+			DeniedModels:  &denied,
+		}
+	}
+
+	_, err = h.pool.UpdateKey(id, update)
+	return err
+}
+
+// mergeTags returns existing followed by the tags from added it lacks.
+func mergeTags(existing, added []string) []string {
+	merged := append([]string{}, existing...)
+	for _, tag := range added {
+		found := false
+		for _, t := range merged {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, tag)
+		}
+	}
+	return merged
+}
+
+// normalizeOptionalPatterns normalizes patterns, keeping nil as "unchanged".
+func normalizeOptionalPatterns(patterns []string) []string {
+	if patterns == nil {
+		return nil
+	}
+	return normalizeModelPatterns(patterns)
+}
+
+// ==================== Import Formats ====================
+
+// parseImportRequest reads an import body in any supported format:
+//   - the ImportKeysRequest JSON object, optionally carrying a bundle
+//   - a key bundle as written by ExportKeyBundle
+//   - a JSON array of key strings or of ImportKeyItem objects
+//   - CSV, with a header row naming key/name/tags/enabled columns or
+//     positional key,name,tags columns
+//   - plain text with one key per line; blank lines and # comments are skipped
+//
+// The format is taken from ?format=json|csv|text, then the Content-Type, then
+// sniffed from the body. Options not set in a JSON request come from the
+// validate, keep_invalid, on_duplicate and concurrency query parameters, and
+// a bundle passphrase from the X-Bundle-Passphrase header.
+func parseImportRequest(c *gin.Context) (types.ImportKeysRequest, error) {
+	var req types.ImportKeysRequest
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportBodySize+1))
+	if err != nil {
+		return req, err
+	}
+	if len(body) > maxImportBodySize {
+		return req, errors.New("body too large")
+	}
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	trimmed := bytes.TrimSpace(body)
+
+	format := c.Query("format")
+	if format == "" {
+		contentType := c.ContentType()
+		switch {
+		case contentType == "text/csv":
+			format = "csv"
+		case len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '['):
+			format = "json"
+		default:
+			format = "text"
+		}
+	}
+
+	switch format {
+	case "json":
+		if err := parseImportJSON(trimmed, &req); err != nil {
+			return req, err
+		}
+	case "csv":
+		if req.Keys, err = parseImportCSV(body); err != nil {
+			return req, err
+		}
+	case "text":
+		req.Keys = parseImportLines(body)
+	default:
+		return req, fmt.Errorf("unsupported format %q", format)
+	}
+
+	if !req.Validate && c.Query("validate") == "true" {
+		req.Validate = true
+	}
+	if !req.KeepInvalid && c.Query("keep_invalid") == "true" {
+		req.KeepInvalid = true
+	}
+	if req.OnDuplicate == "" {
+		req.OnDuplicate = c.Query("on_duplicate")
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency, _ = strconv.Atoi(c.Query("concurrency"))
+	}
+	if req.Passphrase == "" {
+		req.Passphrase = c.GetHeader(BundlePassphraseHeader)
+	}
+	return req, nil
+}
+
+// parseImportJSON decodes a JSON request object, a bare bundle or a JSON array.
+func parseImportJSON(body []byte, req *types.ImportKeysRequest) error {
+	if len(body) > 0 && body[0] == '[' {
+		var keys []string
+		if err := json.Unmarshal(body, &keys); err == nil {
+			for _, key := range keys {
+				req.Keys = append(req.Keys, types.ImportKeyItem{Key: key})
+			}
+			return nil
+		}
+		return json.Unmarshal(body, &req.Keys)
+	}
+
+	var probe struct {
+		Format string `json:"format"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return err
+	}
+	if probe.Format == types.KeyBundleFormat {
+		req.Bundle = &types.KeyBundle{}
+		return json.Unmarshal(body, req.Bundle)
+	}
+	return json.Unmarshal(body, req)
+}
+
+// parseImportCSV reads keys from CSV. A first row containing a "key" or
+// "api_key" cell is a header; otherwise columns are key, name, tags.
+// Tags within a cell are separated by ";" or "|".
+func parseImportCSV(body []byte) ([]types.ImportKeyItem, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{"key": 0, "name": 1, "tags": 2, "enabled": -1}
+	header := make(map[string]int)
+	for i, cell := range records[0] {
+		name := strings.ToLower(strings.TrimSpace(cell))
+		if name == "api_key" {
+			name = "key"
+		}
+		header[name] = i
+	}
+	if _, ok := header["key"]; ok {
+		for name := range columns {
+			columns[name] = -1
+			if i, ok := header[name]; ok {
+				columns[name] = i
+			}
+		}
+		records = records[1:]
+	}
+
+	field := func(record []string, name string) string {
+		if i := columns[name]; i >= 0 && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	items := make([]types.ImportKeyItem, 0, len(records))
+	for _, record := range records {
+		item := types.ImportKeyItem{Key: field(record, "key"), Name: field(record, "name")}
+		if item.Key == "" {
+			continue
+		}
+		if tags := field(record, "tags"); tags != "" {
+			for _, tag := range strings.FieldsFunc(tags, func(r rune) bool { return r == ';' || r == '|' }) {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					item.Tags = append(item.Tags, tag)
+				}
+			}
+		}
+		if enabled, err := strconv.ParseBool(field(record, "enabled")); err == nil {
+			item.Enabled = &enabled
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// parseImportLines reads one key per line, skipping blank lines and # comments.
+func parseImportLines(body []byte) []types.ImportKeyItem {
+	var items []types.ImportKeyItem
+	for _, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		items = append(items, types.ImportKeyItem{Key: line})
+	}
+	return items
+}
@@ -0,0 +1,270 @@
+﻿package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"muxueTools/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+)
+
+// ==================== Bulk Key Testing ====================
+
+const (
+	defaultKeyTestConcurrency = 8
+	maxKeyTestConcurrency     = 32
+	keyProbeTimeout           = 30 * time.Second
+)
+
+// keyTestTarget holds the fields of a key needed to probe it, copied up front
+// so probes do not read pool state concurrently.
+type keyTestTarget struct {
+	id, name, maskedKey, apiKey, proxy string
+}
+
+// BulkTestKeys handles POST /api/keys/test - Probe keys concurrently, update
+// their state and return a health report. The report is JSON by default, CSV
+// with ?format=csv, or a Server-Sent Events stream of progress events with
+// ?stream=true or Accept: text/event-stream.
+func (h *AdminHandler) BulkTestKeys(c *gin.Context) {
+	// The body is optional; without one every key is tested
+	var req types.BulkTestKeysRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+			RespondBadRequest(c, "Invalid request body: "+err.Error())
+			return
+		}
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		RespondBadRequest(c, "Invalid format: "+format)
+		return
+	}
+	stream := c.Query("stream") == "true" || strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+
+	if h.client == nil {
+		RespondError(c, types.NewServiceUnavailableError("Key testing is not available"))
+		return
+	}
+
+	targets := h.keyTestTargets(req)
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultKeyTestConcurrency
+	}
+	if concurrency > maxKeyTestConcurrency {
+		concurrency = maxKeyTestConcurrency
+	}
+
+	ctx := c.Request.Context()
+	start := time.Now()
+	results := make([]types.KeyTestReportItem, len(targets))
+	finished := make(chan int)
+
+	go func() {
+		var g errgroup.Group
+		g.SetLimit(concurrency)
+		for i, target := range targets {
+			g.Go(func() error {
+				results[i] = h.testKey(ctx, target, req.DisableInvalid)
+				finished <- i
+				return nil
+			})
+		}
+		_ = g.Wait()
+		close(finished)
+	}()
+
+	var sse *SSEWriter
+	if stream {
+		sse = NewSSEWriter(c)
+	}
+
+	done := 0
+	for i := range finished {
+		done++
+		if sse != nil {
+			result := results[i]
+			writeKeyTestEvent(sse, types.KeyTestEvent{Type: "progress", Done: done, Total: len(targets), Result: &result})
+		}
+	}
+
+	report := buildKeyTestReport(results, time.Since(start))
+	h.logger.WithFields(logrus.Fields{
+		"total":       report.Total,
+		"summary":     report.Summary,
+		"duration_ms": report.DurationMs,
+	}).Info("Bulk key test completed")
+
+	switch {
+	case sse != nil:
+		writeKeyTestEvent(sse, types.KeyTestEvent{Type: "report", Done: done, Total: len(targets), Report: &report})
+		_ = sse.WriteDone()
+	case format == "csv":
+		writeKeyTestCSV(c, report)
+	default:
+		RespondSuccess(c, report)
+	}
+}
+
+// keyTestTargets resolves the keys selected by req; an empty selection means every key.
+func (h *AdminHandler) keyTestTargets(req types.BulkTestKeysRequest) []keyTestTarget {
+	var ids []string
+	if len(req.IDs) == 0 && req.Tag == "" {
+		for _, key := range h.pool.GetStats() {
+			ids = append(ids, key.ID)
+		}
+	} else {
+		ids = h.selectBulkKeys(types.BulkKeyRequest{IDs: req.IDs, Tag: req.Tag})
+	}
+
+	targets := make([]keyTestTarget, 0, len(ids))
+	for _, id := range ids {
+		key, err := h.pool.GetKeyByID(id)
+		if err != nil {
+			continue
+		}
+		targets = append(targets, h.keyTestTarget(key))
+	}
+	return targets
+}
+
+// keyTestTarget copies the fields needed to probe key.
+func (h *AdminHandler) keyTestTarget(key *types.Key) keyTestTarget {
+	return keyTestTarget{
+		id:        key.ID,
+		name:      key.Name,
+		maskedKey: key.MaskedKey,
+		apiKey:    key.APIKey,
+		proxy:     h.pool.ProxyFor(key),
+	}
+}
+
+// testKey probes one key and records the outcome in the pool.
+func (h *AdminHandler) testKey(ctx context.Context, target keyTestTarget, disableInvalid bool) types.KeyTestReportItem {
+	result := h.probeKey(ctx, target.apiKey, target.proxy)
+	if _, err := h.pool.RecordProbe(target.id, result, disableInvalid); err != nil && err != types.ErrKeyNotFound {
+		h.logger.WithError(err).WithField("key_id", target.id).Warn("Failed to record key probe")
+	}
+
+	return types.KeyTestReportItem{
+		KeyID:          target.id,
+		Name:           target.name,
+		MaskedKey:      target.maskedKey,
+		KeyProbeResult: result,
+	}
+}
+
+// probeKey probes one API key upstream through proxy (or the global proxy
+// when empty), bounded by keyProbeTimeout.
+func (h *AdminHandler) probeKey(ctx context.Context, apiKey, proxy string) types.KeyProbeResult {
+	probeCtx, cancel := context.WithTimeout(types.WithKeyProxy(ctx, proxy), keyProbeTimeout)
+	defer cancel()
+
+	return h.client.ProbeKey(probeCtx, apiKey)
+}
+
+// probeKeys probes keys, each through its own proxy, in parallel and returns
+// the results in input order.
+func (h *AdminHandler) probeKeys(ctx context.Context, keys []*types.Key, concurrency int) []types.KeyProbeResult {
+	if concurrency <= 0 {
+		concurrency = defaultKeyTestConcurrency
+	}
+	if concurrency > maxKeyTestConcurrency {
+		concurrency = maxKeyTestConcurrency
+	}
+
+	results := make([]types.KeyProbeResult, len(keys))
+	var g errgroup.Group
+	g.SetLimit(concurrency)
+	for i, key := range keys {
+		proxy := h.pool.ProxyFor(key)
+		g.Go(func() error {
+			results[i] = h.probeKey(ctx, key.APIKey, proxy)
+			return nil
+		})
+	}
+	_ = g.Wait()
+	return results
+}
+
+// applyKeyProbe records a probe result on a key that is about to be added.
+// It returns false if the key is unusable and should not be added; with
+// keepInvalid an unusable key is kept but disabled with a reason.
+func applyKeyProbe(key *types.Key, result types.KeyProbeResult, keepInvalid bool) bool {
+	now := time.Now()
+	key.Health = result.Health
+	key.LastCheckedAt = &now
+	if result.Health == types.KeyHealthValid {
+		key.DetectedModels = result.Models
+		key.ModelsDetectedAt = &now
+	}
+
+	if result.Usable() {
+		return true
+	}
+	if !keepInvalid {
+		return false
+	}
+	key.Enabled = false
+	key.Status = types.KeyStatusDisabled
+	key.DisabledReason = result.Reason()
+	return true
+}
+
+// buildKeyTestReport aggregates probe results into a report.
+func buildKeyTestReport(results []types.KeyTestReportItem, elapsed time.Duration) types.KeyTestReport {
+	report := types.KeyTestReport{
+		Total:      len(results),
+		Summary:    make(map[types.KeyHealth]int),
+		DurationMs: elapsed.Milliseconds(),
+		Results:    results,
+	}
+	for _, result := range results {
+		report.Summary[result.Health]++
+	}
+	return report
+}
+
+// writeKeyTestEvent writes a bulk test event to the SSE stream.
+// Write errors mean the client went away and are ignored.
+func writeKeyTestEvent(sse *SSEWriter, event types.KeyTestEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	_ = sse.WriteEvent(data)
+}
+
+// writeKeyTestCSV writes the report as a downloadable CSV file.
+func writeKeyTestCSV(c *gin.Context, report types.KeyTestReport) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename=key-health-report.csv")
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"key_id", "name", "key", "health", "latency_ms", "model_count", "models", "error"})
+	for _, result := range report.Results {
+		_ = w.Write([]string{
+			result.KeyID,
+			result.Name,
+			result.MaskedKey,
+			string(result.Health),
+			strconv.FormatInt(result.LatencyMs, 10),
+			strconv.Itoa(len(result.Models)),
+			strings.Join(result.Models, ";"),
+			result.Error,
+		})
+	}
+	w.Flush()
+}
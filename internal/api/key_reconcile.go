@@ -0,0 +1,87 @@
+﻿package api
+
+import (
+	"muxueTools/internal/config"
+	"muxueTools/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ==================== Key Reconciliation ====================
+
+// ReconcileKeys handles POST /api/keys/reconcile - Reconcile the config file
+// keys, the database and the in-memory pool. The policy defaults to
+// pool.reconcile_policy and can be overridden with ?policy=config|database;
+// ?dry_run=true only reports the changes.
+func (h *AdminHandler) ReconcileKeys(c *gin.Context) {
+	var keys []types.KeyConfig
+	var policy types.ReconcilePolicy
+	if cfg := config.GetSafe(); cfg != nil {
+		keys = cfg.Keys
+		policy = cfg.Pool.ReconcilePolicy
+	}
+	if p := c.Query("policy"); p != "" {
+		policy = types.ReconcilePolicy(p)
+		if !policy.IsValid() {
+			RespondBadRequest(c, "policy must be 'config' or 'database'")
+			return
+		}
+	}
+
+	report, err := h.pool.Reconcile(keys, policy, c.Query("dry_run") == "true")
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to reconcile keys")
+		RespondInternalError(c, "Failed to reconcile keys: "+err.Error())
+		return
+	}
+	if !report.DryRun {
+		logReconcileReport(h.logger, report)
+	}
+	RespondSuccess(c, report)
+}
+
+// ReloadConfig handles POST /api/config/reload - Re-read the config file,
+// reconcile its keys with the pool and apply its upstream timeouts.
+func (h *AdminHandler) ReloadConfig(c *gin.Context) {
+	cfg, err := config.Reload()
+	if err != nil {
+		RespondBadRequest(c, "Failed to reload config: "+err.Error())
+		return
+	}
+	h.logger.Info("Configuration reloaded")
+	resolveTimeouts(cfg, h.storage).apply(h.client)
+	applyParameterMode(h.client, resolveParameterMode(cfg, h.storage))
+
+	report, err := h.pool.Reconcile(cfg.Keys, cfg.Pool.ReconcilePolicy, false)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to reconcile keys")
+		RespondInternalError(c, "Failed to reconcile keys: "+err.Error())
+		return
+	}
+	logReconcileReport(h.logger, report)
+	RespondSuccess(c, report)
+}
+
+// logReconcileReport logs every applied or failed change of a reconciliation.
+func logReconcileReport(logger *logrus.Logger, report *types.KeyReconcileReport) {
+	for _, change := range report.Changes {
+		entry := logger.WithFields(logrus.Fields{
+			"action": change.Action,
+			"key_id": change.KeyID,
+			"key":    change.MaskedKey,
+		})
+		if len(change.Fields) > 0 {
+			entry = entry.WithField("fields", change.Fields)
+		}
+		if change.Error != "" {
+			entry.WithField("error", change.Error).Warn("Key reconciliation failed")
+		} else {
+			entry.Info("Key reconciled")
+		}
+	}
+	logger.WithFields(logrus.Fields{
+		"policy":  report.Policy,
+		"changes": len(report.Changes),
+	}).Info("Key reconciliation completed")
+}
@@ -0,0 +1,42 @@
+﻿package api
+
+import (
+	"muxueTools/internal/types"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ==================== Log Redaction ====================
+
+// redactHook masks API keys, proxy keys and proxy passwords in log messages
+// and string or error fields before any formatter writes them.
+type redactHook struct{}
+
+// Levels applies the hook to every level.
+func (redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire redacts the entry in place; logrus hands hooks a copy of the fields.
+func (redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = types.RedactSecrets(entry.Message)
+	for name, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			entry.Data[name] = types.RedactSecrets(v)
+		case error:
+			entry.Data[name] = types.RedactSecrets(v.Error())
+		}
+	}
+	return nil
+}
+
+// addRedactHook installs redactHook on logger once.
+func addRedactHook(logger *logrus.Logger) {
+	for _, hook := range logger.Hooks[logrus.InfoLevel] {
+		if _, ok := hook.(redactHook); ok {
+			return
+		}
+	}
+	logger.AddHook(redactHook{})
+}
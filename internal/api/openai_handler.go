@@ -2,10 +2,14 @@
 package api
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"muxueTools/internal/gemini"
@@ -23,6 +27,15 @@ type OpenAIHandler struct {
 	client *gemini.Client
 	pool   *keypool.Pool
 	logger *logrus.Logger
+
+	// Streaming output: chunks arriving within flushInterval of each other
+	// are flushed together, and a heartbeat comment is sent after
+	// heartbeatInterval without output. Zero disables either.
+	flushInterval     time.Duration
+	heartbeatInterval time.Duration
+
+	// presets lists the model presets exposed as virtual models; nil if none.
+	presets gemini.PresetsGetter
 }
 
 // NewOpenAIHandler creates a new OpenAI handler.
@@ -34,8 +47,26 @@ func NewOpenAIHandler(client *gemini.Client, pool *keypool.Pool, logger *logrus.
 	}
 }
 
+// SetStreamIntervals sets the chunk coalescing window and the heartbeat
+// interval of streaming responses.
+func (h *OpenAIHandler) SetStreamIntervals(flush, heartbeat time.Duration) {
+	h.flushInterval = flush
+	h.heartbeatInterval = heartbeat
+}
+
+// SetPresets sets the getter of the model presets listed by /v1/models.
+func (h *OpenAIHandler) SetPresets(getter gemini.PresetsGetter) {
+	h.presets = getter
+}
+
 // ==================== Chat Completions ====================
 
+// KeyGroupHeader is the request header that selects a key group.
+const KeyGroupHeader = "X-Key-Group"
+
+// SessionIDHeader is the request header that identifies a conversation for key affinity.
+const SessionIDHeader = "X-Session-ID"
+
 // ChatCompletions handles POST /v1/chat/completions.
 func (h *OpenAIHandler) ChatCompletions(c *gin.Context) {
 	requestID := GetRequestID(c)
@@ -64,9 +95,20 @@ func (h *OpenAIHandler) ChatCompletions(c *gin.Context) {
 		return
 	}
 
+	// Resolve the key group and hand it to the client through the request context
+	group, appErr := h.resolveKeyGroup(c, &req)
+	if appErr != nil {
+		RespondOpenAIError(c, appErr)
+		return
+	}
+	ctx := types.WithKeyGroup(c.Request.Context(), group)
+	ctx = types.WithKeyAffinity(ctx, conversationAffinity(c, &req))
+	c.Request = c.Request.WithContext(ctx)
+
 	h.logger.WithFields(logrus.Fields{
 		"request_id": requestID,
 		"model":      req.Model,
+		"key_group":  group,
 		"stream":     req.Stream,
 		"messages":   len(req.Messages),
 	}).Debug("Processing chat completion request")
@@ -98,9 +140,63 @@ func (h *OpenAIHandler) validateChatRequest(req *types.ChatCompletionRequest) *t
 		}
 	}
 
+	if req.StreamOptions != nil && !req.Stream {
+		return types.NewInvalidRequestError("stream_options is only allowed when stream is true").WithParam("stream_options")
+	}
+
 	return nil
 }
 
+// resolveKeyGroup determines the key group for a request, in order of precedence:
+// the X-Key-Group header, a "model@group" suffix, a bearer token binding and a
+// source IP binding. The group suffix is stripped from req.Model.
+// Returns "" to let the pool apply its default group.
+func (h *OpenAIHandler) resolveKeyGroup(c *gin.Context, req *types.ChatCompletionRequest) (string, *types.AppError) {
+	group := strings.TrimSpace(c.GetHeader(KeyGroupHeader))
+
+	if i := strings.LastIndex(req.Model, "@"); i > 0 {
+		if group == "" {
+			group = req.Model[i+1:]
+		}
+		req.Model = req.Model[:i]
+	}
+
+	if group == "" {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		group = h.pool.ResolveGroupBinding(token, c.ClientIP())
+	}
+
+	if group != "" && !h.pool.HasGroup(group) {
+		return "", types.NewUnknownKeyGroupError(group)
+	}
+	return group, nil
+}
+
+// conversationAffinity identifies the conversation a request belongs to, so the
+// pool can keep it on one key. It uses the X-Session-ID header, then the OpenAI
+// user field, then a hash of the messages up to and including the first user
+// message, which stays the same across turns.
+func conversationAffinity(c *gin.Context, req *types.ChatCompletionRequest) string {
+	if session := strings.TrimSpace(c.GetHeader(SessionIDHeader)); session != "" {
+		return "session:" + session
+	}
+	if req.User != "" {
+		return "user:" + req.User
+	}
+
+	hash := sha256.New()
+	for _, msg := range req.Messages {
+		hash.Write([]byte(msg.Role))
+		hash.Write([]byte{0})
+		hash.Write(msg.Content)
+		hash.Write([]byte{0})
+		if msg.Role == "user" {
+			return "prefix:" + hex.EncodeToString(hash.Sum(nil)[:16])
+		}
+	}
+	return ""
+}
+
 // handleBlockingRequest handles non-streaming chat completion requests.
 func (h *OpenAIHandler) handleBlockingRequest(c *gin.Context, req *types.ChatCompletionRequest, requestID string) {
 	ctx := c.Request.Context()
@@ -128,16 +224,62 @@ func (h *OpenAIHandler) handleStreamingRequest(c *gin.Context, req *types.ChatCo
 	// Create SSE writer
 	sse := NewSSEWriter(c)
 
-	// Stream events to client
-	for event := range eventChan {
-		// Check for context cancellation (client disconnect)
+	h.streamEvents(ctx, sse, eventChan, requestID)
+}
+
+// streamEvents writes stream events to the client until the stream ends.
+// Chunks are coalesced per flush interval, and heartbeats keep the connection
+// open while upstream is silent, e.g. during long thinking phases.
+func (h *OpenAIHandler) streamEvents(ctx context.Context, sse *SSEWriter, eventChan <-chan gemini.StreamEvent, requestID string) {
+	var heartbeat *time.Ticker
+	var heartbeatC <-chan time.Time
+	if h.heartbeatInterval > 0 {
+		heartbeat = time.NewTicker(h.heartbeatInterval)
+		defer heartbeat.Stop()
+		heartbeatC = heartbeat.C
+	}
+	// resetHeartbeat restarts the heartbeat once data has reached the client
+	resetHeartbeat := func() {
+		if heartbeat != nil {
+			heartbeat.Reset(h.heartbeatInterval)
+		}
+	}
+
+	// flushC fires when the buffered chunks are due; nil while none are pending
+	var flushC <-chan time.Time
+	var flushTimer *time.Timer
+	defer func() {
+		if flushTimer != nil {
+			flushTimer.Stop()
+		}
+	}()
+
+	for {
+		var event gemini.StreamEvent
+		var ok bool
 		select {
 		case <-ctx.Done():
+			// Client disconnected
 			h.logger.WithFields(logrus.Fields{
 				"request_id": requestID,
 			}).Debug("Client disconnected, stopping stream")
 			return
-		default:
+		case <-heartbeatC:
+			if err := sse.WriteComment("keep-alive"); err != nil {
+				return
+			}
+			flushC = nil
+			resetHeartbeat()
+			continue
+		case <-flushC:
+			sse.Flush()
+			flushC = nil
+			resetHeartbeat()
+			continue
+		case event, ok = <-eventChan:
+		}
+		if !ok {
+			break
 		}
 
 		if event.Err != nil {
@@ -172,14 +314,29 @@ func (h *OpenAIHandler) handleStreamingRequest(c *gin.Context, req *types.ChatCo
 				continue
 			}
 
-			// Write SSE event
-			if err := sse.WriteEvent(chunkJSON); err != nil {
+			// Write SSE event, flushing it now or with the next batch
+			write := sse.WriteEvent
+			if h.flushInterval > 0 {
+				write = sse.BufferEvent
+			}
+			if err := write(chunkJSON); err != nil {
 				h.logger.WithFields(logrus.Fields{
 					"request_id": requestID,
 					"error":      err.Error(),
 				}).Debug("Failed to write SSE event (client may have disconnected)")
 				return
 			}
+			if h.flushInterval <= 0 {
+				resetHeartbeat()
+			} else if flushC == nil {
+				// Buffered chunks only count as output once flushed
+				if flushTimer == nil {
+					flushTimer = time.NewTimer(h.flushInterval)
+				} else {
+					flushTimer.Reset(h.flushInterval)
+				}
+				flushC = flushTimer.C
+			}
 		}
 	}
 
@@ -260,6 +417,20 @@ func (h *OpenAIHandler) ListModels(c *gin.Context) {
 		},
 	}
 
+	// Model presets are exposed as virtual models
+	if h.presets != nil {
+		for _, preset := range h.presets() {
+			for _, id := range gemini.PresetModelIDs(preset) {
+				models = append(models, types.ModelInfo{
+					ID:      id,
+					Object:  "model",
+					Created: preset.CreatedAt.Unix(),
+					OwnedBy: "muxuetools",
+				})
+			}
+		}
+	}
+
 	resp := types.ModelsResponse{
 		Object: "list",
 		Data:   models,
@@ -305,6 +476,7 @@ func (h *HealthHandler) Health(c *gin.Context) {
 		Version: h.version,
 		Uptime:  uptime,
 		Keys:    keyStats,
+		Groups:  h.pool.GetGroupStatus(),
 	}
 
 	c.JSON(http.StatusOK, resp)
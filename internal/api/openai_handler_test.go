@@ -7,8 +7,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"muxueTools/internal/gemini"
 	"muxueTools/internal/keypool"
 	"muxueTools/internal/types"
 
@@ -23,7 +26,7 @@ type mockKeyPool struct {
 	keys []*types.Key
 }
 
-func (m *mockKeyPool) GetKey() (*types.Key, error) {
+func (m *mockKeyPool) GetKey(req types.KeyRequest) (*types.Key, error) {
 	if len(m.keys) == 0 {
 		return nil, types.ErrNoAvailableKeys
 	}
@@ -37,6 +40,8 @@ func (m *mockKeyPool) ReportSuccess(key *types.Key, promptTokens, completionToke
 
 func (m *mockKeyPool) ReportFailure(key *types.Key, err error, model string) {}
 
+func (m *mockKeyPool) ReportLatency(key *types.Key, model string, latency time.Duration) {}
+
 // createOpenAITestRouter creates a router specifically for OpenAI handler testing.
 func createOpenAITestRouter() (*gin.Engine, *keypool.Pool) {
 	gin.SetMode(gin.TestMode)
@@ -251,6 +256,32 @@ func TestListModels_Returns200(t *testing.T) {
 	}
 }
 
+func TestListModels_IncludesPresets(t *testing.T) {
+	handler := &OpenAIHandler{logger: logrus.New()}
+	handler.SetPresets(func() []types.ModelPreset {
+		return []types.ModelPreset{{Name: "coder", Model: "gemini-2.5-pro"}}
+	})
+
+	engine := gin.New()
+	engine.GET("/v1/models", handler.ListModels)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/models", nil)
+	engine.ServeHTTP(w, req)
+
+	var resp types.ModelsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	ids := make(map[string]bool)
+	for _, model := range resp.Data {
+		ids[model.ID] = true
+	}
+	if !ids["coder"] || !ids["gemini-2.5-pro:coder"] {
+		t.Errorf("Expected preset virtual models, got %+v", resp.Data)
+	}
+}
+
 // ==================== Health Handler Tests ====================
 
 func TestHealthHandler_CalculatesStats(t *testing.T) {
@@ -450,6 +481,105 @@ func TestValidateChatRequest(t *testing.T) {
 	}
 }
 
+func TestResolveKeyGroup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	pool := keypool.NewPool(
+		[]types.KeyConfig{{Key: "AIzaSyTestKey1XXXXXXXXXXXXXXXXX", Enabled: true, Tags: []string{"paid"}}},
+		keypool.WithKeyGroups([]types.KeyGroupConfig{{Name: "paid"}, {Name: "free"}}),
+		keypool.WithGroupBindings([]types.KeyGroupBinding{{Token: "sk-prod", Group: "paid"}}),
+	)
+	handler := &OpenAIHandler{pool: pool, logger: logrus.New()}
+
+	tests := []struct {
+		name      string
+		model     string
+		header    string
+		token     string
+		wantGroup string
+		wantModel string
+		wantErr   bool
+	}{
+		{name: "no selection", model: "gemini-2.5-pro", wantModel: "gemini-2.5-pro"},
+		{name: "header", model: "gemini-2.5-pro", header: "free", wantGroup: "free", wantModel: "gemini-2.5-pro"},
+		{name: "model suffix", model: "gemini-2.5-pro@free", wantGroup: "free", wantModel: "gemini-2.5-pro"},
+		{name: "header wins over suffix", model: "gemini-2.5-pro@free", header: "paid", wantGroup: "paid", wantModel: "gemini-2.5-pro"},
+		{name: "token binding", model: "gemini-2.5-pro", token: "sk-prod", wantGroup: "paid", wantModel: "gemini-2.5-pro"},
+		{name: "suffix wins over binding", model: "gemini-2.5-pro@free", token: "sk-prod", wantGroup: "free", wantModel: "gemini-2.5-pro"},
+		{name: "unknown group", model: "gemini-2.5-pro@missing", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			if tt.header != "" {
+				c.Request.Header.Set(KeyGroupHeader, tt.header)
+			}
+			if tt.token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			req := &types.ChatCompletionRequest{Model: tt.model}
+			group, err := handler.resolveKeyGroup(c, req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveKeyGroup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if group != tt.wantGroup {
+				t.Errorf("group = %q, want %q", group, tt.wantGroup)
+			}
+			if req.Model != tt.wantModel {
+				t.Errorf("model = %q, want %q", req.Model, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestConversationAffinity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newContext := func(session string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		if session != "" {
+			c.Request.Header.Set(SessionIDHeader, session)
+		}
+		return c
+	}
+
+	turn1 := &types.ChatCompletionRequest{Messages: []types.Message{
+		types.NewTextContent("system", "Be brief."),
+		types.NewTextContent("user", "Hello"),
+	}}
+	turn2 := &types.ChatCompletionRequest{Messages: append(append([]types.Message{}, turn1.Messages...),
+		types.NewTextContent("assistant", "Hi!"),
+		types.NewTextContent("user", "How are you?"),
+	)}
+	other := &types.ChatCompletionRequest{Messages: []types.Message{
+		types.NewTextContent("user", "Different conversation"),
+	}}
+
+	a1 := conversationAffinity(newContext(""), turn1)
+	a2 := conversationAffinity(newContext(""), turn2)
+	if a1 == "" || a1 != a2 {
+		t.Errorf("turns of one conversation should share an affinity, got %q and %q", a1, a2)
+	}
+	if conversationAffinity(newContext(""), other) == a1 {
+		t.Error("different conversations should not share an affinity")
+	}
+
+	if got := conversationAffinity(newContext("abc"), &types.ChatCompletionRequest{User: "u1"}); got != "session:abc" {
+		t.Errorf("session header should take precedence, got %q", got)
+	}
+	if got := conversationAffinity(newContext(""), &types.ChatCompletionRequest{User: "u1"}); got != "user:u1" {
+		t.Errorf("user field should be used, got %q", got)
+	}
+}
+
 // ==================== Helper Functions ====================
 
 func containsString(s, substr string) bool {
@@ -478,3 +608,129 @@ func TestHandleStreamingRequest_ContextCancellation(t *testing.T) {
 	// This is more of a sanity check
 	t.Log("Context cancellation test passed (manual verification)")
 }
+
+// ==================== Stream Output Tests ====================
+
+// flushRecorder is an httptest.ResponseRecorder that counts flushes.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushes++
+	r.ResponseRecorder.Flush()
+}
+
+// runStreamEvents feeds events to streamEvents, waiting delay before each
+// one, and returns the recorded response.
+func runStreamEvents(t *testing.T, handler *OpenAIHandler, delay time.Duration, events ...gemini.StreamEvent) *flushRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	eventChan := make(chan gemini.StreamEvent)
+	go func() {
+		defer close(eventChan)
+		for _, event := range events {
+			time.Sleep(delay)
+			eventChan <- event
+		}
+	}()
+
+	handler.streamEvents(c.Request.Context(), NewSSEWriter(c), eventChan, "test")
+	return rec
+}
+
+func textChunk(text string) gemini.StreamEvent {
+	return gemini.StreamEvent{Chunk: &types.ChatCompletionChunk{
+		ID:      "chatcmpl-test",
+		Object:  "chat.completion.chunk",
+		Choices: []types.ChunkChoice{{Delta: types.Delta{Content: text}}},
+	}}
+}
+
+func TestStreamEvents_Heartbeat(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	handler := &OpenAIHandler{logger: logger}
+	handler.SetStreamIntervals(0, 20*time.Millisecond)
+
+	rec := runStreamEvents(t, handler, 100*time.Millisecond, textChunk("Hi"), gemini.StreamEvent{Done: true})
+
+	body := rec.Body.String()
+	if !strings.Contains(body, ": keep-alive\n\n") {
+		t.Errorf("expected heartbeat comments while waiting, got %q", body)
+	}
+	if !strings.Contains(body, `"content":"Hi"`) || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Errorf("expected chunk and [DONE] after heartbeats, got %q", body)
+	}
+}
+
+func TestStreamEvents_CoalescesChunks(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+
+	events := []gemini.StreamEvent{textChunk("a"), textChunk("b"), textChunk("c"), {Done: true}}
+
+	// Without coalescing every chunk and [DONE] is flushed on its own
+	handler := &OpenAIHandler{logger: logger}
+	rec := runStreamEvents(t, handler, 0, events...)
+	if rec.flushes != 4 {
+		t.Errorf("expected 4 flushes without coalescing, got %d", rec.flushes)
+	}
+
+	// Chunks within the flush interval go out together with [DONE]
+	handler.SetStreamIntervals(time.Minute, 0)
+	rec = runStreamEvents(t, handler, 0, events...)
+	if rec.flushes != 1 {
+		t.Errorf("expected 1 flush with coalescing, got %d", rec.flushes)
+	}
+	for _, text := range []string{"a", "b", "c"} {
+		if !strings.Contains(rec.Body.String(), `"content":"`+text+`"`) {
+			t.Errorf("chunk %q missing from coalesced output", text)
+		}
+	}
+}
+
+func TestStreamEvents_BufferedChunksDoNotDelayHeartbeat(t *testing.T) {
+	logger := logrus.New()
+	logger.SetLevel(logrus.ErrorLevel)
+	handler := &OpenAIHandler{logger: logger}
+	handler.SetStreamIntervals(time.Minute, 50*time.Millisecond)
+
+	// Chunks arrive faster than the heartbeat but stay buffered, so the
+	// client has seen nothing and still needs keep-alives
+	events := make([]gemini.StreamEvent, 0, 11)
+	for i := 0; i < 10; i++ {
+		events = append(events, textChunk("x"))
+	}
+	events = append(events, gemini.StreamEvent{Done: true})
+	rec := runStreamEvents(t, handler, 20*time.Millisecond, events...)
+
+	if !strings.Contains(rec.Body.String(), ": keep-alive\n\n") {
+		t.Errorf("expected heartbeats while chunks were buffered, got %q", rec.Body.String())
+	}
+}
+
+func TestValidateChatRequest_StreamOptionsRequiresStream(t *testing.T) {
+	handler := &OpenAIHandler{}
+	req := &types.ChatCompletionRequest{
+		Model:         "gpt-4",
+		Messages:      []types.Message{types.NewTextContent("user", "Hello")},
+		StreamOptions: &types.StreamOptions{IncludeUsage: true},
+	}
+
+	appErr := handler.validateChatRequest(req)
+	if appErr == nil || appErr.Param != "stream_options" {
+		t.Fatalf("expected stream_options error without stream, got %v", appErr)
+	}
+
+	req.Stream = true
+	if appErr := handler.validateChatRequest(req); appErr != nil {
+		t.Errorf("expected no error with stream enabled, got %v", appErr)
+	}
+}
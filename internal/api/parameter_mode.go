@@ -0,0 +1,38 @@
+﻿package api
+
+import (
+	"muxueTools/internal/gemini"
+	"muxueTools/internal/storage"
+	"muxueTools/internal/types"
+)
+
+// ==================== Parameter Mode ====================
+
+// resolveParameterMode returns the parameter mode from cfg, overridden by any
+// value stored via the admin API.
+func resolveParameterMode(cfg *types.Config, st *storage.Storage) string {
+	mode := cfg.Advanced.ParameterMode
+	if st != nil {
+		if stored, _ := st.GetConfig("advanced.parameter_mode"); isParameterMode(stored) {
+			mode = stored
+		}
+	}
+	if mode == "" {
+		mode = types.ParameterModeClamp // default
+	}
+	return mode
+}
+
+// isParameterMode reports whether mode is a known parameter mode.
+func isParameterMode(mode string) bool {
+	return mode == types.ParameterModeClamp || mode == types.ParameterModeStrict
+}
+
+// applyParameterMode switches client between clamping and rejecting
+// unsupported parameters.
+func applyParameterMode(client *gemini.Client, mode string) {
+	if client == nil {
+		return
+	}
+	client.SetStrictParameters(mode == types.ParameterModeStrict)
+}
@@ -0,0 +1,172 @@
+﻿// Package api provides HTTP API handlers and routing for MuxueTools.
+package api
+
+import (
+	"strings"
+
+	"muxueTools/internal/gemini"
+	"muxueTools/internal/storage"
+	"muxueTools/internal/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ==================== Model Preset Handler ====================
+
+// PresetHandler handles model preset management API endpoints.
+type PresetHandler struct {
+	storage *storage.Storage
+}
+
+// NewPresetHandler creates a new model preset handler.
+func NewPresetHandler(storage *storage.Storage) *PresetHandler {
+	return &PresetHandler{
+		storage: storage,
+	}
+}
+
+// listModelPresets returns a getter reading the model presets from storage.
+func listModelPresets(store *storage.Storage, logger *logrus.Logger) gemini.PresetsGetter {
+	return func() []types.ModelPreset {
+		presets, err := store.ListModelPresets()
+		if err != nil {
+			logger.WithError(err).Warn("Failed to load model presets")
+			return nil
+		}
+		return presets
+	}
+}
+
+// ListPresets handles GET /api/model-presets - List all model presets.
+func (h *PresetHandler) ListPresets(c *gin.Context) {
+	presets, err := h.storage.ListModelPresets()
+	if err != nil {
+		RespondInternalError(c, "Failed to list model presets")
+		return
+	}
+	RespondSuccess(c, presets)
+}
+
+// CreatePreset handles POST /api/model-presets - Create a model preset.
+func (h *PresetHandler) CreatePreset(c *gin.Context) {
+	var req types.CreateModelPresetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondBadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	preset := &types.ModelPreset{
+		Name:        strings.TrimSpace(req.Name),
+		Model:       strings.TrimSpace(req.Model),
+		Description: req.Description,
+		Settings:    req.Settings,
+	}
+	if !h.validatePreset(c, preset) {
+		return
+	}
+
+	if err := h.storage.CreateModelPreset(preset); err != nil {
+		RespondInternalError(c, "Failed to create model preset")
+		return
+	}
+	RespondCreated(c, preset)
+}
+
+// GetPreset handles GET /api/model-presets/:id - Get a model preset.
+func (h *PresetHandler) GetPreset(c *gin.Context) {
+	preset, err := h.storage.GetModelPreset(c.Param("id"))
+	if err != nil {
+		h.respondStorageError(c, err, "Failed to get model preset")
+		return
+	}
+	RespondSuccess(c, preset)
+}
+
+// UpdatePreset handles PUT /api/model-presets/:id - Update a model preset.
+func (h *PresetHandler) UpdatePreset(c *gin.Context) {
+	var req types.UpdateModelPresetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondBadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	preset, err := h.storage.GetModelPreset(c.Param("id"))
+	if err != nil {
+		h.respondStorageError(c, err, "Failed to get model preset")
+		return
+	}
+
+	// Apply updates
+	if req.Name != nil {
+		preset.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Model != nil {
+		preset.Model = strings.TrimSpace(*req.Model)
+	}
+	if req.Description != nil {
+		preset.Description = *req.Description
+	}
+	if req.Settings != nil {
+		preset.Settings = *req.Settings
+	}
+	if !h.validatePreset(c, preset) {
+		return
+	}
+
+	if err := h.storage.UpdateModelPreset(preset); err != nil {
+		h.respondStorageError(c, err, "Failed to update model preset")
+		return
+	}
+	RespondSuccess(c, preset)
+}
+
+// DeletePreset handles DELETE /api/model-presets/:id - Delete a model preset.
+func (h *PresetHandler) DeletePreset(c *gin.Context) {
+	if err := h.storage.DeleteModelPreset(c.Param("id")); err != nil {
+		h.respondStorageError(c, err, "Failed to delete model preset")
+		return
+	}
+	RespondSuccessWithMessage(c, nil, "Model preset deleted successfully")
+}
+
+// validatePreset checks the name, that no other preset uses it, and that the
+// settings are supported by the preset's target model. It responds with 400
+// and returns false if the preset is invalid.
+func (h *PresetHandler) validatePreset(c *gin.Context, preset *types.ModelPreset) bool {
+	if err := gemini.ValidatePresetName(preset.Name); err != nil {
+		RespondError(c, types.NewInvalidRequestError(err.Error()).WithParam("name"))
+		return false
+	}
+	if preset.Model == "" {
+		RespondError(c, types.NewInvalidRequestError("Target model is required").WithParam("model"))
+		return false
+	}
+
+	presets, err := h.storage.ListModelPresets()
+	if err != nil {
+		RespondInternalError(c, "Failed to list model presets")
+		return false
+	}
+	for _, existing := range presets {
+		if existing.Name == preset.Name && existing.ID != preset.ID {
+			RespondBadRequest(c, "A model preset named "+preset.Name+" already exists")
+			return false
+		}
+	}
+
+	if err := gemini.ValidateModelSettings(gemini.MapModelName(preset.Model), &preset.Settings); err != nil {
+		RespondError(c, types.NewInvalidRequestError("Invalid settings: "+err.Error()).WithParam("settings"))
+		return false
+	}
+	return true
+}
+
+// respondStorageError maps a model preset storage error to a response.
+func (h *PresetHandler) respondStorageError(c *gin.Context, err error, message string) {
+	if err == types.ErrModelPresetNotFound {
+		RespondNotFound(c, "Model preset")
+		return
+	}
+	RespondInternalError(c, message)
+}
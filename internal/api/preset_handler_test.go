@@ -0,0 +1,63 @@
+﻿// Package api provides HTTP API handlers and routing for MuxueTools.
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"muxueTools/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestModelPresets_CRUD(t *testing.T) {
+	store, err := storage.NewStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	handler := NewPresetHandler(store)
+	engine := gin.New()
+	engine.GET("/api/model-presets", handler.ListPresets)
+	engine.POST("/api/model-presets", handler.CreatePreset)
+	engine.PUT("/api/model-presets/:id", handler.UpdatePreset)
+
+	w := serveJSONRequest(engine, "POST", "/api/model-presets",
+		`{"name":"creative","model":"gemini-2.5-pro","settings":{"temperature":1.5,"safety_preset":"block_none"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"duplicate name", `{"name":"creative","model":"gemini-2.5-flash"}`},
+		{"separator in name", `{"name":"pro:creative","model":"gemini-2.5-pro"}`},
+		{"missing model", `{"name":"coder"}`},
+		{"unsupported setting", `{"name":"lite","model":"gemini-2.5-flash-lite","settings":{"thinking_level":"HIGH"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveJSONRequest(engine, "POST", "/api/model-presets", tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	presets, _ := store.ListModelPresets()
+	if len(presets) != 1 {
+		t.Fatalf("Expected 1 preset, got %d", len(presets))
+	}
+	w = serveJSONRequest(engine, "PUT", "/api/model-presets/"+presets[0].ID, `{"model":"gemini-2.5-flash"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	updated, _ := store.GetModelPreset(presets[0].ID)
+	if updated.Model != "gemini-2.5-flash" || updated.Settings.Temperature == nil {
+		t.Errorf("Expected only the model to change, got %+v", updated)
+	}
+}
@@ -0,0 +1,154 @@
+﻿// Package api provides HTTP API handlers and routing for MuxueTools.
+package api
+
+import (
+	"strings"
+
+	"muxueTools/internal/gemini"
+	"muxueTools/internal/storage"
+	"muxueTools/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ==================== Model Profile Handler ====================
+
+// ProfileHandler handles model profile management API endpoints.
+type ProfileHandler struct {
+	storage *storage.Storage
+}
+
+// NewProfileHandler creates a new model profile handler.
+func NewProfileHandler(storage *storage.Storage) *ProfileHandler {
+	return &ProfileHandler{
+		storage: storage,
+	}
+}
+
+// ListProfiles handles GET /api/model-profiles - List all model profiles.
+func (h *ProfileHandler) ListProfiles(c *gin.Context) {
+	profiles, err := h.storage.ListModelProfiles()
+	if err != nil {
+		RespondInternalError(c, "Failed to list model profiles")
+		return
+	}
+	RespondSuccess(c, profiles)
+}
+
+// CreateProfile handles POST /api/model-profiles - Create a model profile.
+func (h *ProfileHandler) CreateProfile(c *gin.Context) {
+	var req types.CreateModelProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondBadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	profile := &types.ModelProfile{
+		Name:     strings.TrimSpace(req.Name),
+		Pattern:  strings.TrimSpace(req.Pattern),
+		Settings: req.Settings,
+	}
+	if profile.Name == "" {
+		profile.Name = profile.Pattern
+	}
+	if !h.validateProfile(c, profile) {
+		return
+	}
+
+	if err := h.storage.CreateModelProfile(profile); err != nil {
+		RespondInternalError(c, "Failed to create model profile")
+		return
+	}
+	RespondCreated(c, profile)
+}
+
+// GetProfile handles GET /api/model-profiles/:id - Get a model profile.
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	profile, err := h.storage.GetModelProfile(c.Param("id"))
+	if err != nil {
+		h.respondStorageError(c, err, "Failed to get model profile")
+		return
+	}
+	RespondSuccess(c, profile)
+}
+
+// UpdateProfile handles PUT /api/model-profiles/:id - Update a model profile.
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	var req types.UpdateModelProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		RespondBadRequest(c, "Invalid request body: "+err.Error())
+		return
+	}
+
+	profile, err := h.storage.GetModelProfile(c.Param("id"))
+	if err != nil {
+		h.respondStorageError(c, err, "Failed to get model profile")
+		return
+	}
+
+	// Apply updates
+	if req.Name != nil {
+		profile.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Pattern != nil {
+		profile.Pattern = strings.TrimSpace(*req.Pattern)
+	}
+	if req.Settings != nil {
+		profile.Settings = *req.Settings
+	}
+	if !h.validateProfile(c, profile) {
+		return
+	}
+
+	if err := h.storage.UpdateModelProfile(profile); err != nil {
+		h.respondStorageError(c, err, "Failed to update model profile")
+		return
+	}
+	RespondSuccess(c, profile)
+}
+
+// DeleteProfile handles DELETE /api/model-profiles/:id - Delete a model profile.
+func (h *ProfileHandler) DeleteProfile(c *gin.Context) {
+	if err := h.storage.DeleteModelProfile(c.Param("id")); err != nil {
+		h.respondStorageError(c, err, "Failed to delete model profile")
+		return
+	}
+	RespondSuccessWithMessage(c, nil, "Model profile deleted successfully")
+}
+
+// validateProfile checks the pattern, that no other profile uses it, and
+// that the settings are supported by the model family the pattern targets.
+// It responds with 400 and returns false if the profile is invalid.
+func (h *ProfileHandler) validateProfile(c *gin.Context, profile *types.ModelProfile) bool {
+	if err := gemini.ValidateProfilePattern(profile.Pattern); err != nil {
+		RespondBadRequest(c, err.Error())
+		return false
+	}
+
+	profiles, err := h.storage.ListModelProfiles()
+	if err != nil {
+		RespondInternalError(c, "Failed to list model profiles")
+		return false
+	}
+	for _, existing := range profiles {
+		if existing.Pattern == profile.Pattern && existing.ID != profile.ID {
+			RespondBadRequest(c, "A model profile for pattern "+profile.Pattern+" already exists")
+			return false
+		}
+	}
+
+	if err := gemini.ValidateModelSettings(gemini.ProfileModel(profile.Pattern), &profile.Settings); err != nil {
+		RespondError(c, types.NewInvalidRequestError("Invalid settings: "+err.Error()).WithParam("settings"))
+		return false
+	}
+	return true
+}
+
+// respondStorageError maps a model profile storage error to a response.
+func (h *ProfileHandler) respondStorageError(c *gin.Context, err error, message string) {
+	if err == types.ErrModelProfileNotFound {
+		RespondNotFound(c, "Model profile")
+		return
+	}
+	RespondInternalError(c, message)
+}
@@ -0,0 +1,112 @@
+﻿// Package api provides HTTP API handlers and routing for MuxueTools.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"muxueTools/internal/storage"
+	"muxueTools/internal/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createProfileTestRouter creates a router serving the model profile endpoints.
+func createProfileTestRouter(t *testing.T) *gin.Engine {
+	store, err := storage.NewStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	handler := NewProfileHandler(store)
+	engine := gin.New()
+	profiles := engine.Group("/api/model-profiles")
+	profiles.GET("", handler.ListProfiles)
+	profiles.POST("", handler.CreateProfile)
+	profiles.GET("/:id", handler.GetProfile)
+	profiles.PUT("/:id", handler.UpdateProfile)
+	profiles.DELETE("/:id", handler.DeleteProfile)
+	return engine
+}
+
+func serveJSONRequest(engine *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestModelProfiles_CRUD(t *testing.T) {
+	engine := createProfileTestRouter(t)
+
+	w := serveJSONRequest(engine, "POST", "/api/model-profiles",
+		`{"name":"Pro","pattern":"gemini-2.5-pro*","settings":{"temperature":0.4,"thinking_level":"HIGH"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Data types.ModelProfile `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	id := created.Data.ID
+
+	// The same pattern cannot be used twice
+	w = serveJSONRequest(engine, "POST", "/api/model-profiles", `{"pattern":"gemini-2.5-pro*"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a duplicate pattern, got %d", w.Code)
+	}
+
+	w = serveJSONRequest(engine, "PUT", "/api/model-profiles/"+id, `{"settings":{"system_prompt":"Be precise."}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serveJSONRequest(engine, "GET", "/api/model-profiles/"+id, "")
+	var got struct {
+		Data types.ModelProfile `json:"data"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Data.Settings.SystemPrompt != "Be precise." || got.Data.Settings.Temperature != nil {
+		t.Errorf("Expected the settings to be replaced, got %+v", got.Data.Settings)
+	}
+
+	w = serveJSONRequest(engine, "DELETE", "/api/model-profiles/"+id, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	w = serveJSONRequest(engine, "GET", "/api/model-profiles/"+id, "")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestModelProfiles_RejectsUnsupportedSettings(t *testing.T) {
+	engine := createProfileTestRouter(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"thinking level on flash-lite", `{"pattern":"gemini-2.5-flash-lite","settings":{"thinking_level":"HIGH"}}`},
+		{"thinking level on 1.5", `{"pattern":"gemini-1.5-pro","settings":{"thinking_level":"LOW"}}`},
+		{"temperature out of range", `{"pattern":"gemini-2.5-pro","settings":{"temperature":3}}`},
+		{"bad pattern", `{"pattern":"gemini-[","settings":{}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveJSONRequest(engine, "POST", "/api/model-profiles", tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
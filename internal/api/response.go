@@ -113,8 +113,18 @@ func NewSSEWriter(c *gin.Context) *SSEWriter {
 	return &SSEWriter{c: c}
 }
 
-// WriteEvent writes a single SSE event.
+// WriteEvent writes a single SSE event and flushes it.
 func (w *SSEWriter) WriteEvent(data []byte) error {
+	if err := w.BufferEvent(data); err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+// BufferEvent writes a single SSE event without flushing, so several events
+// can go out together on the next Flush.
+func (w *SSEWriter) BufferEvent(data []byte) error {
 	_, err := w.c.Writer.Write([]byte("data: "))
 	if err != nil {
 		return err
@@ -124,7 +134,13 @@ func (w *SSEWriter) WriteEvent(data []byte) error {
 		return err
 	}
 	_, err = w.c.Writer.Write([]byte("\n\n"))
-	if err != nil {
+	return err
+}
+
+// WriteComment writes and flushes an SSE comment line, which clients ignore.
+// It is used as a keep-alive heartbeat.
+func (w *SSEWriter) WriteComment(text string) error {
+	if _, err := w.c.Writer.Write([]byte(": " + text + "\n\n")); err != nil {
 		return err
 	}
 	w.c.Writer.Flush()
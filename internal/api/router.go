@@ -2,6 +2,8 @@
 package api
 
 import (
+	"time"
+
 	"muxueTools/internal/gemini"
 	"muxueTools/internal/keypool"
 	"muxueTools/internal/storage"
@@ -44,8 +46,16 @@ func NewRouter(cfg *RouterConfig) *gin.Engine {
 
 	// Create handlers
 	openaiHandler := NewOpenAIHandler(cfg.Client, cfg.Pool, cfg.Logger)
+	openaiHandler.SetStreamIntervals(
+		time.Duration(cfg.Config.Advanced.StreamFlushInterval)*time.Millisecond,
+		time.Duration(cfg.Config.Advanced.StreamHeartbeatInterval)*time.Second,
+	)
+	if cfg.Storage != nil {
+		openaiHandler.SetPresets(listModelPresets(cfg.Storage, cfg.Logger))
+	}
 	healthHandler := NewHealthHandler(cfg.Pool, cfg.Version)
 	adminHandler := NewAdminHandler(cfg.Pool, cfg.Logger, cfg.Storage)
+	adminHandler.SetClient(cfg.Client)
 
 	// ==================== OpenAI Compatible Routes ====================
 	// Apply IP whitelist middleware to protect API endpoints
@@ -70,12 +80,21 @@ func NewRouter(cfg *RouterConfig) *gin.Engine {
 		keys := api.Group("/keys")
 		{
 			keys.GET("", adminHandler.ListKeys)
+			keys.GET("/expiring", adminHandler.ListExpiringKeys)
+			keys.GET("/explain", adminHandler.ExplainKeySelection)
 			keys.POST("", adminHandler.AddKey)
 			keys.DELETE("/:id", adminHandler.DeleteKey)
+			keys.PATCH("/:id", adminHandler.UpdateKey)
+			keys.POST("/bulk", adminHandler.BulkUpdateKeys)
+			keys.POST("/test", adminHandler.BulkTestKeys)
 			keys.POST("/:id/test", adminHandler.TestKey)
+			keys.PUT("/:id/models", adminHandler.UpdateKeyModels)
+			keys.POST("/:id/models/detect", adminHandler.DetectKeyModels)
 			keys.POST("/validate", adminHandler.ValidateKey)
 			keys.POST("/import", adminHandler.ImportKeys)
 			keys.GET("/export", adminHandler.ExportKeys)
+			keys.POST("/export", adminHandler.ExportKeyBundle)
+			keys.POST("/reconcile", adminHandler.ReconcileKeys)
 		}
 
 		// Models
@@ -91,12 +110,13 @@ func NewRouter(cfg *RouterConfig) *gin.Engine {
 		// Configuration
 		api.GET("/config", adminHandler.GetConfig)
 		api.PUT("/config", adminHandler.UpdateConfig)
+		api.POST("/config/reload", adminHandler.ReloadConfig)
 		api.POST("/config/regenerate-proxy-key", adminHandler.RegenerateProxyKey)
 
 		// Update check
 		api.GET("/update/check", adminHandler.CheckUpdate)
 
-		// Session management (only if storage is configured)
+		// Session, model profile and preset management (only if storage is configured)
 		if cfg.Storage != nil {
 			sessionHandler := NewSessionHandler(cfg.Storage)
 			sessions := api.Group("/sessions")
@@ -109,6 +129,26 @@ func NewRouter(cfg *RouterConfig) *gin.Engine {
 				sessions.DELETE("/:id", sessionHandler.DeleteSession)
 				sessions.POST("/:id/messages", sessionHandler.AddMessage)
 			}
+
+			profileHandler := NewProfileHandler(cfg.Storage)
+			profiles := api.Group("/model-profiles")
+			{
+				profiles.GET("", profileHandler.ListProfiles)
+				profiles.POST("", profileHandler.CreateProfile)
+				profiles.GET("/:id", profileHandler.GetProfile)
+				profiles.PUT("/:id", profileHandler.UpdateProfile)
+				profiles.DELETE("/:id", profileHandler.DeleteProfile)
+			}
+
+			presetHandler := NewPresetHandler(cfg.Storage)
+			presets := api.Group("/model-presets")
+			{
+				presets.GET("", presetHandler.ListPresets)
+				presets.POST("", presetHandler.CreatePreset)
+				presets.GET("/:id", presetHandler.GetPreset)
+				presets.PUT("/:id", presetHandler.UpdatePreset)
+				presets.DELETE("/:id", presetHandler.DeletePreset)
+			}
 		}
 	}
 
@@ -175,12 +215,21 @@ func SetupAdminRoutes(group *gin.RouterGroup, handler *AdminHandler) {
 	keys := group.Group("/keys")
 	{
 		keys.GET("", handler.ListKeys)
+		keys.GET("/expiring", handler.ListExpiringKeys)
+		keys.GET("/explain", handler.ExplainKeySelection)
 		keys.POST("", handler.AddKey)
 		keys.DELETE("/:id", handler.DeleteKey)
+		keys.PATCH("/:id", handler.UpdateKey)
+		keys.POST("/bulk", handler.BulkUpdateKeys)
+		keys.POST("/test", handler.BulkTestKeys)
 		keys.POST("/:id/test", handler.TestKey)
+		keys.PUT("/:id/models", handler.UpdateKeyModels)
+		keys.POST("/:id/models/detect", handler.DetectKeyModels)
 		keys.POST("/validate", handler.ValidateKey)
 		keys.POST("/import", handler.ImportKeys)
 		keys.GET("/export", handler.ExportKeys)
+		keys.POST("/export", handler.ExportKeyBundle)
+		keys.POST("/reconcile", handler.ReconcileKeys)
 	}
 
 	// Statistics
@@ -192,6 +241,7 @@ func SetupAdminRoutes(group *gin.RouterGroup, handler *AdminHandler) {
 	// Configuration
 	group.GET("/config", handler.GetConfig)
 	group.PUT("/config", handler.UpdateConfig)
+	group.POST("/config/reload", handler.ReloadConfig)
 
 	// Update check
 	group.GET("/update/check", handler.CheckUpdate)
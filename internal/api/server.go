@@ -3,9 +3,12 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"time"
 
 	"muxueTools/internal/config"
@@ -31,6 +34,8 @@ type Server struct {
 	logger     *logrus.Logger
 	version    string
 	webRoot    string // Path to static web files (for desktop mode)
+
+	stopLifecycle context.CancelFunc // Stops the key lifecycle scheduler
 }
 
 // ServerOption is a functional option for configuring the Server.
@@ -57,6 +62,45 @@ func WithWebRoot(path string) ServerOption {
 	}
 }
 
+// errKEKUnavailable wraps failures to load the configured key-encryption key.
+var errKEKUnavailable = errors.New("failed to load key-encryption key")
+
+// OpenStorage opens the configured database with API keys encrypted under
+// the configured key-encryption key. Without one, a key file is generated
+// next to the database and a warning is logged: a copy of the data directory
+// then holds everything needed to decrypt the keys.
+func OpenStorage(cfg types.DatabaseConfig, logger *logrus.Logger) (*storage.Storage, error) {
+	dbPath := config.GetEffectiveDatabasePath(cfg.Path)
+	keyFile := filepath.Join(filepath.Dir(dbPath), storage.DefaultKEKFileName)
+	generated := false
+	if !cfg.Encryption.IsSet() {
+		logger.WithField("key_file", keyFile).Warn("No key-encryption key configured: API keys are encrypted with a key file stored next to the database, " +
+			"so anyone with a copy of the data directory can decrypt them. Move the key file elsewhere and set database.encryption.key_file, " +
+			"or set MXLN_DATABASE_ENCRYPTION_KEY or MXLN_DATABASE_ENCRYPTION_PASSPHRASE")
+		_, err := os.Stat(keyFile)
+		generated = os.IsNotExist(err)
+	}
+	kek, err := storage.ResolveKEK(cfg.Encryption, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errKEKUnavailable, err)
+	}
+
+	st, err := storage.NewStorage(dbPath, storage.WithKEK(kek))
+	if errors.Is(err, storage.ErrWrongKEK) && generated {
+		// The database was encrypted under a key file that has gone missing;
+		// a fresh one can never open it, so do not leave it behind
+		os.Remove(keyFile)
+		return nil, fmt.Errorf("%w: %s is missing, restore it or configure the original key", err, keyFile)
+	}
+	return st, err
+}
+
+// isKeyEncryptionError reports whether err means the stored API keys cannot
+// be decrypted, as opposed to the database being unavailable.
+func isKeyEncryptionError(err error) bool {
+	return errors.Is(err, storage.ErrWrongKEK) || errors.Is(err, errKEKUnavailable)
+}
+
 // NewServer creates a new MuxueTools server.
 func NewServer(cfg *types.Config, opts ...ServerOption) (*Server, error) {
 	// Create server with defaults
@@ -80,7 +124,12 @@ func NewServer(cfg *types.Config, opts ...ServerOption) (*Server, error) {
 	// - Portable mode (uses ./data/muxuetools.db)
 	// - Platform-specific path (default)
 	dbPath := config.GetEffectiveDatabasePath(cfg.Database.Path)
-	st, err := storage.NewStorage(dbPath)
+	st, err := OpenStorage(cfg.Database, server.logger)
+	if err != nil && isKeyEncryptionError(err) {
+		// Running without the database would hide every stored key and
+		// drop edits on restart
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
 	if err != nil {
 		server.logger.WithError(err).Warn("Failed to initialize storage, running in memory-only mode")
 	} else {
@@ -95,19 +144,28 @@ func NewServer(cfg *types.Config, opts ...ServerOption) (*Server, error) {
 	}
 	server.pool = pool
 
+	// Retire expired keys in the background until Shutdown
+	lifecycleCtx, stopLifecycle := context.WithCancel(context.Background())
+	server.stopLifecycle = stopLifecycle
+	go pool.RunLifecycle(lifecycleCtx, time.Duration(cfg.Pool.LifecycleCheckSeconds)*time.Second)
+
 	// Initialize Gemini client
 	clientOpts := []gemini.ClientOption{
-		gemini.WithRequestTimeout(time.Duration(cfg.Advanced.RequestTimeout) * time.Second),
+		gemini.WithProxy(cfg.Advanced.Proxy),
+		gemini.WithKeyProxyResolver(pool.ProxyFor),
 	}
 
 	// Add model settings getter if storage is available
 	if server.storage != nil {
-		clientOpts = append(clientOpts, gemini.WithModelSettings(func() *types.ModelSettingsConfig {
-			return server.getModelSettings()
-		}))
+		clientOpts = append(clientOpts,
+			gemini.WithModelSettings(server.getModelSettings),
+			gemini.WithPresets(listModelPresets(server.storage, server.logger)),
+		)
 	}
 
 	server.client = gemini.NewClient(pool, clientOpts...)
+	resolveTimeouts(cfg, server.storage).apply(server.client)
+	applyParameterMode(server.client, resolveParameterMode(cfg, server.storage))
 
 	// Create router
 	routerConfig := &RouterConfig{
@@ -126,7 +184,7 @@ func NewServer(cfg *types.Config, opts ...ServerOption) (*Server, error) {
 		Addr:         cfg.Server.Addr(),
 		Handler:      server.engine,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 120 * time.Second, // Longer for streaming
+		WriteTimeout: 0, // Upstream calls carry their own timeouts; a cap here would cut long streams
 		IdleTimeout:  120 * time.Second,
 	}
 
@@ -134,7 +192,10 @@ func NewServer(cfg *types.Config, opts ...ServerOption) (*Server, error) {
 }
 
 // configureLogger sets up the logger based on configuration.
+// Secrets are redacted from every entry.
 func (s *Server) configureLogger() {
+	addRedactHook(s.logger)
+
 	// Set log level
 	switch s.config.Logging.Level {
 	case types.LogLevelDebug:
@@ -185,6 +246,8 @@ func (s *Server) initializePool() (*keypool.Pool, error) {
 		strategy = keypool.NewLeastUsedStrategy()
 	case types.PoolStrategyWeighted:
 		strategy = keypool.NewWeightedStrategy()
+	case types.PoolStrategyLatencyAware:
+		strategy = keypool.NewLatencyAwareStrategy()
 	default:
 		strategy = keypool.NewRoundRobinStrategy()
 	}
@@ -193,6 +256,16 @@ func (s *Server) initializePool() (*keypool.Pool, error) {
 	poolOpts := []keypool.PoolOption{
 		keypool.WithStrategy(strategy),
 		keypool.WithCooldownSeconds(s.config.Pool.CooldownSeconds),
+		keypool.WithStatsWindow(s.config.Pool.StatsWindow),
+		keypool.WithKeyGroups(s.config.Pool.Groups),
+		keypool.WithGroupBindings(s.config.Pool.GroupBindings),
+		keypool.WithDefaultGroup(s.config.Pool.DefaultGroup),
+		keypool.WithAffinityTTL(time.Duration(s.config.Pool.AffinityTTLSeconds) * time.Second),
+		keypool.WithKeyEventHandler(s.logKeyEvent),
+		keypool.WithCooldownBackoff(s.config.Pool.CooldownBackoff),
+	}
+	if s.config.Pool.ModelCooldownSeconds > 0 {
+		poolOpts = append(poolOpts, keypool.WithModelCooldownSeconds(s.config.Pool.ModelCooldownSeconds))
 	}
 
 	// Add storage if available
@@ -200,34 +273,53 @@ func (s *Server) initializePool() (*keypool.Pool, error) {
 		poolOpts = append(poolOpts, keypool.WithStorage(s.storage))
 	}
 
-	// Create pool with configuration
-	pool := keypool.NewPool(s.config.Keys, poolOpts...)
-
-	// If storage is configured, sync config keys to DB and load from DB
-	if s.storage != nil {
-		// Sync config keys to storage (first-time setup)
-		synced, err := pool.SyncConfigToStorage(s.config.Keys)
-		if err != nil {
-			s.logger.WithError(err).Warn("Failed to sync config keys to storage")
-		} else if synced > 0 {
-			s.logger.WithField("synced", synced).Info("Synced config keys to storage")
-		}
+	// Without storage the config file is the only key source
+	if s.storage == nil {
+		pool := keypool.NewPool(s.config.Keys, poolOpts...)
+		s.logger.WithFields(logrus.Fields{
+			"key_count": pool.Size(),
+			"strategy":  s.config.Pool.Strategy,
+			"storage":   false,
+		}).Info("Key pool initialized")
+		return pool, nil
+	}
 
-		// Load all keys from storage
-		if err := pool.LoadFromStorage(); err != nil {
-			s.logger.WithError(err).Warn("Failed to load keys from storage")
-		}
+	// Load keys from storage, then reconcile them with the config file
+	pool := keypool.NewPool(nil, poolOpts...)
+	if err := pool.LoadFromStorage(); err != nil {
+		s.logger.WithError(err).Warn("Failed to load keys from storage")
 	}
+	s.reconcileKeys(pool, s.config.Keys, s.config.Pool.ReconcilePolicy)
 
 	s.logger.WithFields(logrus.Fields{
 		"key_count": pool.Size(),
 		"strategy":  s.config.Pool.Strategy,
-		"storage":   s.storage != nil,
+		"storage":   true,
 	}).Info("Key pool initialized")
 
 	return pool, nil
 }
 
+// logKeyEvent logs a key lifecycle event from the pool.
+func (s *Server) logKeyEvent(event types.KeyEvent) {
+	s.logger.WithFields(logrus.Fields{
+		"event":  event.Type,
+		"key_id": event.KeyID,
+		"key":    event.MaskedKey,
+		"name":   event.Name,
+	}).Warn(event.Message)
+}
+
+// reconcileKeys reconciles the pool with the config file keys and logs the result.
+func (s *Server) reconcileKeys(pool *keypool.Pool, keys []types.KeyConfig, policy types.ReconcilePolicy) {
+	report, err := pool.Reconcile(keys, policy, false)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to reconcile keys")
+		return
+	}
+	logReconcileReport(s.logger, report)
+}
+
 // Run starts the HTTP server.
 func (s *Server) Run() error {
 	s.logger.WithFields(logrus.Fields{
@@ -247,6 +339,10 @@ func (s *Server) Run() error {
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logger.Info("Shutting down server...")
 
+	if s.stopLifecycle != nil {
+		s.stopLifecycle()
+	}
+
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		return fmt.Errorf("server shutdown error: %w", err)
 	}
@@ -299,8 +395,27 @@ func (s *Server) Storage() *storage.Storage {
 	return s.storage
 }
 
-// getModelSettings reads model settings from storage.
-func (s *Server) getModelSettings() *types.ModelSettingsConfig {
+// getModelSettings returns the global model settings layered with the model
+// profile matching model, if any.
+func (s *Server) getModelSettings(model string) *types.ModelSettingsConfig {
+	settings := s.getGlobalModelSettings()
+	if settings == nil {
+		return nil
+	}
+
+	profiles, err := s.storage.ListModelProfiles()
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to load model profiles")
+		return settings
+	}
+	if profile := gemini.MatchModelProfile(profiles, model); profile != nil {
+		settings = gemini.MergeModelSettings(settings, &profile.Settings)
+	}
+	return settings
+}
+
+// getGlobalModelSettings reads the global model settings from storage.
+func (s *Server) getGlobalModelSettings() *types.ModelSettingsConfig {
 	if s.storage == nil {
 		return nil
 	}
@@ -350,6 +465,17 @@ func (s *Server) getModelSettings() *types.ModelSettingsConfig {
 		settings.MediaResolution = &resolution
 	}
 
+	// Read Safety Settings; thresholds and per-model overrides are stored as JSON
+	if preset, _ := s.storage.GetConfig("model_settings.safety_preset"); preset != "" {
+		settings.SafetyPreset = &preset
+	}
+	if thresholds, _ := s.storage.GetConfig("model_settings.safety_thresholds"); thresholds != "" {
+		_ = json.Unmarshal([]byte(thresholds), &settings.SafetyThresholds)
+	}
+	if modelSafety, _ := s.storage.GetConfig("model_settings.model_safety"); modelSafety != "" {
+		_ = json.Unmarshal([]byte(modelSafety), &settings.ModelSafety)
+	}
+
 	return settings
 }
 
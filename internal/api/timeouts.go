@@ -0,0 +1,81 @@
+﻿package api
+
+import (
+	"strconv"
+	"time"
+
+	"muxueTools/internal/gemini"
+	"muxueTools/internal/storage"
+	"muxueTools/internal/types"
+)
+
+// ==================== Upstream Timeouts ====================
+
+// upstreamTimeouts are the upstream timeouts in seconds.
+type upstreamTimeouts struct {
+	Request         int
+	StreamFirstByte int
+	StreamIdle      int
+}
+
+// resolveTimeouts returns the upstream timeouts from cfg, overridden by any
+// values saved through the settings API.
+func resolveTimeouts(cfg *types.Config, st *storage.Storage) upstreamTimeouts {
+	t := upstreamTimeouts{
+		Request:         cfg.Advanced.RequestTimeout,
+		StreamFirstByte: cfg.Advanced.StreamFirstByteTimeout,
+		StreamIdle:      cfg.Advanced.StreamIdleTimeout,
+	}
+	if st != nil {
+		if stored, _ := st.GetConfig("advanced.request_timeout"); stored != "" {
+			if parsed, err := strconv.Atoi(stored); err == nil && parsed > 0 {
+				t.Request = parsed
+			}
+		}
+		if stored, _ := st.GetConfig("advanced.stream_first_byte_timeout"); stored != "" {
+			if parsed, err := strconv.Atoi(stored); err == nil && parsed >= 0 {
+				t.StreamFirstByte = parsed
+			}
+		}
+		if stored, _ := st.GetConfig("advanced.stream_idle_timeout"); stored != "" {
+			if parsed, err := strconv.Atoi(stored); err == nil && parsed >= 0 {
+				t.StreamIdle = parsed
+			}
+		}
+	}
+	if t.Request == 0 {
+		t.Request = 120 // default
+	}
+	return t
+}
+
+// apply hot-swaps the timeouts on client.
+func (t upstreamTimeouts) apply(client *gemini.Client) {
+	if client == nil {
+		return
+	}
+	client.SetTimeouts(
+		time.Duration(t.Request)*time.Second,
+		time.Duration(t.StreamFirstByte)*time.Second,
+		time.Duration(t.StreamIdle)*time.Second,
+	)
+}
+
+// applyTimeouts hot-swaps the timeouts set in update on the Gemini client,
+// keeping the current value of any timeout update leaves out.
+func (h *AdminHandler) applyTimeouts(update *AdvancedConfigUpdate) {
+	if h.client == nil {
+		return
+	}
+	request, firstByte, idle := h.client.Timeouts()
+	if update.RequestTimeout != nil {
+		request = time.Duration(*update.RequestTimeout) * time.Second
+	}
+	if update.StreamFirstByteTimeout != nil {
+		firstByte = time.Duration(*update.StreamFirstByteTimeout) * time.Second
+	}
+	if update.StreamIdleTimeout != nil {
+		idle = time.Duration(*update.StreamIdleTimeout) * time.Second
+	}
+	h.client.SetTimeouts(request, firstByte, idle)
+}
@@ -3,6 +3,7 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,6 +20,7 @@ var (
 	configMu     sync.RWMutex
 	once         sync.Once
 	appVersion   = "dev" // Set at build time via -ldflags
+	reloadFn     func() (*types.Config, error)
 )
 
 // SetVersion sets the application version (called at startup).
@@ -81,6 +83,14 @@ func (l *Loader) setupDefaults() {
 	l.v.SetDefault("pool.strategy", string(defaults.Pool.Strategy))
 	l.v.SetDefault("pool.cooldown_seconds", defaults.Pool.CooldownSeconds)
 	l.v.SetDefault("pool.max_retries", defaults.Pool.MaxRetries)
+	l.v.SetDefault("pool.stats_window", string(defaults.Pool.StatsWindow))
+	l.v.SetDefault("pool.cooldown_backoff.multiplier", defaults.Pool.CooldownBackoff.Multiplier)
+	l.v.SetDefault("pool.cooldown_backoff.max_seconds", defaults.Pool.CooldownBackoff.MaxSeconds)
+	l.v.SetDefault("pool.cooldown_backoff.jitter", defaults.Pool.CooldownBackoff.Jitter)
+	l.v.SetDefault("pool.model_cooldown_seconds", defaults.Pool.ModelCooldownSeconds)
+	l.v.SetDefault("pool.affinity_ttl_seconds", defaults.Pool.AffinityTTLSeconds)
+	l.v.SetDefault("pool.lifecycle_check_seconds", defaults.Pool.LifecycleCheckSeconds)
+	l.v.SetDefault("pool.reconcile_policy", string(defaults.Pool.ReconcilePolicy))
 
 	// Logging defaults
 	l.v.SetDefault("logging.level", string(defaults.Logging.Level))
@@ -89,6 +99,10 @@ func (l *Loader) setupDefaults() {
 
 	// Database defaults
 	l.v.SetDefault("database.path", defaults.Database.Path)
+	// Registered so MXLN_DATABASE_ENCRYPTION_* environment variables are picked up
+	l.v.SetDefault("database.encryption.key", "")
+	l.v.SetDefault("database.encryption.key_file", "")
+	l.v.SetDefault("database.encryption.passphrase", "")
 
 	// Update defaults
 	l.v.SetDefault("update.enabled", defaults.Update.Enabled)
@@ -99,6 +113,11 @@ func (l *Loader) setupDefaults() {
 	l.v.SetDefault("advanced.request_timeout", defaults.Advanced.RequestTimeout)
 	l.v.SetDefault("advanced.stream_flush_interval", defaults.Advanced.StreamFlushInterval)
 	l.v.SetDefault("advanced.stats_retention_days", defaults.Advanced.StatsRetentionDays)
+	l.v.SetDefault("advanced.stream_first_byte_timeout", defaults.Advanced.StreamFirstByteTimeout)
+	l.v.SetDefault("advanced.stream_idle_timeout", defaults.Advanced.StreamIdleTimeout)
+	l.v.SetDefault("advanced.stream_heartbeat_interval", defaults.Advanced.StreamHeartbeatInterval)
+	l.v.SetDefault("advanced.proxy", defaults.Advanced.Proxy)
+	l.v.SetDefault("advanced.parameter_mode", defaults.Advanced.ParameterMode)
 }
 
 // setupEnvBindings configures environment variable bindings.
@@ -172,9 +191,33 @@ func Validate(cfg *types.Config) error {
 	if cfg.Pool.CooldownSeconds < 0 {
 		return fmt.Errorf("pool.cooldown_seconds must be >= 0, got %d", cfg.Pool.CooldownSeconds)
 	}
+	if cfg.Pool.CooldownBackoff.Multiplier < 1 {
+		return fmt.Errorf("pool.cooldown_backoff.multiplier must be >= 1, got %g", cfg.Pool.CooldownBackoff.Multiplier)
+	}
+	if cfg.Pool.CooldownBackoff.MaxSeconds < 0 {
+		return fmt.Errorf("pool.cooldown_backoff.max_seconds must be >= 0, got %d", cfg.Pool.CooldownBackoff.MaxSeconds)
+	}
+	if cfg.Pool.CooldownBackoff.Jitter < 0 || cfg.Pool.CooldownBackoff.Jitter > 1 {
+		return fmt.Errorf("pool.cooldown_backoff.jitter must be between 0 and 1, got %g", cfg.Pool.CooldownBackoff.Jitter)
+	}
 	if cfg.Pool.MaxRetries < 1 {
 		return fmt.Errorf("pool.max_retries must be >= 1, got %d", cfg.Pool.MaxRetries)
 	}
+	if cfg.Pool.StatsWindow != "" && !cfg.Pool.StatsWindow.IsValid() {
+		return fmt.Errorf("pool.stats_window is invalid: %s", cfg.Pool.StatsWindow)
+	}
+	if cfg.Pool.AffinityTTLSeconds < 0 {
+		return fmt.Errorf("pool.affinity_ttl_seconds must be >= 0, got %d", cfg.Pool.AffinityTTLSeconds)
+	}
+	if cfg.Pool.LifecycleCheckSeconds < 0 {
+		return fmt.Errorf("pool.lifecycle_check_seconds must be >= 0, got %d", cfg.Pool.LifecycleCheckSeconds)
+	}
+	if cfg.Pool.ReconcilePolicy != "" && !cfg.Pool.ReconcilePolicy.IsValid() {
+		return fmt.Errorf("pool.reconcile_policy is invalid: %s", cfg.Pool.ReconcilePolicy)
+	}
+	if err := validateKeyGroups(&cfg.Pool); err != nil {
+		return err
+	}
 
 	// Validate logging config
 	if !cfg.Logging.Level.IsValid() {
@@ -186,12 +229,104 @@ func Validate(cfg *types.Config) error {
 		if key.Key == "" {
 			return fmt.Errorf("keys[%d].key cannot be empty", i)
 		}
+		if key.Weight < 0 {
+			return fmt.Errorf("keys[%d].weight must be >= 0, got %d", i, key.Weight)
+		}
+		if msg := key.Limits.Validate(); msg != "" {
+			return fmt.Errorf("keys[%d].%s", i, msg)
+		}
+		if msg := types.ValidateProxyURL(key.Proxy); msg != "" {
+			return fmt.Errorf("keys[%d].proxy: %s", i, msg)
+		}
 	}
 
 	// Validate advanced config
 	if cfg.Advanced.RequestTimeout < 1 {
 		return fmt.Errorf("advanced.request_timeout must be >= 1, got %d", cfg.Advanced.RequestTimeout)
 	}
+	if cfg.Advanced.StreamFirstByteTimeout < 0 {
+		return fmt.Errorf("advanced.stream_first_byte_timeout must be >= 0, got %d", cfg.Advanced.StreamFirstByteTimeout)
+	}
+	if cfg.Advanced.StreamIdleTimeout < 0 {
+		return fmt.Errorf("advanced.stream_idle_timeout must be >= 0, got %d", cfg.Advanced.StreamIdleTimeout)
+	}
+	if cfg.Advanced.StreamFlushInterval < 0 {
+		return fmt.Errorf("advanced.stream_flush_interval must be >= 0, got %d", cfg.Advanced.StreamFlushInterval)
+	}
+	if cfg.Advanced.StreamHeartbeatInterval < 0 {
+		return fmt.Errorf("advanced.stream_heartbeat_interval must be >= 0, got %d", cfg.Advanced.StreamHeartbeatInterval)
+	}
+	if msg := types.ValidateProxyURL(cfg.Advanced.Proxy); msg != "" {
+		return fmt.Errorf("advanced.proxy: %s", msg)
+	}
+	switch cfg.Advanced.ParameterMode {
+	case types.ParameterModeClamp, types.ParameterModeStrict:
+	default:
+		return fmt.Errorf("advanced.parameter_mode must be %q or %q, got %q",
+			types.ParameterModeClamp, types.ParameterModeStrict, cfg.Advanced.ParameterMode)
+	}
+
+	return nil
+}
+
+// validateKeyGroups checks group names, strategies, fallback chains and bindings.
+func validateKeyGroups(pool *types.PoolConfig) error {
+	groups := make(map[string]types.KeyGroupConfig, len(pool.Groups))
+	for i, group := range pool.Groups {
+		if group.Name == "" {
+			return fmt.Errorf("pool.groups[%d].name cannot be empty", i)
+		}
+		if _, exists := groups[group.Name]; exists {
+			return fmt.Errorf("pool.groups[%d].name is duplicated: %s", i, group.Name)
+		}
+		if group.Strategy != "" && !group.Strategy.IsValid() {
+			return fmt.Errorf("pool.groups[%d].strategy is invalid: %s", i, group.Strategy)
+		}
+		if group.CooldownSeconds < 0 {
+			return fmt.Errorf("pool.groups[%d].cooldown_seconds must be >= 0, got %d", i, group.CooldownSeconds)
+		}
+		if msg := types.ValidateProxyURL(group.Proxy); msg != "" {
+			return fmt.Errorf("pool.groups[%d].proxy: %s", i, msg)
+		}
+		groups[group.Name] = group
+	}
+
+	for i, group := range pool.Groups {
+		if group.Fallback == "" {
+			continue
+		}
+		if _, exists := groups[group.Fallback]; !exists {
+			return fmt.Errorf("pool.groups[%d].fallback references unknown group: %s", i, group.Fallback)
+		}
+		// Walk the chain to reject cycles
+		seen := map[string]bool{group.Name: true}
+		for next := group.Fallback; next != ""; next = groups[next].Fallback {
+			if seen[next] {
+				return fmt.Errorf("pool.groups[%d].fallback forms a cycle through %s", i, next)
+			}
+			seen[next] = true
+		}
+	}
+
+	if pool.DefaultGroup != "" {
+		if _, exists := groups[pool.DefaultGroup]; !exists {
+			return fmt.Errorf("pool.default_group references unknown group: %s", pool.DefaultGroup)
+		}
+	}
+
+	for i, binding := range pool.GroupBindings {
+		if _, exists := groups[binding.Group]; !exists {
+			return fmt.Errorf("pool.group_bindings[%d].group references unknown group: %s", i, binding.Group)
+		}
+		if (binding.Token == "") == (binding.IP == "") {
+			return fmt.Errorf("pool.group_bindings[%d] must set exactly one of token or ip", i)
+		}
+		if binding.IP != "" && net.ParseIP(binding.IP) == nil {
+			if _, _, err := net.ParseCIDR(binding.IP); err != nil {
+				return fmt.Errorf("pool.group_bindings[%d].ip is invalid: %s", i, binding.IP)
+			}
+		}
+	}
 
 	return nil
 }
@@ -226,31 +361,56 @@ func Set(cfg *types.Config) {
 // Init initializes the global configuration.
 // This is typically called once at application startup.
 func Init(paths ...string) error {
-	loader := NewLoader()
+	load := func() (*types.Config, error) {
+		loader := NewLoader()
 
-	// Add custom search paths
-	for _, p := range paths {
-		loader.AddSearchPath(p)
+		// Add custom search paths
+		for _, p := range paths {
+			loader.AddSearchPath(p)
+		}
+		return loader.Load()
 	}
+	return initWith(load)
+}
 
-	cfg, err := loader.Load()
+// InitFromFile initializes the global configuration from a specific file.
+func InitFromFile(path string) error {
+	return initWith(func() (*types.Config, error) {
+		return NewLoader().LoadFromFile(path)
+	})
+}
+
+// initWith loads the configuration and remembers how for Reload.
+func initWith(load func() (*types.Config, error)) error {
+	cfg, err := load()
 	if err != nil {
 		return err
 	}
 
-	Set(cfg)
+	configMu.Lock()
+	defer configMu.Unlock()
+	globalConfig = cfg
+	reloadFn = load
 	return nil
 }
 
-// InitFromFile initializes the global configuration from a specific file.
-func InitFromFile(path string) error {
-	loader := NewLoader()
-	cfg, err := loader.LoadFromFile(path)
+// Reload re-reads the configuration the same way Init or InitFromFile did and
+// replaces the global configuration. The current configuration is kept if the
+// new one fails to load or validate.
+func Reload() (*types.Config, error) {
+	configMu.RLock()
+	load := reloadFn
+	configMu.RUnlock()
+	if load == nil {
+		return nil, fmt.Errorf("config: configuration not initialized, call Init() first")
+	}
+
+	cfg, err := load()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	Set(cfg)
-	return nil
+	return cfg, nil
 }
 
 // Reset clears the global configuration (primarily for testing).
@@ -258,6 +418,7 @@ func Reset() {
 	configMu.Lock()
 	defer configMu.Unlock()
 	globalConfig = nil
+	reloadFn = nil
 }
 
 // ==================== Config File Generation ====================
@@ -10,6 +10,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"muxueTools/internal/types"
@@ -34,16 +35,17 @@ func WithBaseURL(baseURL string) ClientOption {
 	}
 }
 
-// WithRequestTimeout sets the request timeout.
+// WithRequestTimeout sets the total timeout of blocking (non-streaming) calls.
 func WithRequestTimeout(timeout time.Duration) ClientOption {
 	return func(c *Client) {
-		c.requestTimeout = timeout
+		c.timeouts.request = timeout
 	}
 }
 
-// ModelSettingsGetter is a function that returns the current model settings.
+// ModelSettingsGetter is a function that returns the current model settings
+// for a requested model, with any matching model profile applied.
 // This allows the client to get settings without importing storage package.
-type ModelSettingsGetter func() *types.ModelSettingsConfig
+type ModelSettingsGetter func(model string) *types.ModelSettingsConfig
 
 // WithModelSettings sets the model settings getter.
 func WithModelSettings(getter ModelSettingsGetter) ClientOption {
@@ -52,15 +54,26 @@ func WithModelSettings(getter ModelSettingsGetter) ClientOption {
 	}
 }
 
+// PresetsGetter is a function that returns the current model presets.
+type PresetsGetter func() []types.ModelPreset
+
+// WithPresets sets the model presets getter, enabling preset virtual models.
+func WithPresets(getter PresetsGetter) ClientOption {
+	return func(c *Client) {
+		c.presetsGetter = getter
+	}
+}
+
 // ==================== Key Pool Interface ====================
 
 // KeyPoolInterface defines the interface for key pool operations.
 // This allows for easy mocking in tests.
 type KeyPoolInterface interface {
-	GetKey() (*types.Key, error)
+	GetKey(req types.KeyRequest) (*types.Key, error)
 	ReleaseKey(key *types.Key)
 	ReportSuccess(key *types.Key, promptTokens, completionTokens int, model string)
 	ReportFailure(key *types.Key, err error, model string)
+	ReportLatency(key *types.Key, model string, latency time.Duration)
 }
 
 // ==================== Stream Event ====================
@@ -79,17 +92,30 @@ type Client struct {
 	httpClient          *http.Client
 	pool                KeyPoolInterface
 	baseURL             string
-	requestTimeout      time.Duration
+	timeouts            timeouts
 	modelSettingsGetter ModelSettingsGetter
+	presetsGetter       PresetsGetter
+	strictParams        atomic.Bool
+
+	// Upstream proxies: the global one and per-key overrides
+	proxy        string
+	keyProxy     KeyProxyResolver
+	proxyClients proxyClients
 }
 
 // NewClient creates a new Gemini API client.
+// The HTTP client has no timeout of its own: blocking calls are bounded by the
+// request timeout and streams by the first-byte and idle timeouts.
 func NewClient(pool KeyPoolInterface, opts ...ClientOption) *Client {
 	client := &Client{
-		httpClient:     &http.Client{Timeout: 60 * time.Second},
-		pool:           pool,
-		baseURL:        types.GeminiBaseURL,
-		requestTimeout: 120 * time.Second,
+		httpClient: &http.Client{},
+		pool:       pool,
+		baseURL:    types.GeminiBaseURL,
+		timeouts: timeouts{
+			request:         120 * time.Second,
+			streamFirstByte: 120 * time.Second,
+			streamIdle:      60 * time.Second,
+		},
 	}
 
 	for _, opt := range opts {
@@ -108,41 +134,51 @@ func (c *Client) ChatCompletion(ctx context.Context, req *types.ChatCompletionRe
 		return nil, types.NewInvalidRequestError("Request cannot be nil")
 	}
 
-	// 1. Get a key from the pool
-	key, err := c.pool.GetKey()
+	// 1. Resolve presets, check parameters and convert OpenAI request to
+	// Gemini format before a key is taken, so invalid requests use no quota
+	target, presetSettings := c.resolvePreset(req)
+	target, err := NormalizeRequestParams(target, c.StrictParameters())
 	if err != nil {
 		return nil, err
 	}
-	defer c.pool.ReleaseKey(key)
-
-	// 2. Convert OpenAI request to Gemini format
-	geminiReq, err := ConvertOpenAIRequest(req)
+	geminiReq, err := ConvertOpenAIRequest(target)
 	if err != nil {
 		return nil, err
 	}
 
-	// 2.5. Apply global model settings if available
-	if c.modelSettingsGetter != nil {
-		ApplyModelSettings(geminiReq, c.modelSettingsGetter())
-	}
+	// 2. Apply model settings, profiles, presets and safety overrides
+	ApplyModelSettings(geminiReq, MergeModelSettings(c.modelSettings(target.Model), presetSettings), target)
 
-	// 3. Map model name
-	geminiModel := MapModelName(req.Model)
+	// 3. Map model name and get a key that may serve it
+	geminiModel := MapModelName(target.Model)
+	key, err := c.pool.GetKey(types.KeyRequest{
+		Model:    geminiModel,
+		Group:    types.KeyGroupFromContext(ctx),
+		Affinity: types.KeyAffinityFromContext(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer c.pool.ReleaseKey(key)
 
 	// 4. Build URL
-	url := c.buildURL(geminiModel, key.APIKey, false)
+	url := c.buildURL(geminiModel, false)
 
-	// 5. Send HTTP request
-	geminiResp, err := c.doRequest(ctx, url, geminiReq)
+	// 5. Send HTTP request within the request timeout
+	ctx, cancel := c.withRequestTimeout(ctx)
+	defer cancel()
+	start := time.Now()
+	geminiResp, err := c.doRequest(ctx, c.httpClientForKey(key), url, key.APIKey, geminiReq)
 	if err != nil {
-		c.pool.ReportFailure(key, err, req.Model)
+		c.pool.ReportFailure(key, err, geminiModel)
 		return nil, err
 	}
+	c.pool.ReportLatency(key, geminiModel, time.Since(start))
 
 	// 6. Convert Gemini response to OpenAI format
 	openAIResp, err := ConvertGeminiResponse(geminiResp, req.Model)
 	if err != nil {
-		c.pool.ReportFailure(key, err, req.Model)
+		c.pool.ReportFailure(key, err, geminiModel)
 		return nil, err
 	}
 
@@ -153,7 +189,7 @@ func (c *Client) ChatCompletion(ctx context.Context, req *types.ChatCompletionRe
 		promptTokens = geminiResp.UsageMetadata.PromptTokenCount
 		completionTokens = geminiResp.UsageMetadata.CandidatesTokenCount
 	}
-	c.pool.ReportSuccess(key, promptTokens, completionTokens, req.Model)
+	c.pool.ReportSuccess(key, promptTokens, completionTokens, geminiModel)
 
 	return openAIResp, nil
 }
@@ -168,84 +204,126 @@ func (c *Client) ChatCompletionStream(ctx context.Context, req *types.ChatComple
 		return nil, types.NewInvalidRequestError("Request cannot be nil")
 	}
 
-	// 1. Get a key from the pool
-	key, err := c.pool.GetKey()
+	// 1. Resolve presets, check parameters and convert OpenAI request to
+	// Gemini format before a key is taken, so invalid requests use no quota
+	target, presetSettings := c.resolvePreset(req)
+	target, err := NormalizeRequestParams(target, c.StrictParameters())
 	if err != nil {
 		return nil, err
 	}
-
-	// 2. Convert OpenAI request to Gemini format
-	geminiReq, err := ConvertOpenAIRequest(req)
+	geminiReq, err := ConvertOpenAIRequest(target)
 	if err != nil {
-		c.pool.ReleaseKey(key)
 		return nil, err
 	}
 
-	// 2.5. Apply global model settings if available
-	if c.modelSettingsGetter != nil {
-		ApplyModelSettings(geminiReq, c.modelSettingsGetter())
-	}
-
-	// 3. Map model name
-	geminiModel := MapModelName(req.Model)
+	// 2. Apply model settings, profiles, presets and safety overrides
+	ApplyModelSettings(geminiReq, MergeModelSettings(c.modelSettings(target.Model), presetSettings), target)
 
-	// 4. Build URL with streaming endpoint
-	url := c.buildURL(geminiModel, key.APIKey, true)
-
-	// 5. Marshal request body
+	// 3. Marshal request body
 	body, err := json.Marshal(geminiReq)
 	if err != nil {
-		c.pool.ReleaseKey(key)
 		return nil, types.NewInternalError("Failed to marshal request").WithCause(err)
 	}
 
-	// 6. Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	// 4. Map model name and get a key that may serve it
+	geminiModel := MapModelName(target.Model)
+	key, err := c.pool.GetKey(types.KeyRequest{
+		Model:    geminiModel,
+		Group:    types.KeyGroupFromContext(ctx),
+		Affinity: types.KeyAffinityFromContext(ctx),
+	})
 	if err != nil {
+		return nil, err
+	}
+
+	// 5. Build URL with streaming endpoint
+	url := c.buildURL(geminiModel, true)
+
+	// 6. Create HTTP request, watched by the first-byte and idle timeouts
+	streamCtx, cancel := context.WithCancelCause(ctx)
+	_, firstByte, idle := c.Timeouts()
+	watchdog := newStreamWatchdog(cancel, firstByte, idle)
+	httpReq, err := newUpstreamRequest(streamCtx, http.MethodPost, url, key.APIKey, bytes.NewReader(body))
+	if err != nil {
+		watchdog.stop()
 		c.pool.ReleaseKey(key)
 		return nil, types.NewInternalError("Failed to create request").WithCause(err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 
 	// 7. Send request
-	resp, err := c.httpClient.Do(httpReq)
+	start := time.Now()
+	resp, err := c.httpClientForKey(key).Do(httpReq)
 	if err != nil {
-		c.pool.ReportFailure(key, err, req.Model) // Report failure BEFORE releasing
+		appErr := c.wrapHTTPError(streamCtx, err)
+		watchdog.stop()
+		c.pool.ReportFailure(key, appErr, geminiModel) // Report failure BEFORE releasing
 		c.pool.ReleaseKey(key)
-		return nil, c.wrapHTTPError(err)
+		return nil, appErr
 	}
 
 	// 8. Check for error status codes
 	if resp.StatusCode != http.StatusOK {
 		defer resp.Body.Close()
 		appErr := c.parseErrorResponse(resp)
-		c.pool.ReportFailure(key, appErr, req.Model) // Report failure BEFORE releasing
+		watchdog.stop()
+		c.pool.ReportFailure(key, appErr, geminiModel) // Report failure BEFORE releasing
 		c.pool.ReleaseKey(key)
 		return nil, appErr
 	}
 
+	// Time to first byte is the latency signal for streaming requests
+	c.pool.ReportLatency(key, geminiModel, time.Since(start))
+
 	// 9. Create output channel and start streaming goroutine
 	eventChan := make(chan StreamEvent)
-	go c.streamResponse(ctx, resp, key, req.Model, eventChan)
+	go c.streamResponse(ctx, streamCtx, resp, key, req, geminiModel, watchdog, eventChan)
 
 	return eventChan, nil
 }
 
 // streamResponse reads SSE events from the response and sends them to the channel.
-func (c *Client) streamResponse(ctx context.Context, resp *http.Response, key *types.Key, originalModel string, eventChan chan<- StreamEvent) {
+// req.Model is echoed back to the client; geminiModel is reported to the pool.
+// watchdog is fed every line read and stopped when the stream ends.
+// Errors are sent until clientCtx is done, so a timeout that cancelled ctx is
+// still reported to a client that is listening.
+func (c *Client) streamResponse(clientCtx, ctx context.Context, resp *http.Response, key *types.Key, req *types.ChatCompletionRequest, geminiModel string, watchdog *streamWatchdog, eventChan chan<- StreamEvent) {
 	defer resp.Body.Close()
 	defer c.pool.ReleaseKey(key)
 	defer close(eventChan)
+	defer watchdog.stop()
 
 	reader := bufio.NewReader(resp.Body)
-	chunkIndex := 0
-	var totalPromptTokens, totalCompletionTokens int
+	state := NewStreamState(req.Model)
+	includeUsage := req.StreamOptions != nil && req.StreamOptions.IncludeUsage
+	var usage types.Usage
+
+	// sendUsage sends the usage-only chunk when the client asked for it
+	sendUsage := func() {
+		if !includeUsage {
+			return
+		}
+		sendEvent(ctx, eventChan, StreamEvent{Chunk: state.UsageChunk(usage)})
+	}
+
+	// stopped ends a stream whose ctx is done. Only our own timeouts count
+	// against the key; when the caller cancelled, the key is just released.
+	stopped := func(cause error) {
+		appErr := c.timeoutError(ctx)
+		if appErr == nil {
+			sendEvent(clientCtx, eventChan, StreamEvent{Err: ctx.Err()})
+			return
+		}
+		if cause != nil {
+			appErr = appErr.WithCause(cause)
+		}
+		sendEvent(clientCtx, eventChan, StreamEvent{Err: appErr})
+		c.pool.ReportFailure(key, appErr, geminiModel)
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			eventChan <- StreamEvent{Err: ctx.Err()}
-			c.pool.ReportFailure(key, ctx.Err(), originalModel)
+			stopped(nil)
 			return
 		default:
 		}
@@ -254,13 +332,19 @@ func (c *Client) streamResponse(ctx context.Context, resp *http.Response, key *t
 		if err != nil {
 			if err == io.EOF {
 				// Normal end of stream
-				c.pool.ReportSuccess(key, totalPromptTokens, totalCompletionTokens, originalModel)
+				sendUsage()
+				c.pool.ReportSuccess(key, usage.PromptTokens, usage.CompletionTokens, geminiModel)
 				return
 			}
-			eventChan <- StreamEvent{Err: types.NewUpstreamError("Stream read error").WithCause(err)}
-			c.pool.ReportFailure(key, err, originalModel)
+			if ctx.Err() != nil {
+				stopped(err)
+				return
+			}
+			sendEvent(clientCtx, eventChan, StreamEvent{Err: types.NewUpstreamError("Stream read error").WithCause(err)})
+			c.pool.ReportFailure(key, err, geminiModel)
 			return
 		}
+		watchdog.touch()
 
 		// Trim whitespace
 		line = bytes.TrimSpace(line)
@@ -281,70 +365,183 @@ func (c *Client) streamResponse(ctx context.Context, resp *http.Response, key *t
 		// Parse Gemini response
 		var geminiResp types.GeminiResponse
 		if err := json.Unmarshal(jsonData, &geminiResp); err != nil {
-			eventChan <- StreamEvent{Err: types.NewUpstreamError("Failed to parse stream chunk").WithCause(err)}
-			c.pool.ReportFailure(key, err, originalModel)
+			sendEvent(clientCtx, eventChan, StreamEvent{Err: types.NewUpstreamError("Failed to parse stream chunk").WithCause(err)})
+			c.pool.ReportFailure(key, err, geminiModel)
+			return
+		}
+
+		// A blocked prompt arrives as a single chunk without candidates
+		if len(geminiResp.Candidates) == 0 && geminiResp.IsPromptBlocked() {
+			appErr := types.NewContentFilterError(geminiResp.PromptFeedback.BlockReason, geminiResp.PromptFeedback.SafetyRatings)
+			sendEvent(clientCtx, eventChan, StreamEvent{Err: appErr})
+			c.pool.ReportFailure(key, appErr, geminiModel) // Not held against the key
 			return
 		}
 
 		// Track token usage from final chunk
 		if geminiResp.UsageMetadata != nil {
-			totalPromptTokens = geminiResp.UsageMetadata.PromptTokenCount
-			totalCompletionTokens = geminiResp.UsageMetadata.CandidatesTokenCount
+			usage = geminiResp.UsageMetadata.ToOpenAIUsage()
 		}
 
 		// Convert to OpenAI chunk format
-		openAIChunk, err := ConvertGeminiStreamChunk(&geminiResp, originalModel, chunkIndex)
+		openAIChunk, err := ConvertGeminiStreamChunk(&geminiResp, state)
 		if err != nil {
-			eventChan <- StreamEvent{Err: err}
-			c.pool.ReportFailure(key, err, originalModel)
+			sendEvent(clientCtx, eventChan, StreamEvent{Err: err})
+			c.pool.ReportFailure(key, err, geminiModel)
 			return
 		}
 
-		chunkIndex++
-
-		// Send chunk to channel with context awareness
+		// Send chunk to channel with context awareness; waiting on the
+		// consumer does not count against the idle timeout
+		watchdog.pause()
 		select {
 		case eventChan <- StreamEvent{Chunk: openAIChunk}:
 			// Successfully sent
 		case <-ctx.Done():
-			c.pool.ReportFailure(key, ctx.Err(), originalModel)
+			stopped(nil)
 			return
 		}
+		watchdog.touch()
 
 		// Check if this is the final chunk
 		if len(geminiResp.Candidates) > 0 && geminiResp.Candidates[0].FinishReason != "" {
+			sendUsage()
+
 			// Send Done event to signal completion
-			select {
-			case eventChan <- StreamEvent{Done: true}:
-				// Successfully sent Done
-			case <-ctx.Done():
-				// Context cancelled, but we already sent the content
-			}
-			c.pool.ReportSuccess(key, totalPromptTokens, totalCompletionTokens, originalModel)
+			// A cancelled context only loses Done, the content was already sent
+			sendEvent(ctx, eventChan, StreamEvent{Done: true})
+			c.pool.ReportSuccess(key, usage.PromptTokens, usage.CompletionTokens, geminiModel)
 			return
 		}
 	}
 }
 
+// sendEvent sends ev unless ctx is done first. The stream goroutine must never
+// block on a consumer that has gone away, or its deferred ReleaseKey never runs
+// and the key's concurrency slot leaks. A consumer that is already waiting
+// still gets the event even when ctx is done.
+func sendEvent(ctx context.Context, eventChan chan<- StreamEvent, ev StreamEvent) {
+	select {
+	case eventChan <- ev:
+		return
+	default:
+	}
+	select {
+	case eventChan <- ev:
+	case <-ctx.Done():
+	}
+}
+
+// ==================== Models ====================
+
+// ListModels probes models.list with the given API key and returns the names
+// (without the "models/" prefix) of models that support generateContent.
+// The request goes through the proxy set with types.WithKeyProxy, if any.
+func (c *Client) ListModels(ctx context.Context, apiKey string) ([]string, error) {
+	ctx, cancel := c.withRequestTimeout(ctx)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/models?pageSize=1000", c.baseURL)
+	httpReq, err := newUpstreamRequest(ctx, http.MethodGet, url, apiKey, nil)
+	if err != nil {
+		return nil, types.NewInternalError("Failed to create request").WithCause(err)
+	}
+
+	resp, err := c.HTTPClient(types.KeyProxyFromContext(ctx)).Do(httpReq)
+	if err != nil {
+		return nil, c.wrapHTTPError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, c.parseErrorResponse(resp)
+	}
+
+	var result struct {
+		Models []struct {
+			Name                       string   `json:"name"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		if appErr := c.timeoutError(ctx); appErr != nil {
+			return nil, appErr.WithCause(err)
+		}
+		return nil, types.NewUpstreamError("Failed to parse models response").WithCause(err)
+	}
+
+	models := make([]string, 0, len(result.Models))
+	for _, m := range result.Models {
+		for _, method := range m.SupportedGenerationMethods {
+			if method == "generateContent" {
+				models = append(models, types.NormalizeModelName(m.Name))
+				break
+			}
+		}
+	}
+	return models, nil
+}
+
 // ==================== Internal Helpers ====================
 
-// buildURL constructs the Gemini API URL.
-func (c *Client) buildURL(model, apiKey string, stream bool) string {
+// modelSettings returns the current model settings for model, or nil when
+// no getter is configured.
+func (c *Client) modelSettings(model string) *types.ModelSettingsConfig {
+	if c.modelSettingsGetter == nil {
+		return nil
+	}
+	return c.modelSettingsGetter(model)
+}
+
+// resolvePreset resolves a preset virtual model. It returns a copy of req
+// addressed to the preset's target model together with the preset settings,
+// which layer over the model settings and under the request; req itself is
+// returned when it names no preset. Responses still echo req.Model.
+func (c *Client) resolvePreset(req *types.ChatCompletionRequest) (*types.ChatCompletionRequest, *types.ModelSettingsConfig) {
+	if c.presetsGetter == nil {
+		return req, nil
+	}
+	preset, model := ResolvePreset(c.presetsGetter(), req.Model)
+	if preset == nil {
+		return req, nil
+	}
+	resolved := *req
+	resolved.Model = model
+	return &resolved, &preset.Settings
+}
+
+// buildURL constructs the Gemini API URL. The API key is sent in a header by
+// newUpstreamRequest so it never appears in URLs, and thus in url.Error messages.
+func (c *Client) buildURL(model string, stream bool) string {
 	endpoint := "generateContent"
 	if stream {
 		endpoint = "streamGenerateContent"
 	}
 
-	url := fmt.Sprintf("%s/models/%s:%s?key=%s", c.baseURL, model, endpoint, apiKey)
+	url := fmt.Sprintf("%s/models/%s:%s", c.baseURL, model, endpoint)
 	if stream {
-		url += "&alt=sse"
+		url += "?alt=sse"
 	}
 
 	return url
 }
 
-// doRequest sends an HTTP request and returns the parsed Gemini response.
-func (c *Client) doRequest(ctx context.Context, url string, geminiReq *types.GeminiRequest) (*types.GeminiResponse, error) {
+// newUpstreamRequest creates a Gemini API request authenticated with apiKey.
+// A body, if any, is sent as JSON.
+func newUpstreamRequest(ctx context.Context, method, url, apiKey string, body io.Reader) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set(types.GeminiAPIKeyHeader, apiKey)
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	return httpReq, nil
+}
+
+// doRequest sends an HTTP request with httpClient and returns the parsed Gemini response.
+func (c *Client) doRequest(ctx context.Context, httpClient *http.Client, url, apiKey string, geminiReq *types.GeminiRequest) (*types.GeminiResponse, error) {
 	// Marshal request body
 	body, err := json.Marshal(geminiReq)
 	if err != nil {
@@ -352,16 +549,15 @@ func (c *Client) doRequest(ctx context.Context, url string, geminiReq *types.Gem
 	}
 
 	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	httpReq, err := newUpstreamRequest(ctx, http.MethodPost, url, apiKey, bytes.NewReader(body))
 	if err != nil {
 		return nil, types.NewInternalError("Failed to create request").WithCause(err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 
 	// Send request
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := httpClient.Do(httpReq)
 	if err != nil {
-		return nil, c.wrapHTTPError(err)
+		return nil, c.wrapHTTPError(ctx, err)
 	}
 	defer resp.Body.Close()
 
@@ -373,6 +569,9 @@ func (c *Client) doRequest(ctx context.Context, url string, geminiReq *types.Gem
 	// Parse response body
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
+		if appErr := c.timeoutError(ctx); appErr != nil {
+			return nil, appErr.WithCause(err)
+		}
 		return nil, types.NewUpstreamError("Failed to read response").WithCause(err)
 	}
 
@@ -436,8 +635,13 @@ func (c *Client) mapStatusCodeToError(statusCode int, body string) *types.AppErr
 	}
 }
 
-// wrapHTTPError wraps an HTTP client error in an AppError.
-func (c *Client) wrapHTTPError(err error) *types.AppError {
+// wrapHTTPError wraps an HTTP client error in an AppError. Errors caused by
+// the client's own timeouts on ctx map to their timeout errors.
+func (c *Client) wrapHTTPError(ctx context.Context, err error) *types.AppError {
+	if appErr := c.timeoutError(ctx); appErr != nil {
+		return appErr.WithCause(err)
+	}
+
 	errStr := err.Error()
 
 	// Check for context cancellation
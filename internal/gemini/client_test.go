@@ -4,6 +4,7 @@ package gemini
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -34,8 +35,10 @@ type mockPool struct {
 	mu             sync.Mutex
 	keys           []*types.Key
 	getKeyFunc     func() (*types.Key, error)
+	keyRequests    []types.KeyRequest
 	successReports []successReport
 	failureReports []failureReport
+	inFlight       int
 }
 
 type successReport struct {
@@ -59,20 +62,34 @@ func newMockPool(keys ...*types.Key) *mockPool {
 	}
 }
 
-func (p *mockPool) GetKey() (*types.Key, error) {
+func (p *mockPool) GetKey(req types.KeyRequest) (*types.Key, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.keyRequests = append(p.keyRequests, req)
 	if p.getKeyFunc != nil {
-		return p.getKeyFunc()
+		key, err := p.getKeyFunc()
+		if err == nil {
+			p.inFlight++
+		}
+		return key, err
 	}
 	if len(p.keys) == 0 {
 		return nil, types.ErrNoAvailableKeys
 	}
+	p.inFlight++
 	return p.keys[0], nil
 }
 
 func (p *mockPool) ReleaseKey(key *types.Key) {
-	// No-op for mock
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight--
+}
+
+func (p *mockPool) inFlightCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.inFlight
 }
 
 func (p *mockPool) ReportSuccess(key *types.Key, promptTokens, completionTokens int, model string) {
@@ -96,15 +113,19 @@ func (p *mockPool) ReportFailure(key *types.Key, err error, model string) {
 	})
 }
 
+func (p *mockPool) ReportLatency(key *types.Key, model string, latency time.Duration) {
+	// No-op for mock
+}
+
 // ==================== Test Helpers ====================
 
 // newTestClient creates a Client pointing to a test server.
 func newTestClient(serverURL string, pool KeyPoolInterface) *Client {
 	return &Client{
-		httpClient:     &http.Client{Timeout: 10 * time.Second},
-		pool:           pool,
-		baseURL:        serverURL,
-		requestTimeout: 30 * time.Second,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		pool:       pool,
+		baseURL:    serverURL,
+		timeouts:   timeouts{request: 30 * time.Second},
 	}
 }
 
@@ -157,11 +178,14 @@ func TestClient_ChatCompletion_SimpleText(t *testing.T) {
 			t.Errorf("Expected path containing 'generateContent', got %s", r.URL.Path)
 		}
 
-		// Validate API key in query
-		apiKey := r.URL.Query().Get("key")
+		// Validate API key in header, never in the query
+		apiKey := r.Header.Get("x-goog-api-key")
 		if apiKey != "test-api-key-1234" {
 			t.Errorf("Expected API key 'test-api-key-1234', got '%s'", apiKey)
 		}
+		if r.URL.Query().Has("key") {
+			t.Errorf("API key should not be sent in the query: %s", r.URL.RawQuery)
+		}
 
 		// Return valid response
 		w.Header().Set("Content-Type", "application/json")
@@ -521,6 +545,65 @@ func TestClient_ChatCompletionStream_ContextCancellation(t *testing.T) {
 	}
 }
 
+func TestClient_ChatCompletionStream_ClientGoneReleasesKey(t *testing.T) {
+	// Arrange: Server keeps streaming until the request is torn down
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+
+		flusher := w.(http.Flusher)
+		for {
+			if _, err := w.Write([]byte(`data: {"candidates":[{"content":{"parts":[{"text":"tick"}],"role":"model"},"index":0}]}` + "\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}))
+	defer server.Close()
+
+	pool := newMockPool(mockKey("key1", "test-key"))
+	client := newTestClient(server.URL, pool)
+
+	req := &types.ChatCompletionRequest{
+		Model:    "gpt-4",
+		Messages: []types.Message{types.NewTextContent("user", "Hello")},
+		Stream:   true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	eventChan, err := client.ChatCompletionStream(ctx, req)
+	if err != nil {
+		t.Fatalf("Unexpected initial error: %v", err)
+	}
+
+	// Act: Read one chunk, then disconnect and stop reading
+	if event := <-eventChan; event.Chunk == nil {
+		t.Fatalf("Expected a chunk first, got %+v", event)
+	}
+	cancel()
+
+	// Assert: The stream goroutine releases the key without a reader
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.inFlightCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Key still in flight after client disconnect: inFlight=%d", pool.inFlightCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A client that went away is not the key's fault
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if len(pool.failureReports) != 0 {
+		t.Errorf("Expected no failure reports after client disconnect, got %+v", pool.failureReports)
+	}
+}
+
 // ==================== Model Mapping Tests ====================
 
 func TestClient_ChatCompletion_ModelMapping(t *testing.T) {
@@ -560,6 +643,100 @@ func TestClient_ChatCompletion_ModelMapping(t *testing.T) {
 	}
 }
 
+func TestClient_ChatCompletion_SelectsKeyForResolvedModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(createGeminiResponse("Response", "STOP", 1, 1)))
+	}))
+	defer server.Close()
+
+	pool := newMockPool(mockKey("key1", "test-key"))
+	client := newTestClient(server.URL, pool)
+
+	req := &types.ChatCompletionRequest{
+		Model:    "gpt-4",
+		Messages: []types.Message{types.NewTextContent("user", "Hello")},
+	}
+	if _, err := client.ChatCompletion(context.Background(), req); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(pool.keyRequests) != 1 || pool.keyRequests[0].Model != "gemini-1.5-pro-latest" {
+		t.Errorf("Expected key request for 'gemini-1.5-pro-latest', got %+v", pool.keyRequests)
+	}
+	if len(pool.successReports) != 1 || pool.successReports[0].model != "gemini-1.5-pro-latest" {
+		t.Errorf("Expected success reported for resolved model, got %+v", pool.successReports)
+	}
+}
+
+// ==================== Models List Tests ====================
+
+func TestClient_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/models" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			t.Errorf("Expected API key 'test-key', got '%s'", r.Header.Get("x-goog-api-key"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"models":[
+			{"name":"models/gemini-2.5-pro","supportedGenerationMethods":["generateContent","countTokens"]},
+			{"name":"models/text-embedding-004","supportedGenerationMethods":["embedContent"]}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, newMockPool())
+	models, err := client.ListModels(context.Background(), "test-key")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(models) != 1 || models[0] != "gemini-2.5-pro" {
+		t.Errorf("Expected [gemini-2.5-pro], got %v", models)
+	}
+}
+
+func TestClient_TransportErrorRedactsKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close() // Every request fails at the transport level
+
+	apiKey := "AIzaSyTransportErrorKeyXXXXXXXXXXXXXXX"
+	client := newTestClient(server.URL, newMockPool(mockKey("key1", apiKey)))
+
+	_, err := client.ChatCompletion(context.Background(), &types.ChatCompletionRequest{
+		Model:    "gpt-4",
+		Messages: []types.Message{types.NewTextContent("user", "Hello")},
+	})
+	if err == nil {
+		t.Fatal("Expected a transport error")
+	}
+	if strings.Contains(err.Error(), apiKey) {
+		t.Errorf("Error leaks the API key: %v", err)
+	}
+
+	// Causes that embed a key anyway are redacted
+	appErr := types.NewUpstreamError("HTTP request failed").WithCause(errors.New(`Get "https://example.com/models?key=` + apiKey + `": EOF`))
+	if strings.Contains(appErr.Error(), apiKey) {
+		t.Errorf("AppError leaks the API key: %v", appErr)
+	}
+}
+
+func TestClient_ListModels_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(createGeminiErrorResponse(403, "Permission denied", "PERMISSION_DENIED")))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, newMockPool())
+	_, err := client.ListModels(context.Background(), "test-key")
+	appErr, ok := err.(*types.AppError)
+	if !ok || appErr.Code != types.ErrCodePermission {
+		t.Errorf("Expected permission error, got %v", err)
+	}
+}
+
 // ==================== Nil Request Tests ====================
 
 func TestClient_ChatCompletion_NilRequest(t *testing.T) {
@@ -637,3 +814,38 @@ func TestClient_ChatCompletionStream_HTTPErrorReportsBeforeRelease(t *testing.T)
 		t.Errorf("Expected 1 failure report, got %d", len(pool.failureReports))
 	}
 }
+
+func TestClient_ChatCompletionStream_PromptBlocked(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(`data: {"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"HIGH","blocked":true}]}}` + "\n\n"))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, newMockPool(mockKey("key1", "test-key")))
+	events, err := client.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{
+		Model:    "gpt-4",
+		Messages: []types.Message{types.NewTextContent("user", "Hello")},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream failed: %v", err)
+	}
+
+	var streamErr error
+	for event := range events {
+		if event.Chunk != nil {
+			t.Errorf("unexpected chunk for a blocked prompt: %+v", event.Chunk)
+		}
+		if event.Err != nil {
+			streamErr = event.Err
+		}
+	}
+
+	appErr, ok := streamErr.(*types.AppError)
+	if !ok || appErr.Code != types.ErrCodeContentFilter {
+		t.Fatalf("expected content filter error, got %v", streamErr)
+	}
+	if appErr.ContentFilter == nil || len(appErr.ContentFilter.SafetyRatings) != 1 {
+		t.Errorf("expected safety ratings on the error, got %+v", appErr.ContentFilter)
+	}
+}
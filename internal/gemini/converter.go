@@ -46,6 +46,23 @@ func ConvertOpenAIRequest(req *types.ChatCompletionRequest) (*types.GeminiReques
 	// Convert generation config
 	geminiReq.GenerationConfig = convertGenerationConfig(req)
 
+	// Convert per-request thinking settings
+	thinking, err := convertThinkingConfig(req)
+	if err != nil {
+		return nil, err
+	}
+	if thinking != nil {
+		if geminiReq.GenerationConfig == nil {
+			geminiReq.GenerationConfig = &types.GeminiGenerationConfig{}
+		}
+		geminiReq.GenerationConfig.ThinkingConfig = thinking
+	}
+
+	// Per-request safety settings are merged in ApplyModelSettings
+	if err := ValidateSafetyOverride(req.SafetySettings); err != nil {
+		return nil, types.NewInvalidRequestError(err.Error()).WithParam("safety_settings")
+	}
+
 	return geminiReq, nil
 }
 
@@ -214,7 +231,11 @@ func convertGenerationConfig(req *types.ChatCompletionRequest) *types.GeminiGene
 		hasConfig = true
 	}
 
-	if req.MaxTokens != nil {
+	// max_completion_tokens supersedes max_tokens
+	if req.MaxCompletionTokens != nil {
+		cfg.MaxOutputTokens = req.MaxCompletionTokens
+		hasConfig = true
+	} else if req.MaxTokens != nil {
 		cfg.MaxOutputTokens = req.MaxTokens
 		hasConfig = true
 	}
@@ -229,6 +250,29 @@ func convertGenerationConfig(req *types.ChatCompletionRequest) *types.GeminiGene
 		hasConfig = true
 	}
 
+	if req.PresencePenalty != nil {
+		cfg.PresencePenalty = req.PresencePenalty
+		hasConfig = true
+	}
+
+	if req.FrequencyPenalty != nil {
+		cfg.FrequencyPenalty = req.FrequencyPenalty
+		hasConfig = true
+	}
+
+	if req.Seed != nil {
+		cfg.Seed = req.Seed
+		hasConfig = true
+	}
+
+	if req.Logprobs != nil && *req.Logprobs {
+		cfg.ResponseLogprobs = req.Logprobs
+		if req.TopLogprobs != nil && *req.TopLogprobs > 0 {
+			cfg.Logprobs = req.TopLogprobs
+		}
+		hasConfig = true
+	}
+
 	if !hasConfig {
 		return nil
 	}
@@ -236,13 +280,69 @@ func convertGenerationConfig(req *types.ChatCompletionRequest) *types.GeminiGene
 	return cfg
 }
 
+// reasoningEffortBudgets maps OpenAI reasoning_effort values to Gemini
+// thinking budgets, following Google's OpenAI compatibility layer.
+var reasoningEffortBudgets = map[string]int{
+	"none":    0,
+	"minimal": 1024,
+	"low":     1024,
+	"medium":  8192,
+	"high":    24576,
+}
+
+// convertThinkingConfig maps reasoning_effort or thinking_budget to a Gemini
+// ThinkingConfig. Thought summaries are requested whenever thinking is on, so
+// they can be returned as reasoning_content. Returns nil if neither is set.
+func convertThinkingConfig(req *types.ChatCompletionRequest) (*types.ThinkingConfig, error) {
+	var budget int
+	switch {
+	case req.ReasoningEffort != "" && req.ThinkingBudget != nil:
+		return nil, types.NewInvalidRequestError("reasoning_effort and thinking_budget cannot be used together").WithParam("reasoning_effort")
+	case req.ReasoningEffort != "":
+		b, ok := reasoningEffortBudgets[strings.ToLower(req.ReasoningEffort)]
+		if !ok {
+			return nil, types.NewInvalidRequestError("reasoning_effort must be none, minimal, low, medium or high").WithParam("reasoning_effort")
+		}
+		budget = b
+	case req.ThinkingBudget != nil:
+		// -1 lets the model decide how much to think
+		if *req.ThinkingBudget < -1 {
+			return nil, types.NewInvalidRequestError("thinking_budget must be >= -1").WithParam("thinking_budget")
+		}
+		budget = *req.ThinkingBudget
+	default:
+		return nil, nil
+	}
+
+	return &types.ThinkingConfig{
+		ThinkingBudget:  &budget,
+		IncludeThoughts: budget != 0,
+	}, nil
+}
+
 // ApplyModelSettings applies global model settings to a GeminiRequest.
 // It only applies settings if they are not already set in the request.
 // Priority: OpenAI request params > Global settings > Gemini defaults
-func ApplyModelSettings(req *types.GeminiRequest, settings *types.ModelSettingsConfig) {
+// source is the original request; its model and safety_settings select the
+// per-model and per-request safety overrides, and settings the model family
+// does not accept are dropped. settings may be nil.
+func ApplyModelSettings(req *types.GeminiRequest, settings *types.ModelSettingsConfig, source *types.ChatCompletionRequest) {
+	// Merge safety settings: request > model > global thresholds > global preset
+	var model string
+	var override *types.SafetyOverride
+	if source != nil {
+		model, override = source.Model, source.SafetySettings
+	}
+	if safety := ResolveSafetySettings(settings, model, override); safety != nil {
+		req.SafetySettings = safety
+	}
+
 	if settings == nil {
 		return
 	}
+	if source != nil {
+		settings = supportedModelSettings(MapModelName(source.Model), settings)
+	}
 
 	// Apply System Prompt if not already set
 	if req.SystemInstruction == nil && settings.SystemPrompt != "" {
@@ -273,8 +373,8 @@ func ApplyModelSettings(req *types.GeminiRequest, settings *types.ModelSettingsC
 		req.GenerationConfig.MaxOutputTokens = settings.MaxOutputTokens
 	}
 
-	// Apply Gemini 2.5+ features
-	if settings.ThinkingLevel != nil && *settings.ThinkingLevel != "" {
+	// Apply Gemini 2.5+ features; per-request thinking takes precedence
+	if req.GenerationConfig.ThinkingConfig == nil && settings.ThinkingLevel != nil && *settings.ThinkingLevel != "" {
 		req.GenerationConfig.ThinkingConfig = &types.ThinkingConfig{
 			ThinkingLevel: settings.ThinkingLevel,
 		}
@@ -290,6 +390,9 @@ func ApplyModelSettings(req *types.GeminiRequest, settings *types.ModelSettingsC
 // ConvertGeminiResponse converts a Gemini response to OpenAI ChatCompletionResponse format.
 func ConvertGeminiResponse(resp *types.GeminiResponse, model string) (*types.ChatCompletionResponse, error) {
 	if len(resp.Candidates) == 0 {
+		if resp.IsPromptBlocked() {
+			return nil, types.NewContentFilterError(resp.PromptFeedback.BlockReason, resp.PromptFeedback.SafetyRatings)
+		}
 		return nil, types.NewUpstreamError("No candidates in Gemini response")
 	}
 
@@ -317,69 +420,141 @@ func ConvertGeminiResponse(resp *types.GeminiResponse, model string) (*types.Cha
 
 // convertCandidate converts a single Gemini candidate to an OpenAI Choice.
 func convertCandidate(candidate types.GeminiCandidate) types.Choice {
-	content := ""
-	if candidate.Content != nil {
-		content = candidate.GetTextContent()
-	}
-
-	finishReason := MapFinishReason(candidate.FinishReason)
-
-	return types.Choice{
+	choice := types.Choice{
 		Index: candidate.Index,
 		Message: types.ResponseMessage{
-			Role:    "assistant",
-			Content: content,
+			Role:             "assistant",
+			Content:          candidate.GetTextContent(),
+			ReasoningContent: candidate.GetThoughtContent(),
 		},
-		FinishReason: finishReason,
+		FinishReason: MapFinishReason(candidate.FinishReason),
+	}
+	if types.IsContentFilterFinish(candidate.FinishReason) {
+		choice.SafetyRatings = candidate.SafetyRatings
+	}
+	choice.Logprobs = convertLogprobs(candidate.LogprobsResult)
+	return choice
+}
+
+// convertLogprobs converts a Gemini logprobs result to OpenAI format, pairing
+// each chosen token with the top candidates of its decoding step. Returns
+// nil if there is no result.
+func convertLogprobs(result *types.GeminiLogprobsResult) *types.ChoiceLogprobs {
+	if result == nil {
+		return nil
+	}
+	content := make([]types.TokenLogprob, 0, len(result.ChosenCandidates))
+	for i, chosen := range result.ChosenCandidates {
+		entry := types.TokenLogprob{
+			Token:       chosen.Token,
+			Logprob:     chosen.LogProbability,
+			Bytes:       tokenBytes(chosen.Token),
+			TopLogprobs: []types.TopLogprob{},
+		}
+		if i < len(result.TopCandidates) {
+			for _, top := range result.TopCandidates[i].Candidates {
+				entry.TopLogprobs = append(entry.TopLogprobs, types.TopLogprob{
+					Token:   top.Token,
+					Logprob: top.LogProbability,
+					Bytes:   tokenBytes(top.Token),
+				})
+			}
+		}
+		content = append(content, entry)
+	}
+	return &types.ChoiceLogprobs{Content: content}
+}
+
+// tokenBytes returns the UTF-8 bytes of a token as OpenAI reports them.
+func tokenBytes(token string) []int {
+	b := make([]int, len(token))
+	for i := 0; i < len(token); i++ {
+		b[i] = int(token[i])
+	}
+	return b
+}
+
+// StreamState holds what one streamed completion shares across its chunks:
+// a single response ID and creation timestamp, and whether the assistant
+// role has been sent yet.
+type StreamState struct {
+	ID       string
+	Created  int64
+	Model    string
+	roleSent bool
+}
+
+// NewStreamState starts the state of a stream echoing model to the client.
+func NewStreamState(model string) *StreamState {
+	return &StreamState{
+		ID:      GenerateResponseID(),
+		Created: GetCreatedTimestamp(),
+		Model:   model,
 	}
 }
 
-// ConvertGeminiStreamChunk converts a Gemini streaming response chunk to OpenAI format.
-func ConvertGeminiStreamChunk(chunk *types.GeminiResponse, model string, index int) (*types.ChatCompletionChunk, error) {
+// newChunk returns a chunk of the stream with the given choices.
+func (s *StreamState) newChunk(choices []types.ChunkChoice) *types.ChatCompletionChunk {
+	return &types.ChatCompletionChunk{
+		ID:      s.ID,
+		Object:  "chat.completion.chunk",
+		Created: s.Created,
+		Model:   s.Model,
+		Choices: choices,
+	}
+}
+
+// UsageChunk returns the final usage-only chunk sent with
+// stream_options.include_usage; its choices are empty.
+func (s *StreamState) UsageChunk(usage types.Usage) *types.ChatCompletionChunk {
+	chunk := s.newChunk([]types.ChunkChoice{})
+	chunk.Usage = &usage
+	return chunk
+}
+
+// ConvertGeminiStreamChunk converts a Gemini streaming response chunk to OpenAI
+// format. The first chunk of the stream carries the assistant role.
+func ConvertGeminiStreamChunk(chunk *types.GeminiResponse, state *StreamState) (*types.ChatCompletionChunk, error) {
+	role := ""
+	if !state.roleSent {
+		role = "assistant"
+		state.roleSent = true
+	}
+
 	if len(chunk.Candidates) == 0 {
 		// Empty chunk - just return empty delta
-		return &types.ChatCompletionChunk{
-			ID:      GenerateResponseID(),
-			Object:  "chat.completion.chunk",
-			Created: GetCreatedTimestamp(),
-			Model:   model,
-			Choices: []types.ChunkChoice{
-				{
-					Index: index,
-					Delta: types.Delta{},
-				},
+		return state.newChunk([]types.ChunkChoice{
+			{
+				Index: 0,
+				Delta: types.Delta{Role: role},
 			},
-		}, nil
+		}), nil
 	}
 
 	choices := make([]types.ChunkChoice, 0, len(chunk.Candidates))
 	for _, candidate := range chunk.Candidates {
-		content := ""
-		if candidate.Content != nil {
-			content = candidate.GetTextContent()
-		}
-
 		finishReason := ""
 		if candidate.FinishReason != "" {
 			finishReason = MapFinishReason(candidate.FinishReason)
 		}
 
-		choices = append(choices, types.ChunkChoice{
+		choice := types.ChunkChoice{
 			Index: candidate.Index,
 			Delta: types.Delta{
-				Content: content,
+				Role:             role,
+				Content:          candidate.GetTextContent(),
+				ReasoningContent: candidate.GetThoughtContent(),
 			},
 			FinishReason: finishReason,
-		})
+		}
+		if types.IsContentFilterFinish(candidate.FinishReason) {
+			choice.SafetyRatings = candidate.SafetyRatings
+		}
+		choice.Logprobs = convertLogprobs(candidate.LogprobsResult)
+		choices = append(choices, choice)
 	}
 
-	return &types.ChatCompletionChunk{
-		ID:      GenerateResponseID(),
-		Object:  "chat.completion.chunk",
-		Created: GetCreatedTimestamp(),
-		Model:   model,
-		Choices: choices,
-	}, nil
+	return state.newChunk(choices), nil
 }
 
 // ==================== Model Mapping ====================
@@ -403,9 +578,10 @@ func MapFinishReason(geminiReason string) string {
 		return "stop"
 	case types.GeminiFinishReasonMaxTokens:
 		return "length"
-	case types.GeminiFinishReasonSafety, types.GeminiFinishReasonRecitation:
-		return "content_filter"
 	default:
+		if types.IsContentFilterFinish(geminiReason) {
+			return "content_filter"
+		}
 		return "stop" // Default to stop
 	}
 }
@@ -263,6 +263,51 @@ func TestConvertOpenAIRequest_Parameters(t *testing.T) {
 	}
 }
 
+func TestConvertOpenAIRequest_PenaltiesSeedLogprobs(t *testing.T) {
+	logprobs := true
+	req := &types.ChatCompletionRequest{
+		Model: "gpt-4",
+		Messages: []types.Message{
+			makeTextMessage("user", "Test"),
+		},
+		PresencePenalty:     newFloat64(0.5),
+		FrequencyPenalty:    newFloat64(-0.5),
+		Seed:                newInt(42),
+		MaxTokens:           newInt(100),
+		MaxCompletionTokens: newInt(200),
+		Logprobs:            &logprobs,
+		TopLogprobs:         newInt(3),
+	}
+
+	geminiReq, err := ConvertOpenAIRequest(req)
+	if err != nil {
+		t.Fatalf("ConvertOpenAIRequest failed: %v", err)
+	}
+
+	cfg := geminiReq.GenerationConfig
+	if cfg == nil {
+		t.Fatal("Expected GenerationConfig to be set")
+	}
+	if cfg.PresencePenalty == nil || *cfg.PresencePenalty != 0.5 {
+		t.Errorf("PresencePenalty mismatch: got %v", cfg.PresencePenalty)
+	}
+	if cfg.FrequencyPenalty == nil || *cfg.FrequencyPenalty != -0.5 {
+		t.Errorf("FrequencyPenalty mismatch: got %v", cfg.FrequencyPenalty)
+	}
+	if cfg.Seed == nil || *cfg.Seed != 42 {
+		t.Errorf("Seed mismatch: got %v", cfg.Seed)
+	}
+	if cfg.MaxOutputTokens == nil || *cfg.MaxOutputTokens != 200 {
+		t.Errorf("MaxOutputTokens = %v, want max_completion_tokens 200", cfg.MaxOutputTokens)
+	}
+	if cfg.ResponseLogprobs == nil || !*cfg.ResponseLogprobs {
+		t.Errorf("ResponseLogprobs mismatch: got %v", cfg.ResponseLogprobs)
+	}
+	if cfg.Logprobs == nil || *cfg.Logprobs != 3 {
+		t.Errorf("Logprobs mismatch: got %v", cfg.Logprobs)
+	}
+}
+
 func TestConvertOpenAIRequest_StopSingleString(t *testing.T) {
 	req := &types.ChatCompletionRequest{
 		Model: "gpt-4",
@@ -347,6 +392,174 @@ func TestConvertGeminiResponse_Normal(t *testing.T) {
 	if resp.Usage.TotalTokens != 18 {
 		t.Errorf("TotalTokens mismatch: got %d", resp.Usage.TotalTokens)
 	}
+	if resp.Usage.PromptTokensDetails != nil {
+		t.Errorf("PromptTokensDetails should be omitted without cached tokens, got %+v", resp.Usage.PromptTokensDetails)
+	}
+}
+
+func TestConvertGeminiResponse_CachedTokens(t *testing.T) {
+	geminiResp := &types.GeminiResponse{
+		Candidates: []types.GeminiCandidate{
+			{
+				Content:      &types.GeminiContent{Parts: []types.GeminiPart{{Text: "Hi"}}, Role: "model"},
+				FinishReason: types.GeminiFinishReasonStop,
+			},
+		},
+		UsageMetadata: &types.GeminiUsageMetadata{
+			PromptTokenCount:        2048,
+			CandidatesTokenCount:    1,
+			TotalTokenCount:         2049,
+			CachedContentTokenCount: 1536,
+		},
+	}
+
+	resp, err := ConvertGeminiResponse(geminiResp, "gpt-4")
+	if err != nil {
+		t.Fatalf("ConvertGeminiResponse failed: %v", err)
+	}
+	if resp.Usage.PromptTokensDetails == nil || resp.Usage.PromptTokensDetails.CachedTokens != 1536 {
+		t.Errorf("Expected 1536 cached tokens, got %+v", resp.Usage.PromptTokensDetails)
+	}
+}
+
+func TestConvertOpenAIRequest_Thinking(t *testing.T) {
+	tests := []struct {
+		name           string
+		effort         string
+		budget         *int
+		wantBudget     int
+		wantThoughts   bool
+		wantErr        bool
+		wantNoThinking bool
+	}{
+		{name: "unset", wantNoThinking: true},
+		{name: "effort high", effort: "high", wantBudget: 24576, wantThoughts: true},
+		{name: "effort upper case", effort: "LOW", wantBudget: 1024, wantThoughts: true},
+		{name: "effort none", effort: "none", wantBudget: 0},
+		{name: "budget", budget: newInt(2048), wantBudget: 2048, wantThoughts: true},
+		{name: "dynamic budget", budget: newInt(-1), wantBudget: -1, wantThoughts: true},
+		{name: "invalid effort", effort: "max", wantErr: true},
+		{name: "invalid budget", budget: newInt(-2), wantErr: true},
+		{name: "both", effort: "low", budget: newInt(100), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &types.ChatCompletionRequest{
+				Model:           "gemini-2.5-flash",
+				Messages:        []types.Message{makeTextMessage("user", "Test")},
+				ReasoningEffort: tt.effort,
+				ThinkingBudget:  tt.budget,
+			}
+
+			geminiReq, err := ConvertOpenAIRequest(req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertOpenAIRequest failed: %v", err)
+			}
+
+			if tt.wantNoThinking {
+				if geminiReq.GenerationConfig != nil && geminiReq.GenerationConfig.ThinkingConfig != nil {
+					t.Errorf("expected no ThinkingConfig, got %+v", geminiReq.GenerationConfig.ThinkingConfig)
+				}
+				return
+			}
+			thinking := geminiReq.GenerationConfig.ThinkingConfig
+			if thinking == nil || thinking.ThinkingBudget == nil || *thinking.ThinkingBudget != tt.wantBudget {
+				t.Fatalf("ThinkingConfig = %+v, want budget %d", thinking, tt.wantBudget)
+			}
+			if thinking.IncludeThoughts != tt.wantThoughts {
+				t.Errorf("IncludeThoughts = %v, want %v", thinking.IncludeThoughts, tt.wantThoughts)
+			}
+		})
+	}
+}
+
+func TestApplyModelSettings_RequestThinkingWins(t *testing.T) {
+	req, err := ConvertOpenAIRequest(&types.ChatCompletionRequest{
+		Model:           "gemini-2.5-flash",
+		Messages:        []types.Message{makeTextMessage("user", "Test")},
+		ReasoningEffort: "medium",
+	})
+	if err != nil {
+		t.Fatalf("ConvertOpenAIRequest failed: %v", err)
+	}
+
+	level := "HIGH"
+	ApplyModelSettings(req, &types.ModelSettingsConfig{ThinkingLevel: &level}, nil)
+
+	thinking := req.GenerationConfig.ThinkingConfig
+	if thinking.ThinkingLevel != nil || thinking.ThinkingBudget == nil || *thinking.ThinkingBudget != 8192 {
+		t.Errorf("global thinking level overrode the request: %+v", thinking)
+	}
+}
+
+func TestConvertGeminiResponse_ThoughtParts(t *testing.T) {
+	geminiResp := &types.GeminiResponse{
+		Candidates: []types.GeminiCandidate{
+			{
+				Content: &types.GeminiContent{Parts: []types.GeminiPart{
+					{Text: "Considering the question.", Thought: true},
+					{Text: "The answer "},
+					{Text: "is 4."},
+				}, Role: "model"},
+				FinishReason: types.GeminiFinishReasonStop,
+			},
+		},
+		UsageMetadata: &types.GeminiUsageMetadata{
+			PromptTokenCount:     10,
+			CandidatesTokenCount: 5,
+			ThoughtsTokenCount:   120,
+			TotalTokenCount:      135,
+		},
+	}
+
+	resp, err := ConvertGeminiResponse(geminiResp, "gpt-4")
+	if err != nil {
+		t.Fatalf("ConvertGeminiResponse failed: %v", err)
+	}
+
+	msg := resp.Choices[0].Message
+	if msg.Content != "The answer is 4." {
+		t.Errorf("Content = %q, want the answer without thoughts", msg.Content)
+	}
+	if msg.ReasoningContent != "Considering the question." {
+		t.Errorf("ReasoningContent = %q", msg.ReasoningContent)
+	}
+
+	if resp.Usage.CompletionTokens != 125 || resp.Usage.TotalTokens != 135 {
+		t.Errorf("usage = %+v, want 125 completion tokens including thoughts", resp.Usage)
+	}
+	if resp.Usage.CompletionTokensDetails == nil || resp.Usage.CompletionTokensDetails.ReasoningTokens != 120 {
+		t.Errorf("expected 120 reasoning tokens, got %+v", resp.Usage.CompletionTokensDetails)
+	}
+}
+
+func TestConvertGeminiStreamChunk_Thought(t *testing.T) {
+	chunk := &types.GeminiResponse{
+		Candidates: []types.GeminiCandidate{
+			{
+				Content: &types.GeminiContent{Parts: []types.GeminiPart{
+					{Text: "Thinking it over", Thought: true},
+				}, Role: "model"},
+			},
+		},
+	}
+
+	streamChunk, err := ConvertGeminiStreamChunk(chunk, NewStreamState("gpt-4"))
+	if err != nil {
+		t.Fatalf("ConvertGeminiStreamChunk failed: %v", err)
+	}
+
+	delta := streamChunk.Choices[0].Delta
+	if delta.ReasoningContent != "Thinking it over" || delta.Content != "" {
+		t.Errorf("delta = %+v, want the thought in reasoning_content only", delta)
+	}
 }
 
 func TestConvertGeminiStreamChunk(t *testing.T) {
@@ -364,7 +577,7 @@ func TestConvertGeminiStreamChunk(t *testing.T) {
 		},
 	}
 
-	streamChunk, err := ConvertGeminiStreamChunk(chunk, "gpt-4", 0)
+	streamChunk, err := ConvertGeminiStreamChunk(chunk, NewStreamState("gpt-4"))
 	if err != nil {
 		t.Fatalf("ConvertGeminiStreamChunk failed: %v", err)
 	}
@@ -398,7 +611,7 @@ func TestConvertGeminiStreamChunk_WithFinishReason(t *testing.T) {
 		},
 	}
 
-	streamChunk, err := ConvertGeminiStreamChunk(chunk, "gpt-4", 5)
+	streamChunk, err := ConvertGeminiStreamChunk(chunk, NewStreamState("gpt-4"))
 	if err != nil {
 		t.Fatalf("ConvertGeminiStreamChunk failed: %v", err)
 	}
@@ -496,8 +709,132 @@ func TestConvertGeminiResponse_BlockedContent(t *testing.T) {
 	}
 }
 
+func TestConvertGeminiResponse_PromptBlocked(t *testing.T) {
+	ratings := []types.GeminiSafetyRating{
+		{Category: types.SafetyCategoryDangerousContent, Probability: "HIGH", Blocked: true},
+	}
+	resp := &types.GeminiResponse{
+		PromptFeedback: &types.GeminiPromptFeedback{BlockReason: "PROHIBITED_CONTENT", SafetyRatings: ratings},
+	}
+
+	_, err := ConvertGeminiResponse(resp, "gpt-4")
+	appErr, ok := err.(*types.AppError)
+	if !ok {
+		t.Fatalf("expected *types.AppError, got %T: %v", err, err)
+	}
+	if appErr.Code != types.ErrCodeContentFilter || appErr.HTTPStatus != 400 {
+		t.Errorf("got code %d status %d, want content filter 400", appErr.Code, appErr.HTTPStatus)
+	}
+	detail := appErr.ToAPIError().Error.ContentFilter
+	if detail == nil || detail.BlockReason != "PROHIBITED_CONTENT" || len(detail.SafetyRatings) != 1 {
+		t.Errorf("content filter detail = %+v", detail)
+	}
+}
+
+func TestConvertGeminiResponse_SafetyFinishCarriesRatings(t *testing.T) {
+	ratings := []types.GeminiSafetyRating{
+		{Category: types.SafetyCategoryHarassment, Probability: "MEDIUM", Blocked: true},
+	}
+	resp := &types.GeminiResponse{
+		Candidates: []types.GeminiCandidate{
+			{FinishReason: types.GeminiFinishReasonBlocklist, SafetyRatings: ratings},
+		},
+	}
+
+	result, err := ConvertGeminiResponse(resp, "gpt-4")
+	if err != nil {
+		t.Fatalf("ConvertGeminiResponse failed: %v", err)
+	}
+	choice := result.Choices[0]
+	if choice.FinishReason != "content_filter" || len(choice.SafetyRatings) != 1 {
+		t.Errorf("choice = %+v, want content_filter with safety ratings", choice)
+	}
+
+	streamChunk, err := ConvertGeminiStreamChunk(resp, NewStreamState("gpt-4"))
+	if err != nil {
+		t.Fatalf("ConvertGeminiStreamChunk failed: %v", err)
+	}
+	if len(streamChunk.Choices[0].SafetyRatings) != 1 {
+		t.Errorf("stream choice = %+v, want safety ratings", streamChunk.Choices[0])
+	}
+}
+
 // ==================== Finish Reason Mapping Tests ====================
 
+func TestConvertGeminiResponse_Logprobs(t *testing.T) {
+	geminiResp := &types.GeminiResponse{
+		Candidates: []types.GeminiCandidate{
+			{
+				Content: &types.GeminiContent{
+					Parts: []types.GeminiPart{{Text: "Hi!"}},
+					Role:  "model",
+				},
+				FinishReason: "STOP",
+				LogprobsResult: &types.GeminiLogprobsResult{
+					ChosenCandidates: []types.GeminiLogprob{
+						{Token: "Hi", LogProbability: -0.1},
+						{Token: "!", LogProbability: -0.5},
+					},
+					TopCandidates: []types.GeminiTopCandidates{
+						{Candidates: []types.GeminiLogprob{
+							{Token: "Hi", LogProbability: -0.1},
+							{Token: "Hello", LogProbability: -2.3},
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	resp, err := ConvertGeminiResponse(geminiResp, "gpt-4")
+	if err != nil {
+		t.Fatalf("ConvertGeminiResponse failed: %v", err)
+	}
+
+	logprobs := resp.Choices[0].Logprobs
+	if logprobs == nil || len(logprobs.Content) != 2 {
+		t.Fatalf("Logprobs = %+v, want 2 tokens", logprobs)
+	}
+	first := logprobs.Content[0]
+	if first.Token != "Hi" || first.Logprob != -0.1 || len(first.Bytes) != 2 || first.Bytes[0] != 'H' {
+		t.Errorf("first token = %+v", first)
+	}
+	if len(first.TopLogprobs) != 2 || first.TopLogprobs[1].Token != "Hello" {
+		t.Errorf("first top_logprobs = %+v", first.TopLogprobs)
+	}
+	if second := logprobs.Content[1]; second.TopLogprobs == nil || len(second.TopLogprobs) != 0 {
+		t.Errorf("second top_logprobs = %+v, want an empty list", second.TopLogprobs)
+	}
+
+	// Chunks carry the logprobs of their own tokens
+	chunk, err := ConvertGeminiStreamChunk(geminiResp, NewStreamState("gpt-4"))
+	if err != nil {
+		t.Fatalf("ConvertGeminiStreamChunk failed: %v", err)
+	}
+	if chunk.Choices[0].Logprobs == nil || len(chunk.Choices[0].Logprobs.Content) != 2 {
+		t.Errorf("chunk logprobs = %+v, want 2 tokens", chunk.Choices[0].Logprobs)
+	}
+}
+
+func TestConvertGeminiResponse_NoLogprobs(t *testing.T) {
+	resp, err := ConvertGeminiResponse(&types.GeminiResponse{
+		Candidates: []types.GeminiCandidate{{
+			Content:      &types.GeminiContent{Parts: []types.GeminiPart{{Text: "Hi"}}, Role: "model"},
+			FinishReason: "STOP",
+		}},
+	}, "gpt-4")
+	if err != nil {
+		t.Fatalf("ConvertGeminiResponse failed: %v", err)
+	}
+
+	data, _ := json.Marshal(resp.Choices[0])
+	var raw map[string]any
+	_ = json.Unmarshal(data, &raw)
+	if _, ok := raw["logprobs"]; ok {
+		t.Errorf("choice JSON %s has logprobs, want it omitted", data)
+	}
+}
+
 func TestMapFinishReason(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -507,7 +844,10 @@ func TestMapFinishReason(t *testing.T) {
 		{types.GeminiFinishReasonMaxTokens, "length"},
 		{types.GeminiFinishReasonSafety, "content_filter"},
 		{types.GeminiFinishReasonRecitation, "content_filter"},
-		{"", "stop"}, // Empty defaults to stop
+		{types.GeminiFinishReasonBlocklist, "content_filter"},
+		{types.GeminiFinishReasonProhibited, "content_filter"},
+		{types.GeminiFinishReasonSPII, "content_filter"},
+		{"", "stop"},        // Empty defaults to stop
 		{"UNKNOWN", "stop"}, // Unknown defaults to stop
 	}
 
@@ -0,0 +1,121 @@
+﻿package gemini
+
+import (
+	"fmt"
+	"math"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Parameter Checks ====================
+
+// Limits of the OpenAI parameters Gemini accepts.
+const (
+	maxStopSequences  = 5
+	maxCandidateCount = 8
+	maxTopLogprobs    = 20
+)
+
+// WithStrictParameters makes the client reject, rather than clamp, OpenAI
+// parameters Gemini cannot honour as given.
+func WithStrictParameters(strict bool) ClientOption {
+	return func(c *Client) {
+		c.strictParams.Store(strict)
+	}
+}
+
+// SetStrictParameters switches between rejecting (strict) and clamping
+// unsupported parameters. Requests already in flight are not affected.
+func (c *Client) SetStrictParameters(strict bool) {
+	c.strictParams.Store(strict)
+}
+
+// StrictParameters reports whether unsupported parameters are rejected.
+func (c *Client) StrictParameters() bool {
+	return c.strictParams.Load()
+}
+
+// NormalizeRequestParams checks the OpenAI parameters of req against what
+// Gemini accepts. Out-of-range values are clamped, extra stop sequences and
+// top_logprobs without logprobs are dropped; in strict mode each of these is
+// rejected with an invalid request error instead. It returns an adjusted
+// copy, leaving req unchanged.
+func NormalizeRequestParams(req *types.ChatCompletionRequest, strict bool) (*types.ChatCompletionRequest, error) {
+	out := *req
+	var err error
+
+	if out.Temperature, err = clampFloat("temperature", out.Temperature, 0, 2, strict); err != nil {
+		return nil, err
+	}
+	if out.TopP, err = clampFloat("top_p", out.TopP, 0, 1, strict); err != nil {
+		return nil, err
+	}
+	if out.PresencePenalty, err = clampFloat("presence_penalty", out.PresencePenalty, -2, 2, strict); err != nil {
+		return nil, err
+	}
+	if out.FrequencyPenalty, err = clampFloat("frequency_penalty", out.FrequencyPenalty, -2, 2, strict); err != nil {
+		return nil, err
+	}
+	if out.N, err = clampInt("n", out.N, 1, maxCandidateCount, strict); err != nil {
+		return nil, err
+	}
+	if out.MaxTokens, err = clampInt("max_tokens", out.MaxTokens, 1, math.MaxInt32, strict); err != nil {
+		return nil, err
+	}
+	if out.MaxCompletionTokens, err = clampInt("max_completion_tokens", out.MaxCompletionTokens, 1, math.MaxInt32, strict); err != nil {
+		return nil, err
+	}
+	if out.TopLogprobs, err = clampInt("top_logprobs", out.TopLogprobs, 0, maxTopLogprobs, strict); err != nil {
+		return nil, err
+	}
+
+	// max_completion_tokens supersedes max_tokens
+	if strict && out.MaxTokens != nil && out.MaxCompletionTokens != nil && *out.MaxTokens != *out.MaxCompletionTokens {
+		return nil, types.NewInvalidRequestError("max_tokens and max_completion_tokens differ; set only max_completion_tokens").
+			WithParam("max_completion_tokens")
+	}
+
+	if len(out.Stop) > maxStopSequences {
+		if strict {
+			return nil, types.NewInvalidRequestError(fmt.Sprintf("stop supports at most %d sequences", maxStopSequences)).
+				WithParam("stop")
+		}
+		out.Stop = out.Stop[:maxStopSequences]
+	}
+
+	if out.TopLogprobs != nil && (out.Logprobs == nil || !*out.Logprobs) {
+		if strict {
+			return nil, types.NewInvalidRequestError("top_logprobs requires logprobs to be true").WithParam("top_logprobs")
+		}
+		out.TopLogprobs = nil
+	}
+
+	return &out, nil
+}
+
+// clampFloat checks that v, if set, lies within [min, max].
+func clampFloat(name string, v *float64, min, max float64, strict bool) (*float64, error) {
+	if v == nil || (*v >= min && *v <= max) {
+		return v, nil
+	}
+	if strict {
+		return nil, types.NewInvalidRequestError(fmt.Sprintf("%s must be between %g and %g", name, min, max)).WithParam(name)
+	}
+	clamped := math.Min(math.Max(*v, min), max)
+	return &clamped, nil
+}
+
+// clampInt checks that v, if set, lies within [min, max].
+func clampInt(name string, v *int, min, max int, strict bool) (*int, error) {
+	if v == nil || (*v >= min && *v <= max) {
+		return v, nil
+	}
+	if strict {
+		return nil, types.NewInvalidRequestError(fmt.Sprintf("%s must be between %d and %d", name, min, max)).WithParam(name)
+	}
+	clamped := min
+	if *v > max {
+		clamped = max
+	}
+	return &clamped, nil
+}
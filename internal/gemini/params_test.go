@@ -0,0 +1,134 @@
+﻿package gemini
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Parameter Check Tests ====================
+
+func newBool(v bool) *bool { return &v }
+
+func TestNormalizeRequestParams_Clamp(t *testing.T) {
+	req := &types.ChatCompletionRequest{
+		Model:            "gemini-2.5-flash",
+		Temperature:      newFloat64(3),
+		TopP:             newFloat64(-0.5),
+		PresencePenalty:  newFloat64(2.5),
+		FrequencyPenalty: newFloat64(-3),
+		N:                newInt(12),
+		MaxTokens:        newInt(0),
+		TopLogprobs:      newInt(5),
+		Stop:             types.StopSequence{"a", "b", "c", "d", "e", "f", "g"},
+	}
+
+	out, err := NormalizeRequestParams(req, false)
+	if err != nil {
+		t.Fatalf("NormalizeRequestParams failed: %v", err)
+	}
+
+	if *out.Temperature != 2 || *out.TopP != 0 {
+		t.Errorf("temperature/top_p = %v/%v, want 2/0", *out.Temperature, *out.TopP)
+	}
+	if *out.PresencePenalty != 2 || *out.FrequencyPenalty != -2 {
+		t.Errorf("penalties = %v/%v, want 2/-2", *out.PresencePenalty, *out.FrequencyPenalty)
+	}
+	if *out.N != maxCandidateCount || *out.MaxTokens != 1 {
+		t.Errorf("n/max_tokens = %d/%d, want %d/1", *out.N, *out.MaxTokens, maxCandidateCount)
+	}
+	if len(out.Stop) != maxStopSequences || out.Stop[4] != "e" {
+		t.Errorf("stop = %v, want the first %d sequences", out.Stop, maxStopSequences)
+	}
+	if out.TopLogprobs != nil {
+		t.Errorf("top_logprobs = %d, want it dropped without logprobs", *out.TopLogprobs)
+	}
+	if *req.Temperature != 3 || len(req.Stop) != 7 {
+		t.Error("NormalizeRequestParams modified the original request")
+	}
+}
+
+func TestNormalizeRequestParams_Strict(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   types.ChatCompletionRequest
+		param string
+	}{
+		{"temperature", types.ChatCompletionRequest{Temperature: newFloat64(2.1)}, "temperature"},
+		{"presence penalty", types.ChatCompletionRequest{PresencePenalty: newFloat64(-2.5)}, "presence_penalty"},
+		{"top logprobs range", types.ChatCompletionRequest{Logprobs: newBool(true), TopLogprobs: newInt(21)}, "top_logprobs"},
+		{"top logprobs without logprobs", types.ChatCompletionRequest{TopLogprobs: newInt(3)}, "top_logprobs"},
+		{"too many stops", types.ChatCompletionRequest{Stop: types.StopSequence{"1", "2", "3", "4", "5", "6"}}, "stop"},
+		{"conflicting max tokens", types.ChatCompletionRequest{MaxTokens: newInt(100), MaxCompletionTokens: newInt(200)}, "max_completion_tokens"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NormalizeRequestParams(&tt.req, true)
+			var appErr *types.AppError
+			if !errors.As(err, &appErr) {
+				t.Fatalf("err = %v, want an AppError", err)
+			}
+			if appErr.Param != tt.param {
+				t.Errorf("param = %q, want %q", appErr.Param, tt.param)
+			}
+		})
+	}
+}
+
+func TestNormalizeRequestParams_StrictAcceptsValid(t *testing.T) {
+	req := &types.ChatCompletionRequest{
+		Temperature:         newFloat64(1),
+		PresencePenalty:     newFloat64(0.5),
+		Seed:                newInt(42),
+		MaxTokens:           newInt(256),
+		MaxCompletionTokens: newInt(256),
+		Logprobs:            newBool(true),
+		TopLogprobs:         newInt(3),
+		Stop:                types.StopSequence{"1", "2", "3", "4", "5"},
+	}
+
+	out, err := NormalizeRequestParams(req, true)
+	if err != nil {
+		t.Fatalf("NormalizeRequestParams failed: %v", err)
+	}
+	if *out.TopLogprobs != 3 || len(out.Stop) != 5 {
+		t.Errorf("out = %+v, want the request unchanged", out)
+	}
+}
+
+func TestClient_StrictParameters(t *testing.T) {
+	client := NewClient(newMockPool(), WithStrictParameters(true))
+	if !client.StrictParameters() {
+		t.Error("StrictParameters() = false after WithStrictParameters(true)")
+	}
+	client.SetStrictParameters(false)
+	if client.StrictParameters() {
+		t.Error("StrictParameters() = true after SetStrictParameters(false)")
+	}
+}
+
+func TestClient_InvalidParamsTakeNoKey(t *testing.T) {
+	pool := newMockPool(mockKey("key1", "test-key"))
+	client := NewClient(pool, WithStrictParameters(true))
+
+	req := &types.ChatCompletionRequest{
+		Model:       "gemini-2.0-flash",
+		Messages:    []types.Message{types.NewTextContent("user", "Hello")},
+		Temperature: newFloat64(3),
+	}
+	if _, err := client.ChatCompletion(context.Background(), req); err == nil {
+		t.Fatal("ChatCompletion accepted temperature 3 in strict mode")
+	}
+	req.Stream = true
+	if _, err := client.ChatCompletionStream(context.Background(), req); err == nil {
+		t.Fatal("ChatCompletionStream accepted temperature 3 in strict mode")
+	}
+
+	// Rejected requests must not count against any key's quota
+	if len(pool.keyRequests) != 0 {
+		t.Errorf("keyRequests = %+v, want none", pool.keyRequests)
+	}
+}
@@ -0,0 +1,59 @@
+﻿package gemini
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Model Presets ====================
+
+// PresetSeparator separates a base model from a preset name in a virtual
+// model such as "gemini-2.5-pro:creative".
+const PresetSeparator = ":"
+
+// presetNamePattern restricts preset names so they cannot contain the
+// preset or key group separators.
+var presetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidatePresetName checks that name can be used as a preset name.
+func ValidatePresetName(name string) error {
+	if !presetNamePattern.MatchString(name) {
+		return fmt.Errorf("preset name %q must start with a letter or digit and contain only letters, digits, '.', '_' or '-'", name)
+	}
+	return nil
+}
+
+// ResolvePreset finds the preset a requested model names, either directly
+// ("coder") or as a suffix of a base model ("gemini-2.5-pro:creative"), and
+// returns it with the model the request should be sent to. It returns nil
+// and model unchanged if the model names no preset.
+func ResolvePreset(presets []types.ModelPreset, model string) (*types.ModelPreset, string) {
+	if len(presets) == 0 {
+		return nil, model
+	}
+
+	base, name := "", model
+	if i := strings.LastIndex(model, PresetSeparator); i > 0 {
+		base, name = model[:i], model[i+len(PresetSeparator):]
+	}
+
+	for i := range presets {
+		if presets[i].Name != name {
+			continue
+		}
+		if base != "" {
+			return &presets[i], base
+		}
+		return &presets[i], presets[i].Model
+	}
+	return nil, model
+}
+
+// PresetModelIDs returns the virtual model IDs of a preset: the bare name
+// and the name applied to the preset's own model.
+func PresetModelIDs(preset types.ModelPreset) []string {
+	return []string{preset.Name, preset.Model + PresetSeparator + preset.Name}
+}
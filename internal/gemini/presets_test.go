@@ -0,0 +1,119 @@
+﻿package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"muxueTools/internal/types"
+)
+
+func TestResolvePreset(t *testing.T) {
+	presets := []types.ModelPreset{
+		{Name: "coder", Model: "gemini-2.5-pro"},
+		{Name: "creative", Model: "gemini-2.5-flash"},
+	}
+
+	tests := []struct {
+		model      string
+		wantPreset string
+		wantModel  string
+	}{
+		{"coder", "coder", "gemini-2.5-pro"},
+		{"gemini-2.5-pro:creative", "creative", "gemini-2.5-pro"},
+		{"gemini-2.5-flash", "", "gemini-2.5-flash"},
+		{"gemini-2.5-pro:unknown", "", "gemini-2.5-pro:unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			preset, model := ResolvePreset(presets, tt.model)
+			if model != tt.wantModel {
+				t.Errorf("model = %q, want %q", model, tt.wantModel)
+			}
+			if tt.wantPreset == "" {
+				if preset != nil {
+					t.Errorf("expected no preset, got %s", preset.Name)
+				}
+				return
+			}
+			if preset == nil || preset.Name != tt.wantPreset {
+				t.Errorf("expected preset %s, got %+v", tt.wantPreset, preset)
+			}
+		})
+	}
+}
+
+func TestValidatePresetName(t *testing.T) {
+	for _, name := range []string{"coder", "creative-v2", "fast_1.5"} {
+		if err := ValidatePresetName(name); err != nil {
+			t.Errorf("ValidatePresetName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "pro:creative", "coder@team", "-dash", "with space"} {
+		if err := ValidatePresetName(name); err == nil {
+			t.Errorf("ValidatePresetName(%q) should fail", name)
+		}
+	}
+}
+
+func TestClient_ChatCompletion_Preset(t *testing.T) {
+	var capturedPath string
+	var captured types.GeminiRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedPath = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &captured)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(createGeminiResponse("Response", "STOP", 1, 1)))
+	}))
+	defer server.Close()
+
+	globalTemp, presetTemp, requestTopP := 1.0, 0.2, 0.5
+	client := newTestClient(server.URL, newMockPool(mockKey("key1", "test-key")))
+	client.modelSettingsGetter = func(model string) *types.ModelSettingsConfig {
+		return &types.ModelSettingsConfig{SystemPrompt: "Global", Temperature: &globalTemp}
+	}
+	client.presetsGetter = func() []types.ModelPreset {
+		return []types.ModelPreset{{
+			Name:  "coder",
+			Model: "gemini-2.5-pro",
+			Settings: types.ModelSettingsConfig{
+				SystemPrompt: "You write Go.",
+				Temperature:  &presetTemp,
+				SafetyPreset: strPtr(types.SafetyPresetBlockNone),
+			},
+		}}
+	}
+
+	req := &types.ChatCompletionRequest{
+		Model:    "coder",
+		Messages: []types.Message{types.NewTextContent("user", "Hello")},
+		TopP:     &requestTopP,
+	}
+	resp, err := client.ChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(capturedPath, "gemini-2.5-pro") {
+		t.Errorf("Expected the preset's target model in the path, got %s", capturedPath)
+	}
+	if resp.Model != "coder" || req.Model != "coder" {
+		t.Errorf("Expected the virtual model to be echoed and the request left intact, got %s / %s", resp.Model, req.Model)
+	}
+	if captured.SystemInstruction == nil || captured.SystemInstruction.Parts[0].Text != "You write Go." {
+		t.Errorf("Expected the preset system prompt, got %+v", captured.SystemInstruction)
+	}
+	config := captured.GenerationConfig
+	if *config.Temperature != presetTemp || *config.TopP != requestTopP {
+		t.Errorf("Expected preset temperature and request top_p, got %+v", config)
+	}
+	if len(captured.SafetySettings) != len(safetyCategories) {
+		t.Errorf("Expected the preset safety settings, got %+v", captured.SafetySettings)
+	}
+}
@@ -0,0 +1,55 @@
+﻿package gemini
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Key Probing ====================
+
+// ProbeKey checks an API key by listing models with it and classifies the outcome.
+func (c *Client) ProbeKey(ctx context.Context, apiKey string) types.KeyProbeResult {
+	start := time.Now()
+	models, err := c.ListModels(ctx, apiKey)
+	result := types.KeyProbeResult{
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+
+	if err != nil {
+		result.Health = ClassifyProbeError(err)
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Health = types.KeyHealthValid
+	result.Models = models
+	return result
+}
+
+// ClassifyProbeError maps an error from probing a key to a KeyHealth.
+// Errors that say nothing about the key itself count as network errors.
+func ClassifyProbeError(err error) types.KeyHealth {
+	var appErr *types.AppError
+	if !errors.As(err, &appErr) {
+		return types.KeyHealthNetworkError
+	}
+
+	// Gemini answers unsupported regions with 400 FAILED_PRECONDITION or 403
+	msg := strings.ToLower(appErr.Message)
+	if strings.Contains(msg, "location is not supported") || strings.Contains(msg, "region") {
+		return types.KeyHealthRegionBlocked
+	}
+
+	switch appErr.Code {
+	case types.ErrCodeRateLimit:
+		return types.KeyHealthQuotaExhausted
+	case types.ErrCodeAuthentication, types.ErrCodePermission, types.ErrCodeInvalidRequest:
+		return types.KeyHealthInvalid
+	default:
+		return types.KeyHealthNetworkError
+	}
+}
@@ -0,0 +1,81 @@
+﻿package gemini
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Key Probe Tests ====================
+
+func TestClient_ProbeKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   types.KeyHealth
+	}{
+		{
+			name:   "valid",
+			status: http.StatusOK,
+			body:   `{"models":[{"name":"models/gemini-2.5-flash","supportedGenerationMethods":["generateContent"]}]}`,
+			want:   types.KeyHealthValid,
+		},
+		{
+			name:   "invalid",
+			status: http.StatusBadRequest,
+			body:   createGeminiErrorResponse(400, "API key not valid. Please pass a valid API key.", "INVALID_ARGUMENT"),
+			want:   types.KeyHealthInvalid,
+		},
+		{
+			name:   "quota exhausted",
+			status: http.StatusTooManyRequests,
+			body:   createGeminiErrorResponse(429, "Resource has been exhausted", "RESOURCE_EXHAUSTED"),
+			want:   types.KeyHealthQuotaExhausted,
+		},
+		{
+			name:   "region blocked",
+			status: http.StatusBadRequest,
+			body:   createGeminiErrorResponse(400, "User location is not supported for the API use.", "FAILED_PRECONDITION"),
+			want:   types.KeyHealthRegionBlocked,
+		},
+		{
+			name:   "upstream failure",
+			status: http.StatusInternalServerError,
+			body:   createGeminiErrorResponse(500, "Internal error", "INTERNAL"),
+			want:   types.KeyHealthNetworkError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := newTestClient(server.URL, newMockPool())
+			result := client.ProbeKey(context.Background(), "test-key")
+			if result.Health != tt.want {
+				t.Errorf("Health = %s, want %s (error: %s)", result.Health, tt.want, result.Error)
+			}
+			if tt.want == types.KeyHealthValid && (len(result.Models) != 1 || result.Error != "") {
+				t.Errorf("Expected one model and no error, got %+v", result)
+			}
+			if tt.want != types.KeyHealthValid && result.Error == "" {
+				t.Error("Expected an error message")
+			}
+		})
+	}
+}
+
+func TestClassifyProbeError_NonAppError(t *testing.T) {
+	if got := ClassifyProbeError(errors.New("dial tcp: connection reset")); got != types.KeyHealthNetworkError {
+		t.Errorf("ClassifyProbeError() = %s, want network_error", got)
+	}
+}
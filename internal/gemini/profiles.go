@@ -0,0 +1,250 @@
+﻿package gemini
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Model Families ====================
+
+// modelFamily describes which model settings a Gemini model family accepts.
+type modelFamily struct {
+	prefix          string
+	thinking        bool // accepts a thinking config
+	thinkingLevel   bool // accepts thinking_level
+	mediaResolution bool
+	maxOutputTokens int
+}
+
+// modelFamilies lists known families, most specific prefix first.
+var modelFamilies = []modelFamily{
+	{prefix: "gemini-2.5-flash-lite", thinking: true, mediaResolution: true, maxOutputTokens: 65536},
+	{prefix: "gemini-2.5-flash", thinking: true, thinkingLevel: true, mediaResolution: true, maxOutputTokens: 65536},
+	{prefix: "gemini-2.5-pro", thinking: true, thinkingLevel: true, mediaResolution: true, maxOutputTokens: 65536},
+	{prefix: "gemini-3", thinking: true, thinkingLevel: true, mediaResolution: true, maxOutputTokens: 65536},
+	{prefix: "gemini-2.0", mediaResolution: true, maxOutputTokens: 8192},
+	{prefix: "gemini-1.5", maxOutputTokens: 8192},
+}
+
+// familyOf returns the family of a Gemini model name, or nil if unknown.
+func familyOf(model string) *modelFamily {
+	model = strings.TrimPrefix(model, "models/")
+	for i := range modelFamilies {
+		if strings.HasPrefix(model, modelFamilies[i].prefix) {
+			return &modelFamilies[i]
+		}
+	}
+	return nil
+}
+
+// unsupportedSettings lists the settings the family of model does not accept.
+// Models of unknown families accept everything.
+func unsupportedSettings(model string, settings *types.ModelSettingsConfig) []string {
+	family := familyOf(model)
+	if family == nil || settings == nil {
+		return nil
+	}
+	var fields []string
+	if settings.ThinkingLevel != nil && *settings.ThinkingLevel != "" && (!family.thinking || !family.thinkingLevel) {
+		fields = append(fields, "thinking_level")
+	}
+	if settings.MediaResolution != nil && *settings.MediaResolution != "" && !family.mediaResolution {
+		fields = append(fields, "media_resolution")
+	}
+	if settings.MaxOutputTokens != nil && *settings.MaxOutputTokens > family.maxOutputTokens {
+		fields = append(fields, "max_output_tokens")
+	}
+	return fields
+}
+
+// supportedModelSettings returns settings with the fields the family of
+// model does not accept dropped, and max_output_tokens clamped, so a setting
+// meant for one family does not break requests to another.
+func supportedModelSettings(model string, settings *types.ModelSettingsConfig) *types.ModelSettingsConfig {
+	fields := unsupportedSettings(model, settings)
+	if len(fields) == 0 {
+		return settings
+	}
+	supported := *settings
+	for _, field := range fields {
+		switch field {
+		case "thinking_level":
+			supported.ThinkingLevel = nil
+		case "media_resolution":
+			supported.MediaResolution = nil
+		case "max_output_tokens":
+			maxTokens := familyOf(model).maxOutputTokens
+			supported.MaxOutputTokens = &maxTokens
+		}
+	}
+	return &supported
+}
+
+// ==================== Model Settings Validation ====================
+
+// ValidateModelSettings checks the ranges of settings and, when model
+// belongs to a known family, that the family accepts them.
+func ValidateModelSettings(model string, settings *types.ModelSettingsConfig) error {
+	if settings == nil {
+		return nil
+	}
+	if t := settings.Temperature; t != nil && (*t < 0 || *t > 2) {
+		return fmt.Errorf("temperature must be between 0 and 2")
+	}
+	if n := settings.MaxOutputTokens; n != nil && (*n < 1 || *n > 65536) {
+		return fmt.Errorf("max_output_tokens must be between 1 and 65536")
+	}
+	if p := settings.TopP; p != nil && (*p < 0 || *p > 1) {
+		return fmt.Errorf("top_p must be between 0 and 1")
+	}
+	if k := settings.TopK; k != nil && (*k < 1 || *k > 100) {
+		return fmt.Errorf("top_k must be between 1 and 100")
+	}
+	if level := settings.ThinkingLevel; level != nil {
+		switch *level {
+		case "", "LOW", "MEDIUM", "HIGH":
+		default:
+			return fmt.Errorf("thinking_level must be LOW, MEDIUM, HIGH, or empty")
+		}
+	}
+	if resolution := settings.MediaResolution; resolution != nil {
+		switch *resolution {
+		case "", "MEDIA_RESOLUTION_LOW", "MEDIA_RESOLUTION_MEDIUM", "MEDIA_RESOLUTION_HIGH":
+		default:
+			return fmt.Errorf("invalid media_resolution %q", *resolution)
+		}
+	}
+	if settings.SafetyPreset != nil {
+		if err := ValidateSafetyPreset(*settings.SafetyPreset); err != nil {
+			return err
+		}
+	}
+	if err := ValidateSafetyThresholds(settings.SafetyThresholds); err != nil {
+		return err
+	}
+	for name, override := range settings.ModelSafety {
+		if err := ValidateSafetyOverride(&override); err != nil {
+			return fmt.Errorf("model_safety for %s: %w", name, err)
+		}
+	}
+
+	if fields := unsupportedSettings(model, settings); len(fields) > 0 {
+		return fmt.Errorf("%s not supported by %s", strings.Join(fields, ", "), familyOf(model).prefix)
+	}
+	return nil
+}
+
+// ==================== Model Profiles ====================
+
+// ValidateProfilePattern checks that pattern is a model name or a valid glob.
+func ValidateProfilePattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// ProfileModel returns the Gemini model a profile pattern targets, taken
+// from the pattern up to its first wildcard, for family validation.
+func ProfileModel(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?["); i >= 0 {
+		return pattern[:i]
+	}
+	return MapModelName(pattern)
+}
+
+// MatchModelProfile returns the profile that applies to model, or nil. The
+// requested name and its Gemini name are both tried; an exact pattern wins
+// over a glob, and a longer glob over a shorter one.
+func MatchModelProfile(profiles []types.ModelProfile, model string) *types.ModelProfile {
+	names := []string{model}
+	if mapped := MapModelName(model); mapped != model {
+		names = append(names, mapped)
+	}
+
+	var best *types.ModelProfile
+	bestScore := -1
+	for i := range profiles {
+		pattern := profiles[i].Pattern
+		for _, name := range names {
+			score := -1
+			if pattern == name {
+				score = 1 << 16
+			} else if matched, _ := path.Match(pattern, name); matched {
+				score = len(pattern)
+			}
+			if score > bestScore {
+				best, bestScore = &profiles[i], score
+			}
+		}
+	}
+	return best
+}
+
+// MergeModelSettings layers overlay over base: every field overlay sets wins.
+// A safety preset in overlay also replaces the thresholds of base, matching
+// how ResolveSafetySettings applies a layer. Either argument may be nil.
+func MergeModelSettings(base, overlay *types.ModelSettingsConfig) *types.ModelSettingsConfig {
+	if overlay == nil {
+		return base
+	}
+	if base == nil {
+		return overlay
+	}
+
+	merged := *base
+	if overlay.SystemPrompt != "" {
+		merged.SystemPrompt = overlay.SystemPrompt
+	}
+	if overlay.Temperature != nil {
+		merged.Temperature = overlay.Temperature
+	}
+	if overlay.MaxOutputTokens != nil {
+		merged.MaxOutputTokens = overlay.MaxOutputTokens
+	}
+	if overlay.TopP != nil {
+		merged.TopP = overlay.TopP
+	}
+	if overlay.TopK != nil {
+		merged.TopK = overlay.TopK
+	}
+	if overlay.ThinkingLevel != nil {
+		merged.ThinkingLevel = overlay.ThinkingLevel
+	}
+	if overlay.MediaResolution != nil {
+		merged.MediaResolution = overlay.MediaResolution
+	}
+	if overlay.StreamOutput != nil {
+		merged.StreamOutput = overlay.StreamOutput
+	}
+
+	if overlay.SafetyPreset != nil {
+		merged.SafetyPreset = overlay.SafetyPreset
+		merged.SafetyThresholds = overlay.SafetyThresholds
+	} else if len(overlay.SafetyThresholds) > 0 {
+		merged.SafetyThresholds = mergeMaps(base.SafetyThresholds, overlay.SafetyThresholds)
+	}
+	if len(overlay.ModelSafety) > 0 {
+		merged.ModelSafety = mergeMaps(base.ModelSafety, overlay.ModelSafety)
+	}
+	return &merged
+}
+
+// mergeMaps returns a new map with the entries of base and overlay, overlay
+// winning on conflicts.
+func mergeMaps[V any](base, overlay map[string]V) map[string]V {
+	merged := make(map[string]V, len(base)+len(overlay))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overlay {
+		merged[k] = v
+	}
+	return merged
+}
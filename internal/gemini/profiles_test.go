@@ -0,0 +1,128 @@
+﻿package gemini
+
+import (
+	"testing"
+
+	"muxueTools/internal/types"
+)
+
+func TestMatchModelProfile(t *testing.T) {
+	profiles := []types.ModelProfile{
+		{ID: "all", Pattern: "gemini-*"},
+		{ID: "flash", Pattern: "gemini-2.5-flash*"},
+		{ID: "lite", Pattern: "gemini-2.5-flash-lite"},
+		{ID: "mapped", Pattern: "gemini-1.5-flash-latest"},
+	}
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"gemini-2.5-flash-lite", "lite"},
+		{"gemini-2.5-flash", "flash"},
+		{"gemini-2.5-pro", "all"},
+		{"gpt-4o", "mapped"}, // matched by its Gemini name
+		{"claude-3", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got := MatchModelProfile(profiles, tt.model)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("expected no profile, got %s", got.ID)
+				}
+				return
+			}
+			if got == nil || got.ID != tt.want {
+				t.Errorf("expected profile %s, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMergeModelSettings(t *testing.T) {
+	temp, topP := 0.7, 0.9
+	base := &types.ModelSettingsConfig{
+		SystemPrompt:     "Global",
+		Temperature:      &temp,
+		SafetyThresholds: map[string]string{"harassment": types.SafetyThresholdBlockNone},
+	}
+	overlay := &types.ModelSettingsConfig{
+		TopP:             &topP,
+		SafetyThresholds: map[string]string{"hate_speech": types.SafetyThresholdBlockNone},
+	}
+
+	merged := MergeModelSettings(base, overlay)
+	if merged.SystemPrompt != "Global" || merged.Temperature != &temp || merged.TopP != &topP {
+		t.Errorf("unexpected merge result: %+v", merged)
+	}
+	if len(merged.SafetyThresholds) != 2 {
+		t.Errorf("expected thresholds of both layers, got %v", merged.SafetyThresholds)
+	}
+	if len(base.SafetyThresholds) != 1 {
+		t.Errorf("merge must not modify the base settings")
+	}
+
+	// A preset in the overlay replaces the base thresholds
+	overlay.SafetyPreset = strPtr(types.SafetyPresetRelaxed)
+	merged = MergeModelSettings(base, overlay)
+	if _, ok := merged.SafetyThresholds["harassment"]; ok {
+		t.Errorf("expected base thresholds to be replaced, got %v", merged.SafetyThresholds)
+	}
+}
+
+func TestApplyModelSettings_DropsUnsupportedSettings(t *testing.T) {
+	level := "HIGH"
+	tokens := 100000
+	settings := &types.ModelSettingsConfig{ThinkingLevel: &level, MaxOutputTokens: &tokens}
+
+	tests := []struct {
+		model        string
+		wantThinking bool
+		wantTokens   int
+	}{
+		{"gemini-2.5-pro", true, 65536},
+		{"gemini-2.5-flash-lite", false, 65536},
+		{"gemini-2.0-flash", false, 8192},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			source := &types.ChatCompletionRequest{
+				Model:    tt.model,
+				Messages: []types.Message{makeTextMessage("user", "Test")},
+			}
+			req, err := ConvertOpenAIRequest(source)
+			if err != nil {
+				t.Fatalf("ConvertOpenAIRequest failed: %v", err)
+			}
+			ApplyModelSettings(req, settings, source)
+
+			if got := req.GenerationConfig.ThinkingConfig != nil; got != tt.wantThinking {
+				t.Errorf("thinking config applied = %v, want %v", got, tt.wantThinking)
+			}
+			if got := *req.GenerationConfig.MaxOutputTokens; got != tt.wantTokens {
+				t.Errorf("MaxOutputTokens = %d, want %d", got, tt.wantTokens)
+			}
+		})
+	}
+	if *settings.ThinkingLevel != "HIGH" || *settings.MaxOutputTokens != 100000 {
+		t.Error("the shared settings must not be modified")
+	}
+}
+
+func TestValidateModelSettings_ModelFamily(t *testing.T) {
+	level := "LOW"
+	settings := &types.ModelSettingsConfig{ThinkingLevel: &level}
+
+	if err := ValidateModelSettings("gemini-2.5-pro", settings); err != nil {
+		t.Errorf("thinking level should be valid for 2.5-pro: %v", err)
+	}
+	if err := ValidateModelSettings("gemini-2.5-flash-lite", settings); err == nil {
+		t.Error("expected thinking level to be rejected for flash-lite")
+	}
+	if err := ValidateModelSettings("custom-model", settings); err != nil {
+		t.Errorf("unknown families should accept every setting: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+﻿package gemini
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Upstream Proxy ====================
+
+// KeyProxyResolver returns the proxy override of a key (its own or its
+// group's), or "" to use the client's global proxy.
+type KeyProxyResolver func(key *types.Key) string
+
+// WithProxy sets the global upstream proxy, an http, https, socks5 or socks5h
+// URL. types.ProxyDirect ignores the HTTP(S)_PROXY environment variables.
+func WithProxy(proxy string) ClientOption {
+	return func(c *Client) {
+		c.proxy = proxy
+	}
+}
+
+// WithKeyProxyResolver sets how per-key proxy overrides are looked up.
+func WithKeyProxyResolver(resolver KeyProxyResolver) ClientOption {
+	return func(c *Client) {
+		c.keyProxy = resolver
+	}
+}
+
+// proxyClients caches one HTTP client per proxy URL so connections to the
+// same proxy are reused.
+type proxyClients struct {
+	mu      sync.Mutex
+	clients map[string]*http.Client
+}
+
+// HTTPClient returns the HTTP client for upstream requests through proxy, or
+// through the global proxy when proxy is "". Without any proxy it is the
+// client's base HTTP client, which honors the HTTP(S)_PROXY variables.
+func (c *Client) HTTPClient(proxy string) *http.Client {
+	if proxy == "" {
+		proxy = c.proxy
+	}
+	if proxy == "" {
+		return c.httpClient
+	}
+
+	c.proxyClients.mu.Lock()
+	defer c.proxyClients.mu.Unlock()
+	if client, ok := c.proxyClients.clients[proxy]; ok {
+		return client
+	}
+
+	client := &http.Client{
+		Transport: c.proxyTransport(proxy),
+		Timeout:   c.httpClient.Timeout,
+	}
+	if c.proxyClients.clients == nil {
+		c.proxyClients.clients = make(map[string]*http.Client)
+	}
+	c.proxyClients.clients[proxy] = client
+	return client
+}
+
+// httpClientForKey returns the HTTP client for upstream requests made with key.
+func (c *Client) httpClientForKey(key *types.Key) *http.Client {
+	if c.keyProxy == nil {
+		return c.HTTPClient("")
+	}
+	return c.HTTPClient(c.keyProxy(key))
+}
+
+// proxyTransport clones the base transport and routes it through proxy.
+// A malformed URL makes every request fail rather than silently going direct.
+func (c *Client) proxyTransport(proxy string) *http.Transport {
+	base, ok := c.httpClient.Transport.(*http.Transport)
+	if !ok {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	transport := base.Clone()
+
+	if proxy == types.ProxyDirect {
+		transport.Proxy = nil
+		return transport
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		transport.Proxy = func(*http.Request) (*url.URL, error) { return nil, err }
+		return transport
+	}
+	// http.Transport dials socks5 proxies itself
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return transport
+}
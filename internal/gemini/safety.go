@@ -0,0 +1,151 @@
+﻿package gemini
+
+import (
+	"fmt"
+	"strings"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Safety Settings ====================
+
+// safetyCategories lists the categories we send, in request order.
+var safetyCategories = []string{
+	types.SafetyCategoryHarassment,
+	types.SafetyCategoryHateSpeech,
+	types.SafetyCategorySexuallyExplicit,
+	types.SafetyCategoryDangerousContent,
+}
+
+// safetyCategoryAliases maps the short category names accepted in
+// configuration and requests to Gemini categories.
+var safetyCategoryAliases = map[string]string{
+	"harassment":        types.SafetyCategoryHarassment,
+	"hate_speech":       types.SafetyCategoryHateSpeech,
+	"sexually_explicit": types.SafetyCategorySexuallyExplicit,
+	"dangerous_content": types.SafetyCategoryDangerousContent,
+}
+
+// safetyPresets maps each preset to the threshold it sets for every
+// category; the default preset leaves them to Gemini.
+var safetyPresets = map[string]string{
+	types.SafetyPresetDefault:   "",
+	types.SafetyPresetRelaxed:   types.SafetyThresholdBlockHighAndAbove,
+	types.SafetyPresetBlockNone: types.SafetyThresholdBlockNone,
+}
+
+// validSafetyThresholds holds the thresholds that may be configured.
+var validSafetyThresholds = map[string]bool{
+	types.SafetyThresholdBlockNone:           true,
+	types.SafetyThresholdBlockLowAndAbove:    true,
+	types.SafetyThresholdBlockMediumAndAbove: true,
+	types.SafetyThresholdBlockHighAndAbove:   true,
+}
+
+// normalizeSafetyCategory resolves a short or full category name.
+func normalizeSafetyCategory(name string) (string, bool) {
+	if category, ok := safetyCategoryAliases[strings.ToLower(name)]; ok {
+		return category, true
+	}
+	upper := strings.ToUpper(name)
+	for _, category := range safetyCategories {
+		if category == upper {
+			return category, true
+		}
+	}
+	return "", false
+}
+
+// ValidateSafetyPreset checks that preset is empty or a known preset.
+func ValidateSafetyPreset(preset string) error {
+	if _, ok := safetyPresets[preset]; preset != "" && !ok {
+		return fmt.Errorf("safety preset must be %s, %s or %s",
+			types.SafetyPresetDefault, types.SafetyPresetRelaxed, types.SafetyPresetBlockNone)
+	}
+	return nil
+}
+
+// ValidateSafetyThresholds checks every category and threshold of a
+// category -> threshold map.
+func ValidateSafetyThresholds(thresholds map[string]string) error {
+	for name, threshold := range thresholds {
+		if _, ok := normalizeSafetyCategory(name); !ok {
+			return fmt.Errorf("unknown safety category %q", name)
+		}
+		if !validSafetyThresholds[strings.ToUpper(threshold)] {
+			return fmt.Errorf("invalid safety threshold %q for %s", threshold, name)
+		}
+	}
+	return nil
+}
+
+// ValidateSafetyOverride checks the preset and thresholds of an override.
+func ValidateSafetyOverride(override *types.SafetyOverride) error {
+	if override == nil {
+		return nil
+	}
+	if err := ValidateSafetyPreset(override.Preset); err != nil {
+		return err
+	}
+	return ValidateSafetyThresholds(override.Thresholds)
+}
+
+// applySafetyLayer applies one layer of settings to the thresholds resolved
+// so far: a preset replaces every category, then thresholds win per category.
+// Invalid entries are skipped.
+func applySafetyLayer(resolved map[string]string, preset string, thresholds map[string]string) {
+	if threshold, ok := safetyPresets[preset]; ok && preset != "" {
+		for _, category := range safetyCategories {
+			if threshold == "" {
+				delete(resolved, category)
+			} else {
+				resolved[category] = threshold
+			}
+		}
+	}
+	for name, threshold := range thresholds {
+		category, ok := normalizeSafetyCategory(name)
+		threshold = strings.ToUpper(threshold)
+		if ok && validSafetyThresholds[threshold] {
+			resolved[category] = threshold
+		}
+	}
+}
+
+// ResolveSafetySettings merges the global, per-model and per-request safety
+// settings in increasing priority. Per-model settings are looked up by the
+// requested model name, then by its Gemini name. It returns nil when every
+// category is left at Gemini's default.
+func ResolveSafetySettings(settings *types.ModelSettingsConfig, model string, override *types.SafetyOverride) []types.GeminiSafetySetting {
+	resolved := make(map[string]string)
+
+	if settings != nil {
+		if settings.SafetyPreset != nil {
+			applySafetyLayer(resolved, *settings.SafetyPreset, nil)
+		}
+		applySafetyLayer(resolved, "", settings.SafetyThresholds)
+
+		modelSafety, ok := settings.ModelSafety[model]
+		if !ok {
+			modelSafety, ok = settings.ModelSafety[MapModelName(model)]
+		}
+		if ok {
+			applySafetyLayer(resolved, modelSafety.Preset, modelSafety.Thresholds)
+		}
+	}
+
+	if override != nil {
+		applySafetyLayer(resolved, override.Preset, override.Thresholds)
+	}
+
+	if len(resolved) == 0 {
+		return nil
+	}
+	result := make([]types.GeminiSafetySetting, 0, len(resolved))
+	for _, category := range safetyCategories {
+		if threshold, ok := resolved[category]; ok {
+			result = append(result, types.GeminiSafetySetting{Category: category, Threshold: threshold})
+		}
+	}
+	return result
+}
@@ -0,0 +1,161 @@
+﻿package gemini
+
+import (
+	"testing"
+
+	"muxueTools/internal/types"
+)
+
+func strPtr(s string) *string { return &s }
+
+func thresholdsOf(settings []types.GeminiSafetySetting) map[string]string {
+	result := make(map[string]string, len(settings))
+	for _, s := range settings {
+		result[s.Category] = s.Threshold
+	}
+	return result
+}
+
+func TestResolveSafetySettings_Priority(t *testing.T) {
+	settings := &types.ModelSettingsConfig{
+		SafetyPreset: strPtr(types.SafetyPresetRelaxed),
+		SafetyThresholds: map[string]string{
+			"hate_speech": types.SafetyThresholdBlockMediumAndAbove,
+		},
+		ModelSafety: map[string]types.SafetyOverride{
+			"gemini-2.5-pro": {Thresholds: map[string]string{
+				types.SafetyCategorySexuallyExplicit: types.SafetyThresholdBlockNone,
+			}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		model    string
+		override *types.SafetyOverride
+		want     map[string]string
+	}{
+		{
+			name:  "global preset and thresholds",
+			model: "gemini-2.5-flash",
+			want: map[string]string{
+				types.SafetyCategoryHarassment:       types.SafetyThresholdBlockHighAndAbove,
+				types.SafetyCategoryHateSpeech:       types.SafetyThresholdBlockMediumAndAbove,
+				types.SafetyCategorySexuallyExplicit: types.SafetyThresholdBlockHighAndAbove,
+				types.SafetyCategoryDangerousContent: types.SafetyThresholdBlockHighAndAbove,
+			},
+		},
+		{
+			name:  "model override",
+			model: "gemini-2.5-pro",
+			want: map[string]string{
+				types.SafetyCategoryHarassment:       types.SafetyThresholdBlockHighAndAbove,
+				types.SafetyCategoryHateSpeech:       types.SafetyThresholdBlockMediumAndAbove,
+				types.SafetyCategorySexuallyExplicit: types.SafetyThresholdBlockNone,
+				types.SafetyCategoryDangerousContent: types.SafetyThresholdBlockHighAndAbove,
+			},
+		},
+		{
+			name:  "request preset replaces every category",
+			model: "gemini-2.5-pro",
+			override: &types.SafetyOverride{
+				Preset:     types.SafetyPresetBlockNone,
+				Thresholds: map[string]string{"dangerous_content": "block_low_and_above"},
+			},
+			want: map[string]string{
+				types.SafetyCategoryHarassment:       types.SafetyThresholdBlockNone,
+				types.SafetyCategoryHateSpeech:       types.SafetyThresholdBlockNone,
+				types.SafetyCategorySexuallyExplicit: types.SafetyThresholdBlockNone,
+				types.SafetyCategoryDangerousContent: types.SafetyThresholdBlockLowAndAbove,
+			},
+		},
+		{
+			name:     "request default preset restores Gemini defaults",
+			model:    "gemini-2.5-flash",
+			override: &types.SafetyOverride{Preset: types.SafetyPresetDefault},
+			want:     map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := thresholdsOf(ResolveSafetySettings(settings, tt.model, tt.override))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for category, threshold := range tt.want {
+				if got[category] != threshold {
+					t.Errorf("%s = %q, want %q", category, got[category], threshold)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveSafetySettings_NoneConfigured(t *testing.T) {
+	if got := ResolveSafetySettings(nil, "gemini-2.5-flash", nil); got != nil {
+		t.Errorf("expected nil safety settings, got %v", got)
+	}
+	if got := ResolveSafetySettings(&types.ModelSettingsConfig{SafetyPreset: strPtr(types.SafetyPresetDefault)}, "gemini-2.5-flash", nil); got != nil {
+		t.Errorf("default preset should send no safety settings, got %v", got)
+	}
+}
+
+func TestValidateSafetyOverride(t *testing.T) {
+	tests := []struct {
+		name     string
+		override *types.SafetyOverride
+		wantErr  bool
+	}{
+		{"nil", nil, false},
+		{"preset", &types.SafetyOverride{Preset: types.SafetyPresetRelaxed}, false},
+		{"full category name", &types.SafetyOverride{Thresholds: map[string]string{types.SafetyCategoryHarassment: "BLOCK_NONE"}}, false},
+		{"unknown preset", &types.SafetyOverride{Preset: "lenient"}, true},
+		{"unknown category", &types.SafetyOverride{Thresholds: map[string]string{"violence": "BLOCK_NONE"}}, true},
+		{"unknown threshold", &types.SafetyOverride{Thresholds: map[string]string{"harassment": "BLOCK_ALL"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateSafetyOverride(tt.override); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSafetyOverride() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyModelSettings_SafetySettings(t *testing.T) {
+	source := &types.ChatCompletionRequest{
+		Model:          "gemini-2.5-flash",
+		Messages:       []types.Message{makeTextMessage("user", "Test")},
+		SafetySettings: &types.SafetyOverride{Preset: types.SafetyPresetBlockNone},
+	}
+	req, err := ConvertOpenAIRequest(source)
+	if err != nil {
+		t.Fatalf("ConvertOpenAIRequest failed: %v", err)
+	}
+
+	// The request override applies even without global settings
+	ApplyModelSettings(req, nil, source)
+
+	if len(req.SafetySettings) != len(safetyCategories) {
+		t.Fatalf("expected %d safety settings, got %v", len(safetyCategories), req.SafetySettings)
+	}
+	for _, s := range req.SafetySettings {
+		if s.Threshold != types.SafetyThresholdBlockNone {
+			t.Errorf("%s = %q, want BLOCK_NONE", s.Category, s.Threshold)
+		}
+	}
+}
+
+func TestConvertOpenAIRequest_InvalidSafetySettings(t *testing.T) {
+	_, err := ConvertOpenAIRequest(&types.ChatCompletionRequest{
+		Model:          "gemini-2.5-flash",
+		Messages:       []types.Message{makeTextMessage("user", "Test")},
+		SafetySettings: &types.SafetyOverride{Preset: "lenient"},
+	})
+	appErr, ok := err.(*types.AppError)
+	if !ok || appErr.Param != "safety_settings" {
+		t.Fatalf("expected invalid safety_settings error, got %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+﻿package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Stream Conformance Tests ====================
+
+// The rules below are checked against streams recorded from the OpenAI API
+// (testdata/openai_stream*.txt) and against our conversion of a recorded
+// Gemini stream, so the converter is held to what real SDKs receive.
+
+// rawChunk is a stream chunk decoded loosely, keeping null apart from absent.
+type rawChunk struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index        int             `json:"index"`
+		Delta        map[string]any  `json:"delta"`
+		FinishReason *string         `json:"finish_reason"`
+		Logprobs     json.RawMessage `json:"logprobs"`
+	} `json:"choices"`
+	Usage *types.Usage `json:"usage"`
+}
+
+// parseSSE splits an SSE body into its data payloads.
+func parseSSE(t *testing.T, body string) []string {
+	t.Helper()
+	var payloads []string
+	for _, line := range strings.Split(body, "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			payloads = append(payloads, data)
+		}
+	}
+	return payloads
+}
+
+// checkStreamConformance asserts that an SSE body follows the OpenAI chunk
+// stream contract: one ID and timestamp, the role on the first delta only,
+// a single finish_reason, an optional trailing usage chunk and [DONE].
+func checkStreamConformance(t *testing.T, body string, includeUsage bool) {
+	t.Helper()
+	payloads := parseSSE(t, body)
+	if len(payloads) < 2 || payloads[len(payloads)-1] != "[DONE]" {
+		t.Fatalf("stream must end with [DONE], got %q", payloads)
+	}
+	payloads = payloads[:len(payloads)-1]
+
+	chunks := make([]rawChunk, len(payloads))
+	for i, payload := range payloads {
+		if err := json.Unmarshal([]byte(payload), &chunks[i]); err != nil {
+			t.Fatalf("chunk %d is not valid JSON: %v", i, err)
+		}
+	}
+
+	first := chunks[0]
+	if !strings.HasPrefix(first.ID, "chatcmpl-") || first.Created == 0 {
+		t.Errorf("first chunk has id %q created %d", first.ID, first.Created)
+	}
+
+	finishes := 0
+	for i, chunk := range chunks {
+		if chunk.ID != first.ID || chunk.Created != first.Created || chunk.Model != first.Model {
+			t.Errorf("chunk %d has id/created/model %q/%d/%q, want %q/%d/%q",
+				i, chunk.ID, chunk.Created, chunk.Model, first.ID, first.Created, first.Model)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Errorf("chunk %d has object %q", i, chunk.Object)
+		}
+
+		isUsageChunk := includeUsage && i == len(chunks)-1
+		if isUsageChunk {
+			if len(chunk.Choices) != 0 || chunk.Usage == nil {
+				t.Errorf("last chunk must have empty choices and usage, got %+v", chunk)
+			} else if chunk.Usage.TotalTokens != chunk.Usage.PromptTokens+chunk.Usage.CompletionTokens {
+				t.Errorf("usage does not add up: %+v", chunk.Usage)
+			}
+			continue
+		}
+
+		if chunk.Usage != nil {
+			t.Errorf("chunk %d carries usage %+v", i, chunk.Usage)
+		}
+		if len(chunk.Choices) != 1 {
+			t.Fatalf("chunk %d has %d choices, want 1", i, len(chunk.Choices))
+		}
+		choice := chunk.Choices[0]
+		if role, ok := choice.Delta["role"]; (i == 0) != ok || (ok && role != "assistant") {
+			t.Errorf("chunk %d delta role = %v (present %v); only the first delta has role assistant", i, role, ok)
+		}
+		if choice.FinishReason != nil {
+			finishes++
+			if *choice.FinishReason == "" {
+				t.Errorf("chunk %d has empty finish_reason", i)
+			}
+		}
+	}
+
+	if finishes != 1 {
+		t.Errorf("got %d chunks with finish_reason, want 1", finishes)
+	}
+}
+
+func readTestdata(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("read testdata: %v", err)
+	}
+	return string(data)
+}
+
+func TestStreamConformance_RecordedOpenAI(t *testing.T) {
+	checkStreamConformance(t, readTestdata(t, "openai_stream.txt"), false)
+	checkStreamConformance(t, readTestdata(t, "openai_stream_include_usage.txt"), true)
+}
+
+// streamAsSSE replays the recorded Gemini stream through the client and
+// renders the events the way the OpenAI handler writes them.
+func streamAsSSE(t *testing.T, streamOptions *types.StreamOptions) string {
+	t.Helper()
+	recorded := readTestdata(t, "gemini_stream.txt")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(recorded))
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, newMockPool(mockKey("key1", "test-key")))
+	events, err := client.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{
+		Model:         "gpt-4",
+		Messages:      []types.Message{types.NewTextContent("user", "Hello")},
+		Stream:        true,
+		StreamOptions: streamOptions,
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream failed: %v", err)
+	}
+
+	var sb strings.Builder
+	for event := range events {
+		if event.Err != nil {
+			t.Fatalf("stream error: %v", event.Err)
+		}
+		if event.Chunk != nil {
+			data, _ := json.Marshal(event.Chunk)
+			sb.WriteString("data: " + string(data) + "\n\n")
+		}
+	}
+	sb.WriteString("data: [DONE]\n\n")
+	return sb.String()
+}
+
+func TestStreamConformance_ConvertedGemini(t *testing.T) {
+	t.Run("without usage", func(t *testing.T) {
+		checkStreamConformance(t, streamAsSSE(t, nil), false)
+	})
+
+	t.Run("include_usage", func(t *testing.T) {
+		body := streamAsSSE(t, &types.StreamOptions{IncludeUsage: true})
+		checkStreamConformance(t, body, true)
+
+		payloads := parseSSE(t, body)
+		var last types.ChatCompletionChunk
+		if err := json.Unmarshal([]byte(payloads[len(payloads)-2]), &last); err != nil {
+			t.Fatalf("decode usage chunk: %v", err)
+		}
+		if last.Usage.PromptTokens != 9 || last.Usage.CompletionTokens != 3 || last.Usage.TotalTokens != 12 {
+			t.Errorf("usage = %+v, want 9/3/12", last.Usage)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+﻿package gemini
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Timeouts ====================
+
+// Cancellation causes that tell the client's own timeouts apart from the
+// caller cancelling or the HTTP transport failing.
+var (
+	errRequestTimeout         = errors.New("upstream request timeout")
+	errStreamFirstByteTimeout = errors.New("upstream stream first-byte timeout")
+	errStreamIdleTimeout      = errors.New("upstream stream idle timeout")
+)
+
+// timeouts holds the hot-reloadable upstream timeouts. Zero disables one.
+type timeouts struct {
+	mu              sync.RWMutex
+	request         time.Duration // Total time for a blocking call
+	streamFirstByte time.Duration // Wait for the first stream chunk
+	streamIdle      time.Duration // Longest gap between stream chunks
+}
+
+// WithStreamTimeouts sets the streaming first-byte and inter-chunk idle timeouts.
+func WithStreamTimeouts(firstByte, idle time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeouts.streamFirstByte = firstByte
+		c.timeouts.streamIdle = idle
+	}
+}
+
+// SetTimeouts replaces the request, stream first-byte and stream idle
+// timeouts. Requests already in flight keep the values they started with.
+func (c *Client) SetTimeouts(request, streamFirstByte, streamIdle time.Duration) {
+	c.timeouts.mu.Lock()
+	defer c.timeouts.mu.Unlock()
+	c.timeouts.request = request
+	c.timeouts.streamFirstByte = streamFirstByte
+	c.timeouts.streamIdle = streamIdle
+}
+
+// Timeouts returns the request, stream first-byte and stream idle timeouts.
+func (c *Client) Timeouts() (request, streamFirstByte, streamIdle time.Duration) {
+	c.timeouts.mu.RLock()
+	defer c.timeouts.mu.RUnlock()
+	return c.timeouts.request, c.timeouts.streamFirstByte, c.timeouts.streamIdle
+}
+
+// withRequestTimeout bounds a blocking call by the request timeout.
+func (c *Client) withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	request, _, _ := c.Timeouts()
+	if request <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, request, errRequestTimeout)
+}
+
+// timeoutError maps a context cancelled by one of the client's timeouts to
+// its AppError, or returns nil when ctx was not cancelled that way.
+func (c *Client) timeoutError(ctx context.Context) *types.AppError {
+	request, firstByte, idle := c.Timeouts()
+	switch context.Cause(ctx) {
+	case errRequestTimeout:
+		return types.NewUpstreamTimeoutError(request)
+	case errStreamFirstByteTimeout:
+		return types.NewStreamFirstByteTimeoutError(firstByte)
+	case errStreamIdleTimeout:
+		return types.NewStreamIdleTimeoutError(idle)
+	}
+	return nil
+}
+
+// ==================== Stream Watchdog ====================
+
+// streamWatchdog cancels a stream that sends no first chunk within the
+// first-byte timeout, or that goes quiet for longer than the idle timeout.
+// Time spent waiting for the downstream consumer is not counted.
+type streamWatchdog struct {
+	cancel    context.CancelCauseFunc
+	firstByte time.Duration
+	idle      time.Duration
+
+	mu       sync.Mutex
+	timer    *time.Timer
+	received bool
+	stopped  bool
+}
+
+// newStreamWatchdog starts the first-byte timer; cancel is called with the
+// matching cause when a timer fires.
+func newStreamWatchdog(cancel context.CancelCauseFunc, firstByte, idle time.Duration) *streamWatchdog {
+	w := &streamWatchdog{cancel: cancel, firstByte: firstByte, idle: idle}
+	if firstByte > 0 {
+		w.timer = time.AfterFunc(firstByte, w.fire)
+	}
+	return w
+}
+
+// fire cancels the stream with the cause for the phase it is in.
+func (w *streamWatchdog) fire() {
+	w.mu.Lock()
+	stopped, received := w.stopped, w.received
+	w.mu.Unlock()
+	if stopped {
+		return
+	}
+	if received {
+		w.cancel(errStreamIdleTimeout)
+	} else {
+		w.cancel(errStreamFirstByteTimeout)
+	}
+}
+
+// touch records upstream activity and restarts the idle timer.
+func (w *streamWatchdog) touch() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stopped {
+		return
+	}
+	w.received = true
+	switch {
+	case w.idle <= 0:
+		if w.timer != nil {
+			w.timer.Stop()
+		}
+	case w.timer == nil:
+		w.timer = time.AfterFunc(w.idle, w.fire)
+	default:
+		w.timer.Reset(w.idle)
+	}
+}
+
+// pause stops the timer while a chunk is handed to the consumer; the next
+// touch restarts it.
+func (w *streamWatchdog) pause() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+// stop disarms the watchdog for good and releases the stream context.
+func (w *streamWatchdog) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.mu.Unlock()
+	w.cancel(nil)
+}
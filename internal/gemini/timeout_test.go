@@ -0,0 +1,166 @@
+﻿package gemini
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Timeout Tests ====================
+
+// newStreamServer starts an upstream that flushes the SSE headers, then sends
+// each chunk after its delay and holds the connection open until the client
+// goes away. Handlers drain the request body so a client disconnect cancels
+// r.Context().
+func newStreamServer(t *testing.T, delays []time.Duration, chunks []string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		flusher := w.(http.Flusher)
+		flusher.Flush()
+		for i, chunk := range chunks {
+			select {
+			case <-time.After(delays[i]):
+			case <-r.Context().Done():
+				return
+			}
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+			flusher.Flush()
+		}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// requireTimeoutCode asserts err is a 504 AppError with the given code.
+func requireTimeoutCode(t *testing.T, err error, code int) {
+	t.Helper()
+	appErr, ok := err.(*types.AppError)
+	if !ok {
+		t.Fatalf("expected *types.AppError, got %T: %v", err, err)
+	}
+	if appErr.Code != code || appErr.HTTPStatus != http.StatusGatewayTimeout {
+		t.Errorf("got code %d status %d, want code %d status 504", appErr.Code, appErr.HTTPStatus, code)
+	}
+}
+
+// collectStream drains a stream and returns its chunk count and first error.
+func collectStream(t *testing.T, events <-chan StreamEvent) (int, error) {
+	t.Helper()
+	chunks := 0
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return chunks, nil
+			}
+			if event.Err != nil {
+				return chunks, event.Err
+			}
+			if event.Chunk != nil {
+				chunks++
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("stream did not finish")
+		}
+	}
+}
+
+func TestClient_ChatCompletion_RequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, newMockPool(mockKey("key1", "test-key")))
+	client.SetTimeouts(50*time.Millisecond, 0, 0)
+
+	_, err := client.ChatCompletion(context.Background(), &types.ChatCompletionRequest{
+		Model:    "gpt-4",
+		Messages: []types.Message{types.NewTextContent("user", "Hello")},
+	})
+	requireTimeoutCode(t, err, types.ErrCodeUpstreamTimeout)
+}
+
+func TestClient_ChatCompletionStream_FirstByteTimeout(t *testing.T) {
+	server := newStreamServer(t, nil, nil)
+
+	client := newTestClient(server.URL, newMockPool(mockKey("key1", "test-key")))
+	client.SetTimeouts(time.Minute, 50*time.Millisecond, time.Minute)
+
+	events, err := client.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{
+		Model:    "gpt-4",
+		Messages: []types.Message{types.NewTextContent("user", "Hello")},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream failed: %v", err)
+	}
+
+	chunks, err := collectStream(t, events)
+	if chunks != 0 {
+		t.Errorf("got %d chunks, want 0", chunks)
+	}
+	requireTimeoutCode(t, err, types.ErrCodeStreamFirstByte)
+}
+
+func TestClient_ChatCompletionStream_IdleTimeout(t *testing.T) {
+	server := newStreamServer(t, []time.Duration{0},
+		[]string{`{"candidates":[{"content":{"parts":[{"text":"Hi"}],"role":"model"},"index":0}]}`})
+
+	client := newTestClient(server.URL, newMockPool(mockKey("key1", "test-key")))
+	client.SetTimeouts(time.Minute, time.Minute, 50*time.Millisecond)
+
+	events, err := client.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{
+		Model:    "gpt-4",
+		Messages: []types.Message{types.NewTextContent("user", "Hello")},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream failed: %v", err)
+	}
+
+	chunks, err := collectStream(t, events)
+	if chunks != 1 {
+		t.Errorf("got %d chunks, want 1", chunks)
+	}
+	requireTimeoutCode(t, err, types.ErrCodeStreamIdle)
+}
+
+func TestClient_ChatCompletionStream_OutlivesRequestTimeout(t *testing.T) {
+	// Five chunks 40ms apart take longer than the request timeout, but no gap
+	// reaches the idle timeout, so the stream completes.
+	chunk := `{"candidates":[{"content":{"parts":[{"text":"x"}],"role":"model"},"index":0}]}`
+	last := `{"candidates":[{"content":{"parts":[{"text":"!"}],"role":"model"},"finishReason":"STOP","index":0}]}`
+	delay := 40 * time.Millisecond
+	server := newStreamServer(t,
+		[]time.Duration{delay, delay, delay, delay, delay},
+		[]string{chunk, chunk, chunk, chunk, last})
+
+	client := newTestClient(server.URL, newMockPool(mockKey("key1", "test-key")))
+	client.SetTimeouts(50*time.Millisecond, time.Second, time.Second)
+
+	events, err := client.ChatCompletionStream(context.Background(), &types.ChatCompletionRequest{
+		Model:    "gpt-4",
+		Messages: []types.Message{types.NewTextContent("user", "Hello")},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream failed: %v", err)
+	}
+
+	chunks, err := collectStream(t, events)
+	if err != nil {
+		t.Fatalf("unexpected stream error: %v", err)
+	}
+	if chunks != 5 {
+		t.Errorf("got %d chunks, want 5", chunks)
+	}
+}
@@ -0,0 +1,72 @@
+﻿package keypool
+
+import (
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Conversation Affinity ====================
+
+// affinityEntry pins a conversation to a key until it expires.
+type affinityEntry struct {
+	keyID   string
+	expires time.Time
+}
+
+// WithAffinityTTL enables conversation affinity: requests carrying the same
+// affinity reuse the key that served the previous turn for up to ttl after it.
+// A ttl of 0 disables affinity.
+func WithAffinityTTL(ttl time.Duration) PoolOption {
+	return func(p *Pool) {
+		if ttl >= 0 {
+			p.affinityTTL = ttl
+		}
+	}
+}
+
+// affinityKey returns the key pinned to the affinity if it is among the
+// candidates and currently available, refreshing its TTL. Caller must hold p.mu.
+func (p *Pool) affinityKey(affinity string, candidates []*types.Key) *types.Key {
+	if affinity == "" || p.affinityTTL <= 0 {
+		return nil
+	}
+
+	entry, ok := p.affinity[affinity]
+	now := p.now()
+	if !ok || now.After(entry.expires) {
+		return nil
+	}
+
+	for _, key := range candidates {
+		if key.ID != entry.keyID {
+			continue
+		}
+		if !key.Enabled || key.Status != types.KeyStatusActive || !key.InSchedule(now) || !p.withinLimits(key, now) {
+			return nil
+		}
+		p.affinity[affinity] = affinityEntry{keyID: key.ID, expires: now.Add(p.affinityTTL)}
+		return key
+	}
+	return nil
+}
+
+// bindAffinity pins the affinity to the key and prunes expired entries at most
+// once per TTL. Caller must hold p.mu.
+func (p *Pool) bindAffinity(affinity string, key *types.Key) {
+	if affinity == "" || p.affinityTTL <= 0 {
+		return
+	}
+
+	now := p.now()
+	if now.Sub(p.affinitySweptAt) >= p.affinityTTL {
+		for id, entry := range p.affinity {
+			if now.After(entry.expires) {
+				delete(p.affinity, id)
+			}
+		}
+		p.affinitySweptAt = now
+	}
+
+	p.affinity[affinity] = affinityEntry{keyID: key.ID, expires: now.Add(p.affinityTTL)}
+}
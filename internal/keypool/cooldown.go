@@ -0,0 +1,50 @@
+﻿package keypool
+
+import (
+	"math"
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// WithCooldownBackoff sets how the cooldown grows for keys that are rate
+// limited repeatedly. Invalid values fall back to a fixed cooldown.
+func WithCooldownBackoff(backoff types.CooldownBackoffConfig) PoolOption {
+	return func(p *Pool) {
+		p.cooldownBackoff = backoff
+	}
+}
+
+// enterCooldown puts a key into cooldown and extends its cooldown streak.
+// A key that is still cooling down (e.g. several in-flight requests failing
+// together) keeps its current cooldown and streak. Caller must hold p.mu.
+func (p *Pool) enterCooldown(key *types.Key) {
+	now := p.now()
+	if key.Status == types.KeyStatusRateLimited && key.CooldownUntil != nil && now.Before(*key.CooldownUntil) {
+		return
+	}
+
+	key.CooldownStreak++
+	until := now.Add(p.cooldownDuration(p.cooldownSecondsFor(key), key.CooldownStreak))
+	key.Status = types.KeyStatusRateLimited
+	key.CooldownUntil = &until
+}
+
+// cooldownDuration returns the length of the streak-th consecutive cooldown
+// for a key whose base cooldown is baseSeconds. The first cooldown of a streak
+// is always exactly the base cooldown.
+func (p *Pool) cooldownDuration(baseSeconds, streak int) time.Duration {
+	base := float64(baseSeconds)
+	backoff := p.cooldownBackoff
+	ceiling := math.Max(float64(backoff.MaxSeconds), base)
+	if base <= 0 || streak <= 1 || backoff.Multiplier <= 1 || ceiling == base {
+		return time.Duration(baseSeconds) * time.Second
+	}
+
+	seconds := math.Min(base*math.Pow(backoff.Multiplier, float64(streak-1)), ceiling)
+	if backoff.Jitter > 0 && backoff.Jitter <= 1 {
+		seconds *= 1 + backoff.Jitter*(2*p.randFloat()-1)
+		seconds = math.Min(seconds, ceiling)
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
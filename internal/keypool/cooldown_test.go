@@ -0,0 +1,96 @@
+﻿package keypool
+
+import (
+	"testing"
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Cooldown Backoff Tests ====================
+
+func TestPool_CooldownBackoff_Escalates(t *testing.T) {
+	pool := NewPool([]types.KeyConfig{{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true}},
+		WithCooldownSeconds(60),
+		WithCooldownBackoff(types.CooldownBackoffConfig{Multiplier: 2, MaxSeconds: 300}),
+	)
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+	rateLimitErr := &types.AppError{Code: types.ErrCodeRateLimit, HTTPStatus: 429}
+	key := pool.keys[0]
+
+	// 60s, 120s, 240s, then capped at 300s
+	for i, want := range []time.Duration{60, 120, 240, 300, 300} {
+		pool.ReportFailure(key, rateLimitErr, "")
+		if got := key.CooldownUntil.Sub(now); got != want*time.Second {
+			t.Errorf("cooldown %d = %v, want %v", i+1, got, want*time.Second)
+		}
+		if key.CooldownStreak != i+1 {
+			t.Errorf("streak after cooldown %d = %d", i+1, key.CooldownStreak)
+		}
+		now = *key.CooldownUntil
+	}
+
+	stats := pool.GetStats()
+	if stats[0].CooldownStreak != 5 || stats[0].CooldownUntil == nil {
+		t.Errorf("stats should show the streak and next retry, got %d %v", stats[0].CooldownStreak, stats[0].CooldownUntil)
+	}
+
+	pool.ReportSuccess(key, 0, 0, "")
+	if key.CooldownStreak != 0 {
+		t.Errorf("success should reset the streak, got %d", key.CooldownStreak)
+	}
+	pool.ReportFailure(key, rateLimitErr, "")
+	if got := key.CooldownUntil.Sub(now); got != 60*time.Second {
+		t.Errorf("cooldown after success = %v, want base cooldown", got)
+	}
+}
+
+func TestPool_CooldownBackoff_WhileCoolingDown(t *testing.T) {
+	pool := NewPool([]types.KeyConfig{{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true}},
+		WithCooldownSeconds(60),
+	)
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+	rateLimitErr := &types.AppError{Code: types.ErrCodeRateLimit, HTTPStatus: 429}
+	key := pool.keys[0]
+
+	// In-flight requests failing together count as a single cooldown
+	pool.ReportFailure(key, rateLimitErr, "")
+	pool.ReportFailure(key, rateLimitErr, "")
+	if key.CooldownStreak != 1 {
+		t.Errorf("streak = %d, want 1", key.CooldownStreak)
+	}
+	if got := key.CooldownUntil.Sub(now); got != 60*time.Second {
+		t.Errorf("cooldown = %v, want 60s", got)
+	}
+}
+
+func TestPool_CooldownDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff types.CooldownBackoffConfig
+		base    int
+		streak  int
+		rand    float64
+		want    time.Duration
+	}{
+		{"first cooldown is the base", types.CooldownBackoffConfig{Multiplier: 2, MaxSeconds: 3600, Jitter: 0.5}, 60, 1, 0, 60 * time.Second},
+		{"multiplier 1 keeps it fixed", types.CooldownBackoffConfig{Multiplier: 1, MaxSeconds: 3600}, 60, 4, 0.5, 60 * time.Second},
+		{"cap below base keeps it fixed", types.CooldownBackoffConfig{Multiplier: 2, MaxSeconds: 0, Jitter: 0.5}, 60, 4, 0, 60 * time.Second},
+		{"jitter shortens", types.CooldownBackoffConfig{Multiplier: 2, MaxSeconds: 3600, Jitter: 0.5}, 60, 2, 0, 60 * time.Second},
+		{"jitter lengthens", types.CooldownBackoffConfig{Multiplier: 2, MaxSeconds: 3600, Jitter: 0.5}, 60, 2, 0.75, 150 * time.Second},
+		{"jitter never exceeds the cap", types.CooldownBackoffConfig{Multiplier: 2, MaxSeconds: 100, Jitter: 0.5}, 60, 2, 0.99, 100 * time.Second},
+		{"zero base", types.CooldownBackoffConfig{Multiplier: 2, MaxSeconds: 3600}, 0, 3, 0.5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool(nil, WithCooldownBackoff(tt.backoff))
+			pool.randFloat = func() float64 { return tt.rand }
+			if got := pool.cooldownDuration(tt.base, tt.streak); got != tt.want {
+				t.Errorf("cooldownDuration(%d, %d) = %v, want %v", tt.base, tt.streak, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+﻿package keypool
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Selection Explain ====================
+
+// ExplainSelection runs key selection for req as a dry run. It reports for
+// every key whether it would be considered and why not, and which key the
+// strategy would choose next. No lease is taken and no strategy state
+// (such as the round-robin position) advances. Affinity is ignored.
+// Returns an invalid request error if the group is not configured.
+func (p *Pool) ExplainSelection(req types.KeyRequest) (*types.KeySelectionExplanation, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	now := p.now()
+	model := types.NormalizeModelName(req.Model)
+	group := req.Group
+	if group == "" {
+		group = p.defaultGroup
+	}
+
+	explanation := &types.KeySelectionExplanation{
+		Model:    model,
+		Group:    group,
+		Strategy: p.strategy.Name(),
+		Keys:     []types.KeyEligibility{},
+	}
+	evaluated := make(map[string]int) // key ID -> index in explanation.Keys
+
+	if group == "" {
+		explanation.Error = p.explainTier(explanation, p.keys, p.strategy, "", model, now, evaluated)
+		return explanation, nil
+	}
+
+	g, ok := p.groups[group]
+	if !ok {
+		return nil, types.NewUnknownKeyGroupError(group)
+	}
+
+	var firstErr string
+	visited := make(map[string]bool)
+	for g != nil && !visited[g.config.Name] {
+		visited[g.config.Name] = true
+		explanation.Groups = append(explanation.Groups, g.config.Name)
+
+		strategy := p.groupStrategy(g)
+		errMsg := p.explainTier(explanation, g.members(p.keys), strategy, g.config.Name, model, now, evaluated)
+		if errMsg == "" {
+			explanation.Strategy = strategy.Name()
+			firstErr = ""
+			break
+		}
+		if firstErr == "" {
+			firstErr = errMsg
+		}
+		g = p.groups[g.config.Fallback]
+	}
+	explanation.Error = firstErr
+
+	// Keys outside every group that was tried
+	for _, key := range p.keys {
+		if _, ok := evaluated[key.ID]; ok {
+			continue
+		}
+		entry := eligibilityEntry(key, "")
+		entry.Reasons = []types.KeyIneligibility{{
+			Reason: types.KeyReasonNotInGroup,
+			Detail: fmt.Sprintf("not a member of group %q or its fallbacks", group),
+		}}
+		explanation.Keys = append(explanation.Keys, entry)
+	}
+	return explanation, nil
+}
+
+// explainTier evaluates the keys of one selection tier (the whole pool or one
+// group), marks the lower-priority keys and records the next key. Keys already
+// evaluated in an earlier tier keep their entry. Returns the error selection
+// would fail with, or "" if a key is selectable. Caller must hold p.mu.
+func (p *Pool) explainTier(explanation *types.KeySelectionExplanation, keys []*types.Key, strategy Strategy, group, model string, now time.Time, evaluated map[string]int) string {
+	var candidates []*types.Key
+	for _, key := range keys {
+		idx, ok := evaluated[key.ID]
+		if !ok {
+			entry := eligibilityEntry(key, group)
+			entry.Reasons = p.ineligibility(key, model, now)
+			entry.Eligible = len(entry.Reasons) == 0
+			idx = len(explanation.Keys)
+			evaluated[key.ID] = idx
+			explanation.Keys = append(explanation.Keys, entry)
+		}
+		if explanation.Keys[idx].Eligible {
+			candidates = append(candidates, key)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return selectionError(keys, model, now).Error()
+	}
+
+	top := highestPriority(candidates)
+	for _, key := range candidates {
+		if key.Priority == top[0].Priority {
+			continue
+		}
+		entry := &explanation.Keys[evaluated[key.ID]]
+		entry.Eligible = false
+		entry.Reasons = append(entry.Reasons, types.KeyIneligibility{
+			Reason: types.KeyReasonLowerPriority,
+			Detail: fmt.Sprintf("priority %d is below %d", key.Priority, top[0].Priority),
+		})
+	}
+
+	if next := peekKey(strategy, top, model); next != nil {
+		explanation.NextKeyID = next.ID
+		explanation.NextKey = next.MaskedKey
+	}
+	return ""
+}
+
+// ineligibility lists every reason the key cannot be selected for the model
+// right now. Caller must hold p.mu.
+func (p *Pool) ineligibility(key *types.Key, model string, now time.Time) []types.KeyIneligibility {
+	var reasons []types.KeyIneligibility
+	add := func(reason types.KeyIneligibleReason, detail string, until *time.Time) {
+		reasons = append(reasons, types.KeyIneligibility{Reason: reason, Detail: detail, Until: until})
+	}
+
+	if !key.Enabled || key.Status == types.KeyStatusDisabled {
+		add(types.KeyReasonDisabled, key.DisabledReason, nil)
+	}
+	if model != "" {
+		if !key.SupportsModel(model) {
+			add(types.KeyReasonModelNotAllowed, "allowed, denied or detected models exclude "+model, nil)
+		}
+		if key.IsModelCoolingDown(model, now) {
+			until := key.ModelCooldowns[model]
+			add(types.KeyReasonModelCoolingDown, "upstream rejected "+model+" on this key", &until)
+		}
+	}
+	if key.Status == types.KeyStatusRateLimited && key.CooldownUntil != nil && now.Before(*key.CooldownUntil) {
+		until := *key.CooldownUntil
+		add(types.KeyReasonCoolingDown, "rate limited", &until)
+	}
+
+	if key.NotBefore != nil && now.Before(*key.NotBefore) {
+		until := *key.NotBefore
+		add(types.KeyReasonNotYetActive, "", &until)
+	}
+	if key.IsExpired(now) {
+		add(types.KeyReasonExpired, "expired at "+key.ExpiresAt.Format(time.RFC3339), nil)
+	}
+	inWindow := func(w types.ActiveWindow) bool { return w.Contains(now) }
+	if len(key.ActiveWindows) > 0 && !slices.ContainsFunc(key.ActiveWindows, inWindow) {
+		add(types.KeyReasonOutsideWindow, "", nil)
+	}
+
+	limits := key.Limits
+	if usage, ok := p.usage[key.ID]; ok && limits != (types.KeyLimits{}) {
+		if limits.MaxConcurrent > 0 && usage.inFlight >= limits.MaxConcurrent {
+			add(types.KeyReasonSaturated, fmt.Sprintf("%d of %d concurrent requests in flight", usage.inFlight, limits.MaxConcurrent), nil)
+		}
+		if limits.RPM > 0 && usage.minute == now.Unix()/60 && usage.minuteCount >= limits.RPM {
+			until := time.Unix((usage.minute+1)*60, 0)
+			add(types.KeyReasonOverBudget, fmt.Sprintf("rpm limit %d reached", limits.RPM), &until)
+		}
+		if limits.DailyRequests > 0 && usage.day == now.Format("2006-01-02") && usage.dayCount >= limits.DailyRequests {
+			y, m, d := now.Date()
+			until := time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+			add(types.KeyReasonOverBudget, fmt.Sprintf("daily limit %d reached", limits.DailyRequests), &until)
+		}
+	}
+	return reasons
+}
+
+// eligibilityEntry creates the explanation entry for a key.
+func eligibilityEntry(key *types.Key, group string) types.KeyEligibility {
+	return types.KeyEligibility{
+		KeyID:     key.ID,
+		Name:      key.Name,
+		MaskedKey: key.MaskedKey,
+		Group:     group,
+		Priority:  key.Priority,
+	}
+}
+
+// selectionError returns the error selectFrom fails with when none of the
+// keys is available for the model at now.
+func selectionError(keys []*types.Key, model string, now time.Time) *types.AppError {
+	candidates := eligibleKeys(keys, model, now)
+	if len(candidates) == 0 {
+		if hasEnabledKeys(keys) {
+			return types.NewModelUnavailableError(model)
+		}
+		return types.ErrNoAvailableKeys
+	}
+	if hasEnabledKeys(candidates) {
+		return types.ErrAllKeysRateLimited
+	}
+	return types.ErrNoAvailableKeys
+}
+
+// peekKey returns the key the strategy would select next among keys, or nil
+// if the strategy cannot tell.
+func peekKey(strategy Strategy, keys []*types.Key, model string) *types.Key {
+	if len(keys) == 1 {
+		return keys[0]
+	}
+	if peeker, ok := strategy.(Peeker); ok {
+		return peeker.Peek(keys, model)
+	}
+	return nil
+}
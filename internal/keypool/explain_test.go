@@ -0,0 +1,151 @@
+﻿package keypool
+
+import (
+	"testing"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Selection Explain Tests ====================
+
+// eligibilityOf returns the explanation entry for the named key.
+func eligibilityOf(t *testing.T, explanation *types.KeySelectionExplanation, name string) types.KeyEligibility {
+	t.Helper()
+	for _, entry := range explanation.Keys {
+		if entry.Name == name {
+			return entry
+		}
+	}
+	t.Fatalf("no entry for key %s", name)
+	return types.KeyEligibility{}
+}
+
+// hasReason reports whether the entry lists the reason.
+func hasReason(entry types.KeyEligibility, reason types.KeyIneligibleReason) bool {
+	for _, r := range entry.Reasons {
+		if r.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPool_ExplainSelection_Reasons(t *testing.T) {
+	pool := NewPool([]types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+		{Key: "AIzaSyKey2", Name: "Key 2", Enabled: true, DeniedModels: []string{"gemini-2.5-pro"}},
+		{Key: "AIzaSyKey3", Name: "Key 3", Enabled: false},
+		{Key: "AIzaSyKey4", Name: "Key 4", Enabled: true},
+		{Key: "AIzaSyKey5", Name: "Key 5", Enabled: true, Priority: -1},
+	})
+	key4 := liveKeyByAPIKey(pool, "AIzaSyKey4")
+	pool.ReportFailure(key4, types.NewRateLimitError(60), "gemini-2.5-pro")
+
+	explanation, err := pool.ExplainSelection(types.KeyRequest{Model: "gemini-2.5-pro"})
+	if err != nil {
+		t.Fatalf("ExplainSelection() error = %v", err)
+	}
+
+	if entry := eligibilityOf(t, explanation, "Key 1"); !entry.Eligible || len(entry.Reasons) != 0 {
+		t.Errorf("Key 1 should be eligible, got %+v", entry)
+	}
+	checks := map[string]types.KeyIneligibleReason{
+		"Key 2": types.KeyReasonModelNotAllowed,
+		"Key 3": types.KeyReasonDisabled,
+		"Key 4": types.KeyReasonCoolingDown,
+		"Key 5": types.KeyReasonLowerPriority,
+	}
+	for name, reason := range checks {
+		entry := eligibilityOf(t, explanation, name)
+		if entry.Eligible || !hasReason(entry, reason) {
+			t.Errorf("%s should be ineligible with %s, got %+v", name, reason, entry)
+		}
+	}
+	if cooling := eligibilityOf(t, explanation, "Key 4"); cooling.Reasons[0].Until == nil {
+		t.Error("cooling_down should report when the cooldown ends")
+	}
+	key1 := liveKeyByAPIKey(pool, "AIzaSyKey1")
+	if explanation.NextKeyID != key1.ID || explanation.Error != "" {
+		t.Errorf("next = %q, error = %q, want Key 1 and no error", explanation.NextKeyID, explanation.Error)
+	}
+}
+
+func TestPool_ExplainSelection_DoesNotAdvance(t *testing.T) {
+	pool := NewPool([]types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+		{Key: "AIzaSyKey2", Name: "Key 2", Enabled: true},
+		{Key: "AIzaSyKey3", Name: "Key 3", Enabled: true},
+	})
+
+	for i := 0; i < 5; i++ {
+		first, _ := pool.ExplainSelection(types.KeyRequest{})
+		second, _ := pool.ExplainSelection(types.KeyRequest{})
+		if first.NextKeyID == "" || first.NextKeyID != second.NextKeyID {
+			t.Fatalf("explain changed the next key: %q then %q", first.NextKeyID, second.NextKeyID)
+		}
+
+		key, err := pool.GetKey(types.KeyRequest{})
+		if err != nil {
+			t.Fatalf("GetKey() error = %v", err)
+		}
+		if key.ID != first.NextKeyID {
+			t.Errorf("GetKey() = %s, explain predicted %s", key.ID, first.NextKeyID)
+		}
+		pool.ReleaseKey(key)
+	}
+}
+
+func TestPool_ExplainSelection_Saturated(t *testing.T) {
+	pool := NewPool([]types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true, Limits: types.KeyLimits{MaxConcurrent: 1}},
+	})
+	key, err := pool.GetKey(types.KeyRequest{})
+	if err != nil {
+		t.Fatalf("GetKey() error = %v", err)
+	}
+
+	explanation, _ := pool.ExplainSelection(types.KeyRequest{})
+	if entry := eligibilityOf(t, explanation, "Key 1"); !hasReason(entry, types.KeyReasonSaturated) {
+		t.Errorf("Key 1 should be saturated, got %+v", entry)
+	}
+	if explanation.Error != types.ErrAllKeysRateLimited.Error() || explanation.NextKeyID != "" {
+		t.Errorf("error = %q, next = %q, want rate limited and no next key", explanation.Error, explanation.NextKeyID)
+	}
+
+	// Explaining takes no lease
+	pool.ReleaseKey(key)
+	explanation, _ = pool.ExplainSelection(types.KeyRequest{})
+	if !eligibilityOf(t, explanation, "Key 1").Eligible {
+		t.Error("Key 1 should be eligible after release")
+	}
+}
+
+func TestPool_ExplainSelection_Groups(t *testing.T) {
+	pool := newGroupTestPool()
+	paid := liveKeyByAPIKey(pool, "AIzaSyPaid")
+	disabled := false
+	pool.UpdateKey(paid.ID, types.UpdateKeyRequest{Enabled: &disabled})
+
+	explanation, err := pool.ExplainSelection(types.KeyRequest{Group: "paid"})
+	if err != nil {
+		t.Fatalf("ExplainSelection() error = %v", err)
+	}
+	if len(explanation.Groups) != 2 || explanation.Groups[1] != "free" {
+		t.Errorf("groups = %v, want [paid free]", explanation.Groups)
+	}
+	if explanation.Strategy != string(types.PoolStrategyLeastUsed) || explanation.Error != "" {
+		t.Errorf("strategy = %s, error = %q, want free group's least_used", explanation.Strategy, explanation.Error)
+	}
+	if entry := eligibilityOf(t, explanation, "Paid"); entry.Group != "paid" || !hasReason(entry, types.KeyReasonDisabled) {
+		t.Errorf("Paid entry = %+v", entry)
+	}
+
+	explanation, _ = pool.ExplainSelection(types.KeyRequest{Group: "free"})
+	if entry := eligibilityOf(t, explanation, "Paid"); !hasReason(entry, types.KeyReasonNotInGroup) {
+		t.Errorf("Paid should be outside the free group, got %+v", entry)
+	}
+
+	if _, err := pool.ExplainSelection(types.KeyRequest{Group: "missing"}); err == nil {
+		t.Error("ExplainSelection() should fail for an unknown group")
+	}
+}
@@ -0,0 +1,261 @@
+﻿package keypool
+
+import (
+	"net"
+	"strings"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Key Groups ====================
+
+// keyGroup is a named subset of the pool selected by key tags.
+// A group with its own strategy keeps independent selection state.
+type keyGroup struct {
+	config   types.KeyGroupConfig
+	tags     []string
+	strategy Strategy // nil inherits the pool strategy
+}
+
+// newKeyGroup builds a group from its configuration.
+func newKeyGroup(cfg types.KeyGroupConfig) *keyGroup {
+	group := &keyGroup{config: cfg, tags: cfg.Tags}
+	if len(group.tags) == 0 {
+		group.tags = []string{cfg.Name}
+	}
+	if cfg.Strategy != "" {
+		group.strategy = StrategyFactory(cfg.Strategy)
+	}
+	return group
+}
+
+// contains returns true if any of the key's tags belongs to the group.
+func (g *keyGroup) contains(key *types.Key) bool {
+	for _, tag := range key.Tags {
+		for _, groupTag := range g.tags {
+			if tag == groupTag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// members returns the keys that belong to the group.
+func (g *keyGroup) members(keys []*types.Key) []*types.Key {
+	members := make([]*types.Key, 0, len(keys))
+	for _, key := range keys {
+		if g.contains(key) {
+			members = append(members, key)
+		}
+	}
+	return members
+}
+
+// WithKeyGroups configures tag-based key groups.
+func WithKeyGroups(groups []types.KeyGroupConfig) PoolOption {
+	return func(p *Pool) {
+		for _, cfg := range groups {
+			if cfg.Name == "" {
+				continue
+			}
+			group := newKeyGroup(cfg)
+			p.groups[cfg.Name] = group
+			p.groupOrder = append(p.groupOrder, group)
+		}
+	}
+}
+
+// WithGroupBindings binds bearer tokens and source IPs to default key groups.
+func WithGroupBindings(bindings []types.KeyGroupBinding) PoolOption {
+	return func(p *Pool) {
+		p.groupBindings = append(p.groupBindings, bindings...)
+	}
+}
+
+// WithDefaultGroup sets the key group used by requests that select no group.
+func WithDefaultGroup(name string) PoolOption {
+	return func(p *Pool) {
+		p.defaultGroup = name
+	}
+}
+
+// HasGroup returns true if a key group with the given name is configured.
+func (p *Pool) HasGroup(name string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.groups[name]
+	return ok
+}
+
+// ResolveGroupBinding returns the key group bound to the bearer token or, failing
+// that, to the source IP. Returns "" when nothing matches.
+func (p *Pool) ResolveGroupBinding(token, ip string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if token != "" {
+		for _, binding := range p.groupBindings {
+			if binding.Token != "" && binding.Token == token {
+				return binding.Group
+			}
+		}
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return ""
+	}
+	for _, binding := range p.groupBindings {
+		if binding.IP == "" {
+			continue
+		}
+		if strings.Contains(binding.IP, "/") {
+			if _, network, err := net.ParseCIDR(binding.IP); err == nil && network.Contains(addr) {
+				return binding.Group
+			}
+		} else if bound := net.ParseIP(binding.IP); bound != nil && bound.Equal(addr) {
+			return binding.Group
+		}
+	}
+	return ""
+}
+
+// GetGroupStatus returns the configuration and key health of every key group.
+func (p *Pool) GetGroupStatus() []types.KeyGroupStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if len(p.groupOrder) == 0 {
+		return nil
+	}
+
+	now := p.now()
+	statuses := make([]types.KeyGroupStatus, 0, len(p.groupOrder))
+	for _, group := range p.groupOrder {
+		status := types.KeyGroupStatus{
+			Name:            group.config.Name,
+			Tags:            group.tags,
+			Strategy:        p.groupStrategy(group).Name(),
+			CooldownSeconds: p.groupCooldownSeconds(group),
+			Fallback:        group.config.Fallback,
+			Default:         group.config.Name == p.defaultGroup,
+		}
+		for _, key := range group.members(p.keys) {
+			status.Total++
+			switch {
+			case !key.Enabled || key.Status == types.KeyStatusDisabled:
+				status.Disabled++
+			case key.Status == types.KeyStatusRateLimited && key.CooldownUntil != nil && now.Before(*key.CooldownUntil):
+				status.RateLimited++
+			default:
+				status.Active++
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// selectFromGroup selects a key from the named group, walking its fallback
+// chain until a group yields a key. The first group's error is returned when
+// every group in the chain is exhausted. Caller must hold p.mu.
+func (p *Pool) selectFromGroup(name, model, affinity string) (*types.Key, error) {
+	group, ok := p.groups[name]
+	if !ok {
+		return nil, types.NewUnknownKeyGroupError(name)
+	}
+
+	var firstErr error
+	visited := make(map[string]bool)
+	for group != nil && !visited[group.config.Name] {
+		visited[group.config.Name] = true
+
+		key, err := p.selectFrom(group.members(p.keys), p.groupStrategy(group), model, affinity)
+		if err == nil {
+			return key, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		group = p.groups[group.config.Fallback]
+	}
+	return nil, firstErr
+}
+
+// groupStrategy returns the group's own strategy, or the pool strategy.
+func (p *Pool) groupStrategy(group *keyGroup) Strategy {
+	if group.strategy != nil {
+		return group.strategy
+	}
+	return p.strategy
+}
+
+// groupCooldownSeconds returns the group's cooldown, or the pool cooldown.
+func (p *Pool) groupCooldownSeconds(group *keyGroup) int {
+	if group.config.CooldownSeconds > 0 {
+		return group.config.CooldownSeconds
+	}
+	return p.cooldownSeconds
+}
+
+// cooldownSecondsFor returns the cooldown applied to a key: that of the first
+// configured group containing it with its own cooldown, else the pool cooldown.
+// Caller must hold p.mu.
+func (p *Pool) cooldownSecondsFor(key *types.Key) int {
+	for _, group := range p.groupOrder {
+		if group.config.CooldownSeconds > 0 && group.contains(key) {
+			return group.config.CooldownSeconds
+		}
+	}
+	return p.cooldownSeconds
+}
+
+// ProxyFor returns the upstream proxy override of a key: its own proxy, else
+// that of the first configured group containing it with a proxy, else "" to
+// use the global proxy. The key need not be in the pool.
+func (p *Pool) ProxyFor(key *types.Key) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if key.Proxy != "" {
+		return key.Proxy
+	}
+	for _, group := range p.groupOrder {
+		if group.config.Proxy != "" && group.contains(key) {
+			return group.config.Proxy
+		}
+	}
+	return ""
+}
+
+// groupNamesFor returns the names of the groups a key belongs to. Caller must hold p.mu.
+func (p *Pool) groupNamesFor(key *types.Key) []string {
+	var names []string
+	for _, group := range p.groupOrder {
+		if group.contains(key) {
+			names = append(names, group.config.Name)
+		}
+	}
+	return names
+}
+
+// feedbackStrategies returns the feedback-driven strategies that should learn
+// from the key's results: the pool strategy and those of the key's groups.
+// A nil key selects every group's strategy.
+// Caller must hold p.mu (read or write).
+func (p *Pool) feedbackStrategies(key *types.Key) []FeedbackStrategy {
+	var strategies []FeedbackStrategy
+	if fs, ok := p.strategy.(FeedbackStrategy); ok {
+		strategies = append(strategies, fs)
+	}
+	for _, group := range p.groupOrder {
+		if group.strategy == nil || (key != nil && !group.contains(key)) {
+			continue
+		}
+		if fs, ok := group.strategy.(FeedbackStrategy); ok {
+			strategies = append(strategies, fs)
+		}
+	}
+	return strategies
+}
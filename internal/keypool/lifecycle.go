@@ -0,0 +1,120 @@
+﻿package keypool
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Key Lifecycle ====================
+
+// KeyEventHandler receives lifecycle events such as a key being retired.
+// It is called without the pool lock held.
+type KeyEventHandler func(event types.KeyEvent)
+
+// WithKeyEventHandler sets the handler notified of key lifecycle events.
+func WithKeyEventHandler(handler KeyEventHandler) PoolOption {
+	return func(p *Pool) {
+		p.onKeyEvent = handler
+	}
+}
+
+// RetireExpiredKeys disables every enabled key whose ExpiresAt has passed,
+// persists the change and emits a KeyEventExpired event for each.
+// Returns the retired keys.
+func (p *Pool) RetireExpiredKeys() []types.Key {
+	p.mu.Lock()
+	now := p.now()
+	var retired []types.Key
+	var events []types.KeyEvent
+	for _, key := range p.keys {
+		if !key.Enabled || !key.IsExpired(now) {
+			continue
+		}
+		expiry := key.ExpiresAt.Format(time.RFC3339)
+		key.Enabled = false
+		key.Status = types.KeyStatusDisabled
+		key.CooldownUntil = nil
+		key.DisabledReason = "expired: " + expiry
+		key.UpdatedAt = now
+		p.consecutiveFailures[key.ID] = 0
+		for _, fs := range p.feedbackStrategies(nil) {
+			fs.Forget(key.ID)
+		}
+
+		message := "key expired at " + expiry + " and was disabled"
+		if p.storage != nil {
+			if err := p.storage.UpdateKey(key); err != nil {
+				message += " (not persisted: " + err.Error() + ")"
+			}
+		}
+		retired = append(retired, *key)
+		events = append(events, types.KeyEvent{
+			Type:      types.KeyEventExpired,
+			KeyID:     key.ID,
+			MaskedKey: key.MaskedKey,
+			Name:      key.Name,
+			Message:   message,
+			Time:      now,
+		})
+	}
+	handler := p.onKeyEvent
+	p.mu.Unlock()
+
+	if handler != nil {
+		for _, event := range events {
+			handler(event)
+		}
+	}
+	return retired
+}
+
+// RunLifecycle retires expired keys every interval until ctx is done.
+// It runs one pass immediately.
+func (p *Pool) RunLifecycle(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		p.RetireExpiredKeys()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ExpiringKeys lists the keys that have expired or expire within the given
+// duration, soonest first.
+func (p *Pool) ExpiringKeys(within time.Duration) []types.KeyExpiration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	now := p.now()
+	deadline := now.Add(within)
+	expiring := []types.KeyExpiration{}
+	for _, key := range p.keys {
+		if key.ExpiresAt == nil || key.ExpiresAt.After(deadline) {
+			continue
+		}
+		expiring = append(expiring, types.KeyExpiration{
+			ID:               key.ID,
+			Name:             key.Name,
+			MaskedKey:        key.MaskedKey,
+			Enabled:          key.Enabled,
+			ExpiresAt:        *key.ExpiresAt,
+			Expired:          key.IsExpired(now),
+			RemainingSeconds: int64(key.ExpiresAt.Sub(now) / time.Second),
+		})
+	}
+	slices.SortFunc(expiring, func(a, b types.KeyExpiration) int {
+		return a.ExpiresAt.Compare(b.ExpiresAt)
+	})
+	return expiring
+}
@@ -0,0 +1,164 @@
+﻿package keypool
+
+import (
+	"testing"
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Lifecycle Tests ====================
+
+func TestActiveWindow_Contains(t *testing.T) {
+	// 2026-01-03 is a Saturday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name   string
+		window types.ActiveWindow
+		t      time.Time
+		want   bool
+	}{
+		{"inside daily window", types.ActiveWindow{Start: "09:00", End: "17:00", Timezone: "UTC"}, at(5, 12, 0), true},
+		{"end is exclusive", types.ActiveWindow{Start: "09:00", End: "17:00", Timezone: "UTC"}, at(5, 17, 0), false},
+		{"wrapping window before midnight", types.ActiveWindow{Start: "22:00", End: "06:00", Timezone: "UTC"}, at(5, 23, 0), true},
+		{"wrapping window after midnight", types.ActiveWindow{Start: "22:00", End: "06:00", Timezone: "UTC"}, at(5, 5, 59), true},
+		{"wrapping window daytime", types.ActiveWindow{Start: "22:00", End: "06:00", Timezone: "UTC"}, at(5, 12, 0), false},
+		{"whole weekend day", types.ActiveWindow{Days: []string{"sat", "sun"}, Start: "00:00", End: "00:00", Timezone: "UTC"}, at(3, 15, 0), true},
+		{"weekday outside weekend", types.ActiveWindow{Days: []string{"sat", "sun"}, Start: "00:00", End: "00:00", Timezone: "UTC"}, at(5, 15, 0), false},
+		{"friday night spills into saturday", types.ActiveWindow{Days: []string{"fri"}, Start: "22:00", End: "02:00", Timezone: "UTC"}, at(3, 1, 0), true},
+		{"saturday night is not friday", types.ActiveWindow{Days: []string{"fri"}, Start: "22:00", End: "02:00", Timezone: "UTC"}, at(3, 23, 0), false},
+		{"timezone shifts the window", types.ActiveWindow{Start: "09:00", End: "17:00", Timezone: "Asia/Shanghai"}, at(5, 2, 0), true},
+		{"malformed window", types.ActiveWindow{Start: "9am", End: "17:00"}, at(5, 12, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Contains(tt.t); got != tt.want {
+				t.Errorf("Contains() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPool_GetKey_Schedule(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+	outside := types.ActiveWindow{
+		Start:    now.UTC().Add(2 * time.Hour).Format("15:04"),
+		End:      now.UTC().Add(3 * time.Hour).Format("15:04"),
+		Timezone: "UTC",
+	}
+
+	tests := []struct {
+		name string
+		key  *types.Key
+		want bool
+	}{
+		{"no schedule", &types.Key{}, true},
+		{"not yet active", &types.Key{NotBefore: &future}, false},
+		{"active", &types.Key{NotBefore: &past, ExpiresAt: &future}, true},
+		{"expired", &types.Key{ExpiresAt: &past}, false},
+		{"outside window", &types.Key{ActiveWindows: []types.ActiveWindow{outside}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool(nil)
+			tt.key.ID = "k1"
+			tt.key.APIKey = "AIzaSyKey1"
+			tt.key.Enabled = true
+			tt.key.Status = types.KeyStatusActive
+			if err := pool.AddKey(tt.key); err != nil {
+				t.Fatalf("AddKey() error = %v", err)
+			}
+
+			key, err := pool.GetKey(types.KeyRequest{})
+			if got := err == nil; got != tt.want {
+				t.Fatalf("GetKey() error = %v, want available = %v", err, tt.want)
+			}
+			if key != nil {
+				pool.ReleaseKey(key)
+			}
+		})
+	}
+}
+
+func TestPool_RetireExpiredKeys(t *testing.T) {
+	store := newMemStorage()
+	var events []types.KeyEvent
+	pool := NewPool(nil, WithStorage(store), WithKeyEventHandler(func(event types.KeyEvent) {
+		events = append(events, event)
+	}))
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	for _, key := range []*types.Key{
+		{ID: "expired", APIKey: "AIzaSyExpired", Enabled: true, ExpiresAt: &past},
+		{ID: "valid", APIKey: "AIzaSyValid", Enabled: true, ExpiresAt: &future},
+	} {
+		if err := pool.AddKey(key); err != nil {
+			t.Fatalf("AddKey() error = %v", err)
+		}
+	}
+
+	retired := pool.RetireExpiredKeys()
+	if len(retired) != 1 || retired[0].ID != "expired" {
+		t.Fatalf("retired = %+v, want the expired key", retired)
+	}
+	if len(events) != 1 || events[0].Type != types.KeyEventExpired || events[0].KeyID != "expired" {
+		t.Errorf("events = %+v, want one key_expired event", events)
+	}
+
+	key := pool.findKey("expired")
+	if key.Enabled || key.Status != types.KeyStatusDisabled || key.DisabledReason == "" {
+		t.Errorf("expired key not retired: %+v", key)
+	}
+	if store.keys["expired"].Enabled {
+		t.Error("retirement was not persisted")
+	}
+
+	// Already retired keys are not retired again
+	if retired := pool.RetireExpiredKeys(); len(retired) != 0 {
+		t.Errorf("second pass retired %d keys, want 0", len(retired))
+	}
+}
+
+func TestPool_ExpiringKeys(t *testing.T) {
+	pool := NewPool(nil)
+	now := time.Now()
+	expiresAt := map[string]time.Time{
+		"expired":   now.Add(-time.Hour),
+		"soon":      now.Add(24 * time.Hour),
+		"later":     now.Add(30 * 24 * time.Hour),
+		"very-soon": now.Add(time.Hour),
+	}
+	for id, at := range expiresAt {
+		if err := pool.AddKey(&types.Key{ID: id, APIKey: "AIzaSy" + id, Enabled: true, ExpiresAt: &at}); err != nil {
+			t.Fatalf("AddKey() error = %v", err)
+		}
+	}
+	if err := pool.AddKey(&types.Key{ID: "forever", APIKey: "AIzaSyForever", Enabled: true}); err != nil {
+		t.Fatalf("AddKey() error = %v", err)
+	}
+
+	expiring := pool.ExpiringKeys(7 * 24 * time.Hour)
+	var ids []string
+	for _, e := range expiring {
+		ids = append(ids, e.ID)
+	}
+	want := []string{"expired", "very-soon", "soon"}
+	if len(ids) != len(want) {
+		t.Fatalf("ExpiringKeys() = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("ExpiringKeys() = %v, want %v", ids, want)
+		}
+	}
+	if !expiring[0].Expired || expiring[0].RemainingSeconds >= 0 {
+		t.Errorf("expired entry = %+v, want expired with negative remaining", expiring[0])
+	}
+}
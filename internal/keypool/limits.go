@@ -0,0 +1,108 @@
+﻿package keypool
+
+import (
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Per-Key Limits ====================
+
+// keyUsage tracks the requests started on a key and its in-flight leases,
+// which are checked against the key's KeyLimits during selection.
+type keyUsage struct {
+	minute      int64  // Unix minute that minuteCount belongs to
+	minuteCount int    // Requests started during minute
+	day         string // Local date that dayCount belongs to
+	dayCount    int    // Requests started during day
+	inFlight    int    // Keys handed out by GetKey and not yet released
+}
+
+// usageFor returns the usage tracker for a key, creating it if needed.
+// Caller must hold p.mu.
+func (p *Pool) usageFor(keyID string) *keyUsage {
+	usage, ok := p.usage[keyID]
+	if !ok {
+		usage = &keyUsage{}
+		p.usage[keyID] = usage
+	}
+	return usage
+}
+
+// withinLimits returns true if the key may start another request now.
+// Caller must hold p.mu.
+func (p *Pool) withinLimits(key *types.Key, now time.Time) bool {
+	limits := key.Limits
+	if limits == (types.KeyLimits{}) {
+		return true
+	}
+
+	usage, ok := p.usage[key.ID]
+	if !ok {
+		return true
+	}
+	if limits.MaxConcurrent > 0 && usage.inFlight >= limits.MaxConcurrent {
+		return false
+	}
+	if limits.RPM > 0 && usage.minute == now.Unix()/60 && usage.minuteCount >= limits.RPM {
+		return false
+	}
+	if limits.DailyRequests > 0 && usage.day == now.Format("2006-01-02") && usage.dayCount >= limits.DailyRequests {
+		return false
+	}
+	return true
+}
+
+// acquire records that a request is starting on the key. Caller must hold p.mu.
+func (p *Pool) acquire(key *types.Key, now time.Time) {
+	usage := p.usageFor(key.ID)
+
+	minute := now.Unix() / 60
+	if usage.minute != minute {
+		usage.minute = minute
+		usage.minuteCount = 0
+	}
+	day := now.Format("2006-01-02")
+	if usage.day != day {
+		usage.day = day
+		usage.dayCount = 0
+	}
+
+	usage.minuteCount++
+	usage.dayCount++
+	usage.inFlight++
+}
+
+// availableKeys returns the enabled, active keys that are within their
+// schedule and limits. Caller must hold p.mu.
+func (p *Pool) availableKeys(keys []*types.Key, now time.Time) []*types.Key {
+	available := make([]*types.Key, 0, len(keys))
+	for _, key := range keys {
+		if key.Enabled && key.Status == types.KeyStatusActive && key.InSchedule(now) && p.withinLimits(key, now) {
+			available = append(available, key)
+		}
+	}
+	return available
+}
+
+// highestPriority returns the keys that share the highest priority.
+func highestPriority(keys []*types.Key) []*types.Key {
+	if len(keys) == 0 {
+		return keys
+	}
+
+	top := keys[0].Priority
+	for _, key := range keys[1:] {
+		if key.Priority > top {
+			top = key.Priority
+		}
+	}
+
+	tier := make([]*types.Key, 0, len(keys))
+	for _, key := range keys {
+		if key.Priority == top {
+			tier = append(tier, key)
+		}
+	}
+	return tier
+}
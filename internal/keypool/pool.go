@@ -3,6 +3,7 @@ package keypool
 
 import (
 	"errors"
+	"math/rand"
 	"sync"
 	"time"
 
@@ -55,6 +56,23 @@ func WithStorage(storage KeyStorage) PoolOption {
 	}
 }
 
+// WithModelCooldownSeconds sets how long a key-model pair stays ineligible
+// after upstream rejects the model with NotFound or Permission errors.
+func WithModelCooldownSeconds(seconds int) PoolOption {
+	return func(p *Pool) {
+		p.modelCooldownSeconds = seconds
+	}
+}
+
+// WithStatsWindow sets the rolling window that stats-based strategies rank on.
+func WithStatsWindow(window types.StatsWindow) PoolOption {
+	return func(p *Pool) {
+		if window.IsValid() {
+			p.statsWindow = window
+		}
+	}
+}
+
 // ==================== Pool ====================
 
 // Pool manages a collection of API keys and handles selection, rate limiting, and statistics.
@@ -66,10 +84,39 @@ type Pool struct {
 
 	// Configuration
 	cooldownSeconds        int
+	cooldownBackoff        types.CooldownBackoffConfig
 	maxConsecutiveFailures int
+	modelCooldownSeconds   int
+	statsWindow            types.StatsWindow
 
 	// Internal tracking for consecutive failures per key
 	consecutiveFailures map[string]int
+
+	// Rolling request statistics per key ID. Guarded by windowsMu rather than mu
+	// so strategies can read them while GetKey holds the pool lock.
+	windowsMu sync.Mutex
+	windows   map[string]*slidingWindow
+	now       func() time.Time
+
+	// Source of cooldown jitter in [0, 1)
+	randFloat func() float64
+
+	// Tag-based key groups, in configuration order
+	groups        map[string]*keyGroup
+	groupOrder    []*keyGroup
+	groupBindings []types.KeyGroupBinding
+	defaultGroup  string
+
+	// Conversation affinity to preferred keys
+	affinityTTL     time.Duration
+	affinity        map[string]affinityEntry
+	affinitySweptAt time.Time
+
+	// Per-key request counters and leases for KeyLimits
+	usage map[string]*keyUsage
+
+	// Notified when the pool retires a key on its own
+	onKeyEvent KeyEventHandler
 }
 
 // NewPool creates a new key pool from the provided key configurations.
@@ -78,36 +125,31 @@ func NewPool(configs []types.KeyConfig, opts ...PoolOption) *Pool {
 		keys:                   make([]*types.Key, 0, len(configs)),
 		strategy:               NewRoundRobinStrategy(),
 		cooldownSeconds:        60,
+		cooldownBackoff:        types.DefaultPoolConfig().CooldownBackoff,
 		maxConsecutiveFailures: 5,
+		modelCooldownSeconds:   600,
+		statsWindow:            types.StatsWindow1h,
 		consecutiveFailures:    make(map[string]int),
+		windows:                make(map[string]*slidingWindow),
+		now:                    time.Now,
+		randFloat:              rand.Float64,
+		groups:                 make(map[string]*keyGroup),
+		affinity:               make(map[string]affinityEntry),
+		usage:                  make(map[string]*keyUsage),
 	}
 
 	// Apply options
 	for _, opt := range opts {
 		opt(pool)
 	}
+	pool.bindStrategyWindow(pool.strategy)
+	for _, group := range pool.groupOrder {
+		pool.bindStrategyWindow(group.strategy)
+	}
 
 	// Initialize keys from configs
 	for _, cfg := range configs {
-		key := &types.Key{
-			ID:        uuid.New().String(),
-			APIKey:    cfg.Key,
-			MaskedKey: types.MaskAPIKey(cfg.Key),
-			Name:      cfg.Name,
-			Status:    types.KeyStatusActive,
-			Enabled:   cfg.Enabled,
-			Tags:      cfg.Tags,
-			Stats:     types.KeyStats{},
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-
-		// Handle disabled from config
-		if !cfg.Enabled {
-			key.Status = types.KeyStatusDisabled
-		}
-
-		pool.keys = append(pool.keys, key)
+		pool.keys = append(pool.keys, keyFromConfig(cfg))
 	}
 
 	return pool
@@ -115,10 +157,20 @@ func NewPool(configs []types.KeyConfig, opts ...PoolOption) *Pool {
 
 // ==================== Key Operations ====================
 
-// GetKey retrieves an available key from the pool using the configured strategy.
+// GetKey retrieves an available key for the request using the configured strategy.
+// Only keys whose model patterns permit req.Model, and whose key-model pair is
+// not cooling down, are considered.
+// When req.Group (or the default group) is set, only keys tagged for that group
+// are considered, falling back along the group's fallback chain.
+// When req.Affinity is set and affinity is enabled, the key that served the
+// conversation's previous turn is preferred while it remains available.
+// Otherwise the strategy chooses among the available keys with the highest
+// priority. Keys at their KeyLimits are skipped; callers must ReleaseKey.
+// Returns an invalid request error if the group is not configured.
 // Returns ErrNoAvailableKeys if the pool is empty or all keys are disabled.
-// Returns ErrAllKeysRateLimited if all keys are in cooldown.
-func (p *Pool) GetKey() (*types.Key, error) {
+// Returns a model unavailable error if enabled keys exist but none may serve the model.
+// Returns ErrAllKeysRateLimited if all eligible keys are in cooldown.
+func (p *Pool) GetKey(req types.KeyRequest) (*types.Key, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -129,28 +181,68 @@ func (p *Pool) GetKey() (*types.Key, error) {
 	// Try to reset cooldowns for rate-limited keys
 	p.resetExpiredCooldowns()
 
-	// Use strategy to select a key
-	key := p.strategy.Select(p.keys)
-	if key == nil {
-		// Determine if all keys are rate limited or disabled
-		if p.allKeysRateLimited() {
+	model := types.NormalizeModelName(req.Model)
+	group := req.Group
+	if group == "" {
+		group = p.defaultGroup
+	}
+	if group != "" {
+		return p.selectFromGroup(group, model, req.Affinity)
+	}
+	return p.selectFrom(p.keys, p.strategy, model, req.Affinity)
+}
+
+// selectFrom selects a key among the given keys, preferring the key pinned to
+// the affinity and otherwise using the given strategy on the highest-priority
+// available keys. The selected key is leased until ReleaseKey. Caller must hold p.mu.
+func (p *Pool) selectFrom(keys []*types.Key, strategy Strategy, model, affinity string) (*types.Key, error) {
+	candidates := eligibleKeys(keys, model, p.now())
+	if len(candidates) == 0 {
+		if hasEnabledKeys(keys) {
+			return nil, types.NewModelUnavailableError(model)
+		}
+		return nil, types.ErrNoAvailableKeys
+	}
+
+	now := p.now()
+	if key := p.affinityKey(affinity, candidates); key != nil {
+		p.acquire(key, now)
+		return key, nil
+	}
+
+	available := p.availableKeys(candidates, now)
+	if len(available) == 0 {
+		// Enabled keys exist but are all cooling down or at their limits
+		if hasEnabledKeys(candidates) {
 			return nil, types.ErrAllKeysRateLimited
 		}
 		return nil, types.ErrNoAvailableKeys
 	}
 
+	// Use strategy to select a key
+	key := selectKey(strategy, highestPriority(available), model)
+	if key == nil {
+		return nil, types.ErrNoAvailableKeys
+	}
+
+	p.acquire(key, now)
+	p.bindAffinity(affinity, key)
 	return key, nil
 }
 
-// ReleaseKey returns a key back to the pool.
-// This is a no-op for the current implementation but provided for future extensibility.
+// ReleaseKey returns a key obtained from GetKey back to the pool, ending its
+// lease for the purpose of the key's MaxConcurrent limit.
 func (p *Pool) ReleaseKey(key *types.Key) {
 	if key == nil {
 		return
 	}
-	// In the current implementation, keys are always available in the pool.
-	// This method is provided for API consistency and potential future use
-	// (e.g., connection pooling, lease-based allocation).
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if usage, ok := p.usage[key.ID]; ok && usage.inFlight > 0 {
+		usage.inFlight--
+	}
 }
 
 // Size returns the total number of keys in the pool.
@@ -173,9 +265,15 @@ func (p *Pool) ReportSuccess(key *types.Key, promptTokens, completionTokens int,
 	defer p.mu.Unlock()
 
 	key.IncrementStats(true, promptTokens, completionTokens, model)
+	p.recordOutcome(key.ID, outcomeSuccess)
 
-	// Reset consecutive failures on success
+	// Reset consecutive failures and the cooldown streak on success
 	p.consecutiveFailures[key.ID] = 0
+	key.CooldownStreak = 0
+
+	for _, fs := range p.feedbackStrategies(key) {
+		fs.RecordResult(key.ID, model, true)
+	}
 
 	// Sync to storage if available
 	if p.storage != nil {
@@ -186,9 +284,10 @@ func (p *Pool) ReportSuccess(key *types.Key, promptTokens, completionTokens int,
 // ReportFailure records a failed request for the given key.
 // If the error indicates rate limiting, the key enters cooldown.
 // If consecutive failures exceed the threshold, the key also enters cooldown.
+// Content filter errors are ignored, since the key itself served the request.
 // model: the actual model used in this request (for usage tracking)
 func (p *Pool) ReportFailure(key *types.Key, err error, model string) {
-	if key == nil {
+	if key == nil || isContentFilterError(err) {
 		return
 	}
 
@@ -197,17 +296,34 @@ func (p *Pool) ReportFailure(key *types.Key, err error, model string) {
 
 	key.IncrementStats(false, 0, 0, model)
 
+	for _, fs := range p.feedbackStrategies(key) {
+		fs.RecordResult(key.ID, model, false)
+	}
+
+	// The key works but cannot use this model: sideline only the key-model pair
+	if model != "" && isModelAccessError(err) {
+		p.recordOutcome(key.ID, outcomeError)
+		key.SetModelCooldown(model, p.now().Add(time.Duration(p.modelCooldownSeconds)*time.Second))
+		if p.storage != nil {
+			_ = p.storage.UpdateKey(key) // Best effort
+		}
+		return
+	}
+
 	// Check if this is a rate limit error
 	if isRateLimitError(err) {
-		key.SetRateLimited(p.cooldownSeconds)
+		p.recordOutcome(key.ID, outcomeRateLimited)
+		p.enterCooldown(key)
 		p.consecutiveFailures[key.ID] = 0
 		return
 	}
 
+	p.recordOutcome(key.ID, outcomeError)
+
 	// Track consecutive failures
 	p.consecutiveFailures[key.ID]++
 	if p.consecutiveFailures[key.ID] >= p.maxConsecutiveFailures {
-		key.SetRateLimited(p.cooldownSeconds)
+		p.enterCooldown(key)
 		p.consecutiveFailures[key.ID] = 0
 	}
 
@@ -217,6 +333,26 @@ func (p *Pool) ReportFailure(key *types.Key, err error, model string) {
 	}
 }
 
+// ReportLatency records the upstream latency of a successful request for the given key.
+// The sample feeds the key's rolling statistics and any FeedbackStrategy.
+func (p *Pool) ReportLatency(key *types.Key, model string, latency time.Duration) {
+	if key == nil {
+		return
+	}
+
+	p.windowsMu.Lock()
+	p.windowFor(key.ID).recordLatency(p.now(), latency)
+	p.windowsMu.Unlock()
+
+	p.mu.RLock()
+	strategies := p.feedbackStrategies(key)
+	p.mu.RUnlock()
+
+	for _, fs := range strategies {
+		fs.RecordLatency(key.ID, model, latency)
+	}
+}
+
 // ==================== Statistics ====================
 
 // GetStats returns statistics for all keys in the pool.
@@ -228,21 +364,82 @@ func (p *Pool) GetStats() []types.Key {
 	for i, key := range p.keys {
 		// Create a copy to avoid exposing internal state
 		stats[i] = types.Key{
-			ID:            key.ID,
-			MaskedKey:     key.MaskedKey,
-			Name:          key.Name,
-			Status:        key.Status,
-			Enabled:       key.Enabled,
-			Tags:          key.Tags,
-			Stats:         key.Stats,
-			CooldownUntil: key.CooldownUntil,
-			CreatedAt:     key.CreatedAt,
-			UpdatedAt:     key.UpdatedAt,
+			ID:             key.ID,
+			MaskedKey:      key.MaskedKey,
+			Name:           key.Name,
+			Status:         key.Status,
+			Enabled:        key.Enabled,
+			Tags:           key.Tags,
+			Provider:       key.Provider,
+			DefaultModel:   key.DefaultModel,
+			Weight:         key.Weight,
+			Priority:       key.Priority,
+			Limits:         key.Limits,
+			Health:         key.Health,
+			LastCheckedAt:  key.LastCheckedAt,
+			Stats:          key.Clone().Stats,
+			CooldownUntil:  key.CooldownUntil,
+			CooldownStreak: key.CooldownStreak,
+			CreatedAt:      key.CreatedAt,
+			UpdatedAt:      key.UpdatedAt,
+			Source:         key.Source,
+			NotBefore:      key.NotBefore,
+			ExpiresAt:      key.ExpiresAt,
+			ActiveWindows:  key.ActiveWindows,
+			Proxy:          types.RedactProxyURL(key.Proxy),
+
+			AllowedModels:    key.AllowedModels,
+			DeniedModels:     key.DeniedModels,
+			DetectedModels:   key.DetectedModels,
+			ModelsDetectedAt: key.ModelsDetectedAt,
+			DisabledReason:   key.DisabledReason,
+			ModelCooldowns:   activeModelCooldowns(key, p.now()),
+			Groups:           p.groupNamesFor(key),
 		}
 	}
 	return stats
 }
 
+// GetWindowStats returns the rolling statistics of a key for every tracked window.
+func (p *Pool) GetWindowStats(keyID string) map[types.StatsWindow]types.WindowStats {
+	result := make(map[types.StatsWindow]types.WindowStats, len(types.RollingStatsWindows))
+	for _, window := range types.RollingStatsWindows {
+		result[window] = p.windowStats(keyID, window)
+	}
+	return result
+}
+
+// windowStats returns a key's statistics over a single rolling window.
+// It satisfies WindowStatsFunc and only takes windowsMu.
+func (p *Pool) windowStats(keyID string, window types.StatsWindow) types.WindowStats {
+	p.windowsMu.Lock()
+	defer p.windowsMu.Unlock()
+
+	w, ok := p.windows[keyID]
+	if !ok {
+		return types.WindowStats{}
+	}
+	return w.sum(p.now(), window.Duration())
+}
+
+// recordOutcome adds a request outcome to a key's rolling statistics.
+func (p *Pool) recordOutcome(keyID string, outcome windowOutcome) {
+	p.windowsMu.Lock()
+	defer p.windowsMu.Unlock()
+	p.windowFor(keyID).recordOutcome(p.now(), outcome)
+}
+
+// windowFor returns the sliding window for a key, creating it if needed.
+// Caller must hold p.windowsMu.
+func (p *Pool) windowFor(keyID string) *slidingWindow {
+	w, ok := p.windows[keyID]
+	if !ok {
+		w = newSlidingWindow()
+		p.windows[keyID] = w
+	}
+	return w
+}
+
 // ==================== Dynamic Key Management ====================
 
 // AddKey adds a new key to the pool.
@@ -285,58 +482,271 @@ func (p *Pool) AddKey(key *types.Key) error {
 	return nil
 }
 
+// HasAPIKey reports whether a key with the given API key string is in the pool.
+func (p *Pool) HasAPIKey(apiKey string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, k := range p.keys {
+		if k.APIKey == apiKey {
+			return true
+		}
+	}
+	return false
+}
+
 // RemoveKey removes a key from the pool by ID.
 // If storage is configured, the key is also deleted from storage.
 func (p *Pool) RemoveKey(id string) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	// Find and remove from slice
-	found := false
-	for i, key := range p.keys {
-		if key.ID == id {
-			p.keys = append(p.keys[:i], p.keys[i+1:]...)
-			found = true
-			break
-		}
-	}
-
-	if !found {
+	if p.findKey(id) == nil {
 		return types.ErrKeyNotFound
 	}
 
-	// Delete from storage
+	// Delete from storage first so a failure leaves both sides unchanged.
+	// A row that is already gone only means the two had diverged.
 	if p.storage != nil {
-		if err := p.storage.DeleteKey(id); err != nil {
-			// Key already removed from memory, but DB deletion failed.
-			// This may cause inconsistency between memory and DB.
-			// Log the error but don't fail since the memory state is already updated.
-			// The key will be re-added to DB on next restart if still in config,
-			// or the DB record will be orphaned if key was dynamically added.
-			// TODO: Consider rollback strategy or reconciliation on startup.
-			_ = err // Logged in production via structured logging
+		if err := p.storage.DeleteKey(id); err != nil && !errors.Is(err, types.ErrKeyNotFound) {
+			return err
+		}
+	}
+	p.removeKeyLocked(id)
+	return nil
+}
+
+// removeKeyLocked drops a key and its runtime state from memory.
+// The caller must hold p.mu.
+func (p *Pool) removeKeyLocked(id string) {
+	for i, key := range p.keys {
+		if key.ID == id {
+			p.keys = append(p.keys[:i], p.keys[i+1:]...)
+			break
 		}
 	}
 
 	// Clean up consecutive failures tracking
 	delete(p.consecutiveFailures, id)
+	delete(p.usage, id)
 
-	return nil
+	p.windowsMu.Lock()
+	delete(p.windows, id)
+	p.windowsMu.Unlock()
+
+	for _, fs := range p.feedbackStrategies(nil) {
+		fs.Forget(id)
+	}
 }
 
-// GetKeyByID returns a key by its ID.
+// GetKeyByID returns a copy of the key with the given ID.
 func (p *Pool) GetKeyByID(id string) (*types.Key, error) {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 
 	for _, key := range p.keys {
 		if key.ID == id {
-			return key, nil
+			return key.Clone(), nil
 		}
 	}
 	return nil, types.ErrKeyNotFound
 }
 
+// GetKeyByAPIKey returns a copy of the key with the given API key string.
+func (p *Pool) GetKeyByAPIKey(apiKey string) (*types.Key, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, key := range p.keys {
+		if key.APIKey == apiKey {
+			return key.Clone(), nil
+		}
+	}
+	return nil, types.ErrKeyNotFound
+}
+
+// SetModelPatterns replaces the allowed and denied model patterns of a key
+// and returns a copy of the result.
+// A nil slice leaves the corresponding patterns unchanged.
+func (p *Pool) SetModelPatterns(id string, allowed, denied []string) (*types.Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := p.findKey(id)
+	if key == nil {
+		return nil, types.ErrKeyNotFound
+	}
+
+	if allowed != nil {
+		key.AllowedModels = allowed
+	}
+	if denied != nil {
+		key.DeniedModels = denied
+	}
+	key.UpdatedAt = p.now()
+
+	if p.storage != nil {
+		if err := p.storage.UpdateKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return key.Clone(), nil
+}
+
+// UpdateKey applies the non-nil fields of req to a key, persists it and
+// returns a copy of the result.
+// Disabling a key clears its cooldown and failure count; enabling a disabled
+// key makes it active again.
+func (p *Pool) UpdateKey(id string, req types.UpdateKeyRequest) (*types.Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := p.findKey(id)
+	if key == nil {
+		return nil, types.ErrKeyNotFound
+	}
+
+	if req.Name != nil {
+		key.Name = *req.Name
+	}
+	if req.Tags != nil {
+		key.Tags = *req.Tags
+	}
+	if req.Provider != nil {
+		key.Provider = *req.Provider
+	}
+	if req.DefaultModel != nil {
+		key.DefaultModel = *req.DefaultModel
+	}
+	if req.Weight != nil {
+		key.Weight = *req.Weight
+	}
+	if req.Priority != nil {
+		key.Priority = *req.Priority
+	}
+	if req.Limits != nil {
+		key.Limits = *req.Limits
+	}
+	if req.Proxy != nil {
+		key.Proxy = *req.Proxy
+	}
+	if req.NotBefore.Set {
+		key.NotBefore = req.NotBefore.Time
+	}
+	if req.ExpiresAt.Set {
+		key.ExpiresAt = req.ExpiresAt.Time
+	}
+	if req.ActiveWindows != nil {
+		key.ActiveWindows = *req.ActiveWindows
+	}
+	if req.AllowedModels != nil {
+		key.AllowedModels = *req.AllowedModels
+	}
+	if req.DeniedModels != nil {
+		key.DeniedModels = *req.DeniedModels
+	}
+	if req.Enabled != nil {
+		key.Enabled = *req.Enabled
+		switch {
+		case !key.Enabled:
+			key.Status = types.KeyStatusDisabled
+			key.CooldownUntil = nil
+			p.consecutiveFailures[key.ID] = 0
+		case key.Status == types.KeyStatusDisabled:
+			key.Status = types.KeyStatusActive
+		}
+		if key.Enabled {
+			key.DisabledReason = ""
+		}
+	}
+	key.UpdatedAt = time.Now()
+
+	if p.storage != nil {
+		if err := p.storage.UpdateKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return key.Clone(), nil
+}
+
+// RecordProbe records the outcome of probing a key and updates its state:
+// a valid key has its models recorded as detected, an exhausted key enters
+// cooldown, and an invalid or region-blocked key is disabled when
+// disableInvalid is set. A valid probe only lists models, which costs no
+// quota, so it leaves a rate-limit cooldown and its streak in place. It
+// returns a copy of the updated key.
+func (p *Pool) RecordProbe(id string, result types.KeyProbeResult, disableInvalid bool) (*types.Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := p.findKey(id)
+	if key == nil {
+		return nil, types.ErrKeyNotFound
+	}
+
+	now := p.now()
+	key.Health = result.Health
+	key.LastCheckedAt = &now
+
+	switch result.Health {
+	case types.KeyHealthValid:
+		key.DetectedModels = result.Models
+		key.ModelsDetectedAt = &now
+		key.ModelCooldowns = nil
+	case types.KeyHealthQuotaExhausted:
+		if key.Enabled && key.Status != types.KeyStatusDisabled {
+			p.enterCooldown(key)
+		}
+	case types.KeyHealthInvalid, types.KeyHealthRegionBlocked:
+		if disableInvalid {
+			key.Enabled = false
+			key.Status = types.KeyStatusDisabled
+			key.CooldownUntil = nil
+			key.DisabledReason = result.Reason()
+		}
+	}
+	key.UpdatedAt = now
+
+	if p.storage != nil {
+		if err := p.storage.UpdateKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return key.Clone(), nil
+}
+
+// SetDetectedModels records the models a key reported in a models.list probe.
+// Model cooldowns for models that are now listed are cleared. It returns a
+// copy of the updated key.
+func (p *Pool) SetDetectedModels(id string, models []string) (*types.Key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := p.findKey(id)
+	if key == nil {
+		return nil, types.ErrKeyNotFound
+	}
+
+	detected := make([]string, 0, len(models))
+	for _, m := range models {
+		m = types.NormalizeModelName(m)
+		detected = append(detected, m)
+		delete(key.ModelCooldowns, m)
+	}
+
+	now := p.now()
+	key.DetectedModels = detected
+	key.ModelsDetectedAt = &now
+	key.UpdatedAt = now
+
+	if p.storage != nil {
+		if err := p.storage.UpdateKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return key.Clone(), nil
+}
+
 // LoadFromStorage loads all keys from storage into the pool.
 // This replaces any existing keys in the pool.
 func (p *Pool) LoadFromStorage() error {
@@ -367,50 +777,6 @@ func (p *Pool) LoadFromStorage() error {
 	return nil
 }
 
-// SyncConfigToStorage syncs keys from config to storage (for initial setup).
-// Only adds keys that don't already exist in storage.
-func (p *Pool) SyncConfigToStorage(configs []types.KeyConfig) (int, error) {
-	if p.storage == nil {
-		return 0, errors.New("no storage configured")
-	}
-
-	synced := 0
-	for _, cfg := range configs {
-		// Check if key already exists
-		exists, err := p.storage.KeyExists(cfg.Key)
-		if err != nil {
-			continue
-		}
-		if exists {
-			continue
-		}
-
-		// Create new key
-		key := &types.Key{
-			ID:        uuid.New().String(),
-			APIKey:    cfg.Key,
-			MaskedKey: types.MaskAPIKey(cfg.Key),
-			Name:      cfg.Name,
-			Status:    types.KeyStatusActive,
-			Enabled:   cfg.Enabled,
-			Tags:      cfg.Tags,
-			Stats:     types.KeyStats{},
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
-
-		if !cfg.Enabled {
-			key.Status = types.KeyStatusDisabled
-		}
-
-		if err := p.storage.CreateKey(key); err == nil {
-			synced++
-		}
-	}
-
-	return synced, nil
-}
-
 // HasStorage returns true if a storage backend is configured.
 func (p *Pool) HasStorage() bool {
 	return p.storage != nil
@@ -423,6 +789,7 @@ func (p *Pool) SetStrategy(strategy Strategy) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.strategy = strategy
+	p.bindStrategyWindow(strategy)
 }
 
 // GetStrategy returns the current key selection strategy name.
@@ -464,8 +831,55 @@ func (p *Pool) GetMaxConsecutiveFailures() int {
 	return p.maxConsecutiveFailures
 }
 
+// SetModelCooldownSeconds updates how long key-model pairs stay ineligible at runtime.
+func (p *Pool) SetModelCooldownSeconds(seconds int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if seconds > 0 {
+		p.modelCooldownSeconds = seconds
+	}
+}
+
+// GetModelCooldownSeconds returns how long key-model pairs stay ineligible.
+func (p *Pool) GetModelCooldownSeconds() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.modelCooldownSeconds
+}
+
+// SetStatsWindow updates the rolling window that stats-based strategies rank on.
+func (p *Pool) SetStatsWindow(window types.StatsWindow) {
+	if !window.IsValid() {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statsWindow = window
+	p.bindStrategyWindow(p.strategy)
+	for _, group := range p.groupOrder {
+		p.bindStrategyWindow(group.strategy)
+	}
+}
+
+// GetStatsWindow returns the rolling window that stats-based strategies rank on.
+func (p *Pool) GetStatsWindow() types.StatsWindow {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.statsWindow
+}
+
 // ==================== Internal Helpers ====================
 
+// bindStrategyWindow connects a WindowAware strategy to the pool's rolling
+// statistics and applies the configured window. Caller must hold p.mu
+// (or be constructing the pool).
+func (p *Pool) bindStrategyWindow(strategy Strategy) {
+	if wa, ok := strategy.(WindowAware); ok {
+		wa.SetWindowStatsFunc(p.windowStats)
+		wa.SetStatsWindow(p.statsWindow)
+	}
+}
+
 // resetExpiredCooldowns checks all rate-limited keys and resets those
 // whose cooldown has expired.
 func (p *Pool) resetExpiredCooldowns() {
@@ -480,35 +894,83 @@ func (p *Pool) resetExpiredCooldowns() {
 	}
 }
 
-// allKeysRateLimited returns true if there are enabled keys and all of them
-// are currently rate limited (in cooldown).
-func (p *Pool) allKeysRateLimited() bool {
-	now := time.Now()
-	hasEnabledKey := false
-	allRateLimited := true
+// eligibleKeys returns the keys whose model patterns permit the model and whose
+// key-model pair is not cooling down at now. An empty model returns every key.
+func eligibleKeys(keys []*types.Key, model string, now time.Time) []*types.Key {
+	if model == "" {
+		return keys
+	}
 
-	for _, key := range p.keys {
+	eligible := make([]*types.Key, 0, len(keys))
+	for _, key := range keys {
 		if !key.Enabled || key.Status == types.KeyStatusDisabled {
 			continue
 		}
+		if key.SupportsModel(model) && !key.IsModelCoolingDown(model, now) {
+			eligible = append(eligible, key)
+		}
+	}
+	return eligible
+}
 
-		hasEnabledKey = true
+// selectKey picks a key with the given strategy, passing the model to
+// strategies that track per-model statistics.
+func selectKey(strategy Strategy, keys []*types.Key, model string) *types.Key {
+	if ma, ok := strategy.(ModelAware); ok {
+		return ma.SelectForModel(keys, model)
+	}
+	return strategy.Select(keys)
+}
 
-		if key.Status == types.KeyStatusActive {
-			allRateLimited = false
-			break
+// findKey returns the key with the given ID, or nil. Caller must hold p.mu.
+func (p *Pool) findKey(id string) *types.Key {
+	for _, key := range p.keys {
+		if key.ID == id {
+			return key
 		}
-		if key.Status == types.KeyStatusRateLimited {
-			// Check if cooldown has expired
-			if key.CooldownUntil == nil || now.After(*key.CooldownUntil) {
-				allRateLimited = false
-				break
+	}
+	return nil
+}
+
+// hasEnabledKeys returns true if any of the given keys is enabled.
+func hasEnabledKeys(keys []*types.Key) bool {
+	for _, key := range keys {
+		if key.Enabled && key.Status != types.KeyStatusDisabled {
+			return true
+		}
+	}
+	return false
+}
+
+// activeModelCooldowns returns a copy of the key's unexpired model cooldowns.
+func activeModelCooldowns(key *types.Key, now time.Time) map[string]time.Time {
+	var active map[string]time.Time
+	for model, until := range key.ModelCooldowns {
+		if now.Before(until) {
+			if active == nil {
+				active = make(map[string]time.Time)
 			}
+			active[model] = until
 		}
 	}
+	return active
+}
+
+// isModelAccessError checks if an error means the key may not use the requested
+// model (unknown model or no access), as opposed to the key itself failing.
+func isModelAccessError(err error) bool {
+	var appErr *types.AppError
+	if errors.As(err, &appErr) {
+		return appErr.Code == types.ErrCodeNotFound || appErr.Code == types.ErrCodePermission
+	}
+	return false
+}
 
-	// Only return true if there are enabled keys and all are rate limited
-	return hasEnabledKey && allRateLimited
+// isContentFilterError checks if an error means Gemini's safety filters
+// refused the content; the key worked, so it is not a key failure.
+func isContentFilterError(err error) bool {
+	var appErr *types.AppError
+	return errors.As(err, &appErr) && appErr.Code == types.ErrCodeContentFilter
 }
 
 // isRateLimitError checks if an error indicates rate limiting.
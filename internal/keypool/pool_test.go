@@ -14,6 +14,19 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+// liveKeyByAPIKey returns the pool's own key with the given API key, which
+// unlike GetKeyByAPIKey reflects later changes.
+func liveKeyByAPIKey(pool *Pool, apiKey string) *types.Key {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+	for _, key := range pool.keys {
+		if key.APIKey == apiKey {
+			return key
+		}
+	}
+	return nil
+}
+
 // ==================== Pool Creation Tests ====================
 
 func TestNewPool(t *testing.T) {
@@ -93,7 +106,7 @@ func TestPool_GetKey_HappyPath(t *testing.T) {
 	pool := NewPool(configs)
 
 	// Should get a key successfully
-	key, err := pool.GetKey()
+	key, err := pool.GetKey(types.KeyRequest{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -117,7 +130,7 @@ func TestPool_GetKey_RoundRobinOrder(t *testing.T) {
 	// Get 6 keys and verify round-robin order
 	expectedNames := []string{"Key 1", "Key 2", "Key 3", "Key 1", "Key 2", "Key 3"}
 	for i, expectedName := range expectedNames {
-		key, err := pool.GetKey()
+		key, err := pool.GetKey(types.KeyRequest{})
 		if err != nil {
 			t.Fatalf("iteration %d: unexpected error: %v", i, err)
 		}
@@ -139,7 +152,7 @@ func TestPool_GetKey_SkipsDisabledKeys(t *testing.T) {
 
 	// Should only return Key 2
 	for i := 0; i < 5; i++ {
-		key, err := pool.GetKey()
+		key, err := pool.GetKey(types.KeyRequest{})
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -153,7 +166,7 @@ func TestPool_GetKey_SkipsDisabledKeys(t *testing.T) {
 func TestPool_GetKey_NoAvailableKeys(t *testing.T) {
 	pool := NewPool([]types.KeyConfig{})
 
-	key, err := pool.GetKey()
+	key, err := pool.GetKey(types.KeyRequest{})
 
 	if key != nil {
 		t.Errorf("expected nil key, got %v", key)
@@ -171,7 +184,7 @@ func TestPool_GetKey_AllKeysDisabled(t *testing.T) {
 
 	pool := NewPool(configs)
 
-	key, err := pool.GetKey()
+	key, err := pool.GetKey(types.KeyRequest{})
 
 	if key != nil {
 		t.Errorf("expected nil key, got %v", key)
@@ -194,7 +207,7 @@ func TestPool_GetKey_AllKeysRateLimited(t *testing.T) {
 		key.SetRateLimited(60)
 	}
 
-	key, err := pool.GetKey()
+	key, err := pool.GetKey(types.KeyRequest{})
 
 	if key != nil {
 		t.Errorf("expected nil key, got %v", key)
@@ -213,11 +226,11 @@ func TestPool_ReleaseKey(t *testing.T) {
 
 	pool := NewPool(configs)
 
-	key, _ := pool.GetKey()
+	key, _ := pool.GetKey(types.KeyRequest{})
 	pool.ReleaseKey(key)
 
 	// Verify the key can be obtained again
-	key2, err := pool.GetKey()
+	key2, err := pool.GetKey(types.KeyRequest{})
 	if err != nil {
 		t.Errorf("unexpected error after release: %v", err)
 	}
@@ -244,7 +257,7 @@ func TestPool_ReportSuccess(t *testing.T) {
 
 	pool := NewPool(configs)
 
-	key, _ := pool.GetKey()
+	key, _ := pool.GetKey(types.KeyRequest{})
 	initialRequestCount := key.Stats.RequestCount
 
 	pool.ReportSuccess(key, 100, 50, "test-model")
@@ -270,7 +283,7 @@ func TestPool_ReportFailure_RateLimitError(t *testing.T) {
 
 	pool := NewPool(configs, WithCooldownSeconds(30))
 
-	key, _ := pool.GetKey()
+	key, _ := pool.GetKey(types.KeyRequest{})
 
 	// Simulate rate limit error
 	rateLimitErr := &types.AppError{
@@ -294,7 +307,7 @@ func TestPool_ReportFailure_GenericError(t *testing.T) {
 
 	pool := NewPool(configs)
 
-	key, _ := pool.GetKey()
+	key, _ := pool.GetKey(types.KeyRequest{})
 
 	// Report a generic error (not rate limit)
 	genericErr := errors.New("network error")
@@ -316,7 +329,7 @@ func TestPool_ReportFailure_ConsecutiveFailures(t *testing.T) {
 	// Set max consecutive failures to 3
 	pool := NewPool(configs, WithMaxConsecutiveFailures(3), WithCooldownSeconds(60))
 
-	key, _ := pool.GetKey()
+	key, _ := pool.GetKey(types.KeyRequest{})
 
 	// Report 3 consecutive failures
 	for i := 0; i < 3; i++ {
@@ -329,6 +342,740 @@ func TestPool_ReportFailure_ConsecutiveFailures(t *testing.T) {
 	}
 }
 
+func TestPool_ReportFailure_ContentFilterIgnored(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+	}
+
+	pool := NewPool(configs, WithMaxConsecutiveFailures(1), WithCooldownSeconds(60))
+
+	key, _ := pool.GetKey(types.KeyRequest{})
+
+	// A blocked prompt says nothing about the key
+	pool.ReportFailure(key, types.NewContentFilterError("SAFETY", nil), "")
+
+	if key.Status != types.KeyStatusActive {
+		t.Errorf("key should stay active after a content filter error, got status %s", key.Status)
+	}
+	if key.Stats.ErrorCount != 0 {
+		t.Errorf("content filter errors should not be counted, got %d errors", key.Stats.ErrorCount)
+	}
+}
+
+func TestPool_ReportLatency_FeedsStrategy(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+		{Key: "AIzaSyKey2", Name: "Key 2", Enabled: true},
+	}
+
+	strategy := NewLatencyAwareStrategy()
+	pool := NewPool(configs, WithStrategy(strategy))
+	stats := pool.GetStats()
+	fast := pool.findKey(stats[0].ID)
+	slow := pool.findKey(stats[1].ID)
+
+	pool.ReportLatency(fast, "test-model", 50*time.Millisecond)
+	pool.ReportSuccess(fast, 10, 10, "test-model")
+	pool.ReportLatency(slow, "test-model", 3*time.Second)
+	pool.ReportFailure(slow, errors.New("upstream error"), "test-model")
+
+	for i := 0; i < 10; i++ {
+		key, err := pool.GetKey(types.KeyRequest{})
+		if err != nil {
+			t.Fatalf("GetKey() error = %v", err)
+		}
+		if key.ID != fast.ID {
+			t.Fatalf("expected fast key %s, got %s", fast.ID, key.ID)
+		}
+	}
+
+	// Nil keys and non-feedback strategies are ignored
+	pool.ReportLatency(nil, "test-model", time.Second)
+	pool.SetStrategy(NewRoundRobinStrategy())
+	pool.ReportLatency(fast, "test-model", time.Second)
+}
+
+func TestPool_GetWindowStats(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+	}
+
+	pool := NewPool(configs, WithMaxConsecutiveFailures(100))
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+
+	key, _ := pool.GetKey(types.KeyRequest{})
+	pool.ReportFailure(key, errors.New("upstream error"), "test-model")
+	pool.ReportFailure(key, &types.AppError{Code: types.ErrCodeRateLimit, HTTPStatus: 429}, "test-model")
+
+	now = now.Add(30 * time.Minute)
+	pool.ReportSuccess(key, 10, 10, "test-model")
+	pool.ReportLatency(key, "test-model", 250*time.Millisecond)
+
+	windows := pool.GetWindowStats(key.ID)
+	if got := windows[types.StatsWindow5m]; got.RequestCount != 1 || got.SuccessCount != 1 || got.AvgLatencyMs != 250 {
+		t.Errorf("5m window = %+v, want 1 success at 250ms", got)
+	}
+	if got := windows[types.StatsWindow1h]; got.RequestCount != 3 || got.ErrorCount != 2 || got.RateLimitCount != 1 {
+		t.Errorf("1h window = %+v, want 3 requests with 2 errors and 1 rate limit", got)
+	}
+
+	// Removing the key drops its windows
+	_ = pool.RemoveKey(key.ID)
+	if got := pool.GetWindowStats(key.ID)[types.StatsWindow24h]; got.RequestCount != 0 {
+		t.Errorf("removed key should have empty windows, got %+v", got)
+	}
+}
+
+func TestPool_StatsWindow_RanksOnRecentFailures(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+		{Key: "AIzaSyKey2", Name: "Key 2", Enabled: true},
+	}
+
+	strategy := NewLeastUsedStrategy()
+	pool := NewPool(configs, WithStrategy(strategy), WithStatsWindow(types.StatsWindow5m))
+	if strategy.StatsWindow() != types.StatsWindow5m {
+		t.Fatalf("strategy window = %s, want 5m", strategy.StatsWindow())
+	}
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+
+	stats := pool.GetStats()
+	busy := pool.findKey(stats[0].ID)
+	idle := pool.findKey(stats[1].ID)
+
+	// busy has far more lifetime requests, but all of them are old
+	for i := 0; i < 10; i++ {
+		pool.ReportSuccess(busy, 1, 1, "test-model")
+	}
+	now = now.Add(time.Hour)
+	pool.ReportSuccess(idle, 1, 1, "test-model")
+
+	key, err := pool.GetKey(types.KeyRequest{})
+	if err != nil {
+		t.Fatalf("GetKey() error = %v", err)
+	}
+	if key.ID != busy.ID {
+		t.Errorf("expected key with no recent requests %s, got %s", busy.ID, key.ID)
+	}
+
+	// Switching to lifetime ranks on the lifetime counters again
+	pool.SetStatsWindow(types.StatsWindowLifetime)
+	key, _ = pool.GetKey(types.KeyRequest{})
+	if key.ID != idle.ID {
+		t.Errorf("expected key with fewer lifetime requests %s, got %s", idle.ID, key.ID)
+	}
+}
+
+// ==================== Model Routing Tests ====================
+
+func TestPool_GetKey_FiltersByModel(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyPreview", Name: "Preview", Enabled: true, AllowedModels: []string{"gemini-*"}},
+		{Key: "AIzaSyStable", Name: "Stable", Enabled: true, DeniedModels: []string{"*-preview*"}},
+	}
+	pool := NewPool(configs)
+	stats := pool.GetStats()
+	preview, stable := stats[0].ID, stats[1].ID
+
+	for i := 0; i < 5; i++ {
+		key, err := pool.GetKey(types.KeyRequest{Model: "gemini-2.5-pro-preview"})
+		if err != nil {
+			t.Fatalf("GetKey() error = %v", err)
+		}
+		if key.ID != preview {
+			t.Errorf("preview model should only route to preview key, got %s", key.Name)
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		key, err := pool.GetKey(types.KeyRequest{Model: "models/gpt-4o"})
+		if err != nil {
+			t.Fatalf("GetKey() error = %v", err)
+		}
+		if key.ID != stable {
+			t.Errorf("non-gemini model should only route to stable key, got %s", key.Name)
+		}
+	}
+
+	// No model matches every key
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		key, _ := pool.GetKey(types.KeyRequest{})
+		seen[key.ID] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("empty model should rotate across all keys, saw %d", len(seen))
+	}
+}
+
+func TestPool_GetKey_NoKeyForModel(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true, AllowedModels: []string{"gemini-1.5-*"}},
+	}
+	pool := NewPool(configs)
+
+	_, err := pool.GetKey(types.KeyRequest{Model: "gemini-2.5-pro"})
+	var appErr *types.AppError
+	if !errors.As(err, &appErr) || appErr.Param != "model" {
+		t.Fatalf("expected model unavailable error, got %v", err)
+	}
+}
+
+func TestPool_ReportFailure_ModelAccessError(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+		{Key: "AIzaSyKey2", Name: "Key 2", Enabled: true},
+	}
+	pool := NewPool(configs, WithMaxConsecutiveFailures(1))
+	stats := pool.GetStats()
+	key1 := pool.findKey(stats[0].ID)
+
+	pool.ReportFailure(key1, types.NewNotFoundError("model"), "gemini-exp")
+
+	// The whole key is not cooled down...
+	if key1.Status != types.KeyStatusActive {
+		t.Errorf("key status = %s, want active", key1.Status)
+	}
+	for i := 0; i < 4; i++ {
+		key, err := pool.GetKey(types.KeyRequest{Model: "gemini-exp"})
+		if err != nil {
+			t.Fatalf("GetKey() error = %v", err)
+		}
+		// ...but it is skipped for the rejected model
+		if key.ID == key1.ID {
+			t.Error("key should be ineligible for the rejected model")
+		}
+	}
+
+	found := false
+	for i := 0; i < 4; i++ {
+		key, _ := pool.GetKey(types.KeyRequest{Model: "gemini-2.0-flash"})
+		found = found || key.ID == key1.ID
+	}
+	if !found {
+		t.Error("key should remain eligible for other models")
+	}
+
+	if got := pool.GetStats()[0].ModelCooldowns; len(got) != 1 {
+		t.Errorf("ModelCooldowns = %v, want one entry", got)
+	}
+
+	// Permission errors for the only remaining key exhaust the model
+	key2 := pool.findKey(stats[1].ID)
+	pool.ReportFailure(key2, types.NewPermissionError(""), "gemini-exp")
+	if _, err := pool.GetKey(types.KeyRequest{Model: "gemini-exp"}); err == nil {
+		t.Error("expected error when every key is ineligible for the model")
+	}
+}
+
+func TestPool_ModelCooldown_UsesPoolClock(t *testing.T) {
+	pool := NewPool([]types.KeyConfig{{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true}}, WithModelCooldownSeconds(60))
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+
+	key, _ := pool.GetKey(types.KeyRequest{Model: "gemini-exp"})
+	pool.ReportFailure(key, types.NewNotFoundError("model"), "gemini-exp")
+	pool.ReleaseKey(key)
+
+	if _, err := pool.GetKey(types.KeyRequest{Model: "gemini-exp"}); err == nil {
+		t.Error("key should be cooling down for the rejected model")
+	}
+	if got := pool.GetStats()[0].ModelCooldowns; len(got) != 1 {
+		t.Errorf("ModelCooldowns = %v, want one entry", got)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got := pool.GetStats()[0].ModelCooldowns; len(got) != 0 {
+		t.Errorf("ModelCooldowns = %v, want none after the cooldown", got)
+	}
+	if _, err := pool.GetKey(types.KeyRequest{Model: "gemini-exp"}); err != nil {
+		t.Errorf("cooldown should end on the pool clock, got %v", err)
+	}
+}
+
+func TestPool_SetModelPatterns_ReturnsCopy(t *testing.T) {
+	pool := NewPool([]types.KeyConfig{{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true}})
+	id := pool.GetStats()[0].ID
+
+	updated, err := pool.SetModelPatterns(id, []string{"gemini-2.5-*"}, nil)
+	if err != nil {
+		t.Fatalf("SetModelPatterns() error = %v", err)
+	}
+	if updated == pool.findKey(id) {
+		t.Fatal("returned the pool's own key")
+	}
+	pool.SetModelPatterns(id, []string{"gemini-2.0-*"}, nil)
+	if len(updated.AllowedModels) != 1 || updated.AllowedModels[0] != "gemini-2.5-*" {
+		t.Errorf("copy changed with the pool: %v", updated.AllowedModels)
+	}
+}
+
+func TestPool_SetDetectedModels(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+	}
+	pool := NewPool(configs)
+	id := pool.GetStats()[0].ID
+	key := pool.findKey(id)
+	key.SetModelCooldown("gemini-2.5-pro", time.Now().Add(time.Hour))
+
+	if _, err := pool.SetDetectedModels(id, []string{"models/gemini-2.5-pro", "models/gemini-2.0-flash"}); err != nil {
+		t.Fatalf("SetDetectedModels() error = %v", err)
+	}
+	if key.ModelsDetectedAt == nil {
+		t.Error("ModelsDetectedAt should be set")
+	}
+	if _, err := pool.GetKey(types.KeyRequest{Model: "gemini-2.5-pro"}); err != nil {
+		t.Errorf("detected model should clear its cooldown, got %v", err)
+	}
+	if _, err := pool.GetKey(types.KeyRequest{Model: "gemini-1.5-pro"}); err == nil {
+		t.Error("undetected model should not be routed to the key")
+	}
+
+	if _, err := pool.SetDetectedModels("missing", nil); err != types.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+// ==================== Key Group Tests ====================
+
+// newGroupTestPool creates a pool with a "paid" group falling back to "free".
+func newGroupTestPool(opts ...PoolOption) *Pool {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyPaid", Name: "Paid", Enabled: true, Tags: []string{"paid"}},
+		{Key: "AIzaSyFree1", Name: "Free 1", Enabled: true, Tags: []string{"free"}},
+		{Key: "AIzaSyFree2", Name: "Free 2", Enabled: true, Tags: []string{"free", "experiment"}},
+	}
+	groups := []types.KeyGroupConfig{
+		{Name: "paid", Fallback: "free"},
+		{Name: "free", Tags: []string{"free"}, Strategy: types.PoolStrategyLeastUsed, CooldownSeconds: 300},
+	}
+	return NewPool(configs, append([]PoolOption{WithKeyGroups(groups)}, opts...)...)
+}
+
+func TestPool_GetKey_SelectsFromGroup(t *testing.T) {
+	pool := newGroupTestPool()
+
+	for i := 0; i < 4; i++ {
+		key, err := pool.GetKey(types.KeyRequest{Group: "paid"})
+		if err != nil {
+			t.Fatalf("GetKey() error = %v", err)
+		}
+		if key.Name != "Paid" {
+			t.Errorf("paid group should only return the paid key, got %s", key.Name)
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		key, err := pool.GetKey(types.KeyRequest{Group: "free"})
+		if err != nil {
+			t.Fatalf("GetKey() error = %v", err)
+		}
+		if key.Name == "Paid" {
+			t.Error("free group should not return the paid key")
+		}
+	}
+
+	_, err := pool.GetKey(types.KeyRequest{Group: "missing"})
+	var appErr *types.AppError
+	if !errors.As(err, &appErr) || appErr.HTTPStatus != 400 {
+		t.Errorf("unknown group should return a 400 error, got %v", err)
+	}
+}
+
+func TestPool_GetKey_GroupFallback(t *testing.T) {
+	pool := newGroupTestPool()
+
+	paid, _ := pool.GetKey(types.KeyRequest{Group: "paid"})
+	pool.ReportFailure(paid, &types.AppError{Code: types.ErrCodeRateLimit, HTTPStatus: 429}, "")
+
+	key, err := pool.GetKey(types.KeyRequest{Group: "paid"})
+	if err != nil {
+		t.Fatalf("GetKey() should fall back to the free group, error = %v", err)
+	}
+	if key.Name == "Paid" {
+		t.Error("rate-limited paid key should not be returned")
+	}
+
+	// The free group has no fallback
+	for _, k := range pool.keys {
+		if k.Name != "Paid" {
+			k.SetRateLimited(60)
+		}
+	}
+	if _, err := pool.GetKey(types.KeyRequest{Group: "free"}); err != types.ErrAllKeysRateLimited {
+		t.Errorf("expected ErrAllKeysRateLimited, got %v", err)
+	}
+}
+
+func TestPool_GetKey_DefaultGroup(t *testing.T) {
+	pool := newGroupTestPool(WithDefaultGroup("free"))
+
+	for i := 0; i < 4; i++ {
+		key, err := pool.GetKey(types.KeyRequest{})
+		if err != nil {
+			t.Fatalf("GetKey() error = %v", err)
+		}
+		if key.Name == "Paid" {
+			t.Error("default group should not return the paid key")
+		}
+	}
+}
+
+func TestPool_ReportFailure_GroupCooldown(t *testing.T) {
+	pool := newGroupTestPool(WithCooldownSeconds(30))
+	rateLimitErr := &types.AppError{Code: types.ErrCodeRateLimit, HTTPStatus: 429}
+
+	paid, _ := pool.GetKey(types.KeyRequest{Group: "paid"})
+	pool.ReportFailure(paid, rateLimitErr, "")
+	free, _ := pool.GetKey(types.KeyRequest{Group: "free"})
+	pool.ReportFailure(free, rateLimitErr, "")
+
+	if d := time.Until(*paid.CooldownUntil); d > 30*time.Second {
+		t.Errorf("paid key should use the pool cooldown, got %v", d)
+	}
+	if d := time.Until(*free.CooldownUntil); d < 200*time.Second {
+		t.Errorf("free key should use the group cooldown, got %v", d)
+	}
+}
+
+func TestPool_ProxyFor(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyPaid", Name: "Paid", Enabled: true, Tags: []string{"paid"}, Proxy: "socks5://127.0.0.1:1080"},
+		{Key: "AIzaSyFree", Name: "Free", Enabled: true, Tags: []string{"free"}},
+		{Key: "AIzaSyOther", Name: "Other", Enabled: true},
+	}
+	groups := []types.KeyGroupConfig{
+		{Name: "paid", Proxy: "http://10.0.0.1:3128"},
+		{Name: "free", Proxy: "http://10.0.0.2:3128"},
+	}
+	pool := NewPool(configs, WithKeyGroups(groups))
+
+	tests := []struct {
+		apiKey, want string
+	}{
+		{"AIzaSyPaid", "socks5://127.0.0.1:1080"},
+		{"AIzaSyFree", "http://10.0.0.2:3128"},
+		{"AIzaSyOther", ""},
+	}
+	for _, tt := range tests {
+		key := liveKeyByAPIKey(pool, tt.apiKey)
+		if got := pool.ProxyFor(key); got != tt.want {
+			t.Errorf("ProxyFor(%s) = %q, want %q", tt.apiKey, got, tt.want)
+		}
+	}
+}
+
+func TestPool_ResolveGroupBinding(t *testing.T) {
+	pool := newGroupTestPool(WithGroupBindings([]types.KeyGroupBinding{
+		{Token: "sk-prod", Group: "paid"},
+		{IP: "192.168.1.10", Group: "paid"},
+		{IP: "10.0.0.0/8", Group: "free"},
+	}))
+
+	tests := []struct {
+		token, ip, want string
+	}{
+		{"sk-prod", "10.1.2.3", "paid"},
+		{"sk-other", "10.1.2.3", "free"},
+		{"", "192.168.1.10", "paid"},
+		{"", "172.16.0.1", ""},
+		{"", "invalid", ""},
+	}
+	for _, tt := range tests {
+		if got := pool.ResolveGroupBinding(tt.token, tt.ip); got != tt.want {
+			t.Errorf("ResolveGroupBinding(%q, %q) = %q, want %q", tt.token, tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestPool_GetGroupStatus(t *testing.T) {
+	pool := newGroupTestPool(WithDefaultGroup("paid"))
+
+	free, _ := pool.GetKey(types.KeyRequest{Group: "free"})
+	free.SetRateLimited(60)
+
+	statuses := pool.GetGroupStatus()
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(statuses))
+	}
+
+	paid := statuses[0]
+	if paid.Name != "paid" || !paid.Default || paid.Total != 1 || paid.Strategy != "round_robin" || paid.CooldownSeconds != 60 {
+		t.Errorf("unexpected paid group status: %+v", paid)
+	}
+	freeStatus := statuses[1]
+	if freeStatus.Total != 2 || freeStatus.RateLimited != 1 || freeStatus.Strategy != "least_used" || freeStatus.CooldownSeconds != 300 {
+		t.Errorf("unexpected free group status: %+v", freeStatus)
+	}
+
+	for _, key := range pool.GetStats() {
+		if key.Name == "Free 2" && (len(key.Groups) != 1 || key.Groups[0] != "free") {
+			t.Errorf("Free 2 should belong to the free group, got %v", key.Groups)
+		}
+	}
+}
+
+// ==================== Affinity Tests ====================
+
+func TestPool_GetKey_Affinity(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+		{Key: "AIzaSyKey2", Name: "Key 2", Enabled: true},
+		{Key: "AIzaSyKey3", Name: "Key 3", Enabled: true},
+	}
+	pool := NewPool(configs, WithAffinityTTL(time.Minute))
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	first, _ := pool.GetKey(types.KeyRequest{Affinity: "conv-a"})
+	for i := 0; i < 5; i++ {
+		key, err := pool.GetKey(types.KeyRequest{Affinity: "conv-a"})
+		if err != nil {
+			t.Fatalf("GetKey() error = %v", err)
+		}
+		if key.ID != first.ID {
+			t.Errorf("affinity should keep the conversation on %s, got %s", first.Name, key.Name)
+		}
+	}
+
+	// Unavailable pinned key falls back to normal selection and re-pins
+	first.SetRateLimited(60)
+	second, err := pool.GetKey(types.KeyRequest{Affinity: "conv-a"})
+	if err != nil {
+		t.Fatalf("GetKey() error = %v", err)
+	}
+	if second.ID == first.ID {
+		t.Error("rate-limited pinned key should not be returned")
+	}
+	if again, _ := pool.GetKey(types.KeyRequest{Affinity: "conv-a"}); again.ID != second.ID {
+		t.Errorf("affinity should move to %s, got %s", second.Name, again.Name)
+	}
+
+	// Expired affinity no longer pins the key
+	now = now.Add(2 * time.Minute)
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		key, _ := pool.GetKey(types.KeyRequest{Affinity: "conv-b"})
+		seen[key.ID] = true
+		now = now.Add(2 * time.Minute)
+	}
+	if len(seen) < 2 {
+		t.Errorf("expired affinity should allow rotation, saw %d keys", len(seen))
+	}
+}
+
+func TestPool_GetKey_AffinityDisabled(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+		{Key: "AIzaSyKey2", Name: "Key 2", Enabled: true},
+	}
+	pool := NewPool(configs)
+
+	first, _ := pool.GetKey(types.KeyRequest{Affinity: "conv-a"})
+	second, _ := pool.GetKey(types.KeyRequest{Affinity: "conv-a"})
+	if first.ID == second.ID {
+		t.Error("round robin should rotate when affinity is disabled")
+	}
+}
+
+// ==================== Priority and Limit Tests ====================
+
+func TestPool_GetKey_PrefersHighestPriority(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyLow", Name: "Low", Enabled: true},
+		{Key: "AIzaSyHigh1", Name: "High 1", Enabled: true, Priority: 10},
+		{Key: "AIzaSyHigh2", Name: "High 2", Enabled: true, Priority: 10},
+	}
+	pool := NewPool(configs)
+
+	for i := 0; i < 6; i++ {
+		key, _ := pool.GetKey(types.KeyRequest{})
+		if key.Name == "Low" {
+			t.Fatal("low-priority key should not be used while high-priority keys are available")
+		}
+	}
+
+	for _, key := range pool.keys {
+		if key.Priority == 10 {
+			key.SetRateLimited(60)
+		}
+	}
+	key, err := pool.GetKey(types.KeyRequest{})
+	if err != nil || key.Name != "Low" {
+		t.Errorf("expected fallback to the low-priority key, got %v, %v", key, err)
+	}
+}
+
+func TestPool_GetKey_MaxConcurrent(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true, Limits: types.KeyLimits{MaxConcurrent: 1}},
+	}
+	pool := NewPool(configs)
+
+	key, err := pool.GetKey(types.KeyRequest{})
+	if err != nil {
+		t.Fatalf("GetKey() error = %v", err)
+	}
+	if _, err := pool.GetKey(types.KeyRequest{}); err != types.ErrAllKeysRateLimited {
+		t.Errorf("expected ErrAllKeysRateLimited while leased, got %v", err)
+	}
+
+	pool.ReleaseKey(key)
+	if _, err := pool.GetKey(types.KeyRequest{}); err != nil {
+		t.Errorf("key should be available after release, got %v", err)
+	}
+}
+
+func TestPool_GetKey_RPMAndDailyLimits(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true, Limits: types.KeyLimits{RPM: 2, DailyRequests: 3}},
+	}
+	pool := NewPool(configs)
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.Local)
+	pool.now = func() time.Time { return now }
+
+	get := func() error {
+		key, err := pool.GetKey(types.KeyRequest{})
+		pool.ReleaseKey(key)
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("request %d: GetKey() error = %v", i, err)
+		}
+	}
+	if err := get(); err != types.ErrAllKeysRateLimited {
+		t.Errorf("expected RPM limit, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := get(); err != nil {
+		t.Errorf("RPM should reset after a minute, got %v", err)
+	}
+	if err := get(); err != types.ErrAllKeysRateLimited {
+		t.Errorf("expected daily limit, got %v", err)
+	}
+
+	now = now.Add(24 * time.Hour)
+	if err := get(); err != nil {
+		t.Errorf("daily limit should reset the next day, got %v", err)
+	}
+}
+
+func TestPool_UpdateKey_StatusTransitions(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+	}
+	pool := NewPool(configs)
+	id := pool.GetStats()[0].ID
+	key := pool.findKey(id)
+	key.SetRateLimited(60)
+
+	disabled, enabled := false, true
+	if _, err := pool.UpdateKey(id, types.UpdateKeyRequest{Enabled: &disabled}); err != nil {
+		t.Fatalf("UpdateKey() error = %v", err)
+	}
+	if key.Status != types.KeyStatusDisabled || key.CooldownUntil != nil {
+		t.Errorf("disabling should clear the cooldown, got status=%s cooldown=%v", key.Status, key.CooldownUntil)
+	}
+
+	if _, err := pool.UpdateKey(id, types.UpdateKeyRequest{Enabled: &enabled}); err != nil {
+		t.Fatalf("UpdateKey() error = %v", err)
+	}
+	if key.Status != types.KeyStatusActive {
+		t.Errorf("enabling should make the key active, got %s", key.Status)
+	}
+
+	if _, err := pool.UpdateKey("missing", types.UpdateKeyRequest{}); err != types.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
+func TestPool_UpdateKey_ReturnsCopy(t *testing.T) {
+	pool := NewPool([]types.KeyConfig{{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true}})
+	live, err := pool.GetKey(types.KeyRequest{})
+	if err != nil {
+		t.Fatalf("GetKey() error = %v", err)
+	}
+	pool.ReportSuccess(live, 1, 1, "gemini-2.0-flash")
+
+	name := "Renamed"
+	updated, err := pool.UpdateKey(live.ID, types.UpdateKeyRequest{Name: &name})
+	if err != nil {
+		t.Fatalf("UpdateKey() error = %v", err)
+	}
+	byID, _ := pool.GetKeyByID(live.ID)
+
+	// Later requests must not show through the returned keys
+	pool.ReportSuccess(live, 1, 1, "gemini-2.0-flash")
+	for _, key := range []*types.Key{updated, byID} {
+		if key == live {
+			t.Fatal("returned the pool's own key")
+		}
+		if key.Name != "Renamed" || key.Stats.RequestCount != 1 || key.Stats.ModelUsage["gemini-2.0-flash"] != 1 {
+			t.Errorf("copy changed with the pool: name=%q stats=%+v", key.Name, key.Stats)
+		}
+	}
+}
+
+func TestPool_RecordProbe(t *testing.T) {
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+		{Key: "AIzaSyKey2", Name: "Key 2", Enabled: true},
+	}
+	pool := NewPool(configs)
+	stats := pool.GetStats()
+	first, second := stats[0].ID, stats[1].ID
+
+	key := pool.findKey(first)
+	key.SetRateLimited(60)
+	key.CooldownStreak = 2
+	if _, err := pool.RecordProbe(first, types.KeyProbeResult{Health: types.KeyHealthValid, Models: []string{"gemini-2.5-pro"}}, false); err != nil {
+		t.Fatalf("RecordProbe() error = %v", err)
+	}
+	if key.Health != types.KeyHealthValid || key.LastCheckedAt == nil {
+		t.Errorf("valid probe should record health, got health=%s checked=%v", key.Health, key.LastCheckedAt)
+	}
+	if key.Status != types.KeyStatusRateLimited || key.CooldownUntil == nil || key.CooldownStreak != 2 {
+		t.Errorf("valid probe should keep the rate-limit cooldown, got status=%s streak=%d", key.Status, key.CooldownStreak)
+	}
+	if len(key.DetectedModels) != 1 || key.DetectedModels[0] != "gemini-2.5-pro" {
+		t.Errorf("valid probe should record detected models, got %v", key.DetectedModels)
+	}
+
+	pool.RecordProbe(first, types.KeyProbeResult{Health: types.KeyHealthQuotaExhausted}, false)
+	if key.Status != types.KeyStatusRateLimited {
+		t.Errorf("exhausted key should enter cooldown, got %s", key.Status)
+	}
+
+	other := pool.findKey(second)
+	pool.RecordProbe(second, types.KeyProbeResult{Health: types.KeyHealthInvalid}, false)
+	if !other.Enabled {
+		t.Error("invalid key should stay enabled without disableInvalid")
+	}
+	pool.RecordProbe(second, types.KeyProbeResult{Health: types.KeyHealthInvalid, Error: "API key not valid"}, true)
+	if other.Enabled || other.Status != types.KeyStatusDisabled {
+		t.Errorf("invalid key should be disabled, got enabled=%v status=%s", other.Enabled, other.Status)
+	}
+	if other.DisabledReason != "invalid: API key not valid" {
+		t.Errorf("unexpected disabled reason %q", other.DisabledReason)
+	}
+	enabled := true
+	pool.UpdateKey(second, types.UpdateKeyRequest{Enabled: &enabled})
+	if other.DisabledReason != "" {
+		t.Errorf("re-enabling should clear the disabled reason, got %q", other.DisabledReason)
+	}
+
+	if _, err := pool.RecordProbe("missing", types.KeyProbeResult{}, false); err != types.ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", err)
+	}
+}
+
 // ==================== Cooldown Recovery Tests ====================
 
 func TestPool_CooldownRecovery(t *testing.T) {
@@ -338,14 +1085,14 @@ func TestPool_CooldownRecovery(t *testing.T) {
 
 	pool := NewPool(configs, WithCooldownSeconds(1))
 
-	key, _ := pool.GetKey()
+	key, _ := pool.GetKey(types.KeyRequest{})
 
 	// Rate limit the key with 1 second cooldown
 	rateLimitErr := &types.AppError{Code: types.ErrCodeRateLimit}
 	pool.ReportFailure(key, rateLimitErr, "")
 
 	// Verify key is not available immediately
-	_, err := pool.GetKey()
+	_, err := pool.GetKey(types.KeyRequest{})
 	if !errors.Is(err, types.ErrAllKeysRateLimited) {
 		// It's possible the cooldown already expired if the system is slow
 		// So just log rather than fail
@@ -356,7 +1103,7 @@ func TestPool_CooldownRecovery(t *testing.T) {
 	time.Sleep(1100 * time.Millisecond)
 
 	// Now the key should be available
-	recoveredKey, err := pool.GetKey()
+	recoveredKey, err := pool.GetKey(types.KeyRequest{})
 	if err != nil {
 		t.Errorf("key should be available after cooldown: %v", err)
 	}
@@ -379,11 +1126,11 @@ func TestPool_GetStats(t *testing.T) {
 	pool := NewPool(configs)
 
 	// Make some requests
-	k1, _ := pool.GetKey()
+	k1, _ := pool.GetKey(types.KeyRequest{})
 	pool.ReportSuccess(k1, 100, 50, "model-a")
 	pool.ReleaseKey(k1)
 
-	k2, _ := pool.GetKey()
+	k2, _ := pool.GetKey(types.KeyRequest{})
 	pool.ReportSuccess(k2, 200, 100, "model-b")
 	pool.ReleaseKey(k2)
 
@@ -433,7 +1180,7 @@ func TestPool_ConcurrentGetKey(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			key, err := pool.GetKey()
+			key, err := pool.GetKey(types.KeyRequest{})
 			if err != nil {
 				errorCount.Add(1)
 				return
@@ -474,7 +1221,7 @@ func TestPool_ConcurrentGetKey_WithErrgroup(t *testing.T) {
 			default:
 			}
 
-			key, err := pool.GetKey()
+			key, err := pool.GetKey(types.KeyRequest{})
 			if err != nil {
 				return err
 			}
@@ -519,7 +1266,7 @@ func TestPool_ConcurrentMixedOperations(t *testing.T) {
 		go func(id int) {
 			defer wg.Done()
 
-			key, err := pool.GetKey()
+			key, err := pool.GetKey(types.KeyRequest{})
 			if err != nil {
 				return // Some keys might be rate limited
 			}
@@ -562,7 +1309,7 @@ func BenchmarkPool_GetKey(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		key, _ := pool.GetKey()
+		key, _ := pool.GetKey(types.KeyRequest{})
 		pool.ReleaseKey(key)
 	}
 }
@@ -582,7 +1329,7 @@ func BenchmarkPool_GetKey_Concurrent(b *testing.B) {
 
 	b.RunParallel(func(pb *testing.PB) {
 		for pb.Next() {
-			key, err := pool.GetKey()
+			key, err := pool.GetKey(types.KeyRequest{})
 			if err == nil && key != nil {
 				pool.ReleaseKey(key)
 			}
@@ -596,7 +1343,7 @@ func BenchmarkPool_ReportSuccess(b *testing.B) {
 	}
 
 	pool := NewPool(configs)
-	key, _ := pool.GetKey()
+	key, _ := pool.GetKey(types.KeyRequest{})
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
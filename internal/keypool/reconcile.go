@@ -0,0 +1,257 @@
+﻿package keypool
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"muxueTools/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// ==================== Reconciliation ====================
+
+// keyFromConfig creates a pool key from a config file entry.
+func keyFromConfig(cfg types.KeyConfig) *types.Key {
+	now := time.Now()
+	key := &types.Key{
+		ID:        uuid.New().String(),
+		APIKey:    cfg.Key,
+		MaskedKey: types.MaskAPIKey(cfg.Key),
+		Name:      cfg.Name,
+		Status:    types.KeyStatusActive,
+		Enabled:   cfg.Enabled,
+		Tags:      cfg.Tags,
+		Stats:     types.KeyStats{},
+		CreatedAt: now,
+		UpdatedAt: now,
+		Source:    types.KeySourceConfig,
+
+		AllowedModels: cfg.AllowedModels,
+		DeniedModels:  cfg.DeniedModels,
+		Weight:        cfg.Weight,
+		Priority:      cfg.Priority,
+		Limits:        cfg.Limits,
+		Proxy:         cfg.Proxy,
+	}
+
+	// Handle disabled from config
+	if !cfg.Enabled {
+		key.Status = types.KeyStatusDisabled
+	}
+	return key
+}
+
+// configDiff lists the settings of key that differ from cfg.
+func configDiff(key *types.Key, cfg types.KeyConfig) []string {
+	var fields []string
+	if key.Name != cfg.Name {
+		fields = append(fields, "name")
+	}
+	if key.Enabled != cfg.Enabled {
+		fields = append(fields, "enabled")
+	}
+	if !slices.Equal(key.Tags, cfg.Tags) && (len(key.Tags) > 0 || len(cfg.Tags) > 0) {
+		fields = append(fields, "tags")
+	}
+	if !slices.Equal(key.AllowedModels, cfg.AllowedModels) && (len(key.AllowedModels) > 0 || len(cfg.AllowedModels) > 0) {
+		fields = append(fields, "allowed_models")
+	}
+	if !slices.Equal(key.DeniedModels, cfg.DeniedModels) && (len(key.DeniedModels) > 0 || len(cfg.DeniedModels) > 0) {
+		fields = append(fields, "denied_models")
+	}
+	if key.Weight != cfg.Weight {
+		fields = append(fields, "weight")
+	}
+	if key.Priority != cfg.Priority {
+		fields = append(fields, "priority")
+	}
+	if key.Limits != cfg.Limits {
+		fields = append(fields, "limits")
+	}
+	if key.Proxy != cfg.Proxy {
+		fields = append(fields, "proxy")
+	}
+	return fields
+}
+
+// applyConfig copies the settings of cfg onto key.
+func applyConfig(key *types.Key, cfg types.KeyConfig) {
+	key.Name = cfg.Name
+	key.Tags = cfg.Tags
+	key.AllowedModels = cfg.AllowedModels
+	key.DeniedModels = cfg.DeniedModels
+	key.Weight = cfg.Weight
+	key.Priority = cfg.Priority
+	key.Limits = cfg.Limits
+	key.Proxy = cfg.Proxy
+	if key.Enabled != cfg.Enabled {
+		key.Enabled = cfg.Enabled
+		if cfg.Enabled {
+			key.Status = types.KeyStatusActive
+			key.DisabledReason = ""
+		} else {
+			key.Status = types.KeyStatusDisabled
+			key.CooldownUntil = nil
+		}
+	}
+	key.UpdatedAt = time.Now()
+}
+
+// Reconcile diffs the config file keys, the database and the in-memory pool
+// and brings them back in line:
+//   - database rows missing from memory are loaded, and in-memory keys missing
+//     from the database are saved
+//   - config keys missing from the pool are created, and existing keys that
+//     match a config entry are marked as config keys
+//   - with ReconcilePolicyConfig, config keys are updated to match the file
+//     and config keys no longer in the file are removed
+//
+// Keys added through the admin API are never updated or removed. With dryRun
+// the report lists the changes without applying them.
+func (p *Pool) Reconcile(configs []types.KeyConfig, policy types.ReconcilePolicy, dryRun bool) (*types.KeyReconcileReport, error) {
+	if policy == "" {
+		policy = types.ReconcilePolicyDatabase
+	}
+	if !policy.IsValid() {
+		return nil, errors.New("invalid reconcile policy: " + string(policy))
+	}
+
+	var stored []types.Key
+	if p.storage != nil {
+		var err error
+		if stored, err = p.storage.ListKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	report := &types.KeyReconcileReport{
+		Policy:  policy,
+		DryRun:  dryRun,
+		Summary: make(map[types.KeyReconcileAction]int),
+		Changes: []types.KeyReconcileChange{},
+	}
+	record := func(action types.KeyReconcileAction, key *types.Key, fields []string, err error) {
+		change := types.KeyReconcileChange{Action: action, KeyID: key.ID, MaskedKey: key.MaskedKey, Fields: fields}
+		if err != nil {
+			change.Error = err.Error()
+		} else {
+			report.Summary[action]++
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	// Memory against database
+	var pending []*types.Key // keys a dry run would have loaded
+	if p.storage != nil {
+		inStorage := make(map[string]bool, len(stored))
+		for i := range stored {
+			inStorage[stored[i].ID] = true
+			if p.findKey(stored[i].ID) != nil {
+				continue
+			}
+			key := stored[i]
+			if key.MaskedKey == "" {
+				key.MaskedKey = types.MaskAPIKey(key.APIKey)
+			}
+			if key.Enabled {
+				key.Status = types.KeyStatusActive
+			} else {
+				key.Status = types.KeyStatusDisabled
+			}
+			if dryRun {
+				pending = append(pending, &key)
+			} else {
+				p.keys = append(p.keys, &key)
+			}
+			record(types.KeyReconcileLoaded, &key, nil, nil)
+		}
+
+		for _, key := range slices.Clone(p.keys) {
+			if inStorage[key.ID] {
+				continue
+			}
+			var err error
+			if !dryRun {
+				err = p.storage.CreateKey(key)
+			}
+			record(types.KeyReconcilePersisted, key, nil, err)
+		}
+	}
+
+	// Config against pool
+	byAPIKey := make(map[string]*types.Key, len(p.keys))
+	for _, key := range append(slices.Clone(p.keys), pending...) {
+		byAPIKey[key.APIKey] = key
+	}
+	configured := make(map[string]bool, len(configs))
+	for _, cfg := range configs {
+		configured[cfg.Key] = true
+		key := byAPIKey[cfg.Key]
+
+		if key == nil {
+			key = keyFromConfig(cfg)
+			var err error
+			if !dryRun {
+				if p.storage != nil {
+					err = p.storage.CreateKey(key)
+				}
+				if err == nil {
+					p.keys = append(p.keys, key)
+				}
+			}
+			byAPIKey[cfg.Key] = key
+			record(types.KeyReconcileCreated, key, nil, err)
+			continue
+		}
+
+		changed := false
+		if key.Source != types.KeySourceConfig {
+			if !dryRun {
+				key.Source = types.KeySourceConfig
+			}
+			changed = true
+			record(types.KeyReconcileAdopted, key, nil, nil)
+		}
+		if policy == types.ReconcilePolicyConfig {
+			if fields := configDiff(key, cfg); len(fields) > 0 {
+				if !dryRun {
+					applyConfig(key, cfg)
+				}
+				changed = true
+				record(types.KeyReconcileUpdated, key, fields, nil)
+			}
+		}
+		if changed && !dryRun && p.storage != nil {
+			if err := p.storage.UpdateKey(key); err != nil {
+				record(types.KeyReconcileUpdated, key, nil, err)
+			}
+		}
+	}
+
+	if policy == types.ReconcilePolicyConfig {
+		for _, key := range append(slices.Clone(p.keys), pending...) {
+			if key.Source != types.KeySourceConfig || configured[key.APIKey] {
+				continue
+			}
+			var err error
+			if !dryRun {
+				if p.storage != nil {
+					if err = p.storage.DeleteKey(key.ID); errors.Is(err, types.ErrKeyNotFound) {
+						err = nil
+					}
+				}
+				if err == nil {
+					p.removeKeyLocked(key.ID)
+				}
+			}
+			record(types.KeyReconcileRemoved, key, nil, err)
+		}
+	}
+
+	return report, nil
+}
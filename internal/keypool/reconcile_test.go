@@ -0,0 +1,200 @@
+﻿package keypool
+
+import (
+	"testing"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Test Storage ====================
+
+// memStorage is an in-memory KeyStorage for reconciliation tests.
+type memStorage struct {
+	keys map[string]types.Key
+}
+
+func newMemStorage() *memStorage {
+	return &memStorage{keys: make(map[string]types.Key)}
+}
+
+func (s *memStorage) CreateKey(key *types.Key) error {
+	s.keys[key.ID] = *key
+	return nil
+}
+
+func (s *memStorage) GetKey(id string) (*types.Key, error) {
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, types.ErrKeyNotFound
+	}
+	return &key, nil
+}
+
+func (s *memStorage) ListKeys() ([]types.Key, error) {
+	keys := make([]types.Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *memStorage) UpdateKey(key *types.Key) error {
+	if _, ok := s.keys[key.ID]; !ok {
+		return types.ErrKeyNotFound
+	}
+	s.keys[key.ID] = *key
+	return nil
+}
+
+func (s *memStorage) DeleteKey(id string) error {
+	if _, ok := s.keys[id]; !ok {
+		return types.ErrKeyNotFound
+	}
+	delete(s.keys, id)
+	return nil
+}
+
+func (s *memStorage) KeyExists(apiKey string) (bool, error) {
+	for _, key := range s.keys {
+		if key.APIKey == apiKey {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ==================== Reconcile Tests ====================
+
+func TestPool_Reconcile_DatabasePolicy(t *testing.T) {
+	store := newMemStorage()
+	pool := NewPool(nil, WithStorage(store))
+	apiKey := &types.Key{ID: "api-key", APIKey: "AIzaSyApi", Name: "API", Enabled: true, Source: types.KeySourceAPI}
+	if err := pool.AddKey(apiKey); err != nil {
+		t.Fatalf("AddKey() error = %v", err)
+	}
+
+	configs := []types.KeyConfig{{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true}}
+	report, err := pool.Reconcile(configs, types.ReconcilePolicyDatabase, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if report.Summary[types.KeyReconcileCreated] != 1 {
+		t.Errorf("created = %d, want 1", report.Summary[types.KeyReconcileCreated])
+	}
+	if pool.Size() != 2 || len(store.keys) != 2 {
+		t.Fatalf("pool size = %d, stored = %d, want 2", pool.Size(), len(store.keys))
+	}
+
+	// Config edits and removals are ignored under the database policy
+	configs[0].Name = "Renamed"
+	report, err = pool.Reconcile(nil, types.ReconcilePolicyDatabase, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(report.Changes) != 0 {
+		t.Errorf("changes = %+v, want none", report.Changes)
+	}
+	if pool.Size() != 2 {
+		t.Errorf("pool size = %d, want 2", pool.Size())
+	}
+}
+
+func TestPool_Reconcile_ConfigPolicy(t *testing.T) {
+	store := newMemStorage()
+	pool := NewPool(nil, WithStorage(store))
+	configs := []types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+		{Key: "AIzaSyKey2", Name: "Key 2", Enabled: true},
+	}
+	if _, err := pool.Reconcile(configs, types.ReconcilePolicyConfig, false); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	apiKey := &types.Key{ID: "api-key", APIKey: "AIzaSyApi", Name: "API", Enabled: true, Source: types.KeySourceAPI}
+	if err := pool.AddKey(apiKey); err != nil {
+		t.Fatalf("AddKey() error = %v", err)
+	}
+
+	// Key 1 is edited, Key 2 is removed from the file
+	configs = []types.KeyConfig{{Key: "AIzaSyKey1", Name: "Primary", Enabled: false, Weight: 3}}
+	report, err := pool.Reconcile(configs, types.ReconcilePolicyConfig, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if report.Summary[types.KeyReconcileUpdated] != 1 || report.Summary[types.KeyReconcileRemoved] != 1 {
+		t.Fatalf("summary = %v, want 1 updated and 1 removed", report.Summary)
+	}
+
+	if pool.Size() != 2 || len(store.keys) != 2 {
+		t.Fatalf("pool size = %d, stored = %d, want 2", pool.Size(), len(store.keys))
+	}
+	key, err := pool.GetKeyByAPIKey("AIzaSyKey1")
+	if err != nil {
+		t.Fatalf("GetKeyByAPIKey() error = %v", err)
+	}
+	if key.Name != "Primary" || key.Enabled || key.Status != types.KeyStatusDisabled || key.Weight != 3 {
+		t.Errorf("config key not updated: %+v", key)
+	}
+	if store.keys[key.ID].Name != "Primary" {
+		t.Errorf("stored name = %q, want Primary", store.keys[key.ID].Name)
+	}
+	if _, err := pool.GetKeyByAPIKey("AIzaSyKey2"); err == nil {
+		t.Error("removed config key is still in the pool")
+	}
+	if _, err := pool.GetKeyByID("api-key"); err != nil {
+		t.Errorf("API key was removed: %v", err)
+	}
+}
+
+func TestPool_Reconcile_AdoptsExistingKey(t *testing.T) {
+	store := newMemStorage()
+	pool := NewPool(nil, WithStorage(store))
+	if err := pool.AddKey(&types.Key{ID: "k1", APIKey: "AIzaSyKey1", Name: "Manual", Enabled: true}); err != nil {
+		t.Fatalf("AddKey() error = %v", err)
+	}
+
+	configs := []types.KeyConfig{{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true}}
+	report, err := pool.Reconcile(configs, types.ReconcilePolicyDatabase, false)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if report.Summary[types.KeyReconcileAdopted] != 1 || report.Summary[types.KeyReconcileUpdated] != 0 {
+		t.Fatalf("summary = %v, want 1 adopted", report.Summary)
+	}
+	if store.keys["k1"].Source != types.KeySourceConfig || store.keys["k1"].Name != "Manual" {
+		t.Errorf("stored key = %+v, want adopted with name kept", store.keys["k1"])
+	}
+}
+
+func TestPool_Reconcile_MemoryAndDatabase(t *testing.T) {
+	store := newMemStorage()
+	store.keys["db-only"] = types.Key{ID: "db-only", APIKey: "AIzaSyDB", Enabled: true}
+	pool := NewPool([]types.KeyConfig{{Key: "AIzaSyMem", Enabled: true}}, WithStorage(store))
+
+	report, err := pool.Reconcile(nil, types.ReconcilePolicyDatabase, true)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !report.DryRun || report.Summary[types.KeyReconcileLoaded] != 1 || report.Summary[types.KeyReconcilePersisted] != 1 {
+		t.Fatalf("report = %+v, want 1 loaded and 1 persisted", report)
+	}
+	if pool.Size() != 1 || len(store.keys) != 1 {
+		t.Fatalf("dry run changed state: pool = %d, stored = %d", pool.Size(), len(store.keys))
+	}
+
+	if _, err := pool.Reconcile(nil, types.ReconcilePolicyDatabase, false); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if pool.Size() != 2 || len(store.keys) != 2 {
+		t.Errorf("pool = %d, stored = %d, want 2", pool.Size(), len(store.keys))
+	}
+	if _, err := pool.GetKeyByID("db-only"); err != nil {
+		t.Errorf("db-only key not loaded: %v", err)
+	}
+}
+
+func TestPool_Reconcile_InvalidPolicy(t *testing.T) {
+	pool := NewPool(nil)
+	if _, err := pool.Reconcile(nil, "yaml", false); err == nil {
+		t.Error("Reconcile() error = nil, want error for unknown policy")
+	}
+}
@@ -4,8 +4,10 @@ package keypool
 import (
 	"math"
 	"math/rand"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"muxueTools/internal/types"
 )
@@ -22,6 +24,59 @@ type Strategy interface {
 	Name() string
 }
 
+// FeedbackStrategy is implemented by strategies that learn from request outcomes.
+// The pool forwards latency samples and success/failure results to it.
+type FeedbackStrategy interface {
+	Strategy
+
+	// RecordLatency records the upstream latency of a successful request.
+	RecordLatency(keyID, model string, latency time.Duration)
+
+	// RecordResult records whether a request succeeded.
+	RecordResult(keyID, model string, success bool)
+
+	// Forget drops everything recorded for a key that left the pool.
+	Forget(keyID string)
+}
+
+// ModelAware is implemented by strategies that rank keys differently per model.
+// The pool calls SelectForModel instead of Select when a model is known.
+type ModelAware interface {
+	Strategy
+
+	// SelectForModel picks a key for the given model. An empty model behaves like Select.
+	SelectForModel(keys []*types.Key, model string) *types.Key
+}
+
+// Peeker is implemented by strategies that can report which key they would
+// select next without changing their state, for dry-run selection.
+type Peeker interface {
+	Strategy
+
+	// Peek returns the key the next selection would pick, or the most likely
+	// one for randomized strategies. Returns nil if the choice is uniformly random.
+	Peek(keys []*types.Key, model string) *types.Key
+}
+
+// WindowStatsFunc returns a key's statistics over a rolling window.
+type WindowStatsFunc func(keyID string, window types.StatsWindow) types.WindowStats
+
+// WindowAware is implemented by strategies that rank keys on request statistics.
+// The pool supplies rolling-window statistics so that old failures stop
+// counting against a key once they fall out of the chosen window.
+type WindowAware interface {
+	Strategy
+
+	// StatsWindow returns the window the strategy ranks on.
+	StatsWindow() types.StatsWindow
+
+	// SetStatsWindow changes the window the strategy ranks on.
+	SetStatsWindow(window types.StatsWindow)
+
+	// SetWindowStatsFunc sets the source of rolling-window statistics.
+	SetWindowStatsFunc(fn WindowStatsFunc)
+}
+
 // StrategyFactory creates a strategy based on the configuration.
 func StrategyFactory(strategyName types.PoolStrategy) Strategy {
 	switch strategyName {
@@ -31,6 +86,8 @@ func StrategyFactory(strategyName types.PoolStrategy) Strategy {
 		return NewLeastUsedStrategy()
 	case types.PoolStrategyWeighted:
 		return NewWeightedStrategy()
+	case types.PoolStrategyLatencyAware:
+		return NewLatencyAwareStrategy()
 	default:
 		return NewRoundRobinStrategy()
 	}
@@ -62,6 +119,16 @@ func (s *RoundRobinStrategy) Select(keys []*types.Key) *types.Key {
 	return selected
 }
 
+// Peek returns the key the next Select would pick without advancing the rotation.
+func (s *RoundRobinStrategy) Peek(keys []*types.Key, _ string) *types.Key {
+	availableKeys := filterAvailable(keys)
+	if len(availableKeys) == 0 {
+		return nil
+	}
+	idx := atomic.LoadUint64(&s.index)
+	return availableKeys[idx%uint64(len(availableKeys))]
+}
+
 // Name returns the strategy identifier.
 func (s *RoundRobinStrategy) Name() string {
 	return string(types.PoolStrategyRoundRobin)
@@ -97,6 +164,11 @@ func (s *RandomStrategy) Select(keys []*types.Key) *types.Key {
 	return availableKeys[idx]
 }
 
+// Peek returns nil: every available key is equally likely.
+func (s *RandomStrategy) Peek(_ []*types.Key, _ string) *types.Key {
+	return nil
+}
+
 // Name returns the strategy identifier.
 func (s *RandomStrategy) Name() string {
 	return string(types.PoolStrategyRandom)
@@ -105,12 +177,16 @@ func (s *RandomStrategy) Name() string {
 // ==================== Least Used Strategy ====================
 
 // LeastUsedStrategy implements selection based on request count.
-// The key with the fewest total requests is preferred.
-type LeastUsedStrategy struct{}
+// The key with the fewest requests in the configured stats window is preferred.
+type LeastUsedStrategy struct {
+	windowRanking
+}
 
 // NewLeastUsedStrategy creates a new least-used strategy instance.
 func NewLeastUsedStrategy() *LeastUsedStrategy {
-	return &LeastUsedStrategy{}
+	return &LeastUsedStrategy{
+		windowRanking: newWindowRanking(),
+	}
 }
 
 // Select picks the key with the lowest request count.
@@ -121,18 +197,23 @@ func (s *LeastUsedStrategy) Select(keys []*types.Key) *types.Key {
 	}
 
 	minKey := availableKeys[0]
-	minCount := minKey.Stats.RequestCount
+	minCount, _ := s.counts(minKey)
 
 	for _, key := range availableKeys[1:] {
-		if key.Stats.RequestCount < minCount {
+		if count, _ := s.counts(key); count < minCount {
 			minKey = key
-			minCount = key.Stats.RequestCount
+			minCount = count
 		}
 	}
 
 	return minKey
 }
 
+// Peek returns the key Select would pick; the choice is deterministic.
+func (s *LeastUsedStrategy) Peek(keys []*types.Key, _ string) *types.Key {
+	return s.Select(keys)
+}
+
 // Name returns the strategy identifier.
 func (s *LeastUsedStrategy) Name() string {
 	return string(types.PoolStrategyLeastUsed)
@@ -141,9 +222,11 @@ func (s *LeastUsedStrategy) Name() string {
 // ==================== Weighted Strategy ====================
 
 // WeightedStrategy implements weighted selection based on success rate.
-// Keys with higher success rates are more likely to be selected.
-// New keys (0 requests) are given a fair default weight.
+// Keys with higher success rates in the configured stats window are more likely
+// to be selected. Keys with no requests in the window get a fair default weight.
 type WeightedStrategy struct {
+	windowRanking
+
 	mu  sync.Mutex
 	rng *rand.Rand
 }
@@ -151,7 +234,8 @@ type WeightedStrategy struct {
 // NewWeightedStrategy creates a new weighted strategy instance.
 func NewWeightedStrategy() *WeightedStrategy {
 	return &WeightedStrategy{
-		rng: rand.New(rand.NewSource(rand.Int63())),
+		windowRanking: newWindowRanking(),
+		rng:           rand.New(rand.NewSource(rand.Int63())),
 	}
 }
 
@@ -167,7 +251,8 @@ func (s *WeightedStrategy) Select(keys []*types.Key) *types.Key {
 	totalWeight := 0.0
 
 	for i, key := range availableKeys {
-		weight := calculateWeight(key)
+		requests, successes := s.counts(key)
+		weight := calculateWeight(requests, successes) * keyWeight(key)
 		weights[i] = weight
 		totalWeight += weight
 	}
@@ -189,27 +274,48 @@ func (s *WeightedStrategy) Select(keys []*types.Key) *types.Key {
 	return availableKeys[len(availableKeys)-1]
 }
 
+// Peek returns the key with the highest selection weight.
+func (s *WeightedStrategy) Peek(keys []*types.Key, _ string) *types.Key {
+	var best *types.Key
+	bestWeight := -1.0
+	for _, key := range filterAvailable(keys) {
+		requests, successes := s.counts(key)
+		if weight := calculateWeight(requests, successes) * keyWeight(key); weight > bestWeight {
+			best, bestWeight = key, weight
+		}
+	}
+	return best
+}
+
 // Name returns the strategy identifier.
 func (s *WeightedStrategy) Name() string {
 	return string(types.PoolStrategyWeighted)
 }
 
-// calculateWeight computes the selection weight for a key.
+// keyWeight returns the key's configured weight multiplier, treating 0 as 1.
+func keyWeight(key *types.Key) float64 {
+	if key.Weight <= 0 {
+		return 1
+	}
+	return float64(key.Weight)
+}
+
+// calculateWeight computes the selection weight from a key's request counts.
 // Uses success rate with a minimum baseline to ensure all keys get a chance.
-func calculateWeight(key *types.Key) float64 {
+func calculateWeight(requests, successes int64) float64 {
 	const (
-		minWeight     = 0.1  // Minimum weight to ensure selection chance
-		defaultWeight = 0.5  // Default weight for new keys
-		maxWeight     = 1.0  // Maximum weight
+		minWeight     = 0.1 // Minimum weight to ensure selection chance
+		defaultWeight = 0.5 // Default weight for new keys
+		maxWeight     = 1.0 // Maximum weight
 	)
 
-	if key.Stats.RequestCount == 0 {
+	if requests == 0 {
 		// New keys get a fair default weight
 		return defaultWeight
 	}
 
 	// Calculate success rate (0.0 to 1.0)
-	successRate := float64(key.Stats.SuccessCount) / float64(key.Stats.RequestCount)
+	successRate := float64(successes) / float64(requests)
 
 	// Apply minimum threshold to avoid 0 weight
 	weight := math.Max(minWeight, successRate)
@@ -218,6 +324,298 @@ func calculateWeight(key *types.Key) float64 {
 	return math.Min(weight, maxWeight)
 }
 
+// ==================== Latency Aware Strategy ====================
+
+// Tuning parameters for LatencyAwareStrategy.
+const (
+	latencyEWMAAlpha      = 0.3              // Weight of the newest latency sample
+	errorEWMAAlpha        = 0.2              // Weight of the newest success/failure result
+	errorRateHalfLife     = 60 * time.Second // Time for an idle key's error rate to halve
+	errorPenaltyFactor    = 10.0             // Score multiplier per unit of error rate
+	defaultLatencyPriorMs = 1000.0           // Assumed latency before any sample exists
+)
+
+// LatencyAwareStrategy picks keys by power-of-two-choices over an
+// exponentially weighted latency and recent error rate.
+// Two random available keys are compared and the one with the lower score wins,
+// which favours fast, healthy keys without piling all traffic onto a single one.
+// Statistics are tracked per key and model, with a per-key aggregate used when
+// no model-specific samples exist yet.
+type LatencyAwareStrategy struct {
+	mu    sync.Mutex
+	rng   *rand.Rand
+	stats map[string]*latencyStats // keyed by latencyStatsKey(keyID, model)
+	now   func() time.Time
+}
+
+// latencyStats holds the moving averages for one key (and optionally one model).
+type latencyStats struct {
+	latencyMs float64   // EWMA latency in milliseconds
+	errorRate float64   // EWMA of failures (0.0 to 1.0)
+	samples   int64     // Number of latency samples observed
+	updatedAt time.Time // Last time errorRate was updated
+}
+
+// NewLatencyAwareStrategy creates a new latency-aware strategy instance.
+func NewLatencyAwareStrategy() *LatencyAwareStrategy {
+	return &LatencyAwareStrategy{
+		rng:   rand.New(rand.NewSource(rand.Int63())),
+		stats: make(map[string]*latencyStats),
+		now:   time.Now,
+	}
+}
+
+// Select picks a key using the per-key aggregate statistics.
+func (s *LatencyAwareStrategy) Select(keys []*types.Key) *types.Key {
+	return s.SelectForModel(keys, "")
+}
+
+// SelectForModel picks a key using statistics recorded for the given model.
+// An empty model uses the per-key aggregate across all models.
+func (s *LatencyAwareStrategy) SelectForModel(keys []*types.Key, model string) *types.Key {
+	availableKeys := filterAvailable(keys)
+	if len(availableKeys) == 0 {
+		return nil
+	}
+	if len(availableKeys) == 1 {
+		return availableKeys[0]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Pick two distinct candidates
+	i := s.rng.Intn(len(availableKeys))
+	j := s.rng.Intn(len(availableKeys) - 1)
+	if j >= i {
+		j++
+	}
+
+	now := s.now()
+	prior := s.latencyPrior(availableKeys, model)
+	a, b := availableKeys[i], availableKeys[j]
+	if s.score(b.ID, model, prior, now) < s.score(a.ID, model, prior, now) {
+		return b
+	}
+	return a
+}
+
+// Peek returns the key with the lowest score, which wins every comparison it
+// takes part in.
+func (s *LatencyAwareStrategy) Peek(keys []*types.Key, model string) *types.Key {
+	availableKeys := filterAvailable(keys)
+	if len(availableKeys) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	prior := s.latencyPrior(availableKeys, model)
+	best := availableKeys[0]
+	bestScore := s.score(best.ID, model, prior, now)
+	for _, key := range availableKeys[1:] {
+		if score := s.score(key.ID, model, prior, now); score < bestScore {
+			best, bestScore = key, score
+		}
+	}
+	return best
+}
+
+// Name returns the strategy identifier.
+func (s *LatencyAwareStrategy) Name() string {
+	return string(types.PoolStrategyLatencyAware)
+}
+
+// RecordLatency folds a latency sample into the key's moving average.
+func (s *LatencyAwareStrategy) RecordLatency(keyID, model string, latency time.Duration) {
+	ms := float64(latency) / float64(time.Millisecond)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, st := range s.statsFor(keyID, model, true) {
+		if st.samples == 0 {
+			st.latencyMs = ms
+		} else {
+			st.latencyMs = latencyEWMAAlpha*ms + (1-latencyEWMAAlpha)*st.latencyMs
+		}
+		st.samples++
+	}
+}
+
+// RecordResult folds a success or failure into the key's recent error rate.
+// A failure only updates model statistics that a served request created, so
+// made-up model names do not accumulate entries.
+func (s *LatencyAwareStrategy) RecordResult(keyID, model string, success bool) {
+	sample := 0.0
+	if !success {
+		sample = 1.0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, st := range s.statsFor(keyID, model, success) {
+		rate := decayedErrorRate(st, now)
+		st.errorRate = errorEWMAAlpha*sample + (1-errorEWMAAlpha)*rate
+		st.updatedAt = now
+	}
+}
+
+// Forget drops the aggregate and per-model statistics of a key.
+func (s *LatencyAwareStrategy) Forget(keyID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefix := latencyStatsKey(keyID, "")
+	for k := range s.stats {
+		if strings.HasPrefix(k, prefix) {
+			delete(s.stats, k)
+		}
+	}
+}
+
+// statsFor returns the aggregate entry for the key and, if a model is given,
+// the model-specific entry. The aggregate is created as needed; the model
+// entry only when served is set. Caller must hold s.mu.
+func (s *LatencyAwareStrategy) statsFor(keyID, model string, served bool) []*latencyStats {
+	entries := []*latencyStats{s.entry(latencyStatsKey(keyID, ""), true)}
+	if model != "" {
+		if st := s.entry(latencyStatsKey(keyID, model), served); st != nil {
+			entries = append(entries, st)
+		}
+	}
+	return entries
+}
+
+// entry returns the statistics stored under k, creating them if create is set.
+// Caller must hold s.mu.
+func (s *LatencyAwareStrategy) entry(k string, create bool) *latencyStats {
+	st, ok := s.stats[k]
+	if !ok && create {
+		st = &latencyStats{}
+		s.stats[k] = st
+	}
+	return st
+}
+
+// lookup returns the most specific statistics available for a key and model.
+// Caller must hold s.mu.
+func (s *LatencyAwareStrategy) lookup(keyID, model string) *latencyStats {
+	if model != "" {
+		if st, ok := s.stats[latencyStatsKey(keyID, model)]; ok {
+			return st
+		}
+	}
+	return s.stats[latencyStatsKey(keyID, "")]
+}
+
+// latencyPrior returns the mean latency of candidates that have samples,
+// used as the assumed latency for keys that have none. Caller must hold s.mu.
+func (s *LatencyAwareStrategy) latencyPrior(keys []*types.Key, model string) float64 {
+	total, count := 0.0, 0
+	for _, key := range keys {
+		if st := s.lookup(key.ID, model); st != nil && st.samples > 0 {
+			total += st.latencyMs
+			count++
+		}
+	}
+	if count == 0 {
+		return defaultLatencyPriorMs
+	}
+	return total / float64(count)
+}
+
+// score computes the selection cost for a key; lower is better.
+// Caller must hold s.mu.
+func (s *LatencyAwareStrategy) score(keyID, model string, prior float64, now time.Time) float64 {
+	st := s.lookup(keyID, model)
+	if st == nil {
+		return prior
+	}
+
+	latency := prior
+	if st.samples > 0 {
+		latency = st.latencyMs
+	}
+	return latency * (1 + errorPenaltyFactor*decayedErrorRate(st, now))
+}
+
+// decayedErrorRate returns the error rate decayed by the time since it was last
+// updated, so a key that failed a while ago gradually regains its standing.
+func decayedErrorRate(st *latencyStats, now time.Time) float64 {
+	if st.updatedAt.IsZero() || st.errorRate == 0 {
+		return st.errorRate
+	}
+	elapsed := now.Sub(st.updatedAt)
+	if elapsed <= 0 {
+		return st.errorRate
+	}
+	return st.errorRate * math.Pow(0.5, float64(elapsed)/float64(errorRateHalfLife))
+}
+
+// latencyStatsKey builds the map key for a key/model pair.
+func latencyStatsKey(keyID, model string) string {
+	return keyID + "|" + model
+}
+
+// ==================== Window Ranking ====================
+
+// windowRanking implements WindowAware for strategies that rank on request counts.
+// Until the pool supplies a WindowStatsFunc, or when the window is
+// StatsWindowLifetime, the lifetime counters in KeyStats are used.
+type windowRanking struct {
+	windowMu sync.RWMutex
+	window   types.StatsWindow
+	statsFn  WindowStatsFunc
+}
+
+// newWindowRanking creates a windowRanking that ranks on lifetime counters.
+func newWindowRanking() windowRanking {
+	return windowRanking{window: types.StatsWindowLifetime}
+}
+
+// StatsWindow returns the window the strategy ranks on.
+func (r *windowRanking) StatsWindow() types.StatsWindow {
+	r.windowMu.RLock()
+	defer r.windowMu.RUnlock()
+	return r.window
+}
+
+// SetStatsWindow changes the window the strategy ranks on.
+// Invalid windows are ignored.
+func (r *windowRanking) SetStatsWindow(window types.StatsWindow) {
+	if !window.IsValid() {
+		return
+	}
+	r.windowMu.Lock()
+	defer r.windowMu.Unlock()
+	r.window = window
+}
+
+// SetWindowStatsFunc sets the source of rolling-window statistics.
+func (r *windowRanking) SetWindowStatsFunc(fn WindowStatsFunc) {
+	r.windowMu.Lock()
+	defer r.windowMu.Unlock()
+	r.statsFn = fn
+}
+
+// counts returns the request and success counts for a key in the ranking window.
+func (r *windowRanking) counts(key *types.Key) (requests, successes int64) {
+	r.windowMu.RLock()
+	window, fn := r.window, r.statsFn
+	r.windowMu.RUnlock()
+
+	if fn == nil || window == types.StatsWindowLifetime {
+		return key.Stats.RequestCount, key.Stats.SuccessCount
+	}
+	stats := fn(key.ID, window)
+	return stats.RequestCount, stats.SuccessCount
+}
+
 // ==================== Helper Functions ====================
 
 // filterAvailable returns only the keys that are currently available for use.
@@ -2,6 +2,7 @@
 package keypool
 
 import (
+	"fmt"
 	"math"
 	"sync"
 	"testing"
@@ -181,9 +182,9 @@ func TestRandomStrategy_SkipsUnavailable(t *testing.T) {
 // TestLeastUsedStrategy_Select tests the least-used selection strategy.
 func TestLeastUsedStrategy_Select(t *testing.T) {
 	tests := []struct {
-		name     string
-		keys     []*types.Key
-		wantID   string
+		name   string
+		keys   []*types.Key
+		wantID string
 	}{
 		{
 			name: "selects key with lowest request count",
@@ -236,9 +237,9 @@ func TestLeastUsedStrategy_Select(t *testing.T) {
 func TestWeightedStrategy_Select(t *testing.T) {
 	// Create keys with different success rates
 	keys := []*types.Key{
-		createKeyWithStats("key1", 100, 90),  // 90% success rate
-		createKeyWithStats("key2", 100, 50),  // 50% success rate
-		createKeyWithStats("key3", 100, 10),  // 10% success rate
+		createKeyWithStats("key1", 100, 90), // 90% success rate
+		createKeyWithStats("key2", 100, 50), // 50% success rate
+		createKeyWithStats("key3", 100, 10), // 10% success rate
 	}
 
 	strategy := NewWeightedStrategy()
@@ -286,6 +287,217 @@ func TestWeightedStrategy_NewKeysGetChance(t *testing.T) {
 	}
 }
 
+func TestWeightedStrategy_UsesStatsWindow(t *testing.T) {
+	// key1 failed heavily in the past but is healthy in the current window
+	keys := []*types.Key{
+		createKeyWithStats("key1", 1000, 0),
+		createKeyWithStats("key2", 1000, 1000),
+	}
+	recent := map[string]types.WindowStats{
+		"key1": {RequestCount: 10, SuccessCount: 10},
+		"key2": {RequestCount: 10, SuccessCount: 0, ErrorCount: 10},
+	}
+
+	strategy := NewWeightedStrategy()
+	strategy.SetWindowStatsFunc(func(keyID string, window types.StatsWindow) types.WindowStats {
+		return recent[keyID]
+	})
+	strategy.SetStatsWindow(types.StatsWindow1h)
+
+	selectedCounts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		if key := strategy.Select(keys); key != nil {
+			selectedCounts[key.ID]++
+		}
+	}
+
+	if selectedCounts["key1"] < selectedCounts["key2"] {
+		t.Errorf("key1 (healthy in window) should be selected more than key2: key1=%d, key2=%d",
+			selectedCounts["key1"], selectedCounts["key2"])
+	}
+
+	// Invalid windows are ignored
+	strategy.SetStatsWindow("7d")
+	if strategy.StatsWindow() != types.StatsWindow1h {
+		t.Errorf("StatsWindow() = %s, want 1h", strategy.StatsWindow())
+	}
+}
+
+// ==================== Latency Aware Strategy Tests ====================
+
+func TestLatencyAwareStrategy_PrefersFasterKey(t *testing.T) {
+	keys := []*types.Key{
+		createTestKey("fast", types.KeyStatusActive, true),
+		createTestKey("slow", types.KeyStatusActive, true),
+	}
+
+	strategy := NewLatencyAwareStrategy()
+	for i := 0; i < 5; i++ {
+		strategy.RecordLatency("fast", "", 100*time.Millisecond)
+		strategy.RecordLatency("slow", "", 2*time.Second)
+	}
+
+	// With two keys, power-of-two-choices always compares both
+	for i := 0; i < 50; i++ {
+		key := strategy.Select(keys)
+		if key == nil || key.ID != "fast" {
+			t.Fatalf("expected fast key to be selected, got %v", key)
+		}
+	}
+}
+
+func TestLatencyAwareStrategy_PenalizesErrors(t *testing.T) {
+	keys := []*types.Key{
+		createTestKey("healthy", types.KeyStatusActive, true),
+		createTestKey("flaky", types.KeyStatusActive, true),
+	}
+
+	strategy := NewLatencyAwareStrategy()
+	strategy.RecordLatency("healthy", "", 500*time.Millisecond)
+	strategy.RecordLatency("flaky", "", 200*time.Millisecond)
+	for i := 0; i < 5; i++ {
+		strategy.RecordResult("healthy", "", true)
+		strategy.RecordResult("flaky", "", false)
+	}
+
+	key := strategy.Select(keys)
+	if key == nil || key.ID != "healthy" {
+		t.Errorf("expected healthy key despite higher latency, got %v", key)
+	}
+}
+
+func TestLatencyAwareStrategy_ErrorRateDecays(t *testing.T) {
+	keys := []*types.Key{
+		createTestKey("key1", types.KeyStatusActive, true),
+		createTestKey("key2", types.KeyStatusActive, true),
+	}
+
+	now := time.Now()
+	strategy := NewLatencyAwareStrategy()
+	strategy.now = func() time.Time { return now }
+
+	strategy.RecordLatency("key1", "", 200*time.Millisecond)
+	strategy.RecordLatency("key2", "", 500*time.Millisecond)
+	for i := 0; i < 5; i++ {
+		strategy.RecordResult("key1", "", false)
+	}
+
+	if key := strategy.Select(keys); key.ID != "key2" {
+		t.Fatalf("expected key2 while key1 is failing, got %s", key.ID)
+	}
+
+	// After many half-lives the old failures no longer matter
+	now = now.Add(20 * errorRateHalfLife)
+	if key := strategy.Select(keys); key.ID != "key1" {
+		t.Errorf("expected key1 after error rate decayed, got %s", key.ID)
+	}
+}
+
+func TestLatencyAwareStrategy_PerModelStats(t *testing.T) {
+	keys := []*types.Key{
+		createTestKey("key1", types.KeyStatusActive, true),
+		createTestKey("key2", types.KeyStatusActive, true),
+	}
+
+	strategy := NewLatencyAwareStrategy()
+	strategy.RecordLatency("key1", "gemini-2.5-pro", 3*time.Second)
+	strategy.RecordLatency("key2", "gemini-2.5-pro", 1*time.Second)
+	strategy.RecordLatency("key1", "gemini-2.0-flash", 100*time.Millisecond)
+	strategy.RecordLatency("key2", "gemini-2.0-flash", 400*time.Millisecond)
+
+	if key := strategy.SelectForModel(keys, "gemini-2.5-pro"); key.ID != "key2" {
+		t.Errorf("SelectForModel(pro) = %s, want key2", key.ID)
+	}
+	if key := strategy.SelectForModel(keys, "gemini-2.0-flash"); key.ID != "key1" {
+		t.Errorf("SelectForModel(flash) = %s, want key1", key.ID)
+	}
+}
+
+func TestLatencyAwareStrategy_OnlyServedModelsKeepStats(t *testing.T) {
+	strategy := NewLatencyAwareStrategy()
+	strategy.RecordLatency("key1", "gemini-2.0-flash", 100*time.Millisecond)
+	strategy.RecordResult("key1", "gemini-2.0-flash", true)
+	for i := 0; i < 5; i++ {
+		strategy.RecordResult("key1", fmt.Sprintf("made-up-model-%d", i), false)
+	}
+
+	// The aggregate and the served model, nothing for the made-up names
+	if len(strategy.stats) != 2 {
+		t.Errorf("len(stats) = %d, want 2: %v", len(strategy.stats), strategy.stats)
+	}
+	if st := strategy.lookup("key1", ""); st == nil || st.errorRate == 0 {
+		t.Errorf("aggregate error rate not updated: %+v", st)
+	}
+}
+
+func TestLatencyAwareStrategy_Forget(t *testing.T) {
+	strategy := NewLatencyAwareStrategy()
+	strategy.RecordLatency("key1", "gemini-2.0-flash", 100*time.Millisecond)
+	strategy.RecordLatency("key1", "gemini-2.5-pro", 100*time.Millisecond)
+	strategy.RecordLatency("key2", "gemini-2.0-flash", 100*time.Millisecond)
+
+	strategy.Forget("key1")
+
+	if st := strategy.lookup("key1", "gemini-2.0-flash"); st != nil {
+		t.Errorf("key1 stats still present: %+v", st)
+	}
+	if st := strategy.lookup("key2", "gemini-2.0-flash"); st == nil {
+		t.Error("key2 stats were dropped")
+	}
+}
+
+func TestPool_RemoveKey_ForgetsLatencyStats(t *testing.T) {
+	pool := NewPool([]types.KeyConfig{
+		{Key: "AIzaSyKey1", Name: "Key 1", Enabled: true},
+		{Key: "AIzaSyKey2", Name: "Key 2", Enabled: true},
+	}, WithStrategy(NewLatencyAwareStrategy()))
+	key, err := pool.GetKey(types.KeyRequest{Model: "gemini-2.0-flash"})
+	if err != nil {
+		t.Fatalf("GetKey failed: %v", err)
+	}
+	pool.ReportLatency(key, "gemini-2.0-flash", 100*time.Millisecond)
+	pool.ReleaseKey(key)
+
+	if err := pool.RemoveKey(key.ID); err != nil {
+		t.Fatalf("RemoveKey failed: %v", err)
+	}
+
+	strategy := pool.strategy.(*LatencyAwareStrategy)
+	if len(strategy.stats) != 0 {
+		t.Errorf("stats left behind after RemoveKey: %v", strategy.stats)
+	}
+}
+
+func TestLatencyAwareStrategy_SkipsUnavailable(t *testing.T) {
+	keys := []*types.Key{
+		createTestKey("key1", types.KeyStatusDisabled, false),
+		createRateLimitedKey("key2", time.Now().Add(1*time.Hour)),
+		createTestKey("key3", types.KeyStatusActive, true),
+	}
+
+	strategy := NewLatencyAwareStrategy()
+	for i := 0; i < 10; i++ {
+		key := strategy.Select(keys)
+		if key == nil || key.ID != "key3" {
+			t.Fatalf("expected key3, got %v", key)
+		}
+	}
+
+	if key := strategy.Select(keys[:2]); key != nil {
+		t.Errorf("expected nil when no keys are available, got %s", key.ID)
+	}
+}
+
+func TestStrategyFactory_LatencyAware(t *testing.T) {
+	strategy := StrategyFactory(types.PoolStrategyLatencyAware)
+	if strategy.Name() != string(types.PoolStrategyLatencyAware) {
+		t.Errorf("Name() = %s, want %s", strategy.Name(), types.PoolStrategyLatencyAware)
+	}
+	if _, ok := strategy.(FeedbackStrategy); !ok {
+		t.Error("latency-aware strategy should implement FeedbackStrategy")
+	}
+}
+
 // ==================== Strategy Concurrency Tests ====================
 
 func TestRoundRobinStrategy_Concurrent(t *testing.T) {
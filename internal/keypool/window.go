@@ -0,0 +1,114 @@
+﻿// Package keypool provides API key pool management with multiple selection strategies.
+package keypool
+
+import (
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Sliding Window Statistics ====================
+
+const (
+	// windowBucketSpan is the time covered by a single bucket.
+	windowBucketSpan = time.Minute
+	// windowBucketCount is the number of buckets kept per key (24 hours of minutes).
+	windowBucketCount = int(24 * time.Hour / windowBucketSpan)
+)
+
+// windowOutcome classifies a request recorded in a sliding window.
+type windowOutcome int
+
+const (
+	outcomeSuccess windowOutcome = iota
+	outcomeError
+	outcomeRateLimited
+)
+
+// windowBucket aggregates the requests that fell into one bucket span.
+type windowBucket struct {
+	slot         int64 // Bucket index since the Unix epoch; identifies stale buckets
+	success      int64
+	errors       int64
+	rateLimited  int64
+	latencySumMs float64
+	latencyCount int64
+}
+
+// slidingWindow is a ring of per-minute buckets covering the last 24 hours.
+// Shorter windows are answered by summing the most recent buckets.
+// It is not safe for concurrent use; the pool guards it with windowsMu.
+type slidingWindow struct {
+	buckets [windowBucketCount]windowBucket
+}
+
+// newSlidingWindow creates an empty sliding window.
+func newSlidingWindow() *slidingWindow {
+	return &slidingWindow{}
+}
+
+// bucketAt returns the bucket for the given time, clearing it first if it
+// still holds data from an earlier lap of the ring.
+func (w *slidingWindow) bucketAt(now time.Time) *windowBucket {
+	slot := now.UnixNano() / int64(windowBucketSpan)
+	b := &w.buckets[slot%int64(windowBucketCount)]
+	if b.slot != slot {
+		*b = windowBucket{slot: slot}
+	}
+	return b
+}
+
+// recordOutcome adds a request outcome to the current bucket.
+func (w *slidingWindow) recordOutcome(now time.Time, outcome windowOutcome) {
+	b := w.bucketAt(now)
+	switch outcome {
+	case outcomeSuccess:
+		b.success++
+	case outcomeRateLimited:
+		b.rateLimited++
+		b.errors++
+	default:
+		b.errors++
+	}
+}
+
+// recordLatency adds a latency sample to the current bucket.
+func (w *slidingWindow) recordLatency(now time.Time, latency time.Duration) {
+	b := w.bucketAt(now)
+	b.latencySumMs += float64(latency) / float64(time.Millisecond)
+	b.latencyCount++
+}
+
+// sum aggregates the buckets that fall within span before now.
+func (w *slidingWindow) sum(now time.Time, span time.Duration) types.WindowStats {
+	var stats types.WindowStats
+
+	n := int(span / windowBucketSpan)
+	if n <= 0 {
+		return stats
+	}
+	if n > windowBucketCount {
+		n = windowBucketCount
+	}
+
+	current := now.UnixNano() / int64(windowBucketSpan)
+	latencySum, latencyCount := 0.0, int64(0)
+	for i := 0; i < n; i++ {
+		slot := current - int64(i)
+		b := &w.buckets[slot%int64(windowBucketCount)]
+		if b.slot != slot {
+			continue
+		}
+		stats.SuccessCount += b.success
+		stats.ErrorCount += b.errors
+		stats.RateLimitCount += b.rateLimited
+		latencySum += b.latencySumMs
+		latencyCount += b.latencyCount
+	}
+
+	stats.RequestCount = stats.SuccessCount + stats.ErrorCount
+	if latencyCount > 0 {
+		stats.AvgLatencyMs = latencySum / float64(latencyCount)
+	}
+	return stats
+}
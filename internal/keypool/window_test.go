@@ -0,0 +1,56 @@
+﻿// Package keypool provides API key pool management with multiple selection strategies.
+package keypool
+
+import (
+	"testing"
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Sliding Window Tests ====================
+
+func TestSlidingWindow_SumsRecentBuckets(t *testing.T) {
+	w := newSlidingWindow()
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	w.recordOutcome(base, outcomeSuccess)
+	w.recordOutcome(base, outcomeError)
+	w.recordOutcome(base, outcomeRateLimited)
+	w.recordLatency(base, 100*time.Millisecond)
+	w.recordLatency(base, 300*time.Millisecond)
+
+	stats := w.sum(base.Add(30*time.Second), 5*time.Minute)
+	if stats.RequestCount != 3 || stats.SuccessCount != 1 || stats.ErrorCount != 2 || stats.RateLimitCount != 1 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if stats.AvgLatencyMs != 200 {
+		t.Errorf("AvgLatencyMs = %v, want 200", stats.AvgLatencyMs)
+	}
+}
+
+func TestSlidingWindow_OldBucketsExpire(t *testing.T) {
+	w := newSlidingWindow()
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	w.recordOutcome(base, outcomeError)
+	w.recordOutcome(base.Add(10*time.Minute), outcomeSuccess)
+
+	now := base.Add(10 * time.Minute)
+	if got := w.sum(now, types.StatsWindow5m.Duration()); got.ErrorCount != 0 || got.SuccessCount != 1 {
+		t.Errorf("5m window should only see the recent success: %+v", got)
+	}
+	if got := w.sum(now, types.StatsWindow1h.Duration()); got.ErrorCount != 1 || got.SuccessCount != 1 {
+		t.Errorf("1h window should see both requests: %+v", got)
+	}
+
+	// After a full lap of the ring, the old bucket must not be counted again
+	later := base.Add(24*time.Hour + time.Minute)
+	if got := w.sum(later, types.StatsWindow24h.Duration()); got.ErrorCount != 0 {
+		t.Errorf("24h window should have dropped the old error: %+v", got)
+	}
+	w.recordOutcome(base.Add(24*time.Hour), outcomeSuccess)
+	if got := w.sum(base.Add(24*time.Hour), types.StatsWindow5m.Duration()); got.RequestCount != 1 {
+		t.Errorf("reused bucket should have been reset: %+v", got)
+	}
+}
@@ -0,0 +1,118 @@
+﻿package storage
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"muxueTools/internal/types"
+)
+
+// ==================== Key Bundles ====================
+
+const (
+	keyBundleVersion = 1
+	keyBundleKDF     = "pbkdf2-sha256"
+	// maxBundleIterations bounds the work an imported bundle can demand.
+	maxBundleIterations = 10000000
+	// MinBundlePassphraseLength is the shortest passphrase accepted for export.
+	MinBundlePassphraseLength = 8
+)
+
+// ErrBundleDecrypt is returned when a bundle cannot be decrypted, either
+// because the passphrase is wrong or the bundle was modified.
+var ErrBundleDecrypt = errors.New("wrong passphrase or corrupted bundle")
+
+// SealKeyBundle encrypts keys into a bundle under passphrase.
+func SealKeyBundle(keys []types.ImportKeyItem, passphrase string) (*types.KeyBundle, error) {
+	if len(passphrase) < MinBundlePassphraseLength {
+		return nil, fmt.Errorf("passphrase must be at least %d characters", MinBundlePassphraseLength)
+	}
+
+	payload, err := json.Marshal(keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode keys: %w", err)
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	bundle := &types.KeyBundle{
+		Format:     types.KeyBundleFormat,
+		Version:    keyBundleVersion,
+		CreatedAt:  time.Now().UTC(),
+		KeyCount:   len(keys),
+		KDF:        keyBundleKDF,
+		Iterations: kekPBKDF2Iterations,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, bundle.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, payload, bundleAdditionalData(bundle))
+	bundle.Ciphertext = base64.StdEncoding.EncodeToString(sealed)
+	return bundle, nil
+}
+
+// OpenKeyBundle decrypts the keys in a bundle with passphrase.
+func OpenKeyBundle(bundle *types.KeyBundle, passphrase string) ([]types.ImportKeyItem, error) {
+	if bundle.Format != types.KeyBundleFormat || bundle.Version != keyBundleVersion {
+		return nil, fmt.Errorf("unsupported bundle format %q version %d", bundle.Format, bundle.Version)
+	}
+	if bundle.KDF != keyBundleKDF || bundle.Iterations <= 0 || bundle.Iterations > maxBundleIterations {
+		return nil, fmt.Errorf("unsupported bundle key derivation %q with %d iterations", bundle.KDF, bundle.Iterations)
+	}
+	salt, err := base64.StdEncoding.DecodeString(bundle.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle salt: %w", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(bundle.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle ciphertext: %w", err)
+	}
+
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, bundle.Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrBundleDecrypt
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	payload, err := aead.Open(nil, nonce, ciphertext, bundleAdditionalData(bundle))
+	if err != nil {
+		return nil, ErrBundleDecrypt
+	}
+
+	var keys []types.ImportKeyItem
+	if err := json.Unmarshal(payload, &keys); err != nil {
+		return nil, fmt.Errorf("invalid bundle payload: %w", err)
+	}
+	return keys, nil
+}
+
+// bundleAdditionalData binds the bundle header to its ciphertext.
+func bundleAdditionalData(bundle *types.KeyBundle) []byte {
+	return fmt.Appendf(nil, "%s:%d:%d:%s:%d:%s", bundle.Format, bundle.Version, bundle.KeyCount,
+		bundle.KDF, bundle.Iterations, bundle.Salt)
+}
@@ -0,0 +1,488 @@
+﻿package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"muxueTools/internal/types"
+
+	"gorm.io/gorm"
+)
+
+// ==================== Key Encryption ====================
+//
+// Upstream API keys are encrypted at rest with envelope encryption: each
+// api_key column holds AES-256-GCM ciphertext under a random data-encryption
+// key (DEK), and the DEK is stored in app_config wrapped by the
+// key-encryption key (KEK). Rotating the KEK only re-wraps the DEK.
+// Per-key proxy URLs may embed credentials and are sealed the same way.
+// key_hash holds an HMAC-SHA256 of the plaintext key under a key derived from
+// the DEK, so lookups and deduplication work without decrypting every row.
+
+const (
+	// encryptedPrefix marks an api_key or proxy value as ciphertext.
+	encryptedPrefix = "enc:v1:"
+	// configKeyEncryption is the app_config row holding the wrapped DEK.
+	configKeyEncryption = "storage.key_encryption"
+	// kekPBKDF2Iterations is the PBKDF2-SHA256 work factor for passphrase KEKs.
+	kekPBKDF2Iterations = 600000
+	// DefaultKEKFileName is the key file generated in the data directory
+	// when no KEK is configured.
+	DefaultKEKFileName = "master.key"
+)
+
+// ErrWrongKEK is returned when the configured KEK cannot unwrap the stored DEK.
+var ErrWrongKEK = errors.New("key-encryption key does not match the database")
+
+// KEK is a key-encryption key: either 32 raw bytes or a passphrase that is
+// stretched with PBKDF2 using a salt stored in the database.
+type KEK struct {
+	key        []byte
+	passphrase string
+}
+
+// KEKFromBytes returns a KEK from 32 raw bytes.
+func KEKFromBytes(key []byte) (KEK, error) {
+	if len(key) != 32 {
+		return KEK{}, fmt.Errorf("key-encryption key must be 32 bytes, got %d", len(key))
+	}
+	return KEK{key: key}, nil
+}
+
+// KEKFromPassphrase returns a KEK derived from an admin passphrase.
+func KEKFromPassphrase(passphrase string) (KEK, error) {
+	if passphrase == "" {
+		return KEK{}, errors.New("passphrase cannot be empty")
+	}
+	return KEK{passphrase: passphrase}, nil
+}
+
+// ParseKEK decodes a 32-byte KEK written as base64 or hex.
+func ParseKEK(encoded string) (KEK, error) {
+	encoded = strings.TrimSpace(encoded)
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == 32 {
+		return KEKFromBytes(key)
+	}
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == 32 {
+		return KEKFromBytes(key)
+	}
+	return KEK{}, errors.New("key-encryption key must be 32 bytes encoded as base64 or hex")
+}
+
+// LoadKEKFile reads a KEK from a key file holding base64 or hex text.
+func LoadKEKFile(path string) (KEK, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return KEK{}, fmt.Errorf("failed to read key file: %w", err)
+	}
+	kek, err := ParseKEK(string(data))
+	if err != nil {
+		return KEK{}, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	return kek, nil
+}
+
+// ResolveKEK picks the KEK from cfg in order of precedence: an inline key,
+// a key file, then a passphrase. Without any of them the key file at
+// defaultKeyFile is used, and generated on first start.
+func ResolveKEK(cfg types.EncryptionConfig, defaultKeyFile string) (KEK, error) {
+	switch {
+	case cfg.Key != "":
+		return ParseKEK(cfg.Key)
+	case cfg.KeyFile != "":
+		return LoadKEKFile(cfg.KeyFile)
+	case cfg.Passphrase != "":
+		return KEKFromPassphrase(cfg.Passphrase)
+	}
+
+	if _, err := os.Stat(defaultKeyFile); err == nil {
+		return LoadKEKFile(defaultKeyFile)
+	} else if !os.IsNotExist(err) {
+		return KEK{}, fmt.Errorf("failed to stat key file: %w", err)
+	}
+	return GenerateKEKFile(defaultKeyFile)
+}
+
+// GenerateKEKFile creates a key file with a random KEK, readable only by the owner.
+func GenerateKEKFile(path string) (KEK, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return KEK{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return KEK{}, fmt.Errorf("failed to create key directory: %w", err)
+		}
+	}
+	encoded := base64.StdEncoding.EncodeToString(key) + "\n"
+	if err := os.WriteFile(path, []byte(encoded), 0600); err != nil {
+		return KEK{}, fmt.Errorf("failed to write key file: %w", err)
+	}
+	return KEKFromBytes(key)
+}
+
+// WithKEK enables encryption of API keys at rest under kek.
+func WithKEK(kek KEK) StorageOption {
+	return func(s *Storage) {
+		s.kek = &kek
+	}
+}
+
+// keyEncryptionRecord is the JSON stored in the storage.key_encryption row.
+type keyEncryptionRecord struct {
+	Version    int    `json:"version"`
+	Salt       string `json:"salt,omitempty"` // base64 PBKDF2 salt; set for passphrase KEKs
+	WrappedDEK string `json:"wrapped_dek"`    // base64 nonce||ciphertext of the DEK under the KEK
+}
+
+// keyCipher encrypts API keys with the DEK and computes their lookup hashes.
+type keyCipher struct {
+	aead    cipher.AEAD
+	hashKey []byte
+}
+
+// newKeyCipher builds a keyCipher from a 32-byte DEK.
+func newKeyCipher(dek []byte) (*keyCipher, error) {
+	aead, err := newAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, dek)
+	mac.Write([]byte("muxueTools key_hash"))
+	return &keyCipher{aead: aead, hashKey: mac.Sum(nil)}, nil
+}
+
+// encrypt seals an API key as encryptedPrefix + base64(nonce||ciphertext).
+func (c *keyCipher) encrypt(apiKey string) (string, error) {
+	sealed, err := seal(c.aead, []byte(apiKey))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value produced by encrypt.
+func (c *keyCipher) decrypt(value string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode encrypted key: %w", err)
+	}
+	plain, err := open(c.aead, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt key: %w", err)
+	}
+	return string(plain), nil
+}
+
+// hash returns the keyed lookup hash of an API key.
+func (c *keyCipher) hash(apiKey string) string {
+	mac := hmac.New(sha256.New, c.hashKey)
+	mac.Write([]byte(apiKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashAPIKey returns the key_hash of an API key. Without encryption the hash
+// is unkeyed, since the plaintext sits next to it anyway.
+func (s *Storage) hashAPIKey(apiKey string) string {
+	if s.cipher != nil {
+		return s.cipher.hash(apiKey)
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// sealKey fills the key_hash of a DBKey and encrypts its api_key and proxy
+// in place.
+func (s *Storage) sealKey(dbKey *DBKey) error {
+	dbKey.KeyHash = s.hashAPIKey(dbKey.APIKey)
+	if s.cipher == nil {
+		return nil
+	}
+	encrypted, err := s.cipher.encrypt(dbKey.APIKey)
+	if err != nil {
+		return err
+	}
+	dbKey.APIKey = encrypted
+	return s.sealProxy(dbKey)
+}
+
+// sealProxy encrypts the proxy URL of a DBKey in place, since it may carry
+// credentials. An empty proxy stays empty so "inherit" remains visible.
+func (s *Storage) sealProxy(dbKey *DBKey) error {
+	if s.cipher == nil || dbKey.Proxy == "" {
+		return nil
+	}
+	encrypted, err := s.cipher.encrypt(dbKey.Proxy)
+	if err != nil {
+		return err
+	}
+	dbKey.Proxy = encrypted
+	return nil
+}
+
+// openKey decrypts the api_key and proxy of a DBKey in place.
+func (s *Storage) openKey(dbKey *DBKey) error {
+	var err error
+	if dbKey.APIKey, err = s.openValue(dbKey.APIKey); err != nil {
+		return err
+	}
+	if dbKey.Proxy, err = s.openValue(dbKey.Proxy); err != nil {
+		return fmt.Errorf("failed to decrypt proxy: %w", err)
+	}
+	return nil
+}
+
+// openValue decrypts a column value written by keyCipher.encrypt; plaintext
+// values are returned unchanged.
+func (s *Storage) openValue(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	if s.cipher == nil {
+		return "", errors.New("key is encrypted but no key-encryption key is configured")
+	}
+	return s.cipher.decrypt(value)
+}
+
+// initKeyEncryption loads or creates the DEK under s.kek and then migrates
+// plaintext rows. Without a KEK it only backfills missing key hashes.
+func (s *Storage) initKeyEncryption() error {
+	if s.kek != nil {
+		raw, err := s.GetConfig(configKeyEncryption)
+		if err != nil {
+			return err
+		}
+
+		var dek []byte
+		if raw == "" {
+			dek = make([]byte, 32)
+			if _, err := rand.Read(dek); err != nil {
+				return fmt.Errorf("failed to generate data key: %w", err)
+			}
+			record, err := wrapDEK(dek, *s.kek)
+			if err != nil {
+				return err
+			}
+			if err := s.saveKeyEncryptionRecord(s.db, record); err != nil {
+				return err
+			}
+		} else {
+			var record keyEncryptionRecord
+			if err := json.Unmarshal([]byte(raw), &record); err != nil {
+				return fmt.Errorf("invalid key encryption record: %w", err)
+			}
+			if dek, err = unwrapDEK(record, *s.kek); err != nil {
+				return err
+			}
+		}
+
+		if s.cipher, err = newKeyCipher(dek); err != nil {
+			return err
+		}
+		s.dek = dek
+	}
+
+	if err := s.migrateKeyRows(); err != nil {
+		return err
+	}
+	return s.ensureKeyHashIndex()
+}
+
+// migrateKeyRows encrypts plaintext api_key and proxy values and recomputes
+// key hashes for rows written before encryption was enabled.
+func (s *Storage) migrateKeyRows() error {
+	var dbKeys []DBKey
+	query := s.db.Where("key_hash = '' OR key_hash IS NULL")
+	if s.cipher != nil {
+		query = query.Or("api_key NOT LIKE ?", encryptedPrefix+"%").
+			Or("proxy <> '' AND proxy NOT LIKE ?", encryptedPrefix+"%")
+	}
+	if err := query.Find(&dbKeys).Error; err != nil {
+		return fmt.Errorf("failed to scan keys for migration: %w", err)
+	}
+	if len(dbKeys) == 0 {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range dbKeys {
+			dbKey := &dbKeys[i]
+			if err := s.openKey(dbKey); err != nil {
+				return err
+			}
+			if err := s.sealKey(dbKey); err != nil {
+				return err
+			}
+			if err := tx.Model(&DBKey{}).Where("id = ?", dbKey.ID).Updates(map[string]interface{}{
+				"api_key":  dbKey.APIKey,
+				"key_hash": dbKey.KeyHash,
+				"proxy":    dbKey.Proxy,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to migrate key %s: %w", dbKey.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ensureKeyHashIndex makes key_hash unique once every row has a hash.
+// api_key cannot carry the constraint: the same key encrypts to a different
+// ciphertext each time. Rows duplicated before the index existed are dropped,
+// keeping the oldest.
+func (s *Storage) ensureKeyHashIndex() error {
+	migrator := s.db.Migrator()
+	if migrator.HasIndex(&DBKey{}, "idx_keys_key_hash_unique") {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`DELETE FROM keys WHERE rowid NOT IN (
+			SELECT MIN(rowid) FROM keys GROUP BY key_hash)`).Error; err != nil {
+			return fmt.Errorf("failed to remove duplicate keys: %w", err)
+		}
+		for _, name := range []string{"idx_keys_api_key", "idx_keys_key_hash"} {
+			if tx.Migrator().HasIndex(&DBKey{}, name) {
+				if err := tx.Migrator().DropIndex(&DBKey{}, name); err != nil {
+					return fmt.Errorf("failed to drop index %s: %w", name, err)
+				}
+			}
+		}
+		if err := tx.Exec("CREATE UNIQUE INDEX idx_keys_key_hash_unique ON keys(key_hash)").Error; err != nil {
+			return fmt.Errorf("failed to create key hash index: %w", err)
+		}
+		return nil
+	})
+}
+
+// RotateKEK re-wraps the data key under newKEK. The storage keeps working;
+// later opens of the database must use newKEK.
+func (s *Storage) RotateKEK(newKEK KEK) error {
+	if s.cipher == nil {
+		return errors.New("key encryption is not enabled")
+	}
+	record, err := wrapDEK(s.dek, newKEK)
+	if err != nil {
+		return err
+	}
+	if err := s.saveKeyEncryptionRecord(s.db, record); err != nil {
+		return err
+	}
+	s.kek = &newKEK
+	return nil
+}
+
+// saveKeyEncryptionRecord writes the wrapped DEK to app_config.
+func (s *Storage) saveKeyEncryptionRecord(db *gorm.DB, record keyEncryptionRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := db.Save(&DBConfig{Key: configKeyEncryption, Value: string(data)}).Error; err != nil {
+		return fmt.Errorf("failed to save key encryption record: %w", err)
+	}
+	return nil
+}
+
+// wrapDEK encrypts the DEK under kek, deriving the KEK from a fresh salt for passphrases.
+func wrapDEK(dek []byte, kek KEK) (keyEncryptionRecord, error) {
+	record := keyEncryptionRecord{Version: 1}
+	var salt []byte
+	if kek.passphrase != "" {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return record, fmt.Errorf("failed to generate salt: %w", err)
+		}
+		record.Salt = base64.StdEncoding.EncodeToString(salt)
+	}
+
+	key, err := kek.derive(salt)
+	if err != nil {
+		return record, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return record, err
+	}
+	wrapped, err := seal(aead, dek)
+	if err != nil {
+		return record, err
+	}
+	record.WrappedDEK = base64.StdEncoding.EncodeToString(wrapped)
+	return record, nil
+}
+
+// unwrapDEK decrypts the DEK in record with kek.
+func unwrapDEK(record keyEncryptionRecord, kek KEK) ([]byte, error) {
+	salt, err := base64.StdEncoding.DecodeString(record.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key encryption salt: %w", err)
+	}
+	if (kek.passphrase != "") != (len(salt) > 0) {
+		return nil, ErrWrongKEK
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(record.WrappedDEK)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped data key: %w", err)
+	}
+
+	key, err := kek.derive(salt)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := open(aead, wrapped)
+	if err != nil {
+		return nil, ErrWrongKEK
+	}
+	return dek, nil
+}
+
+// derive returns the 32-byte wrapping key for the KEK.
+func (k KEK) derive(salt []byte) ([]byte, error) {
+	if k.passphrase == "" {
+		return k.key, nil
+	}
+	return pbkdf2.Key(sha256.New, k.passphrase, salt, kekPBKDF2Iterations, 32)
+}
+
+// newAEAD returns AES-256-GCM for a 32-byte key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with a random nonce and returns nonce||ciphertext.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts nonce||ciphertext produced by seal.
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
@@ -16,6 +16,9 @@ import (
 // CreateKey creates a new key in the database.
 func (s *Storage) CreateKey(key *types.Key) error {
 	dbKey := keyToDBKey(key)
+	if err := s.sealKey(dbKey); err != nil {
+		return fmt.Errorf("failed to encrypt key: %w", err)
+	}
 	if err := s.db.Create(&dbKey).Error; err != nil {
 		return fmt.Errorf("failed to create key: %w", err)
 	}
@@ -31,18 +34,24 @@ func (s *Storage) GetKey(id string) (*types.Key, error) {
 		}
 		return nil, fmt.Errorf("failed to get key: %w", err)
 	}
+	if err := s.openKey(&dbKey); err != nil {
+		return nil, err
+	}
 	return dbKeyToKey(&dbKey), nil
 }
 
 // GetKeyByAPIKey retrieves a key by its API key value.
 func (s *Storage) GetKeyByAPIKey(apiKey string) (*types.Key, error) {
 	var dbKey DBKey
-	if err := s.db.Where("api_key = ?", apiKey).First(&dbKey).Error; err != nil {
+	if err := s.db.Where("key_hash = ?", s.hashAPIKey(apiKey)).First(&dbKey).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, types.ErrKeyNotFound
 		}
 		return nil, fmt.Errorf("failed to get key by api_key: %w", err)
 	}
+	if err := s.openKey(&dbKey); err != nil {
+		return nil, err
+	}
 	return dbKeyToKey(&dbKey), nil
 }
 
@@ -55,6 +64,9 @@ func (s *Storage) ListKeys() ([]types.Key, error) {
 
 	keys := make([]types.Key, 0, len(dbKeys))
 	for _, dbKey := range dbKeys {
+		if err := s.openKey(&dbKey); err != nil {
+			return nil, fmt.Errorf("failed to read key %s: %w", dbKey.ID, err)
+		}
 		keys = append(keys, *dbKeyToKey(&dbKey))
 	}
 	return keys, nil
@@ -63,18 +75,40 @@ func (s *Storage) ListKeys() ([]types.Key, error) {
 // UpdateKey updates an existing key in the database.
 func (s *Storage) UpdateKey(key *types.Key) error {
 	dbKey := keyToDBKey(key)
+	if err := s.sealProxy(dbKey); err != nil {
+		return fmt.Errorf("failed to encrypt proxy: %w", err)
+	}
 	result := s.db.Model(&DBKey{}).Where("id = ?", key.ID).Updates(map[string]interface{}{
-		"name":              dbKey.Name,
-		"tags":              dbKey.Tags,
-		"enabled":           dbKey.Enabled,
-		"request_count":     dbKey.RequestCount,
-		"success_count":     dbKey.SuccessCount,
-		"error_count":       dbKey.ErrorCount,
-		"prompt_tokens":     dbKey.PromptTokens,
-		"completion_tokens": dbKey.CompletionTokens,
-		"model_usage":       dbKey.ModelUsage,
-		"last_used_at":      dbKey.LastUsedAt,
-		"updated_at":        time.Now().Unix(),
+		"name":               dbKey.Name,
+		"tags":               dbKey.Tags,
+		"enabled":            dbKey.Enabled,
+		"request_count":      dbKey.RequestCount,
+		"success_count":      dbKey.SuccessCount,
+		"error_count":        dbKey.ErrorCount,
+		"prompt_tokens":      dbKey.PromptTokens,
+		"completion_tokens":  dbKey.CompletionTokens,
+		"model_usage":        dbKey.ModelUsage,
+		"last_used_at":       dbKey.LastUsedAt,
+		"allowed_models":     dbKey.AllowedModels,
+		"denied_models":      dbKey.DeniedModels,
+		"detected_models":    dbKey.DetectedModels,
+		"models_detected_at": dbKey.ModelsDetectedAt,
+		"provider":           dbKey.Provider,
+		"default_model":      dbKey.DefaultModel,
+		"weight":             dbKey.Weight,
+		"priority":           dbKey.Priority,
+		"rpm_limit":          dbKey.RPMLimit,
+		"daily_limit":        dbKey.DailyLimit,
+		"max_concurrent":     dbKey.MaxConcurrent,
+		"health":             dbKey.Health,
+		"last_checked_at":    dbKey.LastCheckedAt,
+		"disabled_reason":    dbKey.DisabledReason,
+		"source":             dbKey.Source,
+		"not_before":         dbKey.NotBefore,
+		"expires_at":         dbKey.ExpiresAt,
+		"active_windows":     dbKey.ActiveWindows,
+		"proxy":              dbKey.Proxy,
+		"updated_at":         time.Now().Unix(),
 	})
 	if result.Error != nil {
 		return fmt.Errorf("failed to update key: %w", result.Error)
@@ -109,7 +143,7 @@ func (s *Storage) ImportKeys(keys []types.Key) (int, error) {
 
 			// Check if key already exists
 			var count int64
-			if err := tx.Model(&DBKey{}).Where("api_key = ?", key.APIKey).Count(&count).Error; err != nil {
+			if err := tx.Model(&DBKey{}).Where("key_hash = ?", s.hashAPIKey(key.APIKey)).Count(&count).Error; err != nil {
 				return fmt.Errorf("failed to check key existence: %w", err)
 			}
 			if count > 0 {
@@ -118,6 +152,9 @@ func (s *Storage) ImportKeys(keys []types.Key) (int, error) {
 
 			// Create key using transaction-scoped db
 			dbKey := keyToDBKey(key)
+			if err := s.sealKey(dbKey); err != nil {
+				return fmt.Errorf("failed to encrypt key: %w", err)
+			}
 			if err := tx.Create(&dbKey).Error; err != nil {
 				return fmt.Errorf("failed to create key: %w", err)
 			}
@@ -135,7 +172,7 @@ func (s *Storage) ImportKeys(keys []types.Key) (int, error) {
 // KeyExists checks if a key with the given API key value exists.
 func (s *Storage) KeyExists(apiKey string) (bool, error) {
 	var count int64
-	if err := s.db.Model(&DBKey{}).Where("api_key = ?", apiKey).Count(&count).Error; err != nil {
+	if err := s.db.Model(&DBKey{}).Where("key_hash = ?", s.hashAPIKey(apiKey)).Count(&count).Error; err != nil {
 		return false, fmt.Errorf("failed to check key existence: %w", err)
 	}
 	return count > 0, nil
@@ -161,6 +198,18 @@ func keyToDBKey(key *types.Key) *DBKey {
 		}
 	}
 
+	var modelsDetectedAt *int64
+	if key.ModelsDetectedAt != nil {
+		ts := key.ModelsDetectedAt.Unix()
+		modelsDetectedAt = &ts
+	}
+
+	var lastCheckedAt *int64
+	if key.LastCheckedAt != nil {
+		ts := key.LastCheckedAt.Unix()
+		lastCheckedAt = &ts
+	}
+
 	return &DBKey{
 		ID:               key.ID,
 		APIKey:           key.APIKey,
@@ -174,6 +223,25 @@ func keyToDBKey(key *types.Key) *DBKey {
 		CompletionTokens: key.Stats.CompletionTokens,
 		ModelUsage:       modelUsageJSON,
 		LastUsedAt:       lastUsedAt,
+		AllowedModels:    marshalStringList(key.AllowedModels),
+		DeniedModels:     marshalStringList(key.DeniedModels),
+		DetectedModels:   marshalStringList(key.DetectedModels),
+		ModelsDetectedAt: modelsDetectedAt,
+		Provider:         key.Provider,
+		DefaultModel:     key.DefaultModel,
+		Weight:           key.Weight,
+		Priority:         key.Priority,
+		RPMLimit:         key.Limits.RPM,
+		DailyLimit:       key.Limits.DailyRequests,
+		MaxConcurrent:    key.Limits.MaxConcurrent,
+		Health:           string(key.Health),
+		LastCheckedAt:    lastCheckedAt,
+		DisabledReason:   key.DisabledReason,
+		Source:           string(key.Source),
+		NotBefore:        unixOrNil(key.NotBefore),
+		ExpiresAt:        unixOrNil(key.ExpiresAt),
+		ActiveWindows:    marshalActiveWindows(key.ActiveWindows),
+		Proxy:            key.Proxy,
 		CreatedAt:        key.CreatedAt.Unix(),
 		UpdatedAt:        key.UpdatedAt.Unix(),
 	}
@@ -201,14 +269,35 @@ func dbKeyToKey(dbKey *DBKey) *types.Key {
 		_ = json.Unmarshal([]byte(dbKey.ModelUsage), &modelUsage)
 	}
 
+	var modelsDetectedAt *time.Time
+	if dbKey.ModelsDetectedAt != nil {
+		t := time.Unix(*dbKey.ModelsDetectedAt, 0)
+		modelsDetectedAt = &t
+	}
+
+	var lastCheckedAt *time.Time
+	if dbKey.LastCheckedAt != nil {
+		t := time.Unix(*dbKey.LastCheckedAt, 0)
+		lastCheckedAt = &t
+	}
+
 	return &types.Key{
-		ID:        dbKey.ID,
-		APIKey:    dbKey.APIKey,
-		MaskedKey: types.MaskAPIKey(dbKey.APIKey),
-		Name:      dbKey.Name,
-		Status:    types.KeyStatusActive, // Status is runtime-only
-		Enabled:   dbKey.Enabled,
-		Tags:      tags,
+		ID:           dbKey.ID,
+		APIKey:       dbKey.APIKey,
+		MaskedKey:    types.MaskAPIKey(dbKey.APIKey),
+		Name:         dbKey.Name,
+		Status:       types.KeyStatusActive, // Status is runtime-only
+		Enabled:      dbKey.Enabled,
+		Tags:         tags,
+		Provider:     dbKey.Provider,
+		DefaultModel: dbKey.DefaultModel,
+		Weight:       dbKey.Weight,
+		Priority:     dbKey.Priority,
+		Limits: types.KeyLimits{
+			RPM:           dbKey.RPMLimit,
+			DailyRequests: dbKey.DailyLimit,
+			MaxConcurrent: dbKey.MaxConcurrent,
+		},
 		Stats: types.KeyStats{
 			RequestCount:     dbKey.RequestCount,
 			SuccessCount:     dbKey.SuccessCount,
@@ -220,5 +309,82 @@ func dbKeyToKey(dbKey *DBKey) *types.Key {
 		},
 		CreatedAt: time.Unix(dbKey.CreatedAt, 0),
 		UpdatedAt: time.Unix(dbKey.UpdatedAt, 0),
+
+		AllowedModels:    unmarshalStringList(dbKey.AllowedModels),
+		DeniedModels:     unmarshalStringList(dbKey.DeniedModels),
+		DetectedModels:   unmarshalStringList(dbKey.DetectedModels),
+		ModelsDetectedAt: modelsDetectedAt,
+		Health:           types.KeyHealth(dbKey.Health),
+		LastCheckedAt:    lastCheckedAt,
+		DisabledReason:   dbKey.DisabledReason,
+		Source:           types.KeySource(dbKey.Source),
+		NotBefore:        timeOrNil(dbKey.NotBefore),
+		ExpiresAt:        timeOrNil(dbKey.ExpiresAt),
+		ActiveWindows:    unmarshalActiveWindows(dbKey.ActiveWindows),
+		Proxy:            dbKey.Proxy,
+	}
+}
+
+// unixOrNil converts an optional time to a Unix timestamp.
+func unixOrNil(t *time.Time) *int64 {
+	if t == nil {
+		return nil
+	}
+	ts := t.Unix()
+	return &ts
+}
+
+// timeOrNil converts an optional Unix timestamp to a time.
+func timeOrNil(ts *int64) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := time.Unix(*ts, 0)
+	return &t
+}
+
+// marshalActiveWindows serializes a key's active windows to JSON.
+// No windows are stored as an empty string.
+func marshalActiveWindows(windows []types.ActiveWindow) string {
+	if len(windows) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(windows)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// unmarshalActiveWindows deserializes windows written by marshalActiveWindows.
+func unmarshalActiveWindows(data string) []types.ActiveWindow {
+	if data == "" {
+		return nil
+	}
+	var windows []types.ActiveWindow
+	_ = json.Unmarshal([]byte(data), &windows)
+	return windows
+}
+
+// marshalStringList serializes an optional string list to JSON.
+// Empty lists are stored as an empty string.
+func marshalStringList(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// unmarshalStringList deserializes a list written by marshalStringList.
+func unmarshalStringList(data string) []string {
+	if data == "" {
+		return nil
 	}
+	var list []string
+	_ = json.Unmarshal([]byte(data), &list)
+	return list
 }
@@ -0,0 +1,78 @@
+﻿// Package storage provides SQLite-based persistence layer for MuxueTools.
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"muxueTools/internal/types"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== Model Preset Storage Methods ====================
+
+// CreateModelPreset creates a new model preset in the database.
+func (s *Storage) CreateModelPreset(preset *types.ModelPreset) error {
+	if preset.ID == "" {
+		preset.ID = uuid.New().String()
+	}
+	if preset.CreatedAt.IsZero() {
+		preset.CreatedAt = time.Now()
+	}
+	preset.UpdatedAt = time.Now()
+
+	if err := s.db.Create(preset).Error; err != nil {
+		return fmt.Errorf("failed to create model preset: %w", err)
+	}
+	return nil
+}
+
+// GetModelPreset retrieves a model preset by ID.
+func (s *Storage) GetModelPreset(id string) (*types.ModelPreset, error) {
+	var preset types.ModelPreset
+	if err := s.db.Where("id = ?", id).First(&preset).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, types.ErrModelPresetNotFound
+		}
+		return nil, fmt.Errorf("failed to get model preset: %w", err)
+	}
+	return &preset, nil
+}
+
+// ListModelPresets retrieves all model presets ordered by name.
+func (s *Storage) ListModelPresets() ([]types.ModelPreset, error) {
+	var presets []types.ModelPreset
+	if err := s.db.Order("name ASC").Find(&presets).Error; err != nil {
+		return nil, fmt.Errorf("failed to list model presets: %w", err)
+	}
+	return presets, nil
+}
+
+// UpdateModelPreset updates the name, model, description and settings of a model preset.
+func (s *Storage) UpdateModelPreset(preset *types.ModelPreset) error {
+	preset.UpdatedAt = time.Now()
+	result := s.db.Model(&types.ModelPreset{}).Where("id = ?", preset.ID).
+		Select("name", "model", "description", "settings", "updated_at").
+		Updates(preset)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update model preset: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return types.ErrModelPresetNotFound
+	}
+	return nil
+}
+
+// DeleteModelPreset deletes a model preset.
+func (s *Storage) DeleteModelPreset(id string) error {
+	result := s.db.Where("id = ?", id).Delete(&types.ModelPreset{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete model preset: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return types.ErrModelPresetNotFound
+	}
+	return nil
+}
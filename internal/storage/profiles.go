@@ -0,0 +1,78 @@
+﻿// Package storage provides SQLite-based persistence layer for MuxueTools.
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"muxueTools/internal/types"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== Model Profile Storage Methods ====================
+
+// CreateModelProfile creates a new model profile in the database.
+func (s *Storage) CreateModelProfile(profile *types.ModelProfile) error {
+	if profile.ID == "" {
+		profile.ID = uuid.New().String()
+	}
+	if profile.CreatedAt.IsZero() {
+		profile.CreatedAt = time.Now()
+	}
+	profile.UpdatedAt = time.Now()
+
+	if err := s.db.Create(profile).Error; err != nil {
+		return fmt.Errorf("failed to create model profile: %w", err)
+	}
+	return nil
+}
+
+// GetModelProfile retrieves a model profile by ID.
+func (s *Storage) GetModelProfile(id string) (*types.ModelProfile, error) {
+	var profile types.ModelProfile
+	if err := s.db.Where("id = ?", id).First(&profile).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, types.ErrModelProfileNotFound
+		}
+		return nil, fmt.Errorf("failed to get model profile: %w", err)
+	}
+	return &profile, nil
+}
+
+// ListModelProfiles retrieves all model profiles ordered by pattern.
+func (s *Storage) ListModelProfiles() ([]types.ModelProfile, error) {
+	var profiles []types.ModelProfile
+	if err := s.db.Order("pattern ASC").Find(&profiles).Error; err != nil {
+		return nil, fmt.Errorf("failed to list model profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// UpdateModelProfile updates the name, pattern and settings of a model profile.
+func (s *Storage) UpdateModelProfile(profile *types.ModelProfile) error {
+	profile.UpdatedAt = time.Now()
+	result := s.db.Model(&types.ModelProfile{}).Where("id = ?", profile.ID).
+		Select("name", "pattern", "settings", "updated_at").
+		Updates(profile)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update model profile: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return types.ErrModelProfileNotFound
+	}
+	return nil
+}
+
+// DeleteModelProfile deletes a model profile.
+func (s *Storage) DeleteModelProfile(id string) error {
+	result := s.db.Where("id = ?", id).Delete(&types.ModelProfile{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete model profile: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return types.ErrModelProfileNotFound
+	}
+	return nil
+}
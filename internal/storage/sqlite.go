@@ -16,10 +16,18 @@ import (
 // Storage provides database access methods.
 type Storage struct {
 	db *gorm.DB
+
+	// Encryption of API keys at rest; cipher is nil when disabled
+	kek    *KEK
+	dek    []byte
+	cipher *keyCipher
 }
 
+// StorageOption is a functional option for configuring Storage.
+type StorageOption func(*Storage)
+
 // NewStorage creates a new Storage instance with SQLite backend.
-func NewStorage(dbPath string) (*Storage, error) {
+func NewStorage(dbPath string, opts ...StorageOption) (*Storage, error) {
 	// Ensure directory exists
 	dir := filepath.Dir(dbPath)
 	if dir != "" && dir != "." {
@@ -48,6 +56,9 @@ func NewStorage(dbPath string) (*Storage, error) {
 	sqlDB.SetMaxIdleConns(1)
 
 	storage := &Storage{db: db}
+	for _, opt := range opts {
+		opt(storage)
+	}
 
 	// Run migrations
 	if err := storage.migrate(); err != nil {
@@ -59,8 +70,11 @@ func NewStorage(dbPath string) (*Storage, error) {
 
 // NewStorageWithDB creates a Storage with an existing GORM DB instance.
 // Useful for testing with in-memory database.
-func NewStorageWithDB(db *gorm.DB) (*Storage, error) {
+func NewStorageWithDB(db *gorm.DB, opts ...StorageOption) (*Storage, error) {
 	storage := &Storage{db: db}
+	for _, opt := range opts {
+		opt(storage)
+	}
 
 	if err := storage.migrate(); err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
@@ -71,12 +85,17 @@ func NewStorageWithDB(db *gorm.DB) (*Storage, error) {
 
 // migrate runs database migrations.
 func (s *Storage) migrate() error {
-	return s.db.AutoMigrate(
+	if err := s.db.AutoMigrate(
 		&DBKey{},
 		&types.Session{},
 		&types.ChatMessage{},
 		&DBConfig{}, // 新增配置表
-	)
+		&types.ModelProfile{},
+		&types.ModelPreset{},
+	); err != nil {
+		return err
+	}
+	return s.initKeyEncryption()
 }
 
 // Close closes the database connection.
@@ -109,7 +128,8 @@ func (s *Storage) DB() *gorm.DB {
 // Separate from types.Key to handle GORM-specific concerns.
 type DBKey struct {
 	ID               string `gorm:"primaryKey;type:varchar(36)"`
-	APIKey           string `gorm:"type:text;not null;uniqueIndex"`
+	APIKey           string `gorm:"type:text;not null"` // AES-GCM ciphertext when encryption is enabled
+	KeyHash          string `gorm:"type:varchar(64)"`   // Keyed hash of the plaintext key; unique, see ensureKeyHashIndex
 	Name             string `gorm:"type:varchar(100)"`
 	Tags             string `gorm:"type:text"` // JSON array
 	Enabled          bool   `gorm:"default:true"`
@@ -120,6 +140,25 @@ type DBKey struct {
 	CompletionTokens int64  `gorm:"default:0"`
 	ModelUsage       string `gorm:"type:text"`    // JSON map[string]int64
 	LastUsedAt       *int64 `gorm:"type:integer"` // Unix timestamp
+	AllowedModels    string `gorm:"type:text"`    // JSON array of glob patterns
+	DeniedModels     string `gorm:"type:text"`    // JSON array of glob patterns
+	DetectedModels   string `gorm:"type:text"`    // JSON array from models.list probe
+	ModelsDetectedAt *int64 `gorm:"type:integer"` // Unix timestamp
+	Provider         string `gorm:"type:varchar(50)"`
+	DefaultModel     string `gorm:"type:varchar(100)"`
+	Weight           int    `gorm:"default:0"`
+	Priority         int    `gorm:"default:0"`
+	RPMLimit         int    `gorm:"default:0"`         // 0 means unlimited
+	DailyLimit       int    `gorm:"default:0"`         // Requests per day; 0 means unlimited
+	MaxConcurrent    int    `gorm:"default:0"`         // 0 means unlimited
+	Health           string `gorm:"type:varchar(20)"`  // Result of the last probe
+	LastCheckedAt    *int64 `gorm:"type:integer"`      // Unix timestamp of the last probe
+	DisabledReason   string `gorm:"type:varchar(255)"` // Why the key was disabled automatically
+	Source           string `gorm:"type:varchar(20)"`  // "config" or "api"
+	NotBefore        *int64 `gorm:"type:integer"`      // Unix timestamp
+	ExpiresAt        *int64 `gorm:"type:integer;index"`
+	ActiveWindows    string `gorm:"type:text"` // JSON array of types.ActiveWindow
+	Proxy            string `gorm:"type:text"` // Upstream proxy URL; empty inherits
 	CreatedAt        int64  `gorm:"autoCreateTime"`
 	UpdatedAt        int64  `gorm:"autoUpdateTime"`
 }
@@ -152,6 +152,112 @@ func TestStorage_UpdateKey(t *testing.T) {
 	assert.Equal(t, int64(10), retrieved.Stats.RequestCount)
 }
 
+func TestStorage_UpdateKey_ModelRouting(t *testing.T) {
+	storage := newTestStorage(t)
+	defer storage.Close()
+
+	key := &types.Key{
+		ID:            uuid.New().String(),
+		APIKey:        "AIzaSyModels123",
+		Name:          "Models",
+		Enabled:       true,
+		AllowedModels: []string{"gemini-2.5-*"},
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	require.NoError(t, storage.CreateKey(key))
+
+	detectedAt := time.Now().Truncate(time.Second)
+	key.DeniedModels = []string{"*-preview"}
+	key.DetectedModels = []string{"gemini-2.5-pro", "gemini-2.5-flash"}
+	key.ModelsDetectedAt = &detectedAt
+	require.NoError(t, storage.UpdateKey(key))
+
+	retrieved, err := storage.GetKey(key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gemini-2.5-*"}, retrieved.AllowedModels)
+	assert.Equal(t, []string{"*-preview"}, retrieved.DeniedModels)
+	assert.Equal(t, []string{"gemini-2.5-pro", "gemini-2.5-flash"}, retrieved.DetectedModels)
+	require.NotNil(t, retrieved.ModelsDetectedAt)
+	assert.True(t, detectedAt.Equal(*retrieved.ModelsDetectedAt))
+}
+
+func TestStorage_UpdateKey_Settings(t *testing.T) {
+	storage := newTestStorage(t)
+	defer storage.Close()
+
+	key := &types.Key{
+		ID:        uuid.New().String(),
+		APIKey:    "AIzaSySettings123",
+		Name:      "Settings",
+		Enabled:   true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	require.NoError(t, storage.CreateKey(key))
+
+	key.Name = "Renamed"
+	key.Enabled = false
+	key.Provider = "google_aistudio"
+	key.DefaultModel = "gemini-2.5-flash"
+	key.Weight = 3
+	key.Priority = 10
+	key.Limits = types.KeyLimits{RPM: 60, DailyRequests: 1000, MaxConcurrent: 4}
+	require.NoError(t, storage.UpdateKey(key))
+
+	retrieved, err := storage.GetKey(key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", retrieved.Name)
+	assert.False(t, retrieved.Enabled)
+	assert.Equal(t, "google_aistudio", retrieved.Provider)
+	assert.Equal(t, "gemini-2.5-flash", retrieved.DefaultModel)
+	assert.Equal(t, 3, retrieved.Weight)
+	assert.Equal(t, 10, retrieved.Priority)
+	assert.Equal(t, types.KeyLimits{RPM: 60, DailyRequests: 1000, MaxConcurrent: 4}, retrieved.Limits)
+}
+
+func TestStorage_UpdateKey_Schedule(t *testing.T) {
+	storage := newTestStorage(t)
+	defer storage.Close()
+
+	key := &types.Key{
+		ID:        uuid.New().String(),
+		APIKey:    "AIzaSySchedule123",
+		Enabled:   true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	require.NoError(t, storage.CreateKey(key))
+
+	notBefore := time.Unix(1767225600, 0)
+	expiresAt := notBefore.Add(30 * 24 * time.Hour)
+	windows := []types.ActiveWindow{{Days: []string{"sat", "sun"}, Start: "22:00", End: "06:00", Timezone: "UTC"}}
+	key.NotBefore = &notBefore
+	key.ExpiresAt = &expiresAt
+	key.ActiveWindows = windows
+	require.NoError(t, storage.UpdateKey(key))
+
+	retrieved, err := storage.GetKey(key.ID)
+	require.NoError(t, err)
+	require.NotNil(t, retrieved.NotBefore)
+	require.NotNil(t, retrieved.ExpiresAt)
+	assert.True(t, notBefore.Equal(*retrieved.NotBefore))
+	assert.True(t, expiresAt.Equal(*retrieved.ExpiresAt))
+	assert.Equal(t, windows, retrieved.ActiveWindows)
+
+	// Clearing the schedule
+	key.NotBefore = nil
+	key.ExpiresAt = nil
+	key.ActiveWindows = nil
+	require.NoError(t, storage.UpdateKey(key))
+
+	retrieved, err = storage.GetKey(key.ID)
+	require.NoError(t, err)
+	assert.Nil(t, retrieved.NotBefore)
+	assert.Nil(t, retrieved.ExpiresAt)
+	assert.Empty(t, retrieved.ActiveWindows)
+}
+
 func TestStorage_DeleteKey(t *testing.T) {
 	storage := newTestStorage(t)
 	defer storage.Close()
@@ -319,6 +425,72 @@ func TestStorage_DeleteSession(t *testing.T) {
 	assert.Empty(t, messages)
 }
 
+// ==================== Model Profile Storage Tests ====================
+
+func TestStorage_ModelProfileCRUD(t *testing.T) {
+	storage := newTestStorage(t)
+	defer storage.Close()
+
+	temp := 1.2
+	profile := &types.ModelProfile{
+		Name:     "Flash",
+		Pattern:  "gemini-2.5-flash*",
+		Settings: types.ModelSettingsConfig{SystemPrompt: "Be brief.", Temperature: &temp},
+	}
+	require.NoError(t, storage.CreateModelProfile(profile))
+	assert.NotEmpty(t, profile.ID)
+
+	retrieved, err := storage.GetModelProfile(profile.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Be brief.", retrieved.Settings.SystemPrompt)
+	require.NotNil(t, retrieved.Settings.Temperature)
+	assert.Equal(t, 1.2, *retrieved.Settings.Temperature)
+
+	// Update replaces the settings
+	retrieved.Pattern = "gemini-2.5-flash"
+	retrieved.Settings = types.ModelSettingsConfig{SystemPrompt: "Be thorough."}
+	require.NoError(t, storage.UpdateModelProfile(retrieved))
+
+	profiles, err := storage.ListModelProfiles()
+	require.NoError(t, err)
+	require.Len(t, profiles, 1)
+	assert.Equal(t, "gemini-2.5-flash", profiles[0].Pattern)
+	assert.Equal(t, "Be thorough.", profiles[0].Settings.SystemPrompt)
+	assert.Nil(t, profiles[0].Settings.Temperature)
+
+	require.NoError(t, storage.DeleteModelProfile(profile.ID))
+	_, err = storage.GetModelProfile(profile.ID)
+	assert.ErrorIs(t, err, types.ErrModelProfileNotFound)
+	assert.ErrorIs(t, storage.DeleteModelProfile(profile.ID), types.ErrModelProfileNotFound)
+}
+
+func TestStorage_ModelPresetCRUD(t *testing.T) {
+	storage := newTestStorage(t)
+	defer storage.Close()
+
+	preset := &types.ModelPreset{
+		Name:     "coder",
+		Model:    "gemini-2.5-pro",
+		Settings: types.ModelSettingsConfig{SystemPrompt: "You write Go."},
+	}
+	require.NoError(t, storage.CreateModelPreset(preset))
+
+	preset.Model = "gemini-2.5-flash"
+	preset.Description = "Fast coding"
+	require.NoError(t, storage.UpdateModelPreset(preset))
+
+	presets, err := storage.ListModelPresets()
+	require.NoError(t, err)
+	require.Len(t, presets, 1)
+	assert.Equal(t, "gemini-2.5-flash", presets[0].Model)
+	assert.Equal(t, "Fast coding", presets[0].Description)
+	assert.Equal(t, "You write Go.", presets[0].Settings.SystemPrompt)
+
+	require.NoError(t, storage.DeleteModelPreset(preset.ID))
+	_, err = storage.GetModelPreset(preset.ID)
+	assert.ErrorIs(t, err, types.ErrModelPresetNotFound)
+}
+
 // ==================== Message Storage Tests ====================
 
 func TestStorage_AddMessage(t *testing.T) {
@@ -4,6 +4,7 @@ package types
 import (
 	"fmt"
 	"net/http"
+	"time"
 )
 
 // ==================== Error Codes ====================
@@ -14,6 +15,7 @@ const (
 	ErrCodeInvalidRequest   = 40001 // Invalid request format
 	ErrCodeUnsupportedModel = 40002 // Unsupported model
 	ErrCodeInvalidMessages  = 40003 // Invalid messages format
+	ErrCodeContentFilter    = 40004 // Prompt blocked by safety filters
 	ErrCodeAuthentication   = 40101 // Missing or invalid API key
 	ErrCodePermission       = 40301 // Key disabled or access denied
 	ErrCodeNotFound         = 40401 // Resource not found
@@ -23,6 +25,9 @@ const (
 	ErrCodeInternal           = 50001 // Internal server error
 	ErrCodeUpstream           = 50201 // Upstream (Gemini) API error
 	ErrCodeServiceUnavailable = 50301 // Service temporarily unavailable
+	ErrCodeUpstreamTimeout    = 50401 // Blocking upstream request timed out
+	ErrCodeStreamFirstByte    = 50402 // Stream sent nothing before the first-byte timeout
+	ErrCodeStreamIdle         = 50403 // Stream stalled longer than the idle timeout
 )
 
 // ==================== Error Types ====================
@@ -37,6 +42,7 @@ const (
 	ErrTypeServer             = "server_error"
 	ErrTypeUpstream           = "upstream_error"
 	ErrTypeServiceUnavailable = "service_unavailable"
+	ErrTypeTimeout            = "timeout_error"
 )
 
 // ==================== API Error Response ====================
@@ -54,6 +60,14 @@ type ErrorDetail struct {
 	Type       string `json:"type"`
 	Param      string `json:"param,omitempty"`       // Related parameter (if applicable)
 	RetryAfter int    `json:"retry_after,omitempty"` // Seconds to wait (for 429)
+
+	ContentFilter *ContentFilterDetail `json:"content_filter,omitempty"` // Why a prompt was blocked
+}
+
+// ContentFilterDetail explains a prompt blocked by Gemini's safety filters.
+type ContentFilterDetail struct {
+	BlockReason   string               `json:"block_reason"` // e.g. "SAFETY", "BLOCKLIST", "PROHIBITED_CONTENT"
+	SafetyRatings []GeminiSafetyRating `json:"safety_ratings,omitempty"`
 }
 
 // ==================== AppError ====================
@@ -68,14 +82,17 @@ type AppError struct {
 	Param      string
 	RetryAfter int
 	Cause      error // Underlying error
+
+	ContentFilter *ContentFilterDetail // Set for content filter errors
 }
 
-// Error implements the error interface.
+// Error implements the error interface. Secrets in the message or cause,
+// such as API keys embedded in transport errors, are redacted.
 func (e *AppError) Error() string {
 	if e.Cause != nil {
-		return fmt.Sprintf("[%d] %s: %v", e.Code, e.Message, e.Cause)
+		return RedactSecrets(fmt.Sprintf("[%d] %s: %v", e.Code, e.Message, e.Cause))
 	}
-	return fmt.Sprintf("[%d] %s", e.Code, e.Message)
+	return RedactSecrets(fmt.Sprintf("[%d] %s", e.Code, e.Message))
 }
 
 // Unwrap returns the underlying error.
@@ -92,6 +109,8 @@ func (e *AppError) ToAPIError() *APIError {
 			Type:       e.Type,
 			Param:      e.Param,
 			RetryAfter: e.RetryAfter,
+
+			ContentFilter: e.ContentFilter,
 		},
 	}
 }
@@ -154,6 +173,22 @@ func NewInvalidMessagesError(message string) *AppError {
 	}
 }
 
+// NewContentFilterError creates an error for a prompt Gemini refused to
+// answer, carrying the block reason and safety ratings.
+func NewContentFilterError(blockReason string, ratings []GeminiSafetyRating) *AppError {
+	return &AppError{
+		Code:       ErrCodeContentFilter,
+		Message:    fmt.Sprintf("The prompt was blocked by Gemini's content filter (reason: %s)", blockReason),
+		Type:       ErrTypeInvalidRequest,
+		HTTPStatus: http.StatusBadRequest,
+		Param:      "messages",
+		ContentFilter: &ContentFilterDetail{
+			BlockReason:   blockReason,
+			SafetyRatings: ratings,
+		},
+	}
+}
+
 // NewAuthenticationError creates an error for authentication failure.
 func NewAuthenticationError(message string) *AppError {
 	if message == "" {
@@ -240,6 +275,60 @@ func NewServiceUnavailableError(message string) *AppError {
 	}
 }
 
+// NewUpstreamTimeoutError creates an error for a blocking upstream request
+// that did not complete within timeout.
+func NewUpstreamTimeoutError(timeout time.Duration) *AppError {
+	return &AppError{
+		Code:       ErrCodeUpstreamTimeout,
+		Message:    fmt.Sprintf("Upstream request timed out after %s", timeout),
+		Type:       ErrTypeTimeout,
+		HTTPStatus: http.StatusGatewayTimeout,
+	}
+}
+
+// NewStreamFirstByteTimeoutError creates an error for a stream whose first
+// chunk did not arrive within timeout.
+func NewStreamFirstByteTimeoutError(timeout time.Duration) *AppError {
+	return &AppError{
+		Code:       ErrCodeStreamFirstByte,
+		Message:    fmt.Sprintf("Upstream stream sent no data within %s", timeout),
+		Type:       ErrTypeTimeout,
+		HTTPStatus: http.StatusGatewayTimeout,
+	}
+}
+
+// NewStreamIdleTimeoutError creates an error for a stream that went quiet
+// for longer than timeout between chunks.
+func NewStreamIdleTimeoutError(timeout time.Duration) *AppError {
+	return &AppError{
+		Code:       ErrCodeStreamIdle,
+		Message:    fmt.Sprintf("Upstream stream stalled for more than %s", timeout),
+		Type:       ErrTypeTimeout,
+		HTTPStatus: http.StatusGatewayTimeout,
+	}
+}
+
+// NewModelUnavailableError creates an error when no key in the pool may serve a model.
+func NewModelUnavailableError(model string) *AppError {
+	return &AppError{
+		Code:       ErrCodeServiceUnavailable,
+		Message:    fmt.Sprintf("No available API key can serve model '%s'", model),
+		Type:       ErrTypeServiceUnavailable,
+		HTTPStatus: http.StatusServiceUnavailable,
+		Param:      "model",
+	}
+}
+
+// NewUnknownKeyGroupError creates an error for a key group that is not configured.
+func NewUnknownKeyGroupError(group string) *AppError {
+	return &AppError{
+		Code:       ErrCodeInvalidRequest,
+		Message:    fmt.Sprintf("Unknown key group '%s'", group),
+		Type:       ErrTypeInvalidRequest,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
+
 // ==================== Sentinel Errors ====================
 
 // Pre-defined sentinel errors for common error cases.
@@ -261,6 +350,12 @@ var (
 
 	// ErrSessionNotFound indicates a session was not found in the database.
 	ErrSessionNotFound = NewNotFoundError("Session")
+
+	// ErrModelProfileNotFound indicates a model profile was not found in the database.
+	ErrModelProfileNotFound = NewNotFoundError("Model profile")
+
+	// ErrModelPresetNotFound indicates a model preset was not found in the database.
+	ErrModelPresetNotFound = NewNotFoundError("Model preset")
 )
 
 // ==================== Error Helpers ====================
@@ -1,6 +1,8 @@
 ﻿// Package types defines all data transfer objects and core types for MuxueTools.
 package types
 
+import "strings"
+
 // ==================== Gemini API Request ====================
 
 // GeminiRequest represents a request to Gemini's generateContent endpoint.
@@ -21,6 +23,7 @@ type GeminiContent struct {
 // Can be text, inline data (image), or file data.
 type GeminiPart struct {
 	Text       string            `json:"text,omitempty"`
+	Thought    bool              `json:"thought,omitempty"` // Text is a thought summary, not answer content
 	InlineData *GeminiInlineData `json:"inlineData,omitempty"`
 	FileData   *GeminiFileData   `json:"fileData,omitempty"`
 }
@@ -47,12 +50,19 @@ type GeminiGenerationConfig struct {
 	CandidateCount  *int            `json:"candidateCount,omitempty"`
 	ThinkingConfig  *ThinkingConfig `json:"thinkingConfig,omitempty"`  // Gemini 2.5+ thinking mode
 	MediaResolution *string         `json:"mediaResolution,omitempty"` // MEDIA_RESOLUTION_LOW/MEDIUM/HIGH
+
+	PresencePenalty  *float64 `json:"presencePenalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequencyPenalty,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	ResponseLogprobs *bool    `json:"responseLogprobs,omitempty"` // Return logprobsResult on candidates
+	Logprobs         *int     `json:"logprobs,omitempty"`         // Top candidates per step; needs ResponseLogprobs
 }
 
 // ThinkingConfig configures reasoning mode for Gemini 2.5+ models.
 type ThinkingConfig struct {
-	ThinkingBudget *int    `json:"thinkingBudget,omitempty"` // Max tokens for thinking process
-	ThinkingLevel  *string `json:"thinkingLevel,omitempty"`  // LOW, MEDIUM, HIGH
+	ThinkingBudget  *int    `json:"thinkingBudget,omitempty"`  // Max tokens for thinking process; 0 disables thinking
+	ThinkingLevel   *string `json:"thinkingLevel,omitempty"`   // LOW, MEDIUM, HIGH
+	IncludeThoughts bool    `json:"includeThoughts,omitempty"` // Return thought summaries as thought parts
 }
 
 // GeminiSafetySetting configures safety thresholds.
@@ -76,6 +86,27 @@ type GeminiCandidate struct {
 	FinishReason  string               `json:"finishReason,omitempty"` // "STOP", "MAX_TOKENS", "SAFETY", "RECITATION"
 	SafetyRatings []GeminiSafetyRating `json:"safetyRatings,omitempty"`
 	Index         int                  `json:"index"`
+
+	LogprobsResult *GeminiLogprobsResult `json:"logprobsResult,omitempty"`
+}
+
+// GeminiLogprobsResult holds the log probabilities of a candidate's tokens.
+// TopCandidates, when present, has one entry per decoding step.
+type GeminiLogprobsResult struct {
+	TopCandidates    []GeminiTopCandidates `json:"topCandidates,omitempty"`
+	ChosenCandidates []GeminiLogprob       `json:"chosenCandidates,omitempty"`
+}
+
+// GeminiTopCandidates lists the most likely tokens of one decoding step.
+type GeminiTopCandidates struct {
+	Candidates []GeminiLogprob `json:"candidates,omitempty"`
+}
+
+// GeminiLogprob is the log probability of one token.
+type GeminiLogprob struct {
+	Token          string  `json:"token"`
+	TokenID        int     `json:"tokenId,omitempty"`
+	LogProbability float64 `json:"logProbability"`
 }
 
 // GeminiSafetyRating provides safety analysis for content.
@@ -87,9 +118,11 @@ type GeminiSafetyRating struct {
 
 // GeminiUsageMetadata contains token consumption information.
 type GeminiUsageMetadata struct {
-	PromptTokenCount     int `json:"promptTokenCount"`
-	CandidatesTokenCount int `json:"candidatesTokenCount"`
-	TotalTokenCount      int `json:"totalTokenCount"`
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount,omitempty"` // Part of PromptTokenCount served from cache
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount,omitempty"`      // Thinking tokens, not part of CandidatesTokenCount
 }
 
 // GeminiPromptFeedback contains feedback about the prompt.
@@ -143,11 +176,18 @@ const (
 	// GeminiBaseURL is the base URL for Google AI Studio (Gemini) API.
 	GeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
 
+	// GeminiAPIKeyHeader carries the API key on every upstream request.
+	GeminiAPIKeyHeader = "x-goog-api-key"
+
 	// Gemini finish reasons
 	GeminiFinishReasonStop       = "STOP"
 	GeminiFinishReasonMaxTokens  = "MAX_TOKENS"
 	GeminiFinishReasonSafety     = "SAFETY"
 	GeminiFinishReasonRecitation = "RECITATION"
+	GeminiFinishReasonBlocklist  = "BLOCKLIST"
+	GeminiFinishReasonProhibited = "PROHIBITED_CONTENT"
+	GeminiFinishReasonSPII       = "SPII"
+	GeminiFinishReasonImageSafe  = "IMAGE_SAFETY"
 
 	// Safety categories
 	SafetyCategoryHarassment       = "HARM_CATEGORY_HARASSMENT"
@@ -160,31 +200,65 @@ const (
 	SafetyThresholdBlockLowAndAbove    = "BLOCK_LOW_AND_ABOVE"
 	SafetyThresholdBlockMediumAndAbove = "BLOCK_MEDIUM_AND_ABOVE"
 	SafetyThresholdBlockHighAndAbove   = "BLOCK_ONLY_HIGH"
+
+	// Safety presets
+	SafetyPresetDefault   = "default"    // Gemini's own thresholds
+	SafetyPresetRelaxed   = "relaxed"    // BLOCK_ONLY_HIGH for every category
+	SafetyPresetBlockNone = "block_none" // BLOCK_NONE for every category
 )
 
 // ==================== Helper Methods ====================
 
-// GetTextContent extracts the text content from a Gemini candidate.
-// Returns empty string if no text content is found.
+// GetTextContent returns the answer text of a Gemini candidate, joining its
+// text parts and skipping thought summaries. Returns empty string if there is none.
 func (c *GeminiCandidate) GetTextContent() string {
+	return c.joinText(false)
+}
+
+// GetThoughtContent returns the thought summaries of a Gemini candidate,
+// which are only sent when ThinkingConfig.IncludeThoughts is set.
+func (c *GeminiCandidate) GetThoughtContent() string {
+	return c.joinText(true)
+}
+
+// joinText concatenates the text of the parts whose Thought flag matches.
+func (c *GeminiCandidate) joinText(thought bool) string {
 	if c.Content == nil {
 		return ""
 	}
+	var sb strings.Builder
 	for _, part := range c.Content.Parts {
-		if part.Text != "" {
-			return part.Text
+		if part.Thought == thought {
+			sb.WriteString(part.Text)
 		}
 	}
-	return ""
+	return sb.String()
+}
+
+// IsContentFilterFinish reports whether a Gemini finish reason means the
+// output was stopped by a safety, recitation, blocklist or similar filter.
+func IsContentFilterFinish(finishReason string) bool {
+	switch finishReason {
+	case GeminiFinishReasonSafety, GeminiFinishReasonRecitation, GeminiFinishReasonBlocklist,
+		GeminiFinishReasonProhibited, GeminiFinishReasonSPII, GeminiFinishReasonImageSafe:
+		return true
+	}
+	return false
+}
+
+// IsPromptBlocked returns true if Gemini refused the prompt itself, in which
+// case the response has a block reason and no candidates.
+func (r *GeminiResponse) IsPromptBlocked() bool {
+	return r.PromptFeedback != nil && r.PromptFeedback.BlockReason != ""
 }
 
 // IsBlocked returns true if the response was blocked for safety reasons.
 func (r *GeminiResponse) IsBlocked() bool {
-	if r.PromptFeedback != nil && r.PromptFeedback.BlockReason != "" {
+	if r.IsPromptBlocked() {
 		return true
 	}
 	for _, candidate := range r.Candidates {
-		if candidate.FinishReason == GeminiFinishReasonSafety {
+		if IsContentFilterFinish(candidate.FinishReason) {
 			return true
 		}
 	}
@@ -192,12 +266,20 @@ func (r *GeminiResponse) IsBlocked() bool {
 }
 
 // ToOpenAIUsage converts Gemini usage metadata to OpenAI usage format.
+// As in OpenAI, completion tokens include the reasoning (thinking) tokens.
 func (u *GeminiUsageMetadata) ToOpenAIUsage() Usage {
-	return Usage{
+	usage := Usage{
 		PromptTokens:     u.PromptTokenCount,
-		CompletionTokens: u.CandidatesTokenCount,
+		CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
 		TotalTokens:      u.TotalTokenCount,
 	}
+	if u.CachedContentTokenCount > 0 {
+		usage.PromptTokensDetails = &PromptTokensDetails{CachedTokens: u.CachedContentTokenCount}
+	}
+	if u.ThoughtsTokenCount > 0 {
+		usage.CompletionTokensDetails = &CompletionTokensDetails{ReasoningTokens: u.ThoughtsTokenCount}
+	}
+	return usage
 }
 
 // NewGeminiTextPart creates a GeminiPart with text content.
@@ -2,6 +2,11 @@
 package types
 
 import (
+	"context"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 )
@@ -42,10 +47,231 @@ type Key struct {
 	Tags          []string   `json:"tags"`
 	Provider      string     `json:"provider"`      // e.g., "google_aistudio"
 	DefaultModel  string     `json:"default_model"` // e.g., "gemini-1.5-pro-latest"
+	Weight        int        `json:"weight"`        // Multiplier for the weighted strategy; 0 counts as 1
+	Priority      int        `json:"priority"`      // Higher-priority available keys are always chosen first
+	Limits        KeyLimits  `json:"limits"`
 	Stats         KeyStats   `json:"stats"`
 	CooldownUntil *time.Time `json:"cooldown_until,omitempty"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     time.Time  `json:"updated_at"`
+	// CooldownStreak counts cooldowns entered since the key last served a
+	// request successfully; each one lasts longer. Runtime-only.
+	CooldownStreak int       `json:"cooldown_streak,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	// Model routing. Patterns use path.Match glob syntax (e.g., "gemini-2.5-*").
+	AllowedModels    []string   `json:"allowed_models,omitempty"`     // Empty allows every model
+	DeniedModels     []string   `json:"denied_models,omitempty"`      // Takes precedence over AllowedModels
+	DetectedModels   []string   `json:"detected_models,omitempty"`    // From a models.list probe; empty means unknown
+	ModelsDetectedAt *time.Time `json:"models_detected_at,omitempty"` // When DetectedModels was last refreshed
+
+	// ModelCooldowns holds key-model pairs that upstream rejected with
+	// NotFound/Permission, mapped to when they become eligible again. Runtime-only.
+	ModelCooldowns map[string]time.Time `json:"model_cooldowns,omitempty"`
+
+	// Groups lists the key groups the key belongs to through its tags. Runtime-only.
+	Groups []string `json:"groups,omitempty"`
+
+	// Result of the last probe against the upstream API
+	Health        KeyHealth  `json:"health,omitempty"`
+	LastCheckedAt *time.Time `json:"last_checked_at,omitempty"`
+
+	// DisabledReason explains why the key was disabled automatically
+	// (e.g., "invalid: API key not valid"). Cleared when the key is re-enabled.
+	DisabledReason string `json:"disabled_reason,omitempty"`
+
+	// Source records where the key was defined; empty is treated as KeySourceAPI.
+	Source KeySource `json:"source,omitempty"`
+
+	// Lifecycle schedule. The key serves traffic only from NotBefore until
+	// ExpiresAt and, when ActiveWindows is set, inside one of the windows.
+	// Expired keys are retired (disabled) by the lifecycle scheduler.
+	NotBefore     *time.Time     `json:"not_before,omitempty"`
+	ExpiresAt     *time.Time     `json:"expires_at,omitempty"`
+	ActiveWindows []ActiveWindow `json:"active_windows,omitempty"`
+
+	// Proxy routes the key's upstream requests through an http, https or
+	// socks5 proxy, overriding its group and the global proxy. ProxyDirect
+	// bypasses them; empty inherits. Key listings mask the password.
+	Proxy string `json:"proxy,omitempty"`
+}
+
+// KeySource records where a key was defined.
+type KeySource string
+
+const (
+	// KeySourceConfig marks keys from the config file, which reconciliation manages.
+	KeySourceConfig KeySource = "config"
+	// KeySourceAPI marks keys added through the admin API or imports.
+	KeySourceAPI KeySource = "api"
+)
+
+// KeyHealth classifies the outcome of probing a key against the upstream API.
+type KeyHealth string
+
+const (
+	// KeyHealthValid indicates the key authenticated and listed models.
+	KeyHealthValid KeyHealth = "valid"
+	// KeyHealthInvalid indicates the key was rejected as invalid or unauthorized.
+	KeyHealthInvalid KeyHealth = "invalid"
+	// KeyHealthQuotaExhausted indicates the key is valid but out of quota.
+	KeyHealthQuotaExhausted KeyHealth = "quota_exhausted"
+	// KeyHealthRegionBlocked indicates the API is not available in the caller's location.
+	KeyHealthRegionBlocked KeyHealth = "region_blocked"
+	// KeyHealthNetworkError indicates the probe could not reach the upstream API.
+	KeyHealthNetworkError KeyHealth = "network_error"
+)
+
+// KeyProbeResult is the outcome of probing a single key.
+type KeyProbeResult struct {
+	Health    KeyHealth `json:"health"`
+	LatencyMs int64     `json:"latency_ms"`
+	Models    []string  `json:"models,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Usable reports whether the probe showed the key authenticates.
+// An exhausted key is usable once its quota resets.
+func (r KeyProbeResult) Usable() bool {
+	return r.Health == KeyHealthValid || r.Health == KeyHealthQuotaExhausted
+}
+
+// Reason describes an unusable probe result, for DisabledReason.
+func (r KeyProbeResult) Reason() string {
+	if r.Error == "" {
+		return string(r.Health)
+	}
+	return string(r.Health) + ": " + r.Error
+}
+
+// KeyLimits caps the traffic sent to a single key. Zero values mean unlimited.
+// A key that reaches a limit is skipped by selection until the limit resets.
+type KeyLimits struct {
+	RPM           int `json:"rpm,omitempty" mapstructure:"rpm" yaml:"rpm"`                                  // Requests started per minute
+	DailyRequests int `json:"daily_requests,omitempty" mapstructure:"daily_requests" yaml:"daily_requests"` // Requests started per local day
+	MaxConcurrent int `json:"max_concurrent,omitempty" mapstructure:"max_concurrent" yaml:"max_concurrent"` // Requests in flight at once
+}
+
+// Validate returns an error message if any limit is negative, or "".
+func (l KeyLimits) Validate() string {
+	if l.RPM < 0 || l.DailyRequests < 0 || l.MaxConcurrent < 0 {
+		return "limits must be >= 0"
+	}
+	return ""
+}
+
+// ==================== Key Routing ====================
+
+// KeyRequest describes the request a key is being selected for.
+type KeyRequest struct {
+	// Model is the resolved upstream model name. Empty matches every key.
+	Model string
+
+	// Group is the key group to select from. Empty uses the pool's default group.
+	Group string
+
+	// Affinity identifies the conversation. Requests with the same affinity
+	// prefer the key that served the previous turn. Empty disables affinity.
+	Affinity string
+}
+
+// KeyGroupStatus summarizes a key group and the state of its keys.
+type KeyGroupStatus struct {
+	Name            string   `json:"name"`
+	Tags            []string `json:"tags"`
+	Strategy        string   `json:"strategy"`
+	CooldownSeconds int      `json:"cooldown_seconds"`
+	Fallback        string   `json:"fallback,omitempty"`
+	Default         bool     `json:"default,omitempty"`
+	KeyHealthStats
+}
+
+// KeyIneligibleReason identifies why a key would not be selected.
+type KeyIneligibleReason string
+
+const (
+	KeyReasonDisabled         KeyIneligibleReason = "disabled"           // Disabled manually or automatically
+	KeyReasonNotInGroup       KeyIneligibleReason = "not_in_group"       // Outside the requested group and its fallbacks
+	KeyReasonModelNotAllowed  KeyIneligibleReason = "model_not_allowed"  // Model patterns or detected models exclude the model
+	KeyReasonModelCoolingDown KeyIneligibleReason = "model_cooling_down" // Upstream rejected the model on this key
+	KeyReasonCoolingDown      KeyIneligibleReason = "cooling_down"       // Rate limited
+	KeyReasonNotYetActive     KeyIneligibleReason = "not_yet_active"     // Before NotBefore
+	KeyReasonExpired          KeyIneligibleReason = "expired"            // After ExpiresAt
+	KeyReasonOutsideWindow    KeyIneligibleReason = "outside_window"     // Outside every active window
+	KeyReasonSaturated        KeyIneligibleReason = "saturated"          // At its max_concurrent limit
+	KeyReasonOverBudget       KeyIneligibleReason = "over_budget"        // At its rpm or daily_requests limit
+	KeyReasonLowerPriority    KeyIneligibleReason = "lower_priority"     // Usable, but higher-priority keys are available
+)
+
+// KeyIneligibility is one reason a key would not be selected.
+type KeyIneligibility struct {
+	Reason KeyIneligibleReason `json:"reason"`
+	Detail string              `json:"detail,omitempty"`
+	Until  *time.Time          `json:"until,omitempty"` // When the reason lapses, if known
+}
+
+// KeyEligibility explains whether a key would be considered for a request.
+type KeyEligibility struct {
+	KeyID     string             `json:"key_id"`
+	Name      string             `json:"name"`
+	MaskedKey string             `json:"key"`
+	Group     string             `json:"group,omitempty"` // Group the key was evaluated in
+	Priority  int                `json:"priority"`
+	Eligible  bool               `json:"eligible"`
+	Reasons   []KeyIneligibility `json:"reasons,omitempty"`
+}
+
+// KeySelectionExplanation is the result of a dry-run key selection: the
+// eligibility of every key and the key the strategy would choose next.
+type KeySelectionExplanation struct {
+	Model    string   `json:"model,omitempty"`
+	Group    string   `json:"group,omitempty"`
+	Groups   []string `json:"groups,omitempty"` // Groups tried, following fallbacks
+	Strategy string   `json:"strategy"`
+
+	// NextKeyID is the key the strategy would choose next. For random
+	// strategies it is the most likely choice, and empty for uniform random.
+	NextKeyID string `json:"next_key_id,omitempty"`
+	NextKey   string `json:"next_key,omitempty"`
+
+	// Error is what the request would fail with when no key is eligible.
+	Error string `json:"error,omitempty"`
+
+	Keys []KeyEligibility `json:"keys"`
+}
+
+// keyGroupContextKey is the context key for the requested key group.
+type keyGroupContextKey struct{}
+
+// WithKeyGroup returns a context that asks the key pool to select from the given group.
+func WithKeyGroup(ctx context.Context, group string) context.Context {
+	if group == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, keyGroupContextKey{}, group)
+}
+
+// KeyGroupFromContext returns the key group stored by WithKeyGroup, or "".
+func KeyGroupFromContext(ctx context.Context) string {
+	group, _ := ctx.Value(keyGroupContextKey{}).(string)
+	return group
+}
+
+// keyAffinityContextKey is the context key for the conversation affinity.
+type keyAffinityContextKey struct{}
+
+// WithKeyAffinity returns a context that asks the key pool to keep the
+// conversation on the key that served its previous turn.
+func WithKeyAffinity(ctx context.Context, affinity string) context.Context {
+	if affinity == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, keyAffinityContextKey{}, affinity)
+}
+
+// KeyAffinityFromContext returns the affinity stored by WithKeyAffinity, or "".
+func KeyAffinityFromContext(ctx context.Context) string {
+	affinity, _ := ctx.Value(keyAffinityContextKey{}).(string)
+	return affinity
 }
 
 // KeyStats holds usage statistics for a single key.
@@ -59,23 +285,88 @@ type KeyStats struct {
 	ModelUsage       map[string]int64 `json:"model_usage,omitempty"`
 }
 
+// ==================== Stats Windows ====================
+
+// StatsWindow identifies the time span over which key statistics are aggregated.
+type StatsWindow string
+
+const (
+	// StatsWindow5m covers the last 5 minutes.
+	StatsWindow5m StatsWindow = "5m"
+	// StatsWindow1h covers the last hour.
+	StatsWindow1h StatsWindow = "1h"
+	// StatsWindow24h covers the last 24 hours.
+	StatsWindow24h StatsWindow = "24h"
+	// StatsWindowLifetime uses the lifetime counters in KeyStats.
+	StatsWindowLifetime StatsWindow = "lifetime"
+)
+
+// RollingStatsWindows lists the rolling windows tracked for every key, shortest first.
+var RollingStatsWindows = []StatsWindow{StatsWindow5m, StatsWindow1h, StatsWindow24h}
+
+// IsValid returns true if the window is a valid StatsWindow value.
+func (w StatsWindow) IsValid() bool {
+	switch w {
+	case StatsWindow5m, StatsWindow1h, StatsWindow24h, StatsWindowLifetime:
+		return true
+	}
+	return false
+}
+
+// Duration returns the span covered by the window, or 0 for StatsWindowLifetime.
+func (w StatsWindow) Duration() time.Duration {
+	switch w {
+	case StatsWindow5m:
+		return 5 * time.Minute
+	case StatsWindow1h:
+		return time.Hour
+	case StatsWindow24h:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// WindowStats holds request outcomes for a single key over a rolling window.
+type WindowStats struct {
+	RequestCount   int64   `json:"request_count"`
+	SuccessCount   int64   `json:"success_count"`
+	ErrorCount     int64   `json:"error_count"`
+	RateLimitCount int64   `json:"rate_limit_count"` // 429 responses, also counted in ErrorCount
+	AvgLatencyMs   float64 `json:"avg_latency_ms"`
+}
+
+// SuccessRate calculates the success rate as a percentage (0-100).
+func (s WindowStats) SuccessRate() float64 {
+	if s.RequestCount == 0 {
+		return 0
+	}
+	return float64(s.SuccessCount) / float64(s.RequestCount) * 100
+}
+
 // ==================== Key Configuration ====================
 
 // KeyConfig represents a key entry in the configuration file.
 type KeyConfig struct {
-	Key     string   `mapstructure:"key" yaml:"key"`
-	Name    string   `mapstructure:"name" yaml:"name"`
-	Enabled bool     `mapstructure:"enabled" yaml:"enabled"`
-	Tags    []string `mapstructure:"tags" yaml:"tags"`
+	Key           string    `mapstructure:"key" yaml:"key"`
+	Name          string    `mapstructure:"name" yaml:"name"`
+	Enabled       bool      `mapstructure:"enabled" yaml:"enabled"`
+	Tags          []string  `mapstructure:"tags" yaml:"tags"`
+	AllowedModels []string  `mapstructure:"allowed_models" yaml:"allowed_models"`
+	DeniedModels  []string  `mapstructure:"denied_models" yaml:"denied_models"`
+	Weight        int       `mapstructure:"weight" yaml:"weight"`
+	Priority      int       `mapstructure:"priority" yaml:"priority"`
+	Limits        KeyLimits `mapstructure:"limits" yaml:"limits"`
+	Proxy         string    `mapstructure:"proxy" yaml:"proxy"`
 }
 
 // ==================== Admin API DTOs ====================
 
 // KeyListResponse represents the response for GET /api/keys.
 type KeyListResponse struct {
-	Success bool  `json:"success"`
-	Data    []Key `json:"data"`
-	Total   int   `json:"total"`
+	Success bool             `json:"success"`
+	Data    []Key            `json:"data"`
+	Total   int              `json:"total"`
+	Groups  []KeyGroupStatus `json:"groups,omitempty"`
 }
 
 // KeyInfo is an alias for Key used in API responses.
@@ -83,11 +374,26 @@ type KeyInfo = Key
 
 // CreateKeyRequest represents the request body for POST /api/keys.
 type CreateKeyRequest struct {
-	Key          string   `json:"key" binding:"required"`
-	Name         string   `json:"name,omitempty"`
-	Tags         []string `json:"tags,omitempty"`
-	Provider     string   `json:"provider,omitempty"`
-	DefaultModel string   `json:"default_model,omitempty"`
+	Key           string    `json:"key" binding:"required"`
+	Name          string    `json:"name,omitempty"`
+	Tags          []string  `json:"tags,omitempty"`
+	Provider      string    `json:"provider,omitempty"`
+	DefaultModel  string    `json:"default_model,omitempty"`
+	AllowedModels []string  `json:"allowed_models,omitempty"`
+	DeniedModels  []string  `json:"denied_models,omitempty"`
+	Weight        int       `json:"weight,omitempty"`
+	Priority      int       `json:"priority,omitempty"`
+	Limits        KeyLimits `json:"limits,omitempty"`
+	Proxy         string    `json:"proxy,omitempty"`
+
+	NotBefore     *time.Time     `json:"not_before,omitempty"`
+	ExpiresAt     *time.Time     `json:"expires_at,omitempty"`
+	ActiveWindows []ActiveWindow `json:"active_windows,omitempty"`
+
+	// Validate checks the key format and probes it upstream before adding it.
+	// An unusable key is rejected unless KeepInvalid adds it disabled.
+	Validate    bool `json:"validate,omitempty"`
+	KeepInvalid bool `json:"keep_invalid,omitempty"`
 }
 
 // CreateKeyResponse represents the response for POST /api/keys.
@@ -110,22 +416,151 @@ type TestKeyResponse struct {
 
 // TestKeyResult contains the result of testing a key's validity.
 type TestKeyResult struct {
-	Valid     bool     `json:"valid"`
-	LatencyMs int64    `json:"latency_ms"`
-	Models    []string `json:"models,omitempty"`
-	Error     string   `json:"error,omitempty"`
+	Valid     bool      `json:"valid"`
+	Health    KeyHealth `json:"health"`
+	LatencyMs int64     `json:"latency_ms"`
+	Models    []string  `json:"models,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// UpdateKeyModelsRequest represents the request body for PUT /api/keys/:id/models.
+// Nil fields are left unchanged; an empty list clears the patterns.
+type UpdateKeyModelsRequest struct {
+	AllowedModels *[]string `json:"allowed_models,omitempty"`
+	DeniedModels  *[]string `json:"denied_models,omitempty"`
+}
+
+// UpdateKeyRequest represents the request body for PATCH /api/keys/:id.
+// Nil fields are left unchanged.
+type UpdateKeyRequest struct {
+	Name         *string    `json:"name,omitempty"`
+	Tags         *[]string  `json:"tags,omitempty"`
+	Enabled      *bool      `json:"enabled,omitempty"`
+	Provider     *string    `json:"provider,omitempty"`
+	DefaultModel *string    `json:"default_model,omitempty"`
+	Weight       *int       `json:"weight,omitempty"`
+	Priority     *int       `json:"priority,omitempty"`
+	Limits       *KeyLimits `json:"limits,omitempty"`
+	Proxy        *string    `json:"proxy,omitempty"` // Empty inherits the group or global proxy
+
+	// Schedule fields; null clears NotBefore/ExpiresAt and an empty list clears the windows
+	NotBefore     OptionalTime    `json:"not_before"`
+	ExpiresAt     OptionalTime    `json:"expires_at"`
+	ActiveWindows *[]ActiveWindow `json:"active_windows,omitempty"`
+
+	// Model patterns are edited over HTTP with PUT /api/keys/:id/models;
+	// imports set them here so a key is updated in one step
+	AllowedModels *[]string `json:"-"`
+	DeniedModels  *[]string `json:"-"`
+}
+
+// Bulk key actions for POST /api/keys/bulk.
+const (
+	BulkKeyActionEnable  = "enable"
+	BulkKeyActionDisable = "disable"
+	BulkKeyActionDelete  = "delete"
+)
+
+// BulkKeyRequest represents the request body for POST /api/keys/bulk.
+// Keys are selected by IDs, by tag, or both (union).
+type BulkKeyRequest struct {
+	Action string   `json:"action" binding:"required"` // "enable", "disable" or "delete"
+	IDs    []string `json:"ids,omitempty"`
+	Tag    string   `json:"tag,omitempty"`
+}
+
+// BulkKeyResult contains the result of a bulk key operation.
+type BulkKeyResult struct {
+	Action   string   `json:"action"`
+	Matched  int      `json:"matched"`
+	Affected int      `json:"affected"`
+	Errors   []string `json:"errors"`
+}
+
+// BulkTestKeysRequest represents the request body for POST /api/keys/test.
+// Keys are selected by IDs and/or tag; an empty selection tests every key.
+type BulkTestKeysRequest struct {
+	IDs            []string `json:"ids,omitempty"`
+	Tag            string   `json:"tag,omitempty"`
+	Concurrency    int      `json:"concurrency,omitempty"`     // Parallel probes; defaults to 8, capped at 32
+	DisableInvalid bool     `json:"disable_invalid,omitempty"` // Disable keys found invalid or region blocked
+}
+
+// KeyTestReportItem is the probe result for one key in a bulk test report.
+type KeyTestReportItem struct {
+	KeyID     string `json:"key_id"`
+	Name      string `json:"name"`
+	MaskedKey string `json:"key"`
+	KeyProbeResult
+}
+
+// KeyTestReport aggregates the results of a bulk key test.
+type KeyTestReport struct {
+	Total      int                 `json:"total"`
+	Summary    map[KeyHealth]int   `json:"summary"`
+	DurationMs int64               `json:"duration_ms"`
+	Results    []KeyTestReportItem `json:"results"`
+}
+
+// KeyTestEvent is a Server-Sent Event emitted while a bulk key test runs.
+// Type is "progress" for each finished key and "report" once all are done.
+type KeyTestEvent struct {
+	Type   string             `json:"type"`
+	Done   int                `json:"done"`
+	Total  int                `json:"total"`
+	Result *KeyTestReportItem `json:"result,omitempty"`
+	Report *KeyTestReport     `json:"report,omitempty"`
 }
 
 // ImportKeyItem represents a single key entry in the import request.
+// The optional fields are filled from key bundles so a backup restores the
+// full key settings; keys created from other sources use defaults.
 type ImportKeyItem struct {
 	Key  string   `json:"key" binding:"required"`
 	Name string   `json:"name,omitempty"`
 	Tags []string `json:"tags,omitempty"`
+
+	Enabled       *bool      `json:"enabled,omitempty"` // Nil means enabled
+	Provider      string     `json:"provider,omitempty"`
+	DefaultModel  string     `json:"default_model,omitempty"`
+	Weight        int        `json:"weight,omitempty"`
+	Priority      int        `json:"priority,omitempty"`
+	Limits        *KeyLimits `json:"limits,omitempty"`
+	AllowedModels []string   `json:"allowed_models,omitempty"`
+	DeniedModels  []string   `json:"denied_models,omitempty"`
+	Stats         *KeyStats  `json:"stats,omitempty"`
+	CreatedAt     *time.Time `json:"created_at,omitempty"`
+	Proxy         string     `json:"proxy,omitempty"`
+
+	NotBefore     *time.Time     `json:"not_before,omitempty"`
+	ExpiresAt     *time.Time     `json:"expires_at,omitempty"`
+	ActiveWindows []ActiveWindow `json:"active_windows,omitempty"`
 }
 
+// How an import handles keys that are already in the pool.
+const (
+	ImportDuplicateSkip      = "skip"      // Leave the existing key untouched
+	ImportDuplicateMerge     = "merge"     // Add new tags and fill empty fields
+	ImportDuplicateOverwrite = "overwrite" // Replace the settings with the imported ones
+)
+
 // ImportKeysRequest represents the request body for POST /api/keys/import.
+// Keys may also come from an encrypted Bundle, decrypted with Passphrase.
 type ImportKeysRequest struct {
-	Keys []ImportKeyItem `json:"keys" binding:"required"` // List of keys to import
+	Keys       []ImportKeyItem `json:"keys,omitempty"` // List of keys to import
+	Bundle     *KeyBundle      `json:"bundle,omitempty"`
+	Passphrase string          `json:"passphrase,omitempty"`
+
+	// OnDuplicate is "skip" (default), "merge" or "overwrite". Stats from a
+	// bundle are only restored on newly created keys.
+	OnDuplicate string `json:"on_duplicate,omitempty"`
+
+	// Validate deduplicates the input, checks each key's format and probes
+	// the new keys in parallel. Unusable keys are rejected unless KeepInvalid
+	// imports them disabled with a reason.
+	Validate    bool `json:"validate,omitempty"`
+	KeepInvalid bool `json:"keep_invalid,omitempty"`
+	Concurrency int  `json:"concurrency,omitempty"` // Parallel probes; defaults to 8, capped at 32
 }
 
 // ImportKeysResponse represents the response for POST /api/keys/import.
@@ -136,9 +571,98 @@ type ImportKeysResponse struct {
 
 // ImportKeysResult contains the result of batch importing keys.
 type ImportKeysResult struct {
-	Imported int      `json:"imported"`
-	Skipped  int      `json:"skipped"` // Duplicate keys
-	Errors   []string `json:"errors"`
+	Imported int                   `json:"imported"`
+	Skipped  int                   `json:"skipped"`  // Duplicate keys
+	Updated  int                   `json:"updated"`  // Existing keys merged or overwritten
+	Disabled int                   `json:"disabled"` // Imported disabled: failed validation or disabled in the source
+	Rejected int                   `json:"rejected"` // Not imported because validation failed
+	Errors   []string              `json:"errors"`
+	Results  []ImportKeyLineResult `json:"results"`
+}
+
+// Per-line outcomes of a key import.
+const (
+	ImportLineImported      = "imported"
+	ImportLineDisabled      = "disabled"
+	ImportLineDuplicate     = "duplicate"
+	ImportLineUpdated       = "updated"
+	ImportLineInvalidFormat = "invalid_format"
+	ImportLineRejected      = "rejected"
+	ImportLineError         = "error"
+)
+
+// ImportKeyLineResult is the outcome of importing one input line.
+type ImportKeyLineResult struct {
+	Line      int       `json:"line"` // 1-based index into the request's keys
+	MaskedKey string    `json:"key"`
+	Status    string    `json:"status"`
+	KeyID     string    `json:"key_id,omitempty"`
+	Health    KeyHealth `json:"health,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ==================== Key Reconciliation DTOs ====================
+
+// KeyReconcileAction describes one change made by reconciliation.
+type KeyReconcileAction string
+
+const (
+	// KeyReconcileCreated: a config key was missing and has been added.
+	KeyReconcileCreated KeyReconcileAction = "created"
+	// KeyReconcileUpdated: a config key's settings were changed to match the file.
+	KeyReconcileUpdated KeyReconcileAction = "updated"
+	// KeyReconcileAdopted: an existing key matching a config entry was marked as a config key.
+	KeyReconcileAdopted KeyReconcileAction = "adopted"
+	// KeyReconcileRemoved: a config key was deleted from the file and removed.
+	KeyReconcileRemoved KeyReconcileAction = "removed"
+	// KeyReconcileLoaded: a database row missing from memory was loaded.
+	KeyReconcileLoaded KeyReconcileAction = "loaded"
+	// KeyReconcilePersisted: an in-memory key missing from the database was saved.
+	KeyReconcilePersisted KeyReconcileAction = "persisted"
+)
+
+// KeyReconcileChange is one change found by reconciliation.
+type KeyReconcileChange struct {
+	Action    KeyReconcileAction `json:"action"`
+	KeyID     string             `json:"key_id"`
+	MaskedKey string             `json:"key"`
+	Fields    []string           `json:"fields,omitempty"` // Settings changed by an update
+	Error     string             `json:"error,omitempty"`  // Set when the change could not be applied
+}
+
+// KeyReconcileReport lists the differences between the config file, the
+// database and the in-memory pool, and what was done about them.
+type KeyReconcileReport struct {
+	Policy  ReconcilePolicy            `json:"policy"`
+	DryRun  bool                       `json:"dry_run"`
+	Summary map[KeyReconcileAction]int `json:"summary"`
+	Changes []KeyReconcileChange       `json:"changes"`
+}
+
+// ==================== Key Backup DTOs ====================
+
+// KeyBundleFormat identifies an encrypted key backup bundle.
+const KeyBundleFormat = "muxuetools-key-bundle"
+
+// KeyBundle is a passphrase-encrypted backup of API keys and their settings.
+// Ciphertext is AES-256-GCM over the JSON-encoded []ImportKeyItem, keyed with
+// PBKDF2-SHA256; the header fields are authenticated as additional data.
+type KeyBundle struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	KeyCount   int       `json:"key_count"`
+	KDF        string    `json:"kdf"` // "pbkdf2-sha256"
+	Iterations int       `json:"iterations"`
+	Salt       string    `json:"salt"`       // base64
+	Ciphertext string    `json:"ciphertext"` // base64 nonce||ciphertext
+}
+
+// ExportKeysRequest represents the request body for POST /api/keys/export.
+type ExportKeysRequest struct {
+	Passphrase string   `json:"passphrase" binding:"required"`
+	IDs        []string `json:"ids,omitempty"` // Keys to export; empty exports every key
+	Tag        string   `json:"tag,omitempty"`
 }
 
 // ==================== Statistics DTOs ====================
@@ -157,6 +681,9 @@ type KeyStatItem struct {
 	SuccessRate  float64 `json:"success_rate"` // Percentage (0-100)
 	TokenUsage   int64   `json:"token_usage"`
 	AvgLatencyMs float64 `json:"avg_latency_ms"`
+
+	// Windows holds rolling statistics keyed by window ("5m", "1h", "24h").
+	Windows map[StatsWindow]WindowStats `json:"windows"`
 }
 
 // StatsResponse represents the response for GET /api/stats.
@@ -239,6 +766,23 @@ type ModelUsageResponse struct {
 
 // ==================== Helper Methods ====================
 
+// apiKeyPattern matches a Google AI Studio API key.
+var apiKeyPattern = regexp.MustCompile(`^AIza[0-9A-Za-z_-]{35}$`)
+
+// NormalizeAPIKey strips the whitespace, quotes and trailing separators that
+// pasted key lists commonly carry.
+func NormalizeAPIKey(apiKey string) string {
+	apiKey = strings.TrimSpace(apiKey)
+	apiKey = strings.TrimRight(apiKey, ",;")
+	apiKey = strings.Trim(apiKey, "\"'` \t")
+	return apiKey
+}
+
+// IsValidAPIKeyFormat reports whether apiKey looks like an AI Studio key.
+func IsValidAPIKeyFormat(apiKey string) bool {
+	return apiKeyPattern.MatchString(apiKey)
+}
+
 // MaskAPIKey returns a masked version of an API key for display.
 // Example: "AIzaSyABC123xyz" -> "AIzaSy...xyz"
 func MaskAPIKey(apiKey string) string {
@@ -253,6 +797,9 @@ func (k *Key) IsAvailable() bool {
 	if !k.Enabled || k.Status == KeyStatusDisabled {
 		return false
 	}
+	if !k.InSchedule(time.Now()) {
+		return false
+	}
 	if k.Status == KeyStatusRateLimited {
 		if k.CooldownUntil != nil && time.Now().Before(*k.CooldownUntil) {
 			return false
@@ -282,6 +829,30 @@ func (k *Key) ResetCooldown() bool {
 	return false
 }
 
+// Clone returns a copy of the key that shares no maps or slices with it, so
+// the copy can be read while the original keeps changing under the pool lock.
+// Time pointers are shared; they are replaced, never written through.
+func (k *Key) Clone() *Key {
+	c := *k
+	c.Tags = slices.Clone(k.Tags)
+	c.Stats.ModelUsage = maps.Clone(k.Stats.ModelUsage)
+	c.AllowedModels = slices.Clone(k.AllowedModels)
+	c.DeniedModels = slices.Clone(k.DeniedModels)
+	c.DetectedModels = slices.Clone(k.DetectedModels)
+	c.ModelCooldowns = maps.Clone(k.ModelCooldowns)
+	c.Groups = slices.Clone(k.Groups)
+	c.ActiveWindows = slices.Clone(k.ActiveWindows)
+	return &c
+}
+
+// Redacted returns a copy of the key for API responses, with the password of
+// its proxy URL masked. The API key itself is never serialized.
+func (k *Key) Redacted() *Key {
+	c := k.Clone()
+	c.Proxy = RedactProxyURL(c.Proxy)
+	return c
+}
+
 // IncrementStats updates the key's statistics after a request.
 // model: the actual model used in this request (for usage tracking)
 func (k *Key) IncrementStats(success bool, promptTokens, completionTokens int, model string) {
@@ -306,6 +877,75 @@ func (k *Key) IncrementStats(success bool, promptTokens, completionTokens int, m
 	}
 }
 
+// SupportsModel reports whether the key's allow/deny patterns and detected
+// model list permit the given model. An empty model is always supported.
+func (k *Key) SupportsModel(model string) bool {
+	model = NormalizeModelName(model)
+	if model == "" {
+		return true
+	}
+
+	for _, pattern := range k.DeniedModels {
+		if MatchModelPattern(pattern, model) {
+			return false
+		}
+	}
+
+	if len(k.AllowedModels) > 0 {
+		allowed := false
+		for _, pattern := range k.AllowedModels {
+			if MatchModelPattern(pattern, model) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	if len(k.DetectedModels) > 0 {
+		for _, detected := range k.DetectedModels {
+			if NormalizeModelName(detected) == model {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// IsModelCoolingDown reports whether the key-model pair is temporarily
+// ineligible after an upstream NotFound/Permission error.
+func (k *Key) IsModelCoolingDown(model string, now time.Time) bool {
+	until, ok := k.ModelCooldowns[NormalizeModelName(model)]
+	return ok && now.Before(until)
+}
+
+// SetModelCooldown marks the key-model pair ineligible until the given time.
+func (k *Key) SetModelCooldown(model string, until time.Time) {
+	if k.ModelCooldowns == nil {
+		k.ModelCooldowns = make(map[string]time.Time)
+	}
+	k.ModelCooldowns[NormalizeModelName(model)] = until
+}
+
+// NormalizeModelName strips the "models/" resource prefix used by the Gemini API.
+func NormalizeModelName(model string) string {
+	return strings.TrimPrefix(strings.TrimSpace(model), "models/")
+}
+
+// MatchModelPattern reports whether a model name matches a glob pattern.
+// Malformed patterns only match the model name literally.
+func MatchModelPattern(pattern, model string) bool {
+	pattern = NormalizeModelName(pattern)
+	if matched, err := path.Match(pattern, model); err == nil {
+		return matched
+	}
+	return pattern == model
+}
+
 // TotalTokens returns the total token consumption for this key.
 func (s *KeyStats) TotalTokens() int64 {
 	return s.PromptTokens + s.CompletionTokens
@@ -325,6 +965,7 @@ func (s *KeyStats) SuccessRate() float64 {
 type ValidateKeyRequest struct {
 	Key      string `json:"key" binding:"required"`
 	Provider string `json:"provider,omitempty"` // 默认 "google_aistudio"
+	Proxy    string `json:"proxy,omitempty"`    // Probe through this proxy instead of the global one
 }
 
 // ValidateKeyResponse represents the response for POST /api/keys/validate.
@@ -335,8 +976,9 @@ type ValidateKeyResponse struct {
 
 // ValidateKeyResult contains the validation result.
 type ValidateKeyResult struct {
-	Valid     bool     `json:"valid"`
-	LatencyMs int64    `json:"latency_ms"`
-	Models    []string `json:"models"`
-	Error     string   `json:"error,omitempty"`
+	Valid     bool      `json:"valid"`
+	Health    KeyHealth `json:"health"`
+	LatencyMs int64     `json:"latency_ms"`
+	Models    []string  `json:"models"`
+	Error     string    `json:"error,omitempty"`
 }
@@ -0,0 +1,197 @@
+﻿package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ==================== Key Lifecycle ====================
+
+// weekdayNames maps the day names accepted in ActiveWindow.Days.
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ActiveWindow is a recurring time-of-day window in which a key may serve
+// traffic, e.g. {"days": ["sat", "sun"], "start": "00:00", "end": "00:00"}
+// for weekends or {"start": "22:00", "end": "06:00"} for nights.
+type ActiveWindow struct {
+	Days     []string `json:"days,omitempty"`     // "mon".."sun"; empty means every day
+	Start    string   `json:"start"`              // "HH:MM"
+	End      string   `json:"end"`                // "HH:MM"; before Start wraps past midnight, equal to Start covers the whole day
+	Timezone string   `json:"timezone,omitempty"` // IANA name; empty uses the server's local time
+}
+
+// Validate returns an error message if the window is malformed, or "".
+func (w ActiveWindow) Validate() string {
+	if _, ok := parseClock(w.Start); !ok {
+		return fmt.Sprintf("active window start must be HH:MM, got %q", w.Start)
+	}
+	if _, ok := parseClock(w.End); !ok {
+		return fmt.Sprintf("active window end must be HH:MM, got %q", w.End)
+	}
+	for _, day := range w.Days {
+		if _, ok := weekdayNames[strings.ToLower(day)]; !ok {
+			return fmt.Sprintf("active window day must be one of mon..sun, got %q", day)
+		}
+	}
+	if w.Timezone != "" {
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Sprintf("active window timezone is invalid: %s", w.Timezone)
+		}
+	}
+	return ""
+}
+
+// Contains reports whether t falls inside the window. The part of a window
+// that wraps past midnight belongs to the day the window started.
+// A malformed window contains nothing.
+func (w ActiveWindow) Contains(t time.Time) bool {
+	start, ok1 := parseClock(w.Start)
+	end, ok2 := parseClock(w.End)
+	if !ok1 || !ok2 {
+		return false
+	}
+	if w.Timezone != "" {
+		loc, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			return false
+		}
+		t = t.In(loc)
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+	switch {
+	case start == end:
+		return w.onDay(today)
+	case start < end:
+		return w.onDay(today) && minute >= start && minute < end
+	default:
+		return (minute >= start && w.onDay(today)) || (minute < end && w.onDay(yesterday))
+	}
+}
+
+// onDay reports whether the window applies to the given weekday.
+func (w ActiveWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(w.Days, func(name string) bool {
+		d, ok := weekdayNames[strings.ToLower(name)]
+		return ok && d == day
+	})
+}
+
+// parseClock parses "HH:MM" into minutes since midnight.
+func parseClock(s string) (int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// ValidateKeySchedule returns an error message if the schedule fields of a
+// key are inconsistent or malformed, or "".
+func ValidateKeySchedule(notBefore, expiresAt *time.Time, windows []ActiveWindow) string {
+	if notBefore != nil && expiresAt != nil && !notBefore.Before(*expiresAt) {
+		return "not_before must be before expires_at"
+	}
+	for _, w := range windows {
+		if msg := w.Validate(); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// IsExpired reports whether the key's ExpiresAt has passed at now.
+func (k *Key) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// InSchedule reports whether the key may serve traffic at now: after
+// NotBefore, before ExpiresAt and inside one of its ActiveWindows, if any.
+func (k *Key) InSchedule(now time.Time) bool {
+	if k.NotBefore != nil && now.Before(*k.NotBefore) {
+		return false
+	}
+	if k.IsExpired(now) {
+		return false
+	}
+	if len(k.ActiveWindows) == 0 {
+		return true
+	}
+	for _, w := range k.ActiveWindows {
+		if w.Contains(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// OptionalTime is a time field of a PATCH request that tells "absent" apart
+// from null: Set is true when the field was present, and Time is nil when it
+// was null (clearing the value).
+type OptionalTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (o *OptionalTime) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if bytes.Equal(data, []byte("null")) {
+		o.Time = nil
+		return nil
+	}
+	var t time.Time
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	o.Time = &t
+	return nil
+}
+
+// KeyEventType identifies a key lifecycle event.
+type KeyEventType string
+
+const (
+	// KeyEventExpired is emitted when an expired key is retired.
+	KeyEventExpired KeyEventType = "key_expired"
+)
+
+// KeyEvent notifies about a change the pool made to a key on its own.
+type KeyEvent struct {
+	Type      KeyEventType `json:"type"`
+	KeyID     string       `json:"key_id"`
+	MaskedKey string       `json:"key"`
+	Name      string       `json:"name"`
+	Message   string       `json:"message"`
+	Time      time.Time    `json:"time"`
+}
+
+// KeyExpiration describes a key that has expired or expires soon,
+// for GET /api/keys/expiring.
+type KeyExpiration struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	MaskedKey string    `json:"key"`
+	Enabled   bool      `json:"enabled"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Expired   bool      `json:"expired"`
+	// Seconds until expiry; negative once expired
+	RemainingSeconds int64 `json:"remaining_seconds"`
+}
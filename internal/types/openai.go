@@ -14,11 +14,37 @@ type ChatCompletionRequest struct {
 	TopP             *float64       `json:"top_p,omitempty"`
 	MaxTokens        *int           `json:"max_tokens,omitempty"`
 	Stream           bool           `json:"stream,omitempty"`
+	StreamOptions    *StreamOptions `json:"stream_options,omitempty"`
 	Stop             StopSequence   `json:"stop,omitempty"`
 	PresencePenalty  *float64       `json:"presence_penalty,omitempty"`
 	FrequencyPenalty *float64       `json:"frequency_penalty,omitempty"`
 	N                *int           `json:"n,omitempty"`
 	User             string         `json:"user,omitempty"`
+
+	// MaxCompletionTokens supersedes MaxTokens. Logprobs requests the log
+	// probability of each output token, TopLogprobs (0-20, needs Logprobs)
+	// that of the most likely alternatives at each position.
+	MaxCompletionTokens *int  `json:"max_completion_tokens,omitempty"`
+	Seed                *int  `json:"seed,omitempty"`
+	Logprobs            *bool `json:"logprobs,omitempty"`
+	TopLogprobs         *int  `json:"top_logprobs,omitempty"`
+
+	// ReasoningEffort ("none", "minimal", "low", "medium" or "high") and
+	// ThinkingBudget (tokens, 0 disables thinking) set the thinking of Gemini
+	// 2.5+ models for this request; at most one may be given.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	ThinkingBudget  *int   `json:"thinking_budget,omitempty"`
+
+	// SafetySettings overrides the configured Gemini safety preset and
+	// thresholds for this request.
+	SafetySettings *SafetyOverride `json:"safety_settings,omitempty"`
+}
+
+// StreamOptions holds options that only apply when Stream is true.
+type StreamOptions struct {
+	// IncludeUsage adds a final chunk with empty choices and the token usage
+	// of the whole request.
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // Message represents a single message in the conversation.
@@ -29,8 +55,8 @@ type Message struct {
 
 // ContentPart represents a multimodal content part (text or image).
 type ContentPart struct {
-	Type     string    `json:"type"`               // "text" or "image_url"
-	Text     string    `json:"text,omitempty"`     // for type="text"
+	Type     string    `json:"type"`                // "text" or "image_url"
+	Text     string    `json:"text,omitempty"`      // for type="text"
 	ImageURL *ImageURL `json:"image_url,omitempty"` // for type="image_url"
 }
 
@@ -83,46 +109,96 @@ type ChatCompletionResponse struct {
 
 // Choice represents a single completion choice.
 type Choice struct {
-	Index        int              `json:"index"`
-	Message      ResponseMessage  `json:"message"`
-	FinishReason string           `json:"finish_reason"` // "stop", "length", "content_filter"
+	Index        int             `json:"index"`
+	Message      ResponseMessage `json:"message"`
+	FinishReason string          `json:"finish_reason"` // "stop", "length", "content_filter"
+
+	// SafetyRatings explain a "content_filter" finish.
+	SafetyRatings []GeminiSafetyRating `json:"safety_ratings,omitempty"`
+
+	// Logprobs is set when the request asked for logprobs.
+	Logprobs *ChoiceLogprobs `json:"logprobs,omitempty"`
+}
+
+// ChoiceLogprobs holds the log probabilities of a choice's output tokens.
+type ChoiceLogprobs struct {
+	Content []TokenLogprob `json:"content"`
+}
+
+// TokenLogprob is the log probability of one output token, with the most
+// likely alternatives at its position when top_logprobs was requested.
+type TokenLogprob struct {
+	Token       string       `json:"token"`
+	Logprob     float64      `json:"logprob"`
+	Bytes       []int        `json:"bytes"`
+	TopLogprobs []TopLogprob `json:"top_logprobs"`
+}
+
+// TopLogprob is one of the most likely tokens at a position.
+type TopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
 }
 
 // ResponseMessage represents the assistant's response message.
 type ResponseMessage struct {
-	Role    string `json:"role"`    // Always "assistant"
-	Content string `json:"content"`
+	Role             string `json:"role"` // Always "assistant"
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content,omitempty"` // Thought summaries, kept out of Content
 }
 
 // Usage represents token consumption statistics.
 type Usage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens        int                  `json:"prompt_tokens"`
+	CompletionTokens    int                  `json:"completion_tokens"`
+	TotalTokens         int                  `json:"total_tokens"`
+	PromptTokensDetails *PromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
+}
+
+// PromptTokensDetails breaks down prompt token usage.
+type PromptTokensDetails struct {
+	CachedTokens int `json:"cached_tokens"` // Prompt tokens served from Gemini's context cache
+}
+
+// CompletionTokensDetails breaks down completion token usage.
+type CompletionTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens"` // Gemini thinking tokens, included in CompletionTokens
 }
 
 // ==================== Chat Completion Response (Streaming) ====================
 
 // ChatCompletionChunk represents a single SSE chunk in streaming response.
+// All chunks of one stream share the same ID and Created.
 type ChatCompletionChunk struct {
 	ID      string        `json:"id"`
 	Object  string        `json:"object"`  // "chat.completion.chunk"
 	Created int64         `json:"created"` // Unix timestamp
 	Model   string        `json:"model"`
 	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage,omitempty"` // Only on the final chunk with stream_options.include_usage
 }
 
 // ChunkChoice represents a single choice in a streaming chunk.
 type ChunkChoice struct {
 	Index        int    `json:"index"`
 	Delta        Delta  `json:"delta"`
-	FinishReason string `json:"finish_reason,omitempty"` // null or "stop", "length"
+	FinishReason string `json:"finish_reason,omitempty"` // null or "stop", "length", "content_filter"
+
+	// SafetyRatings explain a "content_filter" finish.
+	SafetyRatings []GeminiSafetyRating `json:"safety_ratings,omitempty"`
+
+	// Logprobs covers the tokens of this chunk when logprobs were requested.
+	Logprobs *ChoiceLogprobs `json:"logprobs,omitempty"`
 }
 
 // Delta represents incremental content in a streaming chunk.
 type Delta struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
+	Role             string `json:"role,omitempty"`
+	Content          string `json:"content,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
 }
 
 // ==================== Models Endpoint ====================
@@ -136,8 +212,8 @@ type ModelsResponse struct {
 // ModelInfo represents information about a single model.
 type ModelInfo struct {
 	ID      string `json:"id"`
-	Object  string `json:"object"`  // "model"
-	Created int64  `json:"created"` // Unix timestamp
+	Object  string `json:"object"`   // "model"
+	Created int64  `json:"created"`  // Unix timestamp
 	OwnedBy string `json:"owned_by"` // "google" for Gemini models
 }
 
@@ -149,6 +225,8 @@ type HealthResponse struct {
 	Version string         `json:"version"`
 	Uptime  int64          `json:"uptime"` // Seconds since start
 	Keys    KeyHealthStats `json:"keys"`
+
+	Groups []KeyGroupStatus `json:"groups,omitempty"`
 }
 
 // KeyHealthStats provides a summary of key pool status.
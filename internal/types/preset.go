@@ -0,0 +1,43 @@
+﻿// Package types defines all data transfer objects and core types for MuxueTools.
+package types
+
+import "time"
+
+// ==================== Model Preset Types ====================
+
+// ModelPreset bundles a target model with model settings under a name. It
+// is exposed as virtual models: the bare name ("coder") sends requests to
+// Model, and "<model>:<name>" applies the preset to any other model.
+type ModelPreset struct {
+	ID          string              `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Name        string              `json:"name" gorm:"type:varchar(100);uniqueIndex"`
+	Model       string              `json:"model" gorm:"type:varchar(100)"`
+	Description string              `json:"description,omitempty" gorm:"type:varchar(255)"`
+	Settings    ModelSettingsConfig `json:"settings" gorm:"serializer:json"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// TableName specifies the table name for ModelPreset.
+func (ModelPreset) TableName() string {
+	return "model_presets"
+}
+
+// ==================== Model Preset API DTOs ====================
+
+// CreateModelPresetRequest represents the request body for POST /api/model-presets.
+type CreateModelPresetRequest struct {
+	Name        string              `json:"name" binding:"required"`
+	Model       string              `json:"model" binding:"required"`
+	Description string              `json:"description"`
+	Settings    ModelSettingsConfig `json:"settings"`
+}
+
+// UpdateModelPresetRequest represents the request body for PUT /api/model-presets/:id.
+// Settings, when given, replaces the preset's settings.
+type UpdateModelPresetRequest struct {
+	Name        *string              `json:"name,omitempty"`
+	Model       *string              `json:"model,omitempty"`
+	Description *string              `json:"description,omitempty"`
+	Settings    *ModelSettingsConfig `json:"settings,omitempty"`
+}
@@ -0,0 +1,39 @@
+﻿// Package types defines all data transfer objects and core types for MuxueTools.
+package types
+
+import "time"
+
+// ==================== Model Profile Types ====================
+
+// ModelProfile holds model settings for the models matching Pattern. They
+// are layered over the global model settings and under the request.
+type ModelProfile struct {
+	ID        string              `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Name      string              `json:"name" gorm:"type:varchar(100)"`
+	Pattern   string              `json:"pattern" gorm:"type:varchar(100);uniqueIndex"` // Model name or glob, e.g. "gemini-2.5-flash*"
+	Settings  ModelSettingsConfig `json:"settings" gorm:"serializer:json"`
+	CreatedAt time.Time           `json:"created_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// TableName specifies the table name for ModelProfile.
+func (ModelProfile) TableName() string {
+	return "model_profiles"
+}
+
+// ==================== Model Profile API DTOs ====================
+
+// CreateModelProfileRequest represents the request body for POST /api/model-profiles.
+type CreateModelProfileRequest struct {
+	Name     string              `json:"name"`
+	Pattern  string              `json:"pattern" binding:"required"`
+	Settings ModelSettingsConfig `json:"settings"`
+}
+
+// UpdateModelProfileRequest represents the request body for PUT /api/model-profiles/:id.
+// Settings, when given, replaces the profile's settings.
+type UpdateModelProfileRequest struct {
+	Name     *string              `json:"name,omitempty"`
+	Pattern  *string              `json:"pattern,omitempty"`
+	Settings *ModelSettingsConfig `json:"settings,omitempty"`
+}
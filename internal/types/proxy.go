@@ -0,0 +1,61 @@
+﻿package types
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+// ==================== Upstream Proxy ====================
+
+// ProxyDirect as a key or group proxy connects to the upstream directly,
+// bypassing the global proxy.
+const ProxyDirect = "direct"
+
+// ValidateProxyURL returns an error message if proxy is not empty, ProxyDirect
+// or an http, https, socks5 or socks5h URL with a host, or "".
+func ValidateProxyURL(proxy string) string {
+	if proxy == "" || proxy == ProxyDirect {
+		return ""
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return fmt.Sprintf("invalid proxy URL: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Sprintf("proxy scheme must be http, https, socks5 or socks5h, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return "proxy URL must include a host"
+	}
+	return ""
+}
+
+// RedactProxyURL masks the password of a proxy URL for display.
+func RedactProxyURL(proxy string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return proxy
+	}
+	return u.Redacted()
+}
+
+// keyProxyContextKey is the context key for the upstream proxy of a key.
+type keyProxyContextKey struct{}
+
+// WithKeyProxy returns a context whose upstream requests go through the given
+// key or group proxy instead of the global proxy.
+func WithKeyProxy(ctx context.Context, proxy string) context.Context {
+	if proxy == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, keyProxyContextKey{}, proxy)
+}
+
+// KeyProxyFromContext returns the proxy stored by WithKeyProxy, or "".
+func KeyProxyFromContext(ctx context.Context) string {
+	proxy, _ := ctx.Value(keyProxyContextKey{}).(string)
+	return proxy
+}
@@ -1,5 +1,5 @@
 import apiClient from './client'
-import type { ApiResponse } from './types'
+import type { ApiResponse, SafetyOverride, SafetyPreset, SafetyThreshold } from './types'
 
 export interface ModelSettingsConfig {
     system_prompt: string;
@@ -10,6 +10,9 @@ export interface ModelSettingsConfig {
     thinking_level?: 'LOW' | 'MEDIUM' | 'HIGH' | null;
     media_resolution?: 'MEDIA_RESOLUTION_LOW' | 'MEDIA_RESOLUTION_MEDIUM' | 'MEDIA_RESOLUTION_HIGH' | null;
     stream_output?: boolean;  // 是否启用流式输出，默认 true
+    safety_preset?: SafetyPreset | null;
+    safety_thresholds?: Record<string, SafetyThreshold> | null;
+    model_safety?: Record<string, SafetyOverride> | null;
 }
 
 export interface ConfigInfo {
@@ -19,9 +22,10 @@ export interface ConfigInfo {
         host: string;
     };
     pool: {
-        strategy: 'round_robin' | 'random' | 'least_used' | 'weighted';
+        strategy: 'round_robin' | 'random' | 'least_used' | 'weighted' | 'latency_aware';
         cooldown_seconds: number;
         max_retries: number;
+        stats_window?: '5m' | '1h' | '24h' | 'lifetime';
     };
     logging: {
         level: 'debug' | 'info' | 'warn' | 'error';
@@ -38,6 +42,9 @@ export interface ConfigInfo {
     };
     advanced?: {
         request_timeout: number;  // seconds
+        stream_first_byte_timeout?: number;  // seconds, 0 disables
+        stream_idle_timeout?: number;  // seconds, 0 disables
+        parameter_mode?: 'clamp' | 'strict';
     };
     model_settings?: ModelSettingsConfig;
 }
@@ -1,5 +1,5 @@
 import apiClient from './client'
-import type { KeyInfo, ApiResponse, ListResponse, KeyImportItem } from './types'
+import type { KeyInfo, ApiResponse, ListResponse, KeyImportItem, KeyUpdatePayload, BulkKeyResult, KeyTestReport, KeyHealth, ImportKeysResult, KeyBundle, KeyReconcileReport, KeyExpiration, KeySelectionExplanation } from './types'
 
 /**
  * Validation result returned from /api/keys/validate
@@ -7,6 +7,8 @@ import type { KeyInfo, ApiResponse, ListResponse, KeyImportItem } from './types'
 export interface ValidateKeyResult {
     /** Whether the key is valid */
     valid: boolean;
+    /** Probe classification */
+    health: KeyHealth;
     /** API latency in milliseconds */
     latency_ms: number;
     /** List of available models (empty if invalid) */
@@ -19,7 +21,7 @@ export interface ValidateKeyResult {
  * Validate an API key and fetch available models
  * @param data - Key and optional provider
  */
-export const validateKey = async (data: { key: string; provider?: string }) =>
+export const validateKey = async (data: { key: string; provider?: string; proxy?: string }) =>
     (await apiClient.post<ApiResponse<ValidateKeyResult>>('/api/keys/validate', data)) as unknown as ApiResponse<ValidateKeyResult>
 
 /**
@@ -31,22 +33,69 @@ export const getKeys = async () => (await apiClient.get<ListResponse<KeyInfo>>('
  * Create a new API key
  * @param data - Key creation payload with optional provider and default_model
  */
-export const addKey = async (data: { key: string; name?: string; tags?: string[]; provider?: string; default_model?: string }) =>
+export const addKey = async (data: { key: string; name?: string; tags?: string[]; provider?: string; default_model?: string; proxy?: string }) =>
     (await apiClient.post<ApiResponse<KeyInfo>>('/api/keys', data)) as unknown as ApiResponse<KeyInfo>
 
 export const deleteKey = async (id: string) =>
     (await apiClient.delete<ApiResponse<void>>(`/api/keys/${id}`)) as unknown as ApiResponse<void>
 
-export const updateKey = async (id: string, data: { name?: string; tags?: string[]; enabled?: boolean }) =>
-    (await apiClient.put<ApiResponse<KeyInfo>>(`/api/keys/${id}`, data)) as unknown as ApiResponse<KeyInfo>
+/**
+ * Update a key in place; omitted fields are left unchanged
+ */
+export const updateKey = async (id: string, data: KeyUpdatePayload) =>
+    (await apiClient.patch<ApiResponse<KeyInfo>>(`/api/keys/${id}`, data)) as unknown as ApiResponse<KeyInfo>
+
+/**
+ * Enable, disable or delete keys selected by ID list and/or tag
+ */
+export const bulkUpdateKeys = async (data: { action: 'enable' | 'disable' | 'delete'; ids?: string[]; tag?: string }) =>
+    (await apiClient.post<ApiResponse<BulkKeyResult>>('/api/keys/bulk', data)) as unknown as ApiResponse<BulkKeyResult>
+
+/**
+ * Probe keys concurrently and return a health report; omit ids and tag to test every key
+ */
+export const bulkTestKeys = async (data: { ids?: string[]; tag?: string; concurrency?: number; disable_invalid?: boolean } = {}) =>
+    (await apiClient.post<ApiResponse<KeyTestReport>>('/api/keys/test', data)) as unknown as ApiResponse<KeyTestReport>
 
 export const testKey = async (id: string) =>
     (await apiClient.post<ApiResponse<{ valid: boolean; latency_ms: number }>>(`/api/keys/${id}/test`)) as unknown as ApiResponse<{ valid: boolean; latency_ms: number }>
 
 // ... (existing code)
 
-export const importKeys = async (data: { keys: KeyImportItem[] }) =>
-    (await apiClient.post<ApiResponse<{ imported: number; skipped: number; errors: string[] }>>('/api/keys/import', data)) as unknown as ApiResponse<{ imported: number; skipped: number; errors: string[] }>
+export const importKeys = async (data: {
+    keys?: KeyImportItem[];
+    bundle?: KeyBundle;
+    passphrase?: string;
+    on_duplicate?: 'skip' | 'merge' | 'overwrite';
+    validate?: boolean;
+    keep_invalid?: boolean;
+    concurrency?: number
+}) =>
+    (await apiClient.post<ApiResponse<ImportKeysResult>>('/api/keys/import', data)) as unknown as ApiResponse<ImportKeysResult>
 
 export const exportKeys = async () =>
     (await apiClient.get('/api/keys/export', { responseType: 'blob' })) as unknown as Blob
+
+/**
+ * Export a passphrase-encrypted backup of the keys; requires the proxy key
+ */
+export const exportKeyBundle = async (proxyKey: string, data: { passphrase: string; ids?: string[]; tag?: string }) =>
+    (await apiClient.post('/api/keys/export', data, { headers: { Authorization: `Bearer ${proxyKey}` } })) as unknown as KeyBundle
+
+/**
+ * List keys that have expired or expire within the given number of days
+ */
+export const getExpiringKeys = async (days = 7) =>
+    (await apiClient.get<ApiResponse<KeyExpiration[]>>('/api/keys/expiring', { params: { days } })) as unknown as ApiResponse<KeyExpiration[]>
+
+/**
+ * Dry-run key selection: why each key would or would not serve the model, and which key is next
+ */
+export const explainKeySelection = async (params: { model?: string; group?: string } = {}) =>
+    (await apiClient.get<ApiResponse<KeySelectionExplanation>>('/api/keys/explain', { params })) as unknown as ApiResponse<KeySelectionExplanation>
+
+/**
+ * Reconcile config file keys, the database and the pool; dryRun only reports the changes
+ */
+export const reconcileKeys = async (params: { policy?: 'database' | 'config'; dry_run?: boolean } = {}) =>
+    (await apiClient.post<ApiResponse<KeyReconcileReport>>('/api/keys/reconcile', null, { params })) as unknown as ApiResponse<KeyReconcileReport>
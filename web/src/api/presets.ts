@@ -0,0 +1,68 @@
+/**
+ * Model Preset API - 模型参数预设接口封装
+ *
+ * 预设以虚拟模型（name 或 model:name）的形式出现在 /v1/models 中
+ */
+
+import apiClient from './client'
+import type { ApiResponse } from './types'
+import type { ModelSettingsConfig } from './config'
+
+export interface ModelPreset {
+    id: string;
+    /** 预设名，即虚拟模型名 */
+    name: string;
+    /** 目标模型 */
+    model: string;
+    description?: string;
+    settings: ModelSettingsConfig;
+    created_at: string;
+    updated_at: string;
+}
+
+export interface CreateModelPresetRequest {
+    name: string;
+    model: string;
+    description?: string;
+    settings?: ModelSettingsConfig;
+}
+
+export interface UpdateModelPresetRequest {
+    name?: string;
+    model?: string;
+    description?: string;
+    /** 提供时整体替换原有设置 */
+    settings?: ModelSettingsConfig;
+}
+
+/**
+ * 获取预设列表
+ */
+export async function getModelPresets(): Promise<ApiResponse<ModelPreset[]>> {
+    return apiClient.get('/api/model-presets')
+}
+
+/**
+ * 创建预设
+ * @param data - 预设参数
+ */
+export async function createModelPreset(data: CreateModelPresetRequest): Promise<ApiResponse<ModelPreset>> {
+    return apiClient.post('/api/model-presets', data)
+}
+
+/**
+ * 更新预设
+ * @param id - 预设 ID
+ * @param data - 更新数据
+ */
+export async function updateModelPreset(id: string, data: UpdateModelPresetRequest): Promise<ApiResponse<ModelPreset>> {
+    return apiClient.put(`/api/model-presets/${id}`, data)
+}
+
+/**
+ * 删除预设
+ * @param id - 预设 ID
+ */
+export async function deleteModelPreset(id: string): Promise<ApiResponse<null>> {
+    return apiClient.delete(`/api/model-presets/${id}`)
+}
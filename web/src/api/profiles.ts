@@ -0,0 +1,64 @@
+/**
+ * Model Profile API - 模型配置档案接口封装
+ *
+ * 为匹配的模型单独设置采样参数、思考等级和安全设置
+ */
+
+import apiClient from './client'
+import type { ApiResponse } from './types'
+import type { ModelSettingsConfig } from './config'
+
+export interface ModelProfile {
+    id: string;
+    name: string;
+    /** 模型名或通配符，如 gemini-2.5-flash* */
+    pattern: string;
+    settings: ModelSettingsConfig;
+    created_at: string;
+    updated_at: string;
+}
+
+export interface CreateModelProfileRequest {
+    name?: string;
+    pattern: string;
+    settings?: ModelSettingsConfig;
+}
+
+export interface UpdateModelProfileRequest {
+    name?: string;
+    pattern?: string;
+    /** 提供时整体替换原有设置 */
+    settings?: ModelSettingsConfig;
+}
+
+/**
+ * 获取配置档案列表
+ */
+export async function getModelProfiles(): Promise<ApiResponse<ModelProfile[]>> {
+    return apiClient.get('/api/model-profiles')
+}
+
+/**
+ * 创建配置档案
+ * @param data - 档案参数
+ */
+export async function createModelProfile(data: CreateModelProfileRequest): Promise<ApiResponse<ModelProfile>> {
+    return apiClient.post('/api/model-profiles', data)
+}
+
+/**
+ * 更新配置档案
+ * @param id - 档案 ID
+ * @param data - 更新数据
+ */
+export async function updateModelProfile(id: string, data: UpdateModelProfileRequest): Promise<ApiResponse<ModelProfile>> {
+    return apiClient.put(`/api/model-profiles/${id}`, data)
+}
+
+/**
+ * 删除配置档案
+ * @param id - 档案 ID
+ */
+export async function deleteModelProfile(id: string): Promise<ApiResponse<null>> {
+    return apiClient.delete(`/api/model-profiles/${id}`)
+}
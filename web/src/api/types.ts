@@ -22,19 +22,194 @@ export interface KeyInfo {
     enabled: boolean;
     tags: string[];
     stats: KeyStats;
+    /** When the key leaves cooldown and is retried */
     cooldown_until: string | null;
+    /** Consecutive cooldowns since the last successful request */
+    cooldown_streak?: number;
+    /** Upstream proxy override ('direct' bypasses proxies); the password is masked */
+    proxy?: string;
     created_at: string;
     updated_at: string;
     /** Provider identifier (e.g., 'google_aistudio') */
     provider: string;
     /** Default model name for this key */
     default_model?: string;
+    /** Multiplier for the weighted strategy; 0 counts as 1 */
+    weight: number;
+    /** Higher-priority available keys are always chosen first */
+    priority: number;
+    limits: KeyLimits;
+    /** Result of the last probe */
+    health?: KeyHealth;
+    last_checked_at?: string;
+    /** Why the key was disabled automatically */
+    disabled_reason?: string;
+    /** Where the key was defined: the config file or the admin API */
+    source?: 'config' | 'api';
+    /** The key serves traffic only from not_before until expires_at */
+    not_before?: string;
+    expires_at?: string;
+    /** Weekly windows in which the key serves traffic; empty means always */
+    active_windows?: ActiveWindow[];
+}
+
+export interface ActiveWindow {
+    /** "mon".."sun"; empty means every day */
+    days?: string[];
+    /** "HH:MM"; an end before start wraps past midnight */
+    start: string;
+    end: string;
+    /** IANA name; empty uses the server's local time */
+    timezone?: string;
+}
+
+export type KeyIneligibleReason =
+    | 'disabled'
+    | 'not_in_group'
+    | 'model_not_allowed'
+    | 'model_cooling_down'
+    | 'cooling_down'
+    | 'not_yet_active'
+    | 'expired'
+    | 'outside_window'
+    | 'saturated'
+    | 'over_budget'
+    | 'lower_priority'
+
+export interface KeySelectionExplanation {
+    model?: string;
+    group?: string;
+    /** Groups tried, following fallbacks */
+    groups?: string[];
+    strategy: string;
+    /** Key the strategy would choose next; absent for the random strategy */
+    next_key_id?: string;
+    next_key?: string;
+    /** Error the request would fail with when no key is eligible */
+    error?: string;
+    keys: {
+        key_id: string;
+        name: string;
+        key: string;
+        group?: string;
+        priority: number;
+        eligible: boolean;
+        reasons?: { reason: KeyIneligibleReason; detail?: string; until?: string }[];
+    }[];
+}
+
+export interface KeyExpiration {
+    id: string;
+    name: string;
+    key: string;
+    enabled: boolean;
+    expires_at: string;
+    expired: boolean;
+    remaining_seconds: number;
+}
+
+export type KeyHealth = 'valid' | 'invalid' | 'quota_exhausted' | 'region_blocked' | 'network_error'
+
+export interface KeyTestReportItem {
+    key_id: string;
+    name: string;
+    key: string;
+    health: KeyHealth;
+    latency_ms: number;
+    models?: string[];
+    error?: string;
+}
+
+export interface KeyTestReport {
+    total: number;
+    summary: Partial<Record<KeyHealth, number>>;
+    duration_ms: number;
+    results: KeyTestReportItem[];
+}
+
+/** Per-key traffic caps; omitted or 0 means unlimited */
+export interface KeyLimits {
+    rpm?: number;
+    daily_requests?: number;
+    max_concurrent?: number;
+}
+
+export interface KeyUpdatePayload {
+    name?: string;
+    tags?: string[];
+    enabled?: boolean;
+    provider?: string;
+    default_model?: string;
+    weight?: number;
+    priority?: number;
+    limits?: KeyLimits;
+    /** null clears the value */
+    not_before?: string | null;
+    expires_at?: string | null;
+    /** [] clears the windows */
+    active_windows?: ActiveWindow[];
+    /** '' inherits the group or global proxy */
+    proxy?: string;
+}
+
+export interface BulkKeyResult {
+    action: string;
+    matched: number;
+    affected: number;
+    errors: string[];
 }
 
 export interface KeyImportItem {
     key: string;
     name?: string;
     tags?: string[];
+    enabled?: boolean;
+}
+
+/** Encrypted key backup written by POST /api/keys/export */
+export interface KeyBundle {
+    format: 'muxuetools-key-bundle';
+    version: number;
+    created_at: string;
+    key_count: number;
+    kdf: string;
+    iterations: number;
+    salt: string;
+    ciphertext: string;
+}
+
+export interface ImportKeyLineResult {
+    line: number;
+    key: string;
+    status: 'imported' | 'disabled' | 'duplicate' | 'updated' | 'invalid_format' | 'rejected' | 'error';
+    key_id?: string;
+    health?: KeyHealth;
+    error?: string;
+}
+
+export interface ImportKeysResult {
+    imported: number;
+    skipped: number;
+    updated: number;
+    disabled: number;
+    rejected: number;
+    errors: string[];
+    results: ImportKeyLineResult[];
+}
+
+export type KeyReconcileAction = 'created' | 'updated' | 'adopted' | 'removed' | 'loaded' | 'persisted'
+
+export interface KeyReconcileReport {
+    policy: 'database' | 'config';
+    dry_run: boolean;
+    summary: Partial<Record<KeyReconcileAction, number>>;
+    changes: {
+        action: KeyReconcileAction;
+        key_id: string;
+        key: string;
+        fields?: string[];
+        error?: string;
+    }[];
 }
 
 export interface Session {
@@ -116,6 +291,18 @@ export interface ChatCompletionMessageMultimodal {
     content: string | ContentPart[];
 }
 
+export type SafetyPreset = 'default' | 'relaxed' | 'block_none';
+
+export type SafetyThreshold = 'BLOCK_NONE' | 'BLOCK_LOW_AND_ABOVE' | 'BLOCK_MEDIUM_AND_ABOVE' | 'BLOCK_ONLY_HIGH';
+
+/**
+ * 安全预设与按类别阈值，用于按模型或按请求覆盖
+ */
+export interface SafetyOverride {
+    preset?: SafetyPreset;
+    thresholds?: Record<string, SafetyThreshold>;
+}
+
 /**
  * Chat Completion 请求参数
  */
@@ -124,7 +311,17 @@ export interface ChatCompletionRequest {
     messages: ChatCompletionMessage[];
     temperature?: number;
     max_tokens?: number;
+    max_completion_tokens?: number;
+    presence_penalty?: number;
+    frequency_penalty?: number;
+    seed?: number;
+    logprobs?: boolean;
+    top_logprobs?: number;
     stream?: boolean;
+    stream_options?: { include_usage?: boolean };
+    reasoning_effort?: 'none' | 'minimal' | 'low' | 'medium' | 'high';
+    thinking_budget?: number;
+    safety_settings?: SafetyOverride;
     top_p?: number;
     stop?: string | string[];
 }
@@ -135,6 +332,18 @@ export interface ChatCompletionRequest {
 export interface ChatCompletionDelta {
     role?: string;
     content?: string;
+    /** 思考摘要（请求开启 reasoning_effort / thinking_budget 时） */
+    reasoning_content?: string;
+}
+
+/**
+ * 单个 token 的对数概率（请求开启 logprobs 时）
+ */
+export interface TokenLogprob {
+    token: string;
+    logprob: number;
+    bytes: number[];
+    top_logprobs?: Omit<TokenLogprob, 'top_logprobs'>[];
 }
 
 /**
@@ -144,6 +353,7 @@ export interface ChatCompletionChunkChoice {
     index: number;
     delta: ChatCompletionDelta;
     finish_reason: string | null;
+    logprobs?: { content: TokenLogprob[] };
 }
 
 /**
@@ -155,6 +365,12 @@ export interface ChatCompletionChunk {
     created: number;
     model: string;
     choices: ChatCompletionChunkChoice[];
+    /** 仅在 stream_options.include_usage 时出现于最后一个 chunk（choices 为空） */
+    usage?: {
+        prompt_tokens: number;
+        completion_tokens: number;
+        total_tokens: number;
+    };
 }
 
 // ==================== Session API Types ====================
@@ -1,7 +1,7 @@
 import { defineStore } from 'pinia'
 import { ref } from 'vue'
 import { getKeys, addKey, deleteKey, updateKey, testKey, importKeys } from '../api/keys'
-import type { KeyInfo, KeyImportItem } from '../api/types'
+import type { KeyInfo, KeyImportItem, KeyUpdatePayload } from '../api/types'
 
 
 /**
@@ -73,7 +73,7 @@ export const useKeyStore = defineStore('keys', () => {
         }
     }
 
-    async function updateKeyInfo(id: string, data: { name?: string; tags?: string[]; enabled?: boolean }) {
+    async function updateKeyInfo(id: string, data: KeyUpdatePayload) {
         try {
             const res = await updateKey(id, data)
             if (res.success && res.data) {